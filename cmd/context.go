@@ -3,13 +3,17 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/heroku/self/MetaManager/internal/config"
 	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
 	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
 	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
 	"github.com/heroku/self/MetaManager/internal/repository/tree"
@@ -40,11 +44,11 @@ var contextSetCmd = &cobra.Command{
 	RunE:  runContextSet,
 }
 
-// contextCreateCmd creates a named context with a type (local or gdrive) and adds it to contexts.json.
+// contextCreateCmd creates a named context with a type (local, gdrive, webdav or sftp) and adds it to contexts.json.
 var contextCreateCmd = &cobra.Command{
 	Use:   "create <name>",
-	Short: "Create a named context (local or gdrive) and save it in contexts.json",
-	Long:  `Creates a new context with the given name and type (--type local or --type gdrive). Name must be unique. Use "context set <name>" to switch to it.`,
+	Short: "Create a named context (local, gdrive, webdav or sftp) and save it in contexts.json",
+	Long:  `Creates a new context with the given name and type (--type local, gdrive, webdav or sftp). Name must be unique. Use "context set <name>" to switch to it. A webdav context needs "webdav login" before it can track, an sftp context tracks paths like sftp://host/dir (see "sftp config").`,
 	Args:  cobra.ExactArgs(1),
 	RunE:  runContextCreate,
 }
@@ -60,11 +64,12 @@ var contextGetCmd = &cobra.Command{
 
 // contextListCmd lists all contexts in table form.
 var contextListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all contexts in table form",
-	Long:  `Lists every context from contexts.json with name, type, and which one is current.`,
-	Args:  cobra.NoArgs,
-	RunE:  runContextList,
+	Use:         "list",
+	Short:       "List all contexts in table form",
+	Long:        `Lists every context from contexts.json with name, type, and which one is current. With --output json, ndjson, yaml or csv every context is a record with name, type and current.`,
+	Args:        cobra.NoArgs,
+	RunE:        runContextList,
+	Annotations: structuredOutput("context"),
 }
 
 // contextDeleteCmd removes a context from contexts.json.
@@ -76,6 +81,36 @@ var contextDeleteCmd = &cobra.Command{
 	RunE:  runContextDelete,
 }
 
+// contextIgnoreCmd is the parent for the current context's global ignore list.
+var contextIgnoreCmd = &cobra.Command{
+	Use:   "ignore",
+	Short: "Manage the ignore patterns of the current context",
+	Long:  `Gitignore-style patterns applied to every scan of the current context, in addition to .mmignore files. Drive scans honor these patterns as well. Stored in the context's config.json.`,
+}
+
+var contextIgnoreAddCmd = &cobra.Command{
+	Use:   "add <pattern>...",
+	Short: "Add ignore patterns to the current context",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runContextIgnoreAdd,
+}
+
+var contextIgnoreRmCmd = &cobra.Command{
+	Use:     "rm <pattern>...",
+	Short:   "Remove ignore patterns from the current context",
+	Aliases: []string{"remove"},
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runContextIgnoreRm,
+}
+
+var contextIgnoreListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List ignore patterns of the current context",
+	Aliases: []string{"ls"},
+	Args:    cobra.NoArgs,
+	RunE:    runContextIgnoreList,
+}
+
 var contextCreateType string
 
 func init() {
@@ -85,8 +120,12 @@ func init() {
 	contextCmd.AddCommand(contextGetCmd)
 	contextCmd.AddCommand(contextListCmd)
 	contextCmd.AddCommand(contextDeleteCmd)
+	contextCmd.AddCommand(contextIgnoreCmd)
+	contextIgnoreCmd.AddCommand(contextIgnoreAddCmd)
+	contextIgnoreCmd.AddCommand(contextIgnoreRmCmd)
+	contextIgnoreCmd.AddCommand(contextIgnoreListCmd)
 	contextDeleteCmd.Flags().BoolP("all", "a", false, "Delete all contexts")
-	contextCreateCmd.Flags().StringVarP(&contextCreateType, "type", "t", "", "Context type: local or gdrive (required)")
+	contextCreateCmd.Flags().StringVarP(&contextCreateType, "type", "t", "", "Context type: "+strings.Join(filesyspkg.BackendTypes(), " or ")+" (required)")
 	if err := contextCreateCmd.MarkFlagRequired("type"); err != nil {
 		fmt.Fprintln(os.Stderr, "context create: mark flag required:", err)
 		os.Exit(1)
@@ -106,7 +145,11 @@ func runContextSet(cmd *cobra.Command, args []string) error {
 
 func runContextCreate(cmd *cobra.Command, args []string) error {
 	contextType := strings.ToLower(strings.TrimSpace(contextCreateType))
-	err := defaultStore.Create(args[0], contextType)
+	_, err := filesyspkg.LookupBackend(contextType)
+	if err != nil {
+		return err
+	}
+	err = defaultStore.Create(args[0], contextType)
 	if err != nil {
 		return err
 	}
@@ -140,21 +183,17 @@ func runContextList(cmd *cobra.Command, args []string) error {
 	}
 	current = strings.ToLower(strings.TrimSpace(current))
 
-	const nameCol, typeCol, currentCol = 20, 12, 8
-	header := fmt.Sprintf("%-*s %-*s %-*s", nameCol, "NAME", typeCol, "TYPE", currentCol, "CURRENT")
-	fmt.Println(header)
-	fmt.Println(strings.Repeat("-", nameCol+typeCol+currentCol+2))
-	for _, e := range entries {
-		cur := ""
-		if e.Name == current {
-			cur = "*"
-		}
-		fmt.Printf("%-*s %-*s %-*s\n", nameCol, e.Name, typeCol, e.Type, currentCol, cur)
+	out, err := newOutputPrinter()
+	if err != nil {
+		return err
 	}
-	if len(entries) == 0 {
-		fmt.Println("  (no contexts; use 'context create <name> --type local|gdrive')")
+	records := []output.Context{}
+	for _, e := range entries {
+		records = append(records, output.Context{Name: e.Name, Type: e.Type, Current: e.Name == current})
 	}
-	return nil
+	return out.Print(records, func(w io.Writer) error {
+		return output.WriteContexts(w, records)
+	})
 }
 
 func runContextDelete(cmd *cobra.Command, args []string) error {
@@ -196,6 +235,57 @@ func runContextDelete(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// loadCurrentContextConfig returns the current context name and its config.json.
+func loadCurrentContextConfig() (string, *config.Config, error) {
+	name, err := getContextRequired()
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := utils.CommonAlreadyInitializedChecks(name); err != nil {
+		return "", nil, err
+	}
+	cfg, err := config.Load(name)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, cfg, nil
+}
+
+func runContextIgnoreAdd(cmd *cobra.Command, args []string) error {
+	name, cfg, err := loadCurrentContextConfig()
+	if err != nil {
+		return err
+	}
+	for _, pattern := range args {
+		if !slices.Contains(cfg.IgnorePatterns, pattern) {
+			cfg.IgnorePatterns = append(cfg.IgnorePatterns, pattern)
+		}
+	}
+	return config.Save(name, cfg)
+}
+
+func runContextIgnoreRm(cmd *cobra.Command, args []string) error {
+	name, cfg, err := loadCurrentContextConfig()
+	if err != nil {
+		return err
+	}
+	cfg.IgnorePatterns = slices.DeleteFunc(cfg.IgnorePatterns, func(pattern string) bool {
+		return slices.Contains(args, pattern)
+	})
+	return config.Save(name, cfg)
+}
+
+func runContextIgnoreList(cmd *cobra.Command, args []string) error {
+	_, cfg, err := loadCurrentContextConfig()
+	if err != nil {
+		return err
+	}
+	for _, pattern := range cfg.IgnorePatterns {
+		fmt.Println(pattern)
+	}
+	return nil
+}
+
 // deleteContextMMDir deletes the .mm/<contextName>/ directory for the given context.
 func deleteContextMMDir(contextName string) error {
 	appDir, err := utils.GetAppDataDirForContext(contextName)
@@ -273,7 +363,8 @@ func EnsureAppDataDir(contextName string) error {
 	}
 
 	configFilePath := filepath.Join(appDir, utils.ConfigFileName)
-	cfg := config.Config{RootPath: baseDir}
+	// A new context records its tracked roots from the start
+	cfg := config.Config{RootPath: baseDir, TrackedRootsRecorded: true}
 	data, err := json.Marshal(cfg)
 	if err != nil {
 		return err
@@ -282,23 +373,34 @@ func EnsureAppDataDir(contextName string) error {
 		return err
 	}
 
-	absPath := "/"
+	absPath := localRootPath(baseDir)
 	contextType, err := GetContextType(contextName)
-	if err == nil && contextType == contextrepo.TypeGDrive {
-		absPath = file.GDrivePathPrefix
-	} else if os.Getenv("MM_TEST_CONTEXT_DIR") != "" {
-		// In tests, root must match the track root so merge creates the right number of nodes.
-		absPath = baseDir
-	}
-	emptyRoot := &ds.TreeNode{
-		// root is a special path that is used to represent the root of the tree
-		Info:     &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: absPath}},
-		Children: nil,
+	backend, lookupErr := filesyspkg.LookupBackend(contextType)
+	if err == nil && lookupErr == nil && backend.Type != contextrepo.TypeLocal {
+		absPath = backend.RootPath
 	}
 	dataFilePath := filepath.Join(appDir, utils.DataFileName)
 	rw, err := tree.NewFileStorageRW(dataFilePath)
 	if err != nil {
 		return err
 	}
-	return rw.Write(emptyRoot)
+	return rw.Write(emptyTreeRoot(absPath))
+}
+
+// localRootPath returns the path of the tree root of a local context without named roots.
+func localRootPath(baseDir string) string {
+	if os.Getenv("MM_TEST_CONTEXT_DIR") != "" {
+		// In tests, root must match the track root so merge creates the right number of nodes.
+		return baseDir
+	}
+	return "/"
+}
+
+// emptyTreeRoot returns a tree holding only its root node at absPath.
+func emptyTreeRoot(absPath string) *ds.TreeNode {
+	return &ds.TreeNode{
+		// root is a special path that is used to represent the root of the tree
+		Info:     &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: absPath}},
+		Children: nil,
+	}
 }
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// contextRootCmd is the parent for the named roots of the current context.
+var contextRootCmd = &cobra.Command{
+	Use:   "root",
+	Short: "Manage the named roots of the current local context",
+	Long: `A local context can span several directories, each a named root shown at the top of the tree.
+The alias of a root stands for its directory in paths, e.g. "track work:/src*" or "tag add work:/notes.md todo".
+Adding the first root turns the context into a multi-root one; what it already tracks must be inside that root.`,
+}
+
+var contextRootAddCmd = &cobra.Command{
+	Use:   "add <alias> <dir>",
+	Short: "Add a directory as a named root of the current context",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runContextRootAdd,
+}
+
+var contextRootRmCmd = &cobra.Command{
+	Use:     "rm <alias>",
+	Short:   "Remove a named root and everything tracked in it",
+	Aliases: []string{"remove"},
+	Args:    cobra.ExactArgs(1),
+	RunE:    runContextRootRm,
+}
+
+var contextRootListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the named roots of the current context",
+	Aliases: []string{"ls"},
+	Args:    cobra.NoArgs,
+	RunE:    runContextRootList,
+}
+
+func init() {
+	contextCmd.AddCommand(contextRootCmd)
+	contextRootCmd.AddCommand(contextRootAddCmd)
+	contextRootCmd.AddCommand(contextRootRmCmd)
+	contextRootCmd.AddCommand(contextRootListCmd)
+}
+
+// loadLocalContextConfig is loadCurrentContextConfig for commands that only make
+// sense in a local context.
+func loadLocalContextConfig(cmdName string) (string, *config.Config, error) {
+	name, cfg, err := loadCurrentContextConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	typ, err := GetContextType(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if typ != contextrepo.TypeLocal {
+		return "", nil, fmt.Errorf("context root %s requires a local context, %q is a %s context", cmdName, name, typ)
+	}
+	return name, cfg, nil
+}
+
+// contextRootAddInternal adds dir as the root alias of ctxName, in the tree and in config.json.
+func contextRootAddInternal(ctxName string, cfg *config.Config, alias, dir string) error {
+	logrus.Debugf("[context-root] add alias=%q dir=%q", alias, dir)
+	if !config.ValidRootAlias(alias) {
+		return fmt.Errorf("invalid root alias %q: use letters, digits, - and _, starting with a letter", alias)
+	}
+	if _, found := cfg.Root(alias); found {
+		return fmt.Errorf("root %q already exists", alias)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(absDir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", absDir)
+	}
+
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return err
+	}
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	err = drMg.AddRoot(absDir)
+	if err != nil {
+		return err
+	}
+	err = rw.Write(drMg.Root)
+	if err != nil {
+		return err
+	}
+
+	cfg.Roots = append(cfg.Roots, config.NamedRoot{Alias: alias, Path: absDir})
+	return config.Save(ctxName, cfg)
+}
+
+// contextRootRmInternal removes the root alias of ctxName with what is tracked in it.
+// Once the last root is gone the context has a single root again.
+func contextRootRmInternal(ctxName string, cfg *config.Config, alias string) error {
+	logrus.Debugf("[context-root] rm alias=%q", alias)
+	removed, found := cfg.RemoveRoot(alias)
+	if !found {
+		return fmt.Errorf("no root %q in context %q", alias, ctxName)
+	}
+
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return err
+	}
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	err = drMg.RemoveRoot(removed.Path)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Roots) == 0 {
+		baseDir, err := utils.GetBaseDir()
+		if err != nil {
+			return err
+		}
+		drMg.Root = emptyTreeRoot(localRootPath(baseDir))
+	}
+	err = rw.Write(drMg.Root)
+	if err != nil {
+		return err
+	}
+	return config.Save(ctxName, cfg)
+}
+
+func runContextRootAdd(cmd *cobra.Command, args []string) error {
+	name, cfg, err := loadLocalContextConfig("add")
+	if err != nil {
+		return err
+	}
+	return contextRootAddInternal(name, cfg, args[0], args[1])
+}
+
+func runContextRootRm(cmd *cobra.Command, args []string) error {
+	name, cfg, err := loadLocalContextConfig("rm")
+	if err != nil {
+		return err
+	}
+	return contextRootRmInternal(name, cfg, args[0])
+}
+
+func runContextRootList(cmd *cobra.Command, args []string) error {
+	_, cfg, err := loadLocalContextConfig("list")
+	if err != nil {
+		return err
+	}
+	for _, r := range cfg.Roots {
+		fmt.Printf("%s:\t%s\n", r.Alias, r.Path)
+	}
+	return nil
+}
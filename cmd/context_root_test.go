@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextRootCmd(t *testing.T) {
+	const multiCtxName = "multi-root"
+	dir := t.TempDir()
+	os.Setenv("MM_TEST_CONTEXT_DIR", dir)
+	os.Setenv("MM_CONTEXT", multiCtxName)
+	defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+	defer os.Unsetenv("MM_CONTEXT")
+
+	work, nas := t.TempDir(), t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(work, "src"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(work, "src", "main.go"), nil, 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(nas, "photos"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(nas, "photos", "a.jpg"), nil, 0644))
+
+	require.NoError(t, defaultStore.Create(multiCtxName, contextrepo.TypeLocal))
+	require.NoError(t, EnsureAppDataDir(multiCtxName))
+
+	addRoot := func(alias, dir string) error {
+		cfg, err := config.Load(multiCtxName)
+		require.NoError(t, err)
+		return contextRootAddInternal(multiCtxName, cfg, alias, dir)
+	}
+	require.NoError(t, addRoot("work", work))
+	require.NoError(t, addRoot("nas", nas))
+	require.ErrorContains(t, addRoot("work", dir), "already exists")
+	require.ErrorContains(t, addRoot("src", filepath.Join(work, "src")), "overlaps")
+	require.ErrorContains(t, addRoot("bad alias", dir), "invalid root alias")
+
+	// Aliases stand for their root in every command.
+	err := trackInternal(context.Background(), multiCtxName, "work:*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+	require.NoError(t, err)
+	err = trackInternal(context.Background(), multiCtxName, "nas:/photos*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, tagAddInternal(multiCtxName, []string{"work:/src/main.go", "entry"}))
+	require.ErrorContains(t, untrackInternal(multiCtxName, "nas:"), "context root rm")
+
+	readTree := func() *data.DirTreeManager {
+		rw, err := tree.GetRW(multiCtxName)
+		require.NoError(t, err)
+		root, err := rw.Read()
+		require.NoError(t, err)
+		return data.NewDirTreeManager(ds.NewTreeManager(root))
+	}
+	drMg := readTree()
+	require.True(t, drMg.IsMultiRoot())
+	tops := []string{}
+	for _, child := range drMg.Root.Children {
+		tops = append(tops, child.Info.(file.NodeInformable).GetAbsPath())
+	}
+	require.Equal(t, []string{work, nas}, tops)
+	info, err := drMg.FindNodeByAbsPath(filepath.Join(work, "src", "main.go"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"entry"}, info.GetTags())
+	require.NoError(t, trackShowInternal(multiCtxName, true, false, longListing{}))
+
+	// Refresh follows the roots as well.
+	require.NoError(t, os.Remove(filepath.Join(nas, "photos", "a.jpg")))
+	_, err = refreshInternal(context.Background(), multiCtxName, "", data.VanishedRemove, filesyspkg.ScanOptions{}, nil)
+	require.NoError(t, err)
+	_, err = readTree().FindTreeNodeByAbsPath(filepath.Join(nas, "photos", "a.jpg"))
+	require.Error(t, err)
+
+	cfg, err := config.Load(multiCtxName)
+	require.NoError(t, err)
+	require.NoError(t, contextRootRmInternal(multiCtxName, cfg, "nas"))
+	require.ErrorContains(t, contextRootRmInternal(multiCtxName, cfg, "nas"), "no root")
+	cfg, err = config.Load(multiCtxName)
+	require.NoError(t, err)
+	require.Equal(t, []config.NamedRoot{{Alias: "work", Path: work}}, cfg.Roots)
+	require.Equal(t, []config.TrackedRoot{{Path: work + "*"}}, cfg.TrackedRoots)
+	utils.ValidateNodeCnt(t, readTree().Root, 4)
+
+	// Without roots the context has a single root again.
+	require.NoError(t, contextRootRmInternal(multiCtxName, cfg, "work"))
+	drMg = readTree()
+	require.False(t, drMg.IsMultiRoot())
+	require.Equal(t, dir, drMg.Root.Info.(file.NodeInformable).GetAbsPath())
+}
@@ -198,3 +198,12 @@ func TestPackageLevelGetters(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, filesys.TypeLocal, typ)
 }
+
+func TestRunContextCreateUnknownType(t *testing.T) {
+	contextCreateType = "ftp"
+	defer func() { contextCreateType = "" }()
+
+	err := runContextCreate(contextCreateCmd, []string{"archive"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown context type")
+}
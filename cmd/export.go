@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/export"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// exportInternal writes the tracked subtree of ctxName at pathExp, any path the
+// resolver accepts, to w in format f.
+func exportInternal(ctxName, pathExp string, f export.Format, w io.Writer) error {
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return err
+	}
+
+	absPath, err := filesys.NewBasicResolver(defaultStore).Resolve(pathExp)
+	if err != nil {
+		return err
+	}
+	node, err := data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath(absPath)
+	if err != nil {
+		return err
+	}
+	return export.Write(w, f, node)
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName, formatFlag string
+	var f export.Format
+	pathExp := "."
+
+	if len(args) > 1 {
+		err = &cmderror.InvalidNumberOfArguments{}
+		goto finally
+	}
+	if len(args) == 1 {
+		pathExp = args[0]
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	formatFlag, err = cmd.Flags().GetString("format")
+	if err != nil {
+		goto finally
+	}
+	f, err = export.ParseFormat(formatFlag)
+	if err != nil {
+		goto finally
+	}
+
+	err = exportInternal(ctxName, pathExp, f, os.Stdout)
+	if err != nil {
+		goto finally
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export [path]",
+	Short: "Render a tracked subtree as Markdown, HTML, Graphviz DOT or CSV",
+	Long: `Renders the tracked subtree at path, the current directory by default, with the tags
+and ids of its nodes, to share it with people who do not use MetaManager:
+  ./MetaManager export ~/projects > projects.md
+  ./MetaManager export --format html ~/projects > projects.html
+  ./MetaManager export --format dot ~/projects | dot -Tsvg > projects.svg
+
+--format is md (the default), a nested list; html, a single page without external
+files, with folding directories and a tag filter; dot, a Graphviz graph whose edges
+have the class "contains" from directories to their nodes or "symlink" from symlinks
+to their targets; or csv, the node records of --output csv. Here --format names the
+format of the document, not a template.`,
+	Run: runExport,
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+	// Shadows the --format template of the root command, which export does not print
+	exportCmd.Flags().String("format", string(export.FormatMarkdown), "document format: md, html, dot or csv")
+}
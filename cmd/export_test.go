@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/export"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "home",
+		Dirs: []*utils.MockDir{
+			{DirName: "docs", Files: []string{"a.txt", "b.txt"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", contextrepo.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		docs := filepath.Join(root, "docs")
+		require.NoError(t, trackInternal(context.Background(), "default", docs+"*", config.TrackedRoot{}, filesys.ScanOptions{}, nil))
+		a := filepath.Join(docs, "a.txt")
+		require.NoError(t, tagAddInternal("default", []string{a, "finance"}))
+		require.NoError(t, idSetInternal("default", a, "ida"))
+
+		render := func(f export.Format) string {
+			var buf bytes.Buffer
+			require.NoError(t, exportInternal("default", docs, f, &buf))
+			return buf.String()
+		}
+
+		md := render(export.FormatMarkdown)
+		require.True(t, strings.HasPrefix(md, "# "))
+		require.Contains(t, md, "/home/docs/**\n")
+		require.Contains(t, md, "* a.txt · tags: `finance` · id: `ida`\n")
+		require.Contains(t, md, "* b.txt\n")
+
+		html := render(export.FormatHTML)
+		require.Contains(t, html, "<option>finance</option>")
+		require.Contains(t, html, `<span class="id">id: ida</span>`)
+
+		dot := render(export.FormatDOT)
+		require.Contains(t, dot, `n0 -> n1 [class="contains"];`)
+		require.Contains(t, dot, `label="a.txt\n#finance\nid: ida"`)
+
+		csv := render(export.FormatCSV)
+		require.Contains(t, csv, a+",a.txt,file,")
+		require.Contains(t, csv, "finance,ida,")
+
+		require.Error(t, exportInternal("default", filepath.Join(root, "missing"), export.FormatCSV, &bytes.Buffer{}))
+
+		// The --format of export is its own, not the template of the root command.
+		require.NotSame(t, RootCmd.PersistentFlags().Lookup("format"), exportCmd.Flags().Lookup("format"))
+		require.Equal(t, string(export.FormatMarkdown), exportCmd.Flags().Lookup("format").DefValue)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
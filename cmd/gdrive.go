@@ -3,10 +3,12 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/heroku/self/MetaManager/internal/file"
 	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
 	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
 	"github.com/heroku/self/MetaManager/internal/services"
 	"github.com/pkg/browser"
@@ -20,10 +22,11 @@ var gdriveCmd = &cobra.Command{
 }
 
 var gdriveListCmd = &cobra.Command{
-	Use:   "list [path or folder-id]",
-	Short: "List directory structure of Google Drive",
-	Long:  `Lists files and folders at the given path or folder ID. Path is like local: "/" for root, "/FolderName", "/FolderName/SubFolder". You can also pass a Drive folder ID directly. Without arguments, lists root.`,
-	RunE:  runGDriveList,
+	Use:         "list [path or folder-id]",
+	Short:       "List directory structure of Google Drive",
+	Long:        `Lists files and folders at the given path or folder ID. Path is like local: "/" for root, "/FolderName", "/FolderName/SubFolder". You can also pass a Drive folder ID directly. Without arguments, lists root. With --output json, ndjson, yaml or csv every entry is an entry record.`,
+	RunE:        runGDriveList,
+	Annotations: structuredOutput("entry"),
 }
 
 var gdrivePwdCmd = &cobra.Command{
@@ -41,10 +44,11 @@ var gdriveCdCmd = &cobra.Command{
 }
 
 var gdriveLsCmd = &cobra.Command{
-	Use:   "ls [path]",
-	Short: "List current or given Drive directory",
-	Long:  `Lists files and folders at the current Drive directory (see "gdrive pwd") or at the given path. Path is relative to current directory unless it starts with "/". With no argument, lists current directory.`,
-	RunE:  runGDriveLs,
+	Use:         "ls [path]",
+	Short:       "List current or given Drive directory",
+	Long:        `Lists files and folders at the current Drive directory (see "gdrive pwd") or at the given path. Path is relative to current directory unless it starts with "/". With no argument, lists current directory. With --output json, ndjson, yaml or csv every entry is an entry record. With -l every entry is a row of a table, see "track show --long".`,
+	RunE:        runGDriveLs,
+	Annotations: structuredOutput("entry"),
 }
 
 var gdriveGetLinkCmd = &cobra.Command{
@@ -55,25 +59,27 @@ var gdriveGetLinkCmd = &cobra.Command{
 	RunE:  runGDriveGetLink,
 }
 
-// contextLsCmd, contextCdCmd, contextPwdCmd run gdrive ls/cd/pwd when current context is gdrive.
+// contextLsCmd, contextCdCmd, contextPwdCmd run gdrive or webdav ls/cd/pwd, depending on the current context.
+// In any context they also browse the saved searches as virtual directories like "@search:unpaid".
 var contextLsCmd = &cobra.Command{
-	Use:   "ls [path]",
-	Short: "List current or given directory (uses current context)",
-	Long:  `When current context is gdrive, lists the current Drive directory or the given path. Use "context set <name>" to switch context.`,
-	RunE:  runContextLs,
+	Use:         "ls [path]",
+	Short:       "List current or given directory (uses current context)",
+	Long:        `When current context is gdrive or webdav, lists the current directory or the given path. Use "context set <name>" to switch context. In any context, "ls @search:<name>" lists the nodes matching a saved search (see "search save") and "ls @search:" the saved searches. With --output json, ndjson, yaml or csv every entry is an entry record. With -l every entry is a row of a table of its kind, size, modification time, tags and id when tracked, and Drive link, sorted by --sort name, size or mtime.`,
+	RunE:        runContextLs,
+	Annotations: structuredOutput("entry"),
 }
 
 var contextPwdCmd = &cobra.Command{
 	Use:   "pwd",
 	Short: "Print current working directory (uses current context)",
-	Long:  `When current context is gdrive, prints the current Drive directory.`,
+	Long:  `When current context is gdrive or webdav, prints the current directory. In any context, prints the saved search directory "cd" went into.`,
 	RunE:  runContextPwd,
 }
 
 var contextCdCmd = &cobra.Command{
 	Use:   "cd [path]",
 	Short: "Change current working directory (uses current context)",
-	Long:  `When current context is gdrive, changes the current Drive directory. Use ".." or a path relative to current directory.`,
+	Long:  `When current context is gdrive or webdav, changes the current directory. Use ".." or a path relative to current directory. In any context, "cd @search:<name>" goes into the directory of a saved search, so "ls" lists its matches; ".." goes up to "@search:", then back out.`,
 	RunE:  runContextCd,
 }
 
@@ -93,6 +99,8 @@ func init() {
 	gdriveCmd.AddCommand(gdriveGetCmd)
 
 	RootCmd.AddCommand(contextLsCmd)
+	addLongListingFlags(gdriveLsCmd)
+	addLongListingFlags(contextLsCmd)
 	RootCmd.AddCommand(contextPwdCmd)
 	RootCmd.AddCommand(contextCdCmd)
 }
@@ -116,12 +124,27 @@ func requireGDriveContext(cmdName string) error {
 		return err
 	}
 	if !ok {
-		return fmt.Errorf("%s requires gdrive context; use \"context set <name>\" (with a gdrive context) or \"gdrive %s\"", cmdName, cmdName)
+		return fmt.Errorf("%s requires a gdrive or webdav context; use \"context set <name>\" (with a gdrive or webdav context) or \"gdrive %s\"", cmdName, cmdName)
 	}
 	return nil
 }
 
 func runContextLs(cmd *cobra.Command, args []string) error {
+	listing, err := longListingFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	handled, err := runSavedSearchLs(args, listing)
+	if err != nil || handled {
+		return err
+	}
+	webdav, err := isWebDAVContext()
+	if err != nil {
+		return err
+	}
+	if webdav {
+		return runWebDAVLs(cmd, args)
+	}
 	if err := requireGDriveContext("ls"); err != nil {
 		return err
 	}
@@ -129,6 +152,17 @@ func runContextLs(cmd *cobra.Command, args []string) error {
 }
 
 func runContextPwd(cmd *cobra.Command, args []string) error {
+	handled, err := runSavedSearchPwd()
+	if err != nil || handled {
+		return err
+	}
+	webdav, err := isWebDAVContext()
+	if err != nil {
+		return err
+	}
+	if webdav {
+		return runWebDAVPwd(cmd, args)
+	}
 	if err := requireGDriveContext("pwd"); err != nil {
 		return err
 	}
@@ -136,6 +170,17 @@ func runContextPwd(cmd *cobra.Command, args []string) error {
 }
 
 func runContextCd(cmd *cobra.Command, args []string) error {
+	handled, args, err := runSavedSearchCd(args)
+	if err != nil || handled {
+		return err
+	}
+	webdav, err := isWebDAVContext()
+	if err != nil {
+		return err
+	}
+	if webdav {
+		return runWebDAVCd(cmd, args)
+	}
 	if err := requireGDriveContext("cd"); err != nil {
 		return err
 	}
@@ -175,19 +220,26 @@ func runGDriveList(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	fmt.Println(displayPath)
-	fmt.Println("---")
+	return printRemoteListing(displayPath, entries, longListing{})
+}
+
+// printRemoteListing prints the entries of the Drive or WebDAV directory dir in the
+// --output format, or as a table with listing.Long.
+func printRemoteListing(dir string, entries []services.RootEntry, listing longListing) error {
+	out, err := newOutputPrinter()
+	if err != nil {
+		return err
+	}
+	records := make([]output.Entry, 0, len(entries))
 	for _, e := range entries {
-		if e.IsFolder {
-			fmt.Printf("  %s/\n", e.Name)
-		} else {
-			fmt.Printf("  %s\n", e.Name)
-		}
+		records = append(records, output.NewRemoteEntry(dir, e))
 	}
-	if len(entries) == 0 {
-		fmt.Println("  (empty)")
+	if listing.Long {
+		return listing.printRows(remoteEntryRows(records))
 	}
-	return nil
+	return out.Print(records, func(w io.Writer) error {
+		return output.WriteListing(w, dir, records)
+	})
 }
 
 func runGDrivePwd(cmd *cobra.Command, args []string) error {
@@ -232,6 +284,10 @@ func runGDriveCd(cmd *cobra.Command, args []string) error {
 }
 
 func runGDriveLs(cmd *cobra.Command, args []string) error {
+	listing, err := longListingFromFlags(cmd)
+	if err != nil {
+		return err
+	}
 	ctx := context.Background()
 	svc, err := GetGDriveService(ctx)
 	if err != nil {
@@ -269,19 +325,7 @@ func runGDriveLs(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("list %q: %w", displayPath, err)
 	}
 
-	fmt.Println(displayPath)
-	fmt.Println("---")
-	for _, e := range entries {
-		if e.IsFolder {
-			fmt.Printf("  %s/\n", e.Name)
-		} else {
-			fmt.Printf("  %s\n", e.Name)
-		}
-	}
-	if len(entries) == 0 {
-		fmt.Println("  (empty)")
-	}
-	return nil
+	return printRemoteListing(displayPath, entries, listing)
 }
 
 func runGDriveGetLink(cmd *cobra.Command, args []string) error {
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// isPattern reports whether the resolved path is a glob to match rather than a path.
+// Names like "Photo [2020].jpg" look like globs, so a path tracked in drMg, when it
+// is not nil, or found on disk is taken literally. A "\" escapes the character after
+// it in patterns, so "Photo \[202?\].jpg" matches the brackets themselves.
+func isPattern(drMg *data.DirTreeManager, resolved string) bool {
+	if !data.IsGlob(resolved) {
+		return false
+	}
+	literal := strings.TrimSuffix(resolved, "*")
+	if drMg != nil {
+		if _, err := drMg.FindTreeNodeByAbsPath(literal); err == nil {
+			return false
+		}
+	}
+	if filepath.IsAbs(literal) {
+		if _, err := os.Lstat(literal); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveTargets resolves pathExp in the current context. A glob, like "src/**/*.go",
+// is matched against the nodes of drMg and the matching paths are returned in tree
+// order; any other path is returned resolved, tracked or not.
+func resolveTargets(drMg *data.DirTreeManager, pathExp string) ([]string, error) {
+	resolved, err := filesys.NewBasicResolver(defaultStore).Resolve(pathExp)
+	if err != nil {
+		return nil, err
+	}
+	if !isPattern(drMg, resolved) {
+		return []string{resolved}, nil
+	}
+	g, err := data.CompileGlob(resolved)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := drMg.FindTreeNodesByGlob(g)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("[glob] %q matches %d tracked nodes", resolved, len(nodes))
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no tracked path matches %s", resolved)
+	}
+	paths := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		paths = append(paths, node.Info.(file.NodeInformable).GetAbsPath())
+	}
+	return paths, nil
+}
+
+// treeTargets is resolveTargets against the stored tree of ctxName.
+func treeTargets(ctxName, pathExp string) ([]string, error) {
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	return resolveTargets(data.NewDirTreeManager(ds.NewTreeManager(root)), pathExp)
+}
+
+// diskTargets is resolveTargets for track: globs are matched against the files on
+// disk, which requires a local context.
+func diskTargets(ctxName, pathExp string) ([]string, error) {
+	resolved, err := filesys.NewBasicResolver(defaultStore).Resolve(pathExp)
+	if err != nil {
+		return nil, err
+	}
+	if !isPattern(nil, resolved) {
+		return []string{resolved}, nil
+	}
+	typ, err := GetContextType(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	if typ != contextrepo.TypeLocal {
+		return nil, fmt.Errorf("track only matches globs against local files; %q is a %s context", ctxName, typ)
+	}
+	g, err := data.CompileGlob(resolved)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filesys.GlobLocal(g)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("[glob] %q matches %d files", resolved, len(matches))
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("nothing matches %s", resolved)
+	}
+	return matches, nil
+}
+
+// outermostPaths drops the paths that are below another one of paths, since acting
+// on a directory already covers them.
+func outermostPaths(paths []string) []string {
+	kept := []string{}
+	for _, p := range paths {
+		covered := false
+		for _, other := range paths {
+			if other != p && (strings.HasPrefix(p, strings.TrimSuffix(other, "/")+"/") ||
+				strings.HasPrefix(p, other+file.ArchiveSeparator)) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// printDryRun lists the paths a command would act on.
+func printDryRun(paths []string) {
+	for _, p := range paths {
+		fmt.Println(p)
+	}
+	fmt.Printf("%d paths match (dry run, nothing changed)\n", len(paths))
+}
+
+// dryRunTargets handles --dry-run of cmd: when it is set, the paths pathExp matches
+// are listed, from disk when onDisk is set or else from the tree of ctxName, and
+// true is returned so the command stops there.
+func dryRunTargets(cmd *cobra.Command, ctxName, pathExp string, onDisk bool) (bool, error) {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil || !dryRun {
+		return false, err
+	}
+	var paths []string
+	if onDisk {
+		paths, err = diskTargets(ctxName, pathExp)
+	} else {
+		paths, err = treeTargets(ctxName, pathExp)
+	}
+	if err != nil {
+		return true, err
+	}
+	printDryRun(paths)
+	return true, nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobTargets(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "repo",
+		Dirs: []*utils.MockDir{
+			{DirName: "src", Files: []string{"main.go", "main_test.go"}, Dirs: []*utils.MockDir{
+				{DirName: "util", Files: []string{"util.go", "util_test.go"}},
+				{DirName: "node_modules", Files: []string{"x.js"}},
+			}},
+			{DirName: "node_modules", Files: []string{"y.js"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		readTree := func() *data.DirTreeManager {
+			rw, err := tree.GetRW("default")
+			require.NoError(t, err)
+			node, err := rw.Read()
+			require.NoError(t, err)
+			return data.NewDirTreeManager(ds.NewTreeManager(node))
+		}
+
+		// track matches globs on disk: root + src + util + the two test files
+		err := trackInternal(context.Background(), "default", root+"/src/**/*_test.go", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, readTree().Root, 5)
+		_, err = diskTargets("default", root+"/**/*.rs")
+		require.ErrorContains(t, err, "nothing matches")
+
+		err = trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+
+		// Other commands match the tracked nodes.
+		require.NoError(t, tagAddInternal("default", []string{root + "/src/**/*_test.go", "tests"}))
+		paths, err := tagSearchInternal("default", "tests")
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{
+			filepath.Join(root, "src", "main_test.go"),
+			filepath.Join(root, "src", "util", "util_test.go"),
+		}, paths)
+		require.NoError(t, tagDeleteInternal("default", root+"/src/util/*_test.go", "tests"))
+		paths, err = tagSearchInternal("default", "tests")
+		require.NoError(t, err)
+		require.Len(t, paths, 1)
+
+		require.ErrorContains(t, idSetInternal("default", root+"/src/*.go", "main"), "matches 2 nodes")
+		require.NoError(t, idSetInternal("default", root+"/src/m?in.go", "main"))
+		require.NoError(t, idJumpInternal("default", "main"))
+
+		// --dry-run lists the matches and leaves the tree alone.
+		require.NoError(t, untrackCmd.Flags().Set("dry-run", "true"))
+		dryRun, err := dryRunTargets(untrackCmd, "default", root+"/**/node_modules", false)
+		require.NoError(t, untrackCmd.Flags().Set("dry-run", "false"))
+		require.NoError(t, err)
+		require.True(t, dryRun)
+		_, err = readTree().FindTreeNodeByAbsPath(filepath.Join(root, "node_modules"))
+		require.NoError(t, err)
+
+		require.NoError(t, untrackInternal("default", root+"/**/node_modules"))
+		drMg := readTree()
+		for _, p := range []string{filepath.Join(root, "node_modules"), filepath.Join(root, "src", "node_modules", "x.js")} {
+			_, err = drMg.FindTreeNodeByAbsPath(p)
+			require.Error(t, err, p)
+		}
+		require.ErrorContains(t, untrackInternal("default", root+"/**/node_modules"), "no tracked path matches")
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestGlobTargetsLiteralBrackets(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "w",
+		Files:   []string{"Photo [2020].jpg", "Photo 2.jpg"},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		photo := filepath.Join(root, "Photo [2020].jpg")
+
+		// Tracked or on disk, a name with brackets is a path, not a pattern.
+		require.NoError(t, trackInternal(context.Background(), "default", photo, config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil))
+		require.NoError(t, tagAddInternal("default", []string{photo, "foo"}))
+		paths, err := tagSearchInternal("default", "foo")
+		require.NoError(t, err)
+		require.Equal(t, []string{photo}, paths)
+		require.NoError(t, idSetInternal("default", photo, "photo"))
+
+		// Escaped, the brackets of a pattern match themselves.
+		require.NoError(t, trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil))
+		require.NoError(t, tagAddInternal("default", []string{root + `/Photo \[20?0\].jpg`, "bar"}))
+		paths, err = tagSearchInternal("default", "bar")
+		require.NoError(t, err)
+		require.Equal(t, []string{photo}, paths)
+
+		require.NoError(t, untrackInternal("default", photo))
+		_, err = treeTargets("default", root+"/Photo [2020].jpg")
+		require.NoError(t, err)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
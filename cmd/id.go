@@ -5,14 +5,17 @@ package cmd
 
 import (
 	"fmt"
-	"path/filepath"
+	"time"
 
 	"github.com/heroku/self/MetaManager/internal/cmderror"
 	"github.com/heroku/self/MetaManager/internal/data"
 	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/recent"
 	"github.com/heroku/self/MetaManager/internal/repository/tree"
 	"github.com/heroku/self/MetaManager/internal/utils"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -26,12 +29,9 @@ var idCmd = &cobra.Command{
 	},
 }
 
+// idSetInternal sets id on the node at path. A glob must match a single node, as an id
+// names one node.
 func idSetInternal(ctxName, path, id string) error {
-	idFilePath, err := filepath.Abs(path)
-	if err != nil {
-		return err
-	}
-
 	rw, err := tree.GetRW(ctxName)
 	if err != nil {
 		return err
@@ -44,12 +44,20 @@ func idSetInternal(ctxName, path, id string) error {
 
 	mg := data.NewDirTreeManager(ds.NewTreeManager(root))
 
+	idFilePaths, err := resolveTargets(mg, path)
+	if err != nil {
+		return err
+	}
+	if len(idFilePaths) > 1 {
+		return fmt.Errorf("%s matches %d nodes; an id names a single node", path, len(idFilePaths))
+	}
+
 	node, err := mg.FindFileNodeById(id)
 	if err == nil {
 		return fmt.Errorf("id: %s is already set for node %s", id, node.GetAbsPath())
 	}
 
-	pathNode, err := mg.FindNodeByAbsPath(idFilePath)
+	pathNode, err := mg.FindNodeByAbsPath(idFilePaths[0])
 	if err != nil {
 		return err
 	}
@@ -67,6 +75,7 @@ func idSetInternal(ctxName, path, id string) error {
 func idSet(cmd *cobra.Command, args []string) {
 	var err error
 	var ctxName string
+	var dryRun bool
 
 	if len(args) != 2 {
 		err = &cmderror.InvalidNumberOfArguments{}
@@ -82,6 +91,14 @@ func idSet(cmd *cobra.Command, args []string) {
 		goto finally
 	}
 
+	dryRun, err = dryRunTargets(cmd, ctxName, args[0], false)
+	if err != nil {
+		goto finally
+	}
+	if dryRun {
+		return
+	}
+
 	err = idSetInternal(ctxName, args[0], args[1])
 	if err != nil {
 		goto finally
@@ -99,7 +116,7 @@ finally:
 var idSetCmd = &cobra.Command{
 	Use:   "set",
 	Short: "Sets id for a particular node",
-	Long:  "Sets id for a particular node",
+	Long:  "Sets id for a particular node. A glob may be given if it matches a single tracked node; check with --dry-run.",
 	Run:   idSet,
 }
 
@@ -122,6 +139,11 @@ func idJumpInternal(ctxName, id string) error {
 
 	fmt.Println(fileNode.GetAbsPath())
 
+	err = recent.Touch(ctxName, time.Now(), fileNode.GetAbsPath())
+	if err != nil {
+		logrus.Debugf("[id] cannot record the use of %q: %v", fileNode.GetAbsPath(), err)
+	}
+
 	return nil
 }
 
@@ -162,12 +184,9 @@ var idJumpCmd = &cobra.Command{
 	Run:   idJump,
 }
 
+// getIdInternal prints the id of the node at path. For a glob, every matching node is
+// printed with its id.
 func getIdInternal(ctxName, path string) error {
-	idFilePath, err := filepath.Abs(path)
-	if err != nil {
-		return err
-	}
-
 	rw, err := tree.GetRW(ctxName)
 	if err != nil {
 		return err
@@ -180,17 +199,44 @@ func getIdInternal(ctxName, path string) error {
 
 	mg := data.NewDirTreeManager(ds.NewTreeManager(root))
 
-	pathNode, err := mg.FindNodeByAbsPath(idFilePath)
+	idFilePaths, err := resolveTargets(mg, path)
 	if err != nil {
 		return err
 	}
 
-	id := pathNode.GetId()
-	if id == "" {
-		id = "<empty>"
+	out, err := newOutputPrinter()
+	if err != nil {
+		return err
+	}
+	if out.Structured() {
+		records := []output.IdRecord{}
+		for _, idFilePath := range idFilePaths {
+			pathNode, err := mg.FindNodeByAbsPath(idFilePath)
+			if err != nil {
+				return err
+			}
+			records = append(records, output.IdRecord{Path: idFilePath, Id: pathNode.GetId()})
+		}
+		return out.Print(records, nil)
 	}
 
-	fmt.Println(id)
+	for _, idFilePath := range idFilePaths {
+		pathNode, err := mg.FindNodeByAbsPath(idFilePath)
+		if err != nil {
+			return err
+		}
+
+		id := pathNode.GetId()
+		if id == "" {
+			id = "<empty>"
+		}
+
+		if len(idFilePaths) > 1 {
+			fmt.Printf("%s: %s\n", idFilePath, id)
+		} else {
+			fmt.Println(id)
+		}
+	}
 
 	return nil
 }
@@ -226,10 +272,11 @@ finally:
 
 // idGetCmd represents the get command
 var idGetCmd = &cobra.Command{
-	Use:   "get",
-	Short: "Gets the id of the file/dir. Return <empty> if no id set",
-	Long:  "Gets the id of the file/dir. Return <empty> if no id set",
-	Run:   getId,
+	Use:         "get",
+	Short:       "Gets the id of the file/dir. Return <empty> if no id set",
+	Long:        "Gets the id of the file/dir. Return <empty> if no id set. For a glob, every matching tracked node is printed with its id. With --output json, ndjson, yaml or csv every node is printed as a record with its path and id, empty if not set.",
+	Run:         getId,
+	Annotations: structuredOutput("id"),
 }
 
 func init() {
@@ -238,4 +285,5 @@ func init() {
 	idCmd.AddCommand(idSetCmd)
 	idCmd.AddCommand(idJumpCmd)
 	idCmd.AddCommand(idGetCmd)
+	idSetCmd.Flags().Bool("dry-run", false, "list the tracked nodes the path or glob matches without setting the id")
 }
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Maintain the search index of the current context",
+	Long: `Maintain the search index of the current context. The index maps tags, ids and path
+elements to nodes so that "tag searchTag" and "search text" need not walk the whole
+tree. It is updated by every command changing the tree and rebuilt when it
+falls out of date, so rebuilding by hand is only needed to repair it.`,
+}
+
+// indexRebuildInternal rebuilds the index of ctxName and returns the number of nodes
+// and terms in it.
+func indexRebuildInternal(ctxName string) (int, int, error) {
+	rw, err := tree.GetIndexedRW(ctxName)
+	if err != nil {
+		return 0, 0, err
+	}
+	ix, err := rw.RebuildIndex()
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(ix.Docs), len(ix.Postings), nil
+}
+
+func indexRebuild(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+	var nodes, terms int
+
+	if len(args) != 0 {
+		err = &cmderror.InvalidNumberOfArguments{}
+		goto finally
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	nodes, terms, err = indexRebuildInternal(ctxName)
+	if err != nil {
+		goto finally
+	}
+	fmt.Printf("Indexed %d nodes under %d terms\n", nodes, terms)
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+var indexRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuilds the search index from the tree",
+	Long:  "Rebuilds the search index from the tree",
+	Run:   indexRebuild,
+}
+
+func init() {
+	RootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexRebuildCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexCmd(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "acme",
+		Dirs: []*utils.MockDir{
+			{DirName: "reports", Files: []string{"q1.pdf", "q2.pdf"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		err := trackInternal(context.Background(), "default", filepath.Join(root, "reports")+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+
+		q1 := filepath.Join(root, "reports", "q1.pdf")
+		q2 := filepath.Join(root, "reports", "q2.pdf")
+		require.NoError(t, tagAddInternal("default", []string{q1, "board"}))
+		require.NoError(t, tagAddInternal("default", []string{q2, "finance"}))
+		require.NoError(t, idSetInternal("default", q2, "q2-draft"))
+
+		paths, err := tagSearchInternal("default", "board")
+		require.NoError(t, err)
+		require.Equal(t, []string{q1}, paths)
+		paths, err = searchTextInternal("default", []string{"reports", "finance"})
+		require.NoError(t, err)
+		require.Equal(t, []string{q2}, paths)
+		paths, err = searchTextInternal("default", []string{"draft"})
+		require.NoError(t, err)
+		require.Equal(t, []string{q2}, paths)
+
+		require.NoError(t, tagDeleteInternal("default", q2, "finance"))
+		paths, err = searchTextInternal("default", []string{"finance"})
+		require.NoError(t, err)
+		require.Empty(t, paths)
+
+		// The index follows untracking and can be rebuilt from scratch.
+		require.NoError(t, untrackInternal("default", q1))
+		paths, err = tagSearchInternal("default", "board")
+		require.NoError(t, err)
+		require.Empty(t, paths)
+		require.NoError(t, os.Remove(filepath.Join(root, utils.MMDirName, "default", utils.IndexFileName)))
+		nodes, _, err := indexRebuildInternal("default")
+		require.NoError(t, err)
+		require.Equal(t, 3, nodes) // root, reports and q2.pdf
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+
+	"github.com/spf13/cobra"
+)
+
+// longListing is how track show --long and ls -l print: a table sorted by Sort
+// when Long is set.
+type longListing struct {
+	Long bool
+	Sort output.SortKey
+}
+
+// addLongListingFlags adds the --long and --sort flags of long listings to cmd.
+func addLongListingFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("long", "l", false, "print a table with kind, size, modification time, tags, id and Drive link")
+	cmd.Flags().String("sort", string(output.SortName), "sort the rows of --long by name, size (largest first) or mtime (newest first)")
+}
+
+// longListingFromFlags reads the --long and --sort flags of cmd.
+func longListingFromFlags(cmd *cobra.Command) (longListing, error) {
+	var l longListing
+	var err error
+	l.Long, err = cmd.Flags().GetBool("long")
+	if err != nil {
+		return l, err
+	}
+	sortFlag, err := cmd.Flags().GetString("sort")
+	if err != nil {
+		return l, err
+	}
+	l.Sort, err = output.ParseSortKey(sortFlag)
+	if err != nil {
+		return l, err
+	}
+	if cmd.Flags().Changed("sort") && !l.Long {
+		return l, errors.New("--sort requires --long")
+	}
+	if l.Long && (outputFormat != string(output.FormatText) || formatTemplate != "") {
+		return l, errors.New("--long cannot be used with --output or --format")
+	}
+	return l, nil
+}
+
+// printRows sorts rows and prints them as a table fitting the terminal.
+func (l longListing) printRows(rows []output.Row) error {
+	output.SortRows(rows, l.Sort)
+	return output.WriteTable(os.Stdout, rows, output.TerminalWidth(os.Stdout))
+}
+
+// subtreeRows returns the rows of node and the nodes below it, named by their path
+// below node, joined to prefix when it is set. node itself is ".", or prefix.
+func subtreeRows(node *ds.TreeNode, prefix string) ([]output.Row, error) {
+	records, err := subtreeRecords(node)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]output.Row, 0, len(records))
+	base := output.NewNode(node).Path
+	for _, r := range records {
+		name := strings.TrimPrefix(strings.TrimPrefix(r.Path, base), "/")
+		switch {
+		case prefix != "" && name != "":
+			name = prefix + "/" + name
+		case prefix != "":
+			name = prefix
+		case name == "":
+			name = "."
+		}
+		rows = append(rows, output.NodeRow(name, r))
+	}
+	return rows, nil
+}
+
+// remoteEntryRows returns the rows of entries listed in a Drive or WebDAV directory,
+// with the tags and id of the entries tracked in the current context.
+func remoteEntryRows(entries []output.Entry) []output.Row {
+	rows := make([]output.Row, 0, len(entries))
+	for _, e := range entries {
+		rows = append(rows, output.EntryRow(e))
+	}
+
+	ctxName, err := GetContext()
+	if err != nil || ctxName == "" {
+		return rows
+	}
+	typ, err := GetContextType(ctxName)
+	if err != nil {
+		return rows
+	}
+	prefix := ""
+	switch typ {
+	case contextrepo.TypeGDrive:
+		prefix = file.GDrivePathPrefix
+	case filesys.TypeWebDAV:
+		prefix = file.WebDAVPathPrefix
+	default:
+		return rows
+	}
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return rows
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return rows
+	}
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	for i, e := range entries {
+		node, err := drMg.FindTreeNodeByAbsPath(prefix + strings.TrimPrefix(e.Path, "/"))
+		if err != nil {
+			// Not tracked
+			continue
+		}
+		n := output.NewNode(node)
+		rows[i].Tags = n.Tags
+		rows[i].Id = n.Id
+	}
+	return rows
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/printer"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuredOutput(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "home",
+		Dirs: []*utils.MockDir{
+			{DirName: "docs", Files: []string{"a.txt"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", contextrepo.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		require.NoError(t, trackInternal(context.Background(), "default", filepath.Join(root, "docs")+"*", config.TrackedRoot{}, filesys.ScanOptions{}, nil))
+		a := filepath.Join(root, "docs", "a.txt")
+		require.NoError(t, tagAddInternal("default", []string{a, "work"}))
+		require.NoError(t, idSetInternal("default", a, "ida"))
+
+		records, err := tagSearchRecords("default", "work")
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, a, records[0].Path)
+		require.Equal(t, output.KindFile, records[0].Kind)
+		require.Equal(t, []string{"work"}, records[0].Tags)
+		require.Equal(t, "ida", records[0].Id)
+		require.NotNil(t, records[0].ModTime)
+		require.NoError(t, tagAddInternal("default", []string{filepath.Join(root, "docs"), "work"}))
+		records, err = tagSearchRecords("default", "work")
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, filepath.Join(root, "docs"), records[0].Path)
+		require.Equal(t, a, records[1].Path)
+		records, err = tagSearchRecords("default", "missing")
+		require.NoError(t, err)
+		require.Empty(t, records)
+
+		rw, err := tree.GetRW("default")
+		require.NoError(t, err)
+		treeRoot, err := rw.Read()
+		require.NoError(t, err)
+		docs, err := data.NewDirTreeManager(ds.NewTreeManager(treeRoot)).FindTreeNodeByAbsPath(filepath.Join(root, "docs"))
+		require.NoError(t, err)
+		records, err = subtreeRecords(docs)
+		require.NoError(t, err)
+		paths := []string{}
+		for _, r := range records {
+			paths = append(paths, r.Path)
+		}
+		require.Equal(t, []string{filepath.Join(root, "docs"), a}, paths)
+		require.Equal(t, output.KindDir, output.NewNode(docs).Kind)
+
+		// Commands printing records accept structured formats, the others do not.
+		outputFormat = "json"
+		defer func() { outputFormat = string(output.FormatText) }()
+		require.NoError(t, RootCmd.PersistentPreRunE(trackShowCmd, nil))
+		require.NoError(t, RootCmd.PersistentPreRunE(contextListCmd, nil))
+		require.ErrorContains(t, RootCmd.PersistentPreRunE(tagAddCmd, nil), "does not support --output json")
+		require.NoError(t, tagSearchPrintInternal("default", "work", false))
+		outputFormat = "xml"
+		require.ErrorContains(t, RootCmd.PersistentPreRunE(trackShowCmd, nil), "invalid output format")
+
+		outputFormat = string(output.FormatText)
+		formatTemplate = "{{.Path}}\t{{.Id}}"
+		defer func() { formatTemplate = "" }()
+		require.NoError(t, RootCmd.PersistentPreRunE(searchQueryCmd, nil))
+		require.ErrorContains(t, RootCmd.PersistentPreRunE(tagAddCmd, nil), "does not support --format")
+		out, err := newOutputPrinter()
+		require.NoError(t, err)
+		require.IsType(t, &printer.TemplatePrinter{}, nodePrintingContexts(out)[0])
+		require.NoError(t, tagSearchPrintInternal("default", "work", true))
+		outputFormat = "json"
+		require.ErrorContains(t, RootCmd.PersistentPreRunE(trackShowCmd, nil), "--format cannot be used with --output json")
+		outputFormat = string(output.FormatText)
+		formatTemplate = "{{.Path"
+		require.ErrorContains(t, RootCmd.PersistentPreRunE(trackShowCmd, nil), "invalid format")
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestLongListing(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "home",
+		Dirs: []*utils.MockDir{
+			{DirName: "docs", Files: []string{"a.txt"}, Dirs: []*utils.MockDir{{DirName: "sub", Files: []string{"b.txt"}}}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", contextrepo.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		require.NoError(t, trackInternal(context.Background(), "default", filepath.Join(root, "docs")+"*", config.TrackedRoot{}, filesys.ScanOptions{}, nil))
+		a := filepath.Join(root, "docs", "a.txt")
+		require.NoError(t, tagAddInternal("default", []string{a, "work"}))
+		require.NoError(t, idSetInternal("default", a, "ida"))
+
+		rw, err := tree.GetRW("default")
+		require.NoError(t, err)
+		treeRoot, err := rw.Read()
+		require.NoError(t, err)
+		docs, err := data.NewDirTreeManager(ds.NewTreeManager(treeRoot)).FindTreeNodeByAbsPath(filepath.Join(root, "docs"))
+		require.NoError(t, err)
+
+		rows, err := subtreeRows(docs, "")
+		require.NoError(t, err)
+		output.SortRows(rows, output.SortName)
+		names := []string{}
+		for _, r := range rows {
+			names = append(names, r.Name)
+		}
+		require.Equal(t, []string{".", "a.txt", "sub", "sub/b.txt"}, names)
+		require.Equal(t, []string{"work"}, rows[1].Tags)
+		require.Equal(t, "ida", rows[1].Id)
+		require.Equal(t, output.KindDir, rows[2].Kind)
+		rows, err = subtreeRows(docs, "docs")
+		require.NoError(t, err)
+		require.Equal(t, "docs/sub/b.txt", rows[3].Name)
+
+		cmd := &cobra.Command{Use: "ls"}
+		addLongListingFlags(cmd)
+		listing, err := longListingFromFlags(cmd)
+		require.NoError(t, err)
+		require.False(t, listing.Long)
+		require.NoError(t, cmd.Flags().Set("sort", "size"))
+		_, err = longListingFromFlags(cmd)
+		require.ErrorContains(t, err, "--sort requires --long")
+		require.NoError(t, cmd.Flags().Set("long", "true"))
+		listing, err = longListingFromFlags(cmd)
+		require.NoError(t, err)
+		require.Equal(t, longListing{Long: true, Sort: output.SortSize}, listing)
+		require.NoError(t, cmd.Flags().Set("sort", "tags"))
+		_, err = longListingFromFlags(cmd)
+		require.ErrorContains(t, err, "invalid sort key")
+
+		require.NoError(t, cmd.Flags().Set("sort", "mtime"))
+		outputFormat = "json"
+		defer func() { outputFormat = string(output.FormatText) }()
+		_, err = longListingFromFlags(cmd)
+		require.ErrorContains(t, err, "--long cannot be used with --output")
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
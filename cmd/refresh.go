@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/orphans"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// refreshedRoot is what refreshing one tracked root did.
+type refreshedRoot struct {
+	Root string
+	*data.RefreshResult
+	// ScanErr is set when the rescan was incomplete or failed; vanished nodes
+	// were still only counted once confirmed missing
+	ScanErr error
+}
+
+// selectTrackedRoots returns the roots refreshed for path: those at or below it, or else
+// the recursive root containing it. An empty path selects every root.
+func selectTrackedRoots(roots []config.TrackedRoot, path string) []config.TrackedRoot {
+	if path == "" {
+		return roots
+	}
+	selected := []config.TrackedRoot{}
+	for _, r := range roots {
+		if r.Dir() == path || strings.HasPrefix(r.Dir(), strings.TrimSuffix(path, "/")+"/") {
+			selected = append(selected, r)
+		}
+	}
+	if len(selected) > 0 {
+		return selected
+	}
+	for _, r := range roots {
+		if r.Recursive() && strings.HasPrefix(path, strings.TrimSuffix(r.Dir(), "/")+"/") {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+// loadTrackedRoots returns the config of ctxName with its tracked roots. Local trees
+// tracked before tracked roots were recorded have none in their config; they are then
+// found in the tree at root, see legacyTrackedRoots, and saved so that this only
+// happens once. Other contexts are left without roots, their paths are to be tracked
+// again.
+func loadTrackedRoots(ctxName string, root *ds.TreeNode) (*config.Config, error) {
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TrackedRootsRecorded || len(cfg.TrackedRoots) > 0 || root == nil || len(root.Children) == 0 {
+		return cfg, nil
+	}
+	contextType, err := GetContextType(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	if contextType != contextrepo.TypeLocal {
+		logrus.Debugf("[refresh] %s context %q has no tracked roots to migrate", contextType, ctxName)
+		return cfg, nil
+	}
+
+	tops := []*ds.TreeNode{root}
+	if data.NewDirTreeManager(ds.NewTreeManager(root)).IsMultiRoot() {
+		tops = root.Children
+	}
+	for _, top := range tops {
+		for _, r := range legacyTrackedRoots(top) {
+			cfg.AddTrackedRoot(r)
+		}
+	}
+	if len(cfg.TrackedRoots) == 0 {
+		return cfg, nil
+	}
+	logrus.Debugf("[refresh] migrated tracked roots of %q from the tree: %v", ctxName, cfg.TrackedRoots)
+	err = config.Save(ctxName, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// legacyTrackedRoots returns the outermost tracked subtrees at or below node: the
+// directories whose entries on disk are all tracked, recursive, and the files tracked
+// on their own. Directories holding more entries than they track, like "/" above a
+// tracked home directory, only lead to what was tracked and are looked into instead.
+// Nodes that cannot be read, like vanished ones, are roots as they are.
+func legacyTrackedRoots(node *ds.TreeNode) []config.TrackedRoot {
+	info, ok := node.Info.(file.NodeInformable)
+	if !ok {
+		return nil
+	}
+	p := info.GetAbsPath()
+	entries, err := os.ReadDir(p)
+	if err != nil || len(node.Children) == 0 || allTracked(node, entries) {
+		r := config.TrackedRoot{Path: p}
+		if err == nil || len(node.Children) > 0 {
+			r.Path += "*"
+		}
+		return []config.TrackedRoot{r}
+	}
+
+	roots := []config.TrackedRoot{}
+	for _, child := range node.Children {
+		if child != nil {
+			roots = append(roots, legacyTrackedRoots(child)...)
+		}
+	}
+	return roots
+}
+
+// allTracked reports whether every one of entries, those of the directory of node,
+// is tracked below it.
+func allTracked(node *ds.TreeNode, entries []os.DirEntry) bool {
+	tracked := map[string]bool{}
+	for _, child := range node.Children {
+		if info, ok := child.Info.(file.NodeInformable); ok {
+			tracked[filepath.Base(info.GetAbsPath())] = true
+		}
+	}
+	for _, e := range entries {
+		if !tracked[e.Name()] {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshInternal rescans the tracked roots selected by pathExp (all when empty), merges
+// what was added and handles vanished nodes according to policy. Metadata of removed
+// nodes is kept in the context's orphans. Nothing is written if ctx is cancelled.
+func refreshInternal(ctx context.Context, ctxName, pathExp string, policy data.VanishedPolicy, opts filesys.ScanOptions, explain io.Writer) ([]refreshedRoot, error) {
+	logrus.Debugf("[refresh] refreshInternal start ctx=%q pathExp=%q policy=%s", ctxName, pathExp, policy)
+
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadTrackedRoots(ctxName, root)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvedPath := ""
+	if pathExp != "" {
+		resolver := filesys.NewBasicResolver(defaultStore)
+		resolvedPath, err = resolver.Resolve(pathExp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	roots := selectTrackedRoots(cfg.TrackedRoots, resolvedPath)
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no tracked root to refresh; track a path first")
+	}
+
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	results := []refreshedRoot{}
+	removed := []file.NodeInformable{}
+	for _, r := range roots {
+		res, err := refreshRoot(ctx, drMg, r, policy, opts)
+		if err != nil {
+			return nil, fmt.Errorf("refresh %s: %w", r.Path, err)
+		}
+		results = append(results, *res)
+		removed = append(removed, res.Removed...)
+
+		refreshed, err := drMg.FindTreeNodeByAbsPath(r.Dir())
+		if err != nil {
+			// The root itself vanished and was removed.
+			continue
+		}
+		_, err = applyTagRules(ctxName, refreshed, explain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Orphans go first: losing the tree update is better than losing metadata.
+	err = orphans.Append(ctxName, orphansOf(removed))
+	if err != nil {
+		return nil, err
+	}
+	err = rw.Write(drMg.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Debugf("[refresh] refreshInternal done, %d roots", len(results))
+	return results, nil
+}
+
+func refreshRoot(ctx context.Context, drMg *data.DirTreeManager, r config.TrackedRoot, policy data.VanishedPolicy, opts filesys.ScanOptions) (*refreshedRoot, error) {
+	err := applyTrackedRoot(&opts, r)
+	if err != nil {
+		return nil, err
+	}
+	tracker, err := filesys.GetTrackerFromContextWithOptions(defaultStore, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := filesys.CloseTracker(tracker); err != nil {
+			logrus.Debugf("[refresh] close tracker: %v", err)
+		}
+	}()
+
+	var scanned *ds.TreeNode
+	var scanErr error
+	if r.Recursive() {
+		scanned, scanErr = filesys.TrackWithContext(ctx, tracker, r.Path)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var partial filesys.ScanErrors
+		if scanErr != nil && !errors.As(scanErr, &partial) {
+			logrus.Debugf("[refresh] scan of %q failed: %v", r.Path, scanErr)
+			scanned = nil
+		}
+	}
+
+	exists := func(string) (bool, error) { return true, nil }
+	if checker, ok := tracker.(filesys.ExistenceChecker); ok {
+		exists = func(p string) (bool, error) { return checker.Exists(ctx, p) }
+	}
+	res, err := drMg.Refresh(r.Dir(), r.Recursive(), scanned, exists, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &refreshedRoot{Root: r.Path, RefreshResult: res, ScanErr: scanErr}, nil
+}
+
+// orphansOf returns the orphans to keep for removed nodes, those without tags or id are dropped.
+func orphansOf(removed []file.NodeInformable) []orphans.Orphan {
+	list := []orphans.Orphan{}
+	for _, info := range removed {
+		if len(info.GetTags()) == 0 && info.GetId() == "" {
+			continue
+		}
+		list = append(list, orphans.Orphan{
+			Path:      info.GetAbsPath(),
+			Tags:      info.GetTags(),
+			Id:        info.GetId(),
+			RemovedAt: time.Now(),
+		})
+	}
+	return list
+}
+
+func printRefreshSummary(results []refreshedRoot, policy data.VanishedPolicy) {
+	var added, restored, vanished int
+	for _, res := range results {
+		fmt.Printf("%s: %d added, %d restored, %d vanished\n", res.Root, res.Added, res.Restored, res.Vanished)
+		if res.ScanErr != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", res.Root, res.ScanErr)
+		}
+		added += res.Added
+		restored += res.Restored
+		vanished += res.Vanished
+	}
+	action := "marked"
+	if policy == data.VanishedRemove {
+		action = "removed, metadata kept in orphans"
+	}
+	fmt.Printf("Refreshed %d roots: %d added, %d restored, %d vanished (%s)\n", len(results), added, restored, vanished, action)
+}
+
+func refresh(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName, pathExp, vanished string
+	var policy data.VanishedPolicy
+	var opts filesys.ScanOptions
+	var explain io.Writer
+	var results []refreshedRoot
+	var ctx context.Context
+	var stop context.CancelFunc
+
+	if len(args) > 1 {
+		err = &cmderror.InvalidNumberOfArguments{}
+		goto finally
+	}
+	if len(args) == 1 {
+		pathExp = args[0]
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	vanished, err = cmd.Flags().GetString("vanished")
+	if err != nil {
+		goto finally
+	}
+	policy, err = data.ParseVanishedPolicy(vanished)
+	if err != nil {
+		goto finally
+	}
+	opts, err = scanRuntimeFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	explain, err = explainFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	results, err = refreshInternal(ctx, ctxName, pathExp, policy, opts, explain)
+	if err != nil {
+		goto finally
+	}
+	printRefreshSummary(results, policy)
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func runRefreshOrphans(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+	var list []orphans.Orphan
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	list, err = orphans.Load(ctxName)
+	if err != nil {
+		goto finally
+	}
+	if len(list) == 0 {
+		fmt.Println("No orphans")
+	}
+	for _, o := range list {
+		line := o.Path
+		if o.Id != "" {
+			line += "  id: " + o.Id
+		}
+		if len(o.Tags) > 0 {
+			line += "  tags: " + strings.Join(o.Tags, ", ")
+		}
+		fmt.Printf("%s  (removed %s)\n", line, o.RemovedAt.Format(time.DateTime))
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// refreshCmd represents the refresh command
+var refreshCmd = &cobra.Command{
+	Use:   "refresh [path]",
+	Short: "Reconcile tracked trees with the file system (local or Google Drive)",
+	Long: `Rescans tracked roots with the options they were tracked with and merges
+new files and directories into the tree.
+
+Without a path every tracked root of the current context is refreshed. With a
+path, the roots at or below it are refreshed, or else the recursive root
+containing it.
+
+Tracked nodes that no longer exist are "vanished". With --vanished=mark (the
+default) they stay in the tree with their tags and ids and are shown as
+vanished until they come back. With --vanished=remove they are removed, and
+the tags and ids they carried are kept in the context's orphans:
+  refresh orphans
+
+The tagging rules of the context (see "tag apply-rules") are applied to the
+refreshed nodes; --explain prints which rule added which tag.
+
+In local contexts tracked before refresh existed, the tracked paths are found in
+the tree the first time refresh or watch runs: every outermost directory whose
+entries are all tracked, and every file tracked on its own. Paths of other contexts
+tracked before then are to be tracked again once.`,
+	Run: refresh,
+}
+
+// refreshOrphansCmd lists metadata of nodes removed by refresh.
+var refreshOrphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "List tags and ids of nodes removed by refresh",
+	Args:  cobra.NoArgs,
+	Run:   runRefreshOrphans,
+}
+
+func init() {
+	RootCmd.AddCommand(refreshCmd)
+	refreshCmd.AddCommand(refreshOrphansCmd)
+	refreshCmd.Flags().String("vanished", string(data.VanishedMark), "what to do with tracked nodes that no longer exist: mark or remove")
+	refreshCmd.Flags().Int("workers", filesys.DefaultScanWorkers, "number of entries scanned concurrently in recursive local scans")
+	refreshCmd.Flags().Bool("no-progress", false, "do not report scan progress on stderr")
+	refreshCmd.Flags().Bool("explain", false, "print which tagging rule added which tag")
+}
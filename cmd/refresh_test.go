@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/orphans"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func findTracked(t *testing.T, path string) (*ds.TreeNode, error) {
+	rw, err := tree.GetRW("default")
+	require.NoError(t, err)
+	root, err := rw.Read()
+	require.NoError(t, err)
+	return data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath(path)
+}
+
+func TestRefreshCmd(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"1_a"},
+		Dirs: []*utils.MockDir{
+			{
+				DirName: "2_1",
+				Files:   []string{"2_a", "2_b"},
+			},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		require.NoError(t, InitializeRootAndScan(root))
+
+		gone := filepath.Join(root, "2_1", "2_a")
+		require.NoError(t, tagAddInternal("default", []string{gone, "keep-me"}))
+		require.NoError(t, os.Remove(gone))
+		added := filepath.Join(root, "2_1", "new")
+		require.NoError(t, os.WriteFile(added, nil, 0644))
+
+		// mark keeps the node and its tags
+		results, err := refreshInternal(context.Background(), "default", filepath.Join(root, "2_1"), data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, root+"*", results[0].Root)
+		require.Equal(t, 1, results[0].Vanished)
+		require.GreaterOrEqual(t, results[0].Added, 1)
+
+		_, err = findTracked(t, added)
+		require.NoError(t, err)
+		node, err := findTracked(t, gone)
+		require.NoError(t, err)
+		require.True(t, node.Info.(file.Vanishable).IsVanished())
+		require.Equal(t, []string{"keep-me"}, node.Info.(file.NodeInformable).GetTags())
+
+		// a file coming back is restored
+		require.NoError(t, os.WriteFile(gone, nil, 0644))
+		results, err = refreshInternal(context.Background(), "default", "", data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, results[0].Restored)
+		node, err = findTracked(t, gone)
+		require.NoError(t, err)
+		require.False(t, node.Info.(file.Vanishable).IsVanished())
+
+		// remove takes the node out and keeps its metadata as an orphan
+		require.NoError(t, os.Remove(gone))
+		_, err = refreshInternal(context.Background(), "default", "", data.VanishedRemove, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		_, err = findTracked(t, gone)
+		require.Error(t, err)
+
+		list, err := orphans.Load("default")
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		require.Equal(t, gone, list[0].Path)
+		require.Equal(t, []string{"keep-me"}, list[0].Tags)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestRefreshCmdHonorsTrackOptions(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"a.pdf", "b.txt"},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+
+		docs := filepath.Join(root, "docs")
+		require.NoError(t, os.Mkdir(docs, 0755))
+		require.NoError(t, trackInternal(context.Background(), "default", docs+"*", config.TrackedRoot{Include: []string{"*.pdf"}}, filesyspkg.ScanOptions{}, nil))
+
+		cfg, err := config.Load("default")
+		require.NoError(t, err)
+		require.Equal(t, []config.TrackedRoot{{Path: docs + "*", Include: []string{"*.pdf"}}}, cfg.TrackedRoots)
+
+		require.NoError(t, os.WriteFile(filepath.Join(docs, "c.pdf"), nil, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(docs, "d.txt"), nil, 0644))
+		results, err := refreshInternal(context.Background(), "default", "", data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, 1, results[0].Added)
+		require.Equal(t, 0, results[0].Vanished)
+
+		_, err = findTracked(t, filepath.Join(docs, "d.txt"))
+		require.Error(t, err)
+
+		// untracking forgets the root
+		require.NoError(t, untrackInternal("default", docs))
+		_, err = refreshInternal(context.Background(), "default", "", data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+		require.Error(t, err)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestRefreshCmdWithoutTrackedRoots(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"1_a"},
+		Dirs:    []*utils.MockDir{{DirName: "sub", Files: []string{"s"}}},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		require.NoError(t, InitializeRootAndScan(root))
+
+		// Trees tracked before tracked roots were recorded have none in their config.
+		cfg, err := config.Load("default")
+		require.NoError(t, err)
+		cfg.TrackedRoots = nil
+		cfg.TrackedRootsRecorded = false
+		require.NoError(t, config.Save("default", cfg))
+
+		added := filepath.Join(root, "sub", "new")
+		require.NoError(t, os.WriteFile(added, nil, 0644))
+		results, err := refreshInternal(context.Background(), "default", "", data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, root+"*", results[0].Root)
+		_, err = findTracked(t, added)
+		require.NoError(t, err)
+
+		cfg, err = config.Load("default")
+		require.NoError(t, err)
+		require.Equal(t, []config.TrackedRoot{{Path: root + "*"}}, cfg.TrackedRoots)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestLegacyTrackedRootsBelowFilesystemRoot(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"1_a"},
+		Dirs:    []*utils.MockDir{{DirName: "sub", Files: []string{"s"}}},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		require.NoError(t, InitializeRootAndScan(root))
+		cfg, err := config.Load("default")
+		require.NoError(t, err)
+		cfg.TrackedRoots = nil
+		cfg.TrackedRootsRecorded = false
+		require.NoError(t, config.Save("default", cfg))
+
+		// Local contexts used to be rooted at "/", with the directories leading to
+		// what was tracked as nodes.
+		rw, err := tree.GetRW("default")
+		require.NoError(t, err)
+		tracked, err := rw.Read()
+		require.NoError(t, err)
+		legacy := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/"}})
+		parent := legacy
+		chain := []*ds.TreeNode{}
+		for dir := filepath.Dir(root); dir != "/"; dir = filepath.Dir(dir) {
+			chain = append([]*ds.TreeNode{ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: dir}})}, chain...)
+		}
+		for _, node := range append(chain, tracked) {
+			parent.Children = []*ds.TreeNode{node}
+			parent = node
+		}
+
+		require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(root), "untracked"), nil, 0644))
+		cfg, err = loadTrackedRoots("default", legacy)
+		require.NoError(t, err)
+		require.Equal(t, []config.TrackedRoot{{Path: root + "*"}}, cfg.TrackedRoots)
+
+		// A directory with entries it does not track only leads to what it tracks.
+		cfg.TrackedRoots = nil
+		cfg.TrackedRootsRecorded = false
+		require.NoError(t, config.Save("default", cfg))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "loose"), nil, 0644))
+		cfg, err = loadTrackedRoots("default", legacy)
+		require.NoError(t, err)
+		require.Subset(t, cfg.TrackedRoots, []config.TrackedRoot{{Path: filepath.Join(root, "1_a")}, {Path: filepath.Join(root, "sub") + "*"}})
+		require.NotContains(t, cfg.TrackedRoots, config.TrackedRoot{Path: root + "*"})
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
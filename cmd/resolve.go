@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/recent"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// resolveInternal resolves expr like every command taking a path does, and reports
+// whether the resolved path is tracked in ctxName. Resolving a tracked path counts as
+// using it for the ranking of "search fuzzy".
+func resolveInternal(ctxName, expr string) (string, bool, error) {
+	resolved, err := filesys.NewBasicResolver(defaultStore).Resolve(expr)
+	if err != nil {
+		return "", false, err
+	}
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return "", false, err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return "", false, err
+	}
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	_, err = drMg.FindTreeNodeByAbsPath(strings.TrimSuffix(resolved, "*"))
+	if err != nil {
+		return resolved, false, nil
+	}
+	err = recent.Touch(ctxName, time.Now(), strings.TrimSuffix(resolved, "*"))
+	if err != nil {
+		logrus.Debugf("[resolve] cannot record the use of %q: %v", resolved, err)
+	}
+	return resolved, true, nil
+}
+
+func resolve(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName, resolved string
+	var tracked bool
+
+	if len(args) != 1 {
+		err = &cmderror.InvalidNumberOfArguments{}
+		goto finally
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	resolved, tracked, err = resolveInternal(ctxName, args[0])
+	if err != nil {
+		goto finally
+	}
+	if tracked {
+		fmt.Println(resolved)
+	} else {
+		fmt.Println(resolved, "(not tracked)")
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// resolveCmd represents the resolve command
+var resolveCmd = &cobra.Command{
+	Use:   "resolve <path>",
+	Short: "Print the path a path expression stands for",
+	Long: `Resolves a path the way track, untrack, tag, id and search do, and prints it. Useful to check an expression before using it.
+Besides plain and relative paths, expressions may use:
+  ~/docs              the home directory (local contexts)
+  $PROJECTS/x         environment variables, ${VAR} works too; unset ones are kept
+  @proj/docs/spec.md  a path relative to the node with id "proj"
+  work:/src           a named root of the context (see "context root")
+  ../x, /a/../b       ".." steps, in Drive and WebDAV paths as well
+  old.zip!/src        an entry of an archive`,
+	Run: resolve,
+}
+
+func init() {
+	RootCmd.AddCommand(resolveCmd)
+}
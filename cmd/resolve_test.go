@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveCmd(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "proj",
+		Dirs: []*utils.MockDir{
+			{DirName: "docs", Files: []string{"spec.md"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+		t.Setenv("MM_RESOLVE_TEST_DIR", root)
+
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		err := trackInternal(context.Background(), "default", "$MM_RESOLVE_TEST_DIR/docs*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		require.NoError(t, idSetInternal("default", "${MM_RESOLVE_TEST_DIR}/docs", "docs"))
+
+		spec := filepath.Join(root, "docs", "spec.md")
+		resolved, tracked, err := resolveInternal("default", "@docs/spec.md")
+		require.NoError(t, err)
+		require.Equal(t, spec, resolved)
+		require.True(t, tracked)
+		resolved, tracked, err = resolveInternal("default", "@docs/../notes.md")
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(root, "notes.md"), resolved)
+		require.False(t, tracked)
+
+		// Other commands take the same expressions.
+		require.NoError(t, tagAddInternal("default", []string{"@docs/spec.md", "spec"}))
+		tags, err := tagGetInternal("default", spec)
+		require.NoError(t, err)
+		require.Equal(t, []string{"spec"}, tags)
+		require.NoError(t, searchNodeInternal("default", "spec", "@docs"))
+		require.NoError(t, untrackInternal("default", "@docs/spec.md"))
+		_, tracked, err = resolveInternal("default", spec)
+		require.NoError(t, err)
+		require.False(t, tracked)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/printer"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -14,17 +16,80 @@ var RootCmd = &cobra.Command{
 	Use:   "MetaManager",
 	Short: "Manage your metadata using this!",
 	Long:  `MetaManager is a tool for managing your files metadata.`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		if ok, _ := cmd.Root().PersistentFlags().GetBool("debug"); ok {
 			logrus.SetLevel(logrus.DebugLevel)
 		} else {
 			logrus.SetLevel(logrus.InfoLevel)
 		}
+		f, err := output.ParseFormat(outputFormat)
+		if err != nil {
+			return err
+		}
+		if f != output.FormatText && cmd.Annotations[structuredOutputAnnotation] == "" {
+			return fmt.Errorf("%s does not support --output %s", cmd.CommandPath(), f)
+		}
+		if formatTemplate == "" {
+			return nil
+		}
+		if cmd.Annotations[structuredOutputAnnotation] == "" {
+			return fmt.Errorf("%s does not support --format", cmd.CommandPath())
+		}
+		if f != output.FormatText {
+			return fmt.Errorf("--format cannot be used with --output %s", f)
+		}
+		_, err = output.ParseTemplate(formatTemplate)
+		return err
 	},
 }
 
+// outputFormat is the --output flag, see output.Format.
+var outputFormat string
+
+// formatTemplate is the --format flag, see output.Template.
+var formatTemplate string
+
+// structuredOutputAnnotation marks the commands supporting --format and --output
+// formats other than text. Its value names the schema of their records.
+const structuredOutputAnnotation = "output"
+
+// structuredOutput returns the annotations of a command printing records of schema.
+func structuredOutput(schema string) map[string]string {
+	return map[string]string{structuredOutputAnnotation: schema}
+}
+
+// newOutputPrinter returns the printer of the --output format and --format template,
+// writing to stdout.
+func newOutputPrinter() (*output.Printer, error) {
+	f, err := output.ParseFormat(outputFormat)
+	if err != nil {
+		return nil, err
+	}
+	out := output.NewPrinter(os.Stdout, f)
+	if formatTemplate != "" {
+		out.Template, err = output.ParseTemplate(formatTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// nodePrintingContexts returns how trees of nodes are printed: with the --format
+// template of out when there is one, else with contexts.
+func nodePrintingContexts(out *output.Printer, contexts ...printer.PrintingContext) []printer.PrintingContext {
+	if out.Template != nil {
+		return []printer.PrintingContext{printer.NewTemplatePrinter(out.Template)}
+	}
+	return contexts
+}
+
 func init() {
 	RootCmd.PersistentFlags().BoolP("debug", "d", false, "enable debug logging")
+	RootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", string(output.FormatText),
+		"output format: text, json, ndjson, yaml or csv, for the commands listing nodes, entries, contexts and ids")
+	RootCmd.PersistentFlags().StringVar(&formatTemplate, "format", "",
+		`Go template printing each record of the same commands, e.g. '{{.Path}}\t{{join .Tags ","}}\t{{.Id}}'`)
 }
 
 // Execute runs the root command and exits with the appropriate code on error.
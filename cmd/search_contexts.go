@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/output"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+
+	"github.com/spf13/cobra"
+)
+
+// addSearchContextsFlags adds the flags choosing the contexts a search runs over.
+func addSearchContextsFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all-contexts", false, "search every context instead of the current one")
+	cmd.Flags().StringSlice("contexts", nil, "search these contexts instead of the current one, e.g. local,gdrive")
+	cmd.MarkFlagsMutuallyExclusive("all-contexts", "contexts")
+}
+
+// searchContextsFromFlags returns the contexts chosen by the flags of
+// addSearchContextsFlags, nil when the search runs in the current context only.
+func searchContextsFromFlags(cmd *cobra.Command) ([]contextrepo.ContextEntry, error) {
+	all, err := cmd.Flags().GetBool("all-contexts")
+	if err != nil {
+		return nil, err
+	}
+	names, err := cmd.Flags().GetStringSlice("contexts")
+	if err != nil {
+		return nil, err
+	}
+	return searchContexts(all, names)
+}
+
+// searchContexts returns every context with all, else the named ones in the order
+// given. It returns nil when neither is asked for.
+func searchContexts(all bool, names []string) ([]contextrepo.ContextEntry, error) {
+	if !all && len(names) == 0 {
+		return nil, nil
+	}
+	entries, err := GetContexts()
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("no contexts; use 'context create <name> --type local|gdrive'")
+		}
+		return entries, nil
+	}
+
+	chosen := []contextrepo.ContextEntry{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		i := slices.IndexFunc(entries, func(e contextrepo.ContextEntry) bool { return e.Name == name })
+		if i == -1 {
+			return nil, fmt.Errorf("context %q not found", name)
+		}
+		if !slices.Contains(chosen, entries[i]) {
+			chosen = append(chosen, entries[i])
+		}
+	}
+	return chosen, nil
+}
+
+// searchEachContext runs search in every context of contexts, printing its results
+// below a heading with the context name and type. An error in one context is printed
+// in its group and does not stop the others.
+func searchEachContext(contexts []contextrepo.ContextEntry, search func(ctxName string) error) {
+	for i, c := range contexts {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("== %s (%s) ==\n", c.Name, c.Type)
+		err := search(c.Name)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// searchEachContextRecords is searchEachContext for structured output: the records
+// search returns for every context are marked with it and printed as one list. An
+// error in one context is printed on stderr, to keep the list parseable, and does not
+// stop the others.
+func searchEachContextRecords(out *output.Printer, contexts []contextrepo.ContextEntry, search func(ctxName string) ([]output.Node, error)) error {
+	records := []output.Node{}
+	for _, c := range contexts {
+		found, err := search(c.Name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "context %s: %v\n", c.Name, err)
+			continue
+		}
+		for _, r := range found {
+			r.Context = c.Name
+			records = append(records, r)
+		}
+	}
+	return out.Print(records, nil)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchAcrossContexts(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "tax",
+		Dirs: []*utils.MockDir{
+			{DirName: "home", Files: []string{"return.pdf"}},
+			{DirName: "work", Files: []string{"payslip.pdf", "travel.pdf"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		for _, name := range []string{"home", "work"} {
+			os.Setenv("MM_CONTEXT", name)
+			dir := filepath.Join(root, name)
+			require.NoError(t, defaultStore.Create(name, filesys.TypeLocal))
+			require.NoError(t, EnsureAppDataDir(name))
+			err := trackInternal(context.Background(), name, dir+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+			require.NoError(t, err)
+			require.NoError(t, tagAddInternal(name, []string{dir + "/*.pdf", "tax-2025"}))
+		}
+		require.NoError(t, defaultStore.Create("empty", filesys.TypeLocal))
+
+		contexts, err := searchContexts(false, nil)
+		require.NoError(t, err)
+		require.Nil(t, contexts)
+		contexts, err = searchContexts(true, nil)
+		require.NoError(t, err)
+		require.Len(t, contexts, 3)
+		contexts, err = searchContexts(false, []string{"work", " Home", "work"})
+		require.NoError(t, err)
+		require.Equal(t, []filesys.ContextEntry{{Name: "work", Type: "local"}, {Name: "home", Type: "local"}}, contexts)
+		_, err = searchContexts(false, []string{"missing"})
+		require.ErrorContains(t, err, "not found")
+
+		// Every context is searched even when one cannot be read.
+		found := map[string][]string{}
+		contexts, err = searchContexts(true, nil)
+		require.NoError(t, err)
+		searchEachContext(contexts, func(ctxName string) error {
+			paths, err := tagSearchInternal(ctxName, "tax-2025")
+			found[ctxName] = paths
+			return err
+		})
+		require.ElementsMatch(t, []string{filepath.Join(root, "home", "return.pdf")}, found["home"])
+		require.ElementsMatch(t, []string{filepath.Join(root, "work", "payslip.pdf"), filepath.Join(root, "work", "travel.pdf")}, found["work"])
+		require.Empty(t, found["empty"])
+
+		// So are they with structured output, the failing ones left out.
+		var buf bytes.Buffer
+		err = searchEachContextRecords(output.NewPrinter(&buf, output.FormatNDJSON), contexts, func(ctxName string) ([]output.Node, error) {
+			if ctxName == "empty" {
+				return nil, errors.New("no tree")
+			}
+			return []output.Node{{Path: ctxName, Tags: []string{}}}, nil
+		})
+		require.NoError(t, err)
+		require.Contains(t, buf.String(), `"context":"home"`)
+		require.Contains(t, buf.String(), `"context":"work"`)
+		require.NotContains(t, buf.String(), `"context":"empty"`)
+
+		// The current context does not matter.
+		os.Setenv("MM_CONTEXT", "home")
+		_, nodes, err := searchQueryNodes("work", "tag:tax-2025 AND name:pay*", "")
+		require.NoError(t, err)
+		require.Len(t, nodes, 1)
+		require.NoError(t, tagSearchPrintInternal("work", "tax-2025", true))
+		require.NoError(t, searchQueryInternal("work", "tag:tax-2025", "", false))
+
+		// searchNode, text and fuzzy take the contexts flags too.
+		for _, c := range []*cobra.Command{searchNodeCmd, searchTextCmd, searchFuzzyCmd} {
+			require.NotNil(t, c.Flags().Lookup("all-contexts"), c.Name())
+			require.NotNil(t, c.Flags().Lookup("contexts"), c.Name())
+		}
+		_, matched, err := searchNodeNodes("work", "payslip", "")
+		require.NoError(t, err)
+		require.Len(t, matched, 1)
+		require.NoError(t, searchTextPrintInternal("home", []string{"return"}))
+		require.NoError(t, searchFuzzyPrintInternal("work", "trvl", "", 5))
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
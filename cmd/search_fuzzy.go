@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/fuzzy"
+	"github.com/heroku/self/MetaManager/internal/recent"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	highlightStart = "\x1b[1;32m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// searchFuzzyInternal ranks the nodes below dir, the whole tree when dir is empty,
+// against text and returns the best limit.
+func searchFuzzyInternal(ctxName, text, dir string, limit int) ([]fuzzy.Result, error) {
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		wd, err := filesys.NewBasicResolver(defaultStore).Resolve(dir)
+		if err != nil {
+			return nil, err
+		}
+		root, err = data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath(wd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodes := []file.NodeInformable{}
+	it := ds.NewTreeIterator(ds.NewTreeManager(root))
+	for it.HasNext() {
+		node, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		info, ok := node.Info.(file.NodeInformable)
+		if !ok {
+			return nil, errors.New("info not convertible to NodeInformable")
+		}
+		if info.GetAbsPath() != file.RootsPath {
+			nodes = append(nodes, info)
+		}
+	}
+
+	used, err := recent.Load(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	return fuzzy.Rank(text, nodes, used, time.Now(), limit), nil
+}
+
+// highlight wraps the characters of p at positions in terminal highlighting.
+func highlight(p string, positions []int) string {
+	var sb strings.Builder
+	next, open := 0, false
+	for i, r := range p {
+		matched := next < len(positions) && positions[next] == i
+		if matched {
+			next++
+		}
+		if matched != open {
+			if matched {
+				sb.WriteString(highlightStart)
+			} else {
+				sb.WriteString(highlightEnd)
+			}
+			open = matched
+		}
+		sb.WriteRune(r)
+	}
+	if open {
+		sb.WriteString(highlightEnd)
+	}
+	return sb.String()
+}
+
+func searchFuzzy(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+	var contexts []contextrepo.ContextEntry
+
+	if len(args) == 0 {
+		err = errors.New("this command needs the text to look for")
+		goto finally
+	}
+
+	contexts, err = searchContextsFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	if contexts != nil {
+		if cmd.Flags().Changed("in") {
+			err = errors.New("--in names a directory of the current context, it cannot be used with other contexts")
+			goto finally
+		}
+		searchEachContext(contexts, func(ctxName string) error {
+			return searchFuzzyPrintInternal(ctxName, strings.Join(args, " "), "", searchFuzzyLimit)
+		})
+		return
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	err = searchFuzzyPrintInternal(ctxName, strings.Join(args, " "), searchFuzzyIn, searchFuzzyLimit)
+	if err != nil {
+		goto finally
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// searchFuzzyPrintInternal prints the best limit nodes of ctxName below dir for text,
+// highlighting the matched characters on terminals.
+func searchFuzzyPrintInternal(ctxName, text, dir string, limit int) error {
+	results, err := searchFuzzyInternal(ctxName, text, dir, limit)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("No nodes match")
+		return nil
+	}
+
+	color := isTerminal(os.Stdout)
+	for _, res := range results {
+		line := res.Path
+		if color {
+			line = highlight(res.Path, res.Positions)
+		}
+		extra := []string{}
+		if res.Tag != "" {
+			extra = append(extra, "tag: "+res.Tag)
+		}
+		if res.Id != "" {
+			extra = append(extra, "id: "+res.Id)
+		}
+		if len(extra) > 0 {
+			line += " (" + strings.Join(extra, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// searchFuzzyCmd represents the search fuzzy command
+var searchFuzzyCmd = &cobra.Command{
+	Use:   "fuzzy <text>",
+	Short: "Find nodes whose name or path roughly matches a text",
+	Long: `Find nodes whose name or path holds the characters of the text in order, like fzf,
+and print the best ones first. Matches at the start of words and runs of matched
+characters rank higher, as do nodes whose tags or id match and nodes used lately
+through "id jump" or "resolve". The text is case sensitive only if it has an uppercase letter.
+With --all-contexts or --contexts a,b the whole tree of each context is searched and the
+results are ranked and printed by context.`,
+	Run:     searchFuzzy,
+	Aliases: []string{"f"},
+}
+
+var searchFuzzyIn string
+var searchFuzzyLimit int
+
+func init() {
+	searchCmd.AddCommand(searchFuzzyCmd)
+	searchFuzzyCmd.Flags().StringVar(&searchFuzzyIn, "in", "", "directory to search below, any path form of \"resolve\"; the whole tree by default")
+	searchFuzzyCmd.Flags().IntVarP(&searchFuzzyLimit, "limit", "n", 10, "number of results to print, 0 for all")
+	addSearchContextsFlags(searchFuzzyCmd)
+}
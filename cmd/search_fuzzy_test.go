@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/fuzzy"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchFuzzy(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "work",
+		Files:   []string{"budget.xlsx"},
+		Dirs: []*utils.MockDir{
+			{DirName: "budget", Files: []string{"notes.txt"}},
+			{DirName: "plans", Files: []string{"q3.txt"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		err := trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		q3 := filepath.Join(root, "plans", "q3.txt")
+		require.NoError(t, tagAddInternal("default", []string{q3, "budget"}))
+
+		paths := func(results []fuzzy.Result) []string {
+			found := []string{}
+			for _, res := range results {
+				found = append(found, res.Path)
+			}
+			return found
+		}
+		results, err := searchFuzzyInternal("default", "budget", "", 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			filepath.Join(root, "budget"),
+			filepath.Join(root, "budget.xlsx"),
+			filepath.Join(root, "budget", "notes.txt"),
+			q3,
+		}, paths(results))
+
+		results, err = searchFuzzyInternal("default", "bdgnts", filepath.Join(root, "budget"), 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "budget", "notes.txt")}, paths(results))
+
+		// Jumping to a node makes it rank higher.
+		require.NoError(t, idSetInternal("default", filepath.Join(root, "budget", "notes.txt"), "notes"))
+		require.NoError(t, idJumpInternal("default", "notes"))
+		results, err = searchFuzzyInternal("default", "budget", "", 1)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "budget", "notes.txt")}, paths(results))
+
+		require.Equal(t, "/w/\x1b[1;32mbu\x1b[0mdg\x1b[1;32me\x1b[0mt", highlight("/w/budget", []int{3, 4, 7}))
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
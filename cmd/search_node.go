@@ -6,13 +6,16 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"os"
 	"regexp"
+	"sort"
 
 	"github.com/heroku/self/MetaManager/internal/data"
 	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
 	"github.com/heroku/self/MetaManager/internal/printer"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
 	"github.com/heroku/self/MetaManager/internal/repository/tree"
 	"github.com/heroku/self/MetaManager/internal/utils"
 
@@ -22,22 +25,50 @@ import (
 func searchNode(cmd *cobra.Command, args []string) {
 	var err error
 	var ctxName string
+	var contexts []contextrepo.ContextEntry
+	var out *output.Printer
 
-	ctxName, err = getContextRequired()
-	if err != nil {
+	if len(args) != 1 {
+		err = errors.New("this command accepts only 1 argument")
 		goto finally
 	}
-	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+
+	contexts, err = searchContextsFromFlags(cmd)
 	if err != nil {
 		goto finally
 	}
+	if contexts != nil {
+		if cmd.Flags().Changed("in") {
+			err = errors.New("--in names a directory of the current context, it cannot be used with other contexts")
+			goto finally
+		}
+		out, err = newOutputPrinter()
+		if err != nil {
+			goto finally
+		}
+		if out.Structured() {
+			err = searchEachContextRecords(out, contexts, func(ctxName string) ([]output.Node, error) {
+				_, found, err := searchNodeNodes(ctxName, args[0], "")
+				return output.NewNodes(found), err
+			})
+			goto finally
+		}
+		searchEachContext(contexts, func(ctxName string) error {
+			return searchNodeInternal(ctxName, args[0], "")
+		})
+		return
+	}
 
-	if len(args) != 1 {
-		err = errors.New("this command accepts only 1 argument")
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
 		goto finally
 	}
 
-	err = searchNodeInternal(ctxName, args[0])
+	err = searchNodeInternal(ctxName, args[0], searchNodeIn)
 	if err != nil {
 		goto finally
 	}
@@ -48,35 +79,72 @@ finally:
 	}
 }
 
-func searchNodeInternal(ctxName, regexPattern string) error {
-	rw, err := tree.GetRW(ctxName)
+// searchNodeNodes returns the nodes below dir, the whole tree when dir is empty,
+// whose path matches regexPattern, and the node they were searched below. The
+// pattern is matched against the paths of the search index, and only the branches
+// of the tree leading to matches are walked.
+func searchNodeNodes(ctxName, regexPattern, dir string) (*ds.TreeNode, []*ds.TreeNode, error) {
+	re, err := regexp.Compile(regexPattern)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
+	rw, err := tree.GetIndexedRW(ctxName)
+	if err != nil {
+		return nil, nil, err
+	}
+	ix, err := rw.Index()
+	if err != nil {
+		return nil, nil, err
+	}
+	matches := []string{}
+	for p := range ix.Docs {
+		if p != file.RootsPath && re.MatchString(p) {
+			matches = append(matches, p)
+		}
+	}
+	sort.Strings(matches)
+
 	root, err := rw.Read()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	wd, err := os.Getwd()
+	if dir != "" {
+		wd, err := filesys.NewBasicResolver(defaultStore).Resolve(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		root, err = data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath(wd)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	found, err := data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodesByPaths(matches)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	return root, found, nil
+}
 
-	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
-	wdTrNode, err := drMg.FindTreeNodeByAbsPath(wd)
+// searchNodeInternal prints the nodes below dir, the whole tree when dir is empty,
+// matching regexPattern, dir being any path the resolver accepts.
+func searchNodeInternal(ctxName, regexPattern, dir string) error {
+	base, foundTreeNodes, err := searchNodeNodes(ctxName, regexPattern, dir)
 	if err != nil {
 		return err
 	}
-	wdDrMg := data.NewDirTreeManager(ds.NewTreeManager(wdTrNode))
+	re := regexp.MustCompile(regexPattern)
 
-	foundTreeNodes, err := wdDrMg.FindTreeNodesByRegex(regexPattern)
+	out, err := newOutputPrinter()
 	if err != nil {
 		return err
 	}
+	if out.Structured() {
+		return out.Print(output.NewNodes(foundTreeNodes), nil)
+	}
 
-	drMgFound, err := data.BuildCopyTree(wd, foundTreeNodes)
+	drMgFound, err := data.BuildCopyTreeFrom(base, foundTreeNodes)
 	if err != nil {
 		return err
 	}
@@ -90,12 +158,8 @@ func searchNodeInternal(ctxName, regexPattern string) error {
 		}
 
 		str := node.GetAbsPath()
-		match, err := regexp.MatchString(regexPattern, str)
-		if err != nil {
-			return "", err
-		}
-
-		str, err = utils.GetCurNodeFromAbsPath(str)
+		match := re.MatchString(str)
+		str, err := utils.GetCurNodeFromAbsPath(str)
 		if err != nil {
 			return "", err
 		}
@@ -108,7 +172,7 @@ func searchNodeInternal(ctxName, regexPattern string) error {
 	}
 	prCxt := file.NewNodeExtraInfoPrinter(getPrintStringFunc)
 
-	err = pr.TrPrintV2([]printer.PrintingContext{prCxt})
+	err = pr.TrPrintV2(nodePrintingContexts(out, prCxt))
 	if err != nil {
 		return err
 	}
@@ -121,13 +185,20 @@ var searchNodeCmd = &cobra.Command{
 	Use:   "searchNode",
 	Short: "Find any file/directory in the saved tree using regex",
 	Long: `Find any file/directory in the saved tree using regex. This prints the found nodes
-in a tree fashion.`,
-	Run:     searchNode,
-	Aliases: []string{"node"},
+in a tree fashion. With --all-contexts or --contexts a,b the whole tree of each context is searched and the
+results are printed by context. With --output json, ndjson, yaml or csv only the matching nodes are printed, as node records, with their context when several are searched. With --format every node of the tree is printed by a template
+of its node record.`,
+	Run:         searchNode,
+	Aliases:     []string{"node"},
+	Annotations: structuredOutput("node"),
 }
 
+var searchNodeIn string
+
 func init() {
 	searchCmd.AddCommand(searchNodeCmd)
+	searchNodeCmd.Flags().StringVar(&searchNodeIn, "in", ".", "directory to search below, any path form of \"resolve\"")
+	addSearchContextsFlags(searchNodeCmd)
 
 	// Here you will define your flags and configuration settings.
 
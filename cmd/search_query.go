@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/printer"
+	"github.com/heroku/self/MetaManager/internal/query"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/sirupsen/logrus"
+
+	"github.com/spf13/cobra"
+)
+
+func searchQuery(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+	var contexts []contextrepo.ContextEntry
+	var out *output.Printer
+
+	if len(args) == 0 {
+		err = errors.New("this command needs a query")
+		goto finally
+	}
+
+	contexts, err = searchContextsFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	if contexts != nil {
+		if cmd.Flags().Changed("in") {
+			err = errors.New("--in names a directory of the current context, it cannot be used with other contexts")
+			goto finally
+		}
+		_, err = query.Parse(strings.Join(args, " "))
+		if err != nil {
+			goto finally
+		}
+		out, err = newOutputPrinter()
+		if err != nil {
+			goto finally
+		}
+		if out.Structured() {
+			err = searchEachContextRecords(out, contexts, func(ctxName string) ([]output.Node, error) {
+				_, found, err := searchQueryNodes(ctxName, strings.Join(args, " "), "")
+				return output.NewNodes(found), err
+			})
+			goto finally
+		}
+		searchEachContext(contexts, func(ctxName string) error {
+			return searchQueryInternal(ctxName, strings.Join(args, " "), "", searchQueryFlat)
+		})
+		return
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	// Unquoted queries arrive split by the shell.
+	err = searchQueryInternal(ctxName, strings.Join(args, " "), searchQueryIn, searchQueryFlat)
+	if err != nil {
+		goto finally
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// searchQueryNodes returns the nodes below dir, the whole tree when dir is empty,
+// matching expr, and the node they were searched below. When expr requires tags, ids
+// or path elements, only the nodes the search index has under them are tested.
+func searchQueryNodes(ctxName, expr, dir string) (*ds.TreeNode, []*ds.TreeNode, error) {
+	q, err := query.Parse(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rw, err := tree.GetIndexedRW(ctxName)
+	if err != nil {
+		return nil, nil, err
+	}
+	ix, err := rw.Index()
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if dir != "" {
+		wd, err := filesys.NewBasicResolver(defaultStore).Resolve(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		root, err = data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath(wd)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	var found []*ds.TreeNode
+	if candidates, ok := q.Candidates(ix); ok {
+		logrus.Debugf("[search] the index narrows %q to %d nodes", expr, len(candidates))
+		found, err = drMg.FindTreeNodesByPaths(candidates)
+	} else {
+		found, err = drMg.FindTreeNodesByMatcher(q)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	found = slices.DeleteFunc(found, func(n *ds.TreeNode) bool {
+		info := n.Info.(file.NodeInformable)
+		return info.GetAbsPath() == file.RootsPath || !q.Match(info)
+	})
+	return root, found, nil
+}
+
+// searchQueryInternal prints the nodes below dir, the whole tree when dir is empty,
+// matching expr, as a tree or, with flat, one path per line.
+func searchQueryInternal(ctxName, expr, dir string, flat bool) error {
+	base, found, err := searchQueryNodes(ctxName, expr, dir)
+	if err != nil {
+		return err
+	}
+	out, err := newOutputPrinter()
+	if err != nil {
+		return err
+	}
+	if out.Structured() || (out.Template != nil && flat) {
+		return out.Print(output.NewNodes(found), nil)
+	}
+	if len(found) == 0 {
+		fmt.Println("No nodes match")
+		return nil
+	}
+
+	if flat {
+		for _, node := range found {
+			fmt.Println(node.Info.(file.NodeInformable).GetAbsPath())
+		}
+		return nil
+	}
+
+	matched := map[string]bool{}
+	for _, node := range found {
+		matched[node.Info.(file.NodeInformable).GetAbsPath()] = true
+	}
+	drMgFound, err := data.BuildCopyTreeFrom(base, found)
+	if err != nil {
+		return err
+	}
+
+	getPrintStringFunc := func(info any) (string, error) {
+		node, ok := info.(file.NodeInformable)
+		if !ok {
+			return "", errors.New("info not convertible to NodeInformable")
+		}
+		str, err := utils.GetCurNodeFromAbsPath(node.GetAbsPath())
+		if err != nil {
+			return "", err
+		}
+		if matched[node.GetAbsPath()] {
+			str = str + " [found]"
+		}
+		return str, nil
+	}
+	pr := printer.NewTreePrinterManager(drMgFound.TreeManager)
+	return pr.TrPrintV2(nodePrintingContexts(out, file.NewNodeExtraInfoPrinter(getPrintStringFunc)))
+}
+
+// searchQueryCmd represents the search query command
+var searchQueryCmd = &cobra.Command{
+	Use:   "query <query>",
+	Short: "Find nodes matching a query on tags, names, paths, size and age",
+	Long: `Find nodes matching a query and print them in a tree fashion, or one path per line with --flat.
+
+A query combines conditions with AND, OR, NOT and parentheses; conditions next to each other are ANDed:
+  ./MetaManager search query 'tag:acme AND (ext:pdf OR ext:docx) AND NOT tag:archived AND size>1MB AND modified<30d AND path~"reports/"'
+
+Fields:
+  tag, id, name, ext, path, kind, target, mimetype, vanished
+                            ":" compares, or matches a glob like name:*.md; "~" matches a regular expression; "!=" negates
+  size                      compared with = != < <= > >=, e.g. size>1.5MB (units B, KB, MB, GB, TB)
+  modified                  an age, modified<30d is less than 30 days ago (h, d, w, y), or a date, modified>=2025-01-31
+kind is file, dir or symlink; target, mimetype and vanished are the attributes of node records,
+e.g. target~"^/mnt/", mimetype:image/*, vanished:true.
+A lone word matches names containing it. Size and modification time are those of the last scan.
+
+With --all-contexts or --contexts a,b the whole tree of each context is searched and the
+results are printed by context.
+
+With --output json, ndjson, yaml or csv the matching nodes are printed as node records, with
+their context when several are searched. With --format the nodes of the tree, or the matching
+nodes with --flat, are printed by a template of their node record.`,
+	Run:         searchQuery,
+	Aliases:     []string{"q"},
+	Annotations: structuredOutput("node"),
+}
+
+var searchQueryIn string
+var searchQueryFlat bool
+
+func init() {
+	searchCmd.AddCommand(searchQueryCmd)
+	searchQueryCmd.Flags().StringVar(&searchQueryIn, "in", ".", "directory to search below, any path form of \"resolve\"")
+	searchQueryCmd.Flags().BoolVar(&searchQueryFlat, "flat", false, "print matching paths one per line instead of a tree")
+	addSearchContextsFlags(searchQueryCmd)
+}
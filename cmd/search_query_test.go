@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchQuery(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "acme",
+		Dirs: []*utils.MockDir{
+			{DirName: "reports", Files: []string{"q1.pdf", "q2.pdf", "notes.docx"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		q1 := filepath.Join(root, "reports", "q1.pdf")
+		require.NoError(t, os.WriteFile(q1, make([]byte, 2<<20), 0o644))
+
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		err := trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		require.NoError(t, tagAddInternal("default", []string{root + "/reports/*.pdf", "acme"}))
+		require.NoError(t, tagAddInternal("default", []string{root + "/reports/q2.pdf", "archived"}))
+
+		paths := func(nodes []*ds.TreeNode) []string {
+			found := []string{}
+			for _, n := range nodes {
+				found = append(found, n.Info.(file.NodeInformable).GetAbsPath())
+			}
+			return found
+		}
+		_, nodes, err := searchQueryNodes("default", `tag:acme AND NOT tag:archived AND size>1MB AND modified<1d AND path~"reports/"`, root)
+		require.NoError(t, err)
+		require.Equal(t, []string{q1}, paths(nodes))
+		_, nodes, err = searchQueryNodes("default", "ext:docx OR tag:archived", filepath.Join(root, "reports"))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{filepath.Join(root, "reports", "q2.pdf"), filepath.Join(root, "reports", "notes.docx")}, paths(nodes))
+		require.NoError(t, searchQueryInternal("default", "tag:acme", root, false))
+		require.NoError(t, searchQueryInternal("default", "tag:acme", root, true))
+
+		_, _, err = searchQueryNodes("default", "tag:acme AND", root)
+		require.ErrorContains(t, err, "expected a condition")
+
+		// A refresh records the new size.
+		require.NoError(t, os.WriteFile(q1, []byte("small"), 0o644))
+		_, err = refreshInternal(context.Background(), "default", "", data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		_, nodes, err = searchQueryNodes("default", "size>1MB", root)
+		require.NoError(t, err)
+		require.Empty(t, nodes)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/query"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/savedsearch"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// searchSaveInternal saves expr as name in ctxName, replacing an earlier search of
+// that name.
+func searchSaveInternal(ctxName, name, expr string) error {
+	if !savedsearch.ValidName(name) {
+		return fmt.Errorf("invalid search name %q: use letters, digits, \".\", \"-\" and \"_\"", name)
+	}
+	_, err := query.Parse(expr)
+	if err != nil {
+		return err
+	}
+	searches, err := savedsearch.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	searches.Queries[name] = expr
+	return savedsearch.Save(ctxName, searches)
+}
+
+// searchRmInternal forgets the saved search name of ctxName, and leaves its virtual
+// directory if "cd" went into it.
+func searchRmInternal(ctxName, name string) error {
+	searches, err := savedsearch.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	_, err = searches.Query(name)
+	if err != nil {
+		return err
+	}
+	delete(searches.Queries, name)
+	if searches.Cwd == savedsearch.Dir(name) {
+		searches.Cwd = savedsearch.DirPrefix
+	}
+	return savedsearch.Save(ctxName, searches)
+}
+
+// searchRunInternal prints the nodes matching the saved search name like "search query"
+// does.
+func searchRunInternal(ctxName, name, dir string, flat bool) error {
+	searches, err := savedsearch.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	expr, err := searches.Query(name)
+	if err != nil {
+		return err
+	}
+	return searchQueryInternal(ctxName, expr, dir, flat)
+}
+
+// savedSearchLsInternal lists the virtual directory dir: the saved searches for
+// savedsearch.DirPrefix, else the nodes matching the saved search, in the format of
+// the context-aware "ls", or as a table with listing.Long.
+func savedSearchLsInternal(ctxName, dir string, listing longListing) error {
+	name, _ := savedsearch.SplitDir(dir)
+	searches, err := savedsearch.Load(ctxName)
+	if err != nil {
+		return err
+	}
+
+	entries := []output.Entry{}
+	rows := []output.Row{}
+	if name == "" {
+		for _, n := range searches.Names() {
+			entry := output.Entry{Name: n, Path: savedsearch.Dir(n), Kind: output.KindDir}
+			entries = append(entries, entry)
+			rows = append(rows, output.EntryRow(entry))
+		}
+	} else {
+		expr, err := searches.Query(name)
+		if err != nil {
+			return err
+		}
+		_, found, err := searchQueryNodes(ctxName, expr, "")
+		if err != nil {
+			return err
+		}
+		for _, node := range found {
+			entry := output.NewNodeEntry(node)
+			// Matches are listed by path, they come from anywhere in the tree
+			entry.Name = entry.Path
+			entries = append(entries, entry)
+			rows = append(rows, output.NodeRow(entry.Path, output.NewNode(node)))
+		}
+	}
+	if listing.Long {
+		return listing.printRows(rows)
+	}
+
+	out, err := newOutputPrinter()
+	if err != nil {
+		return err
+	}
+	return out.Print(entries, func(w io.Writer) error {
+		return output.WriteListing(w, savedsearch.Dir(name), entries)
+	})
+}
+
+// savedSearchCdInternal changes the virtual directory of ctxName for "cd target" and
+// returns the new one, "" when ".." left the virtual directories. It returns ok false,
+// having left them, when target is outside of them and the backend of the context has
+// to change directory.
+func savedSearchCdInternal(ctxName, target string) (cwd string, ok bool, err error) {
+	searches, err := savedsearch.Load(ctxName)
+	if err != nil {
+		return "", false, err
+	}
+	cur, inside := savedsearch.SplitDir(searches.Cwd)
+
+	name, isDir := savedsearch.SplitDir(target)
+	switch {
+	case isDir:
+	case inside && target == "..":
+		if cur == "" {
+			searches.Cwd = ""
+			return "", true, savedsearch.Save(ctxName, searches)
+		}
+		name = ""
+	case inside && cur == "" && !strings.Contains(target, "/"):
+		// A saved search is a subdirectory of savedsearch.DirPrefix.
+		name = target
+	default:
+		if inside {
+			searches.Cwd = ""
+			err = savedsearch.Save(ctxName, searches)
+		}
+		return "", false, err
+	}
+
+	if name != "" {
+		_, err = searches.Query(name)
+		if err != nil {
+			return "", false, err
+		}
+	}
+	searches.Cwd = savedsearch.Dir(name)
+	return searches.Cwd, true, savedsearch.Save(ctxName, searches)
+}
+
+// savedSearchCwd returns the virtual directory "cd" went into in the current context,
+// "" when outside of them or without a context.
+func savedSearchCwd() (string, error) {
+	ctxName, err := GetContext()
+	if err != nil || ctxName == "" {
+		return "", err
+	}
+	searches, err := savedsearch.Load(ctxName)
+	if err != nil {
+		return "", err
+	}
+	return searches.Cwd, nil
+}
+
+// runSavedSearchLs lists a virtual directory for the context-aware "ls", either the
+// one given or the one "cd" went into. It returns false when neither applies.
+func runSavedSearchLs(args []string, listing longListing) (bool, error) {
+	dir, err := savedSearchCwd()
+	if err != nil {
+		return false, err
+	}
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if _, ok := savedsearch.SplitDir(dir); !ok {
+		return false, nil
+	}
+	ctxName, err := getContextRequired()
+	if err != nil {
+		return false, err
+	}
+	return true, savedSearchLsInternal(ctxName, dir, listing)
+}
+
+// runSavedSearchCd runs the context-aware "cd" when it enters, moves within or leaves
+// the virtual directories. It returns false with the arguments left for the backend
+// when the backend has to change directory, or print it after leaving them.
+func runSavedSearchCd(args []string) (bool, []string, error) {
+	cur, err := savedSearchCwd()
+	if err != nil {
+		return false, nil, err
+	}
+	if len(args) == 0 {
+		if cur == "" {
+			return false, args, nil
+		}
+		fmt.Println(cur)
+		return true, nil, nil
+	}
+	if _, ok := savedsearch.SplitDir(args[0]); !ok && cur == "" {
+		return false, args, nil
+	}
+	ctxName, err := getContextRequired()
+	if err != nil {
+		return false, nil, err
+	}
+	cwd, ok, err := savedSearchCdInternal(ctxName, args[0])
+	if err != nil {
+		return false, nil, err
+	}
+	if !ok {
+		return false, args, nil
+	}
+	if cwd == "" {
+		// Back in the backend's directory, which only gdrive and webdav keep.
+		typ, err := GetContextType(ctxName)
+		if err != nil {
+			return false, nil, err
+		}
+		return typ != contextrepo.TypeGDrive && typ != filesys.TypeWebDAV, nil, nil
+	}
+	fmt.Println(cwd)
+	return true, nil, nil
+}
+
+// runSavedSearchPwd prints the virtual directory "cd" went into, returning false when
+// outside of them.
+func runSavedSearchPwd() (bool, error) {
+	cur, err := savedSearchCwd()
+	if err != nil || cur == "" {
+		return false, err
+	}
+	fmt.Println(cur)
+	return true, nil
+}
+
+func searchSave(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+
+	if len(args) < 2 {
+		err = errors.New("this command needs a name and a query")
+		goto finally
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	// Unquoted queries arrive split by the shell.
+	err = searchSaveInternal(ctxName, args[0], strings.Join(args[1:], " "))
+	if err != nil {
+		goto finally
+	}
+	fmt.Printf("Saved search %q, browse it with \"ls %s\"\n", args[0], savedsearch.Dir(args[0]))
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func searchRun(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+
+	if len(args) != 1 {
+		err = errors.New("this command needs the name of a saved search")
+		goto finally
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	err = searchRunInternal(ctxName, args[0], searchRunIn, searchRunFlat)
+	if err != nil {
+		goto finally
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func searchList(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+	var searches *savedsearch.Searches
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	searches, err = savedsearch.Load(ctxName)
+	if err != nil {
+		goto finally
+	}
+	if len(searches.Queries) == 0 {
+		fmt.Println("No saved searches; use \"search save <name> <query>\"")
+		return
+	}
+	for _, name := range searches.Names() {
+		fmt.Printf("%s\t%s\n", name, searches.Queries[name])
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func searchRm(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+
+	if len(args) != 1 {
+		err = errors.New("this command needs the name of a saved search")
+		goto finally
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	err = searchRmInternal(ctxName, args[0])
+	if err != nil {
+		goto finally
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// searchSaveCmd represents the search save command
+var searchSaveCmd = &cobra.Command{
+	Use:   "save <name> <query>",
+	Short: "Save a query under a name",
+	Long: `Save a query of "search query" under a name, replacing an earlier one of that name:
+  ./MetaManager search save unpaid 'name:*invoice* AND tag:unpaid'
+
+Saved searches belong to the current context. Run one with "search run <name>", or
+browse it as a directory with "ls @search:<name>" and "cd @search:<name>"; "ls @search:"
+lists them all.`,
+	Run: searchSave,
+}
+
+// searchRunCmd represents the search run command
+var searchRunCmd = &cobra.Command{
+	Use:         "run <name>",
+	Short:       "Run a saved search",
+	Long:        `Run a query saved with "search save" over the whole tree, or below --in, and print the matching nodes like "search query" does, --output and --format included.`,
+	Run:         searchRun,
+	Annotations: structuredOutput("node"),
+}
+
+// searchListCmd represents the search list command
+var searchListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the saved searches of the current context",
+	Run:     searchList,
+	Aliases: []string{"ls"},
+}
+
+// searchRmCmd represents the search rm command
+var searchRmCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Short:   "Forget a saved search",
+	Run:     searchRm,
+	Aliases: []string{"remove"},
+}
+
+var searchRunIn string
+var searchRunFlat bool
+
+func init() {
+	searchCmd.AddCommand(searchSaveCmd)
+	searchCmd.AddCommand(searchRunCmd)
+	searchCmd.AddCommand(searchListCmd)
+	searchCmd.AddCommand(searchRmCmd)
+	searchRunCmd.Flags().StringVar(&searchRunIn, "in", "", "directory to search below, any path form of \"resolve\"; the whole tree by default")
+	searchRunCmd.Flags().BoolVar(&searchRunFlat, "flat", false, "print matching paths one per line instead of a tree")
+}
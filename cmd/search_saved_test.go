@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/savedsearch"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedSearch(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "bills",
+		Files:   []string{"invoice-1.pdf", "invoice-2.pdf", "receipt.pdf"},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		err := trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		require.NoError(t, tagAddInternal("default", []string{root + "/invoice-1.pdf", "unpaid"}))
+
+		require.NoError(t, searchSaveInternal("default", "unpaid", "name:invoice* AND tag:unpaid"))
+		require.ErrorContains(t, searchSaveInternal("default", "bad/name", "tag:x"), "invalid search name")
+		require.ErrorContains(t, searchSaveInternal("default", "broken", "tag:x AND"), "expected a condition")
+
+		searches, err := savedsearch.Load("default")
+		require.NoError(t, err)
+		require.Equal(t, []string{"unpaid"}, searches.Names())
+
+		require.NoError(t, searchRunInternal("default", "unpaid", "", true))
+		require.ErrorContains(t, searchRunInternal("default", "missing", "", true), "no saved search")
+
+		_, found, err := searchQueryNodes("default", searches.Queries["unpaid"], "")
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+
+		require.NoError(t, savedSearchLsInternal("default", "@search:", longListing{}))
+		require.NoError(t, savedSearchLsInternal("default", "@search:unpaid", longListing{}))
+		require.Error(t, savedSearchLsInternal("default", "@search:missing", longListing{}))
+
+		// cd goes into a saved search, up to @search: and back out.
+		cwd, ok, err := savedSearchCdInternal("default", "@search:")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "@search:", cwd)
+		cwd, ok, err = savedSearchCdInternal("default", "unpaid")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "@search:unpaid", cwd)
+		cur, err := savedSearchCwd()
+		require.NoError(t, err)
+		require.Equal(t, "@search:unpaid", cur)
+		_, _, err = savedSearchCdInternal("default", "@search:missing")
+		require.Error(t, err)
+		cwd, _, err = savedSearchCdInternal("default", "..")
+		require.NoError(t, err)
+		require.Equal(t, "@search:", cwd)
+		cwd, ok, err = savedSearchCdInternal("default", "..")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Empty(t, cwd)
+
+		// Any other directory is left to the backend.
+		_, _, err = savedSearchCdInternal("default", "@search:unpaid")
+		require.NoError(t, err)
+		_, ok, err = savedSearchCdInternal("default", "/elsewhere")
+		require.NoError(t, err)
+		require.False(t, ok)
+		cur, err = savedSearchCwd()
+		require.NoError(t, err)
+		require.Empty(t, cur)
+
+		require.NoError(t, searchRmInternal("default", "unpaid"))
+		require.Error(t, searchRmInternal("default", "unpaid"))
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
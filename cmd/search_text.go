@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/jedib0t/go-pretty/v6/list"
+	"github.com/spf13/cobra"
+)
+
+// searchTextInternal returns the paths of the nodes matching every word, from the
+// index.
+func searchTextInternal(ctxName string, words []string) ([]string, error) {
+	rw, err := tree.GetIndexedRW(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := rw.Index()
+	if err != nil {
+		return nil, err
+	}
+	return ix.Search(words), nil
+}
+
+func searchText(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName string
+	var contexts []contextrepo.ContextEntry
+
+	if len(args) == 0 {
+		err = errors.New("this command needs at least one word")
+		goto finally
+	}
+
+	contexts, err = searchContextsFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	if contexts != nil {
+		searchEachContext(contexts, func(ctxName string) error {
+			return searchTextPrintInternal(ctxName, args)
+		})
+		return
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	err = searchTextPrintInternal(ctxName, args)
+	if err != nil {
+		goto finally
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// searchTextPrintInternal prints the paths of the nodes of ctxName matching words.
+func searchTextPrintInternal(ctxName string, words []string) error {
+	paths, err := searchTextInternal(ctxName, words)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fmt.Println("No nodes match")
+		return nil
+	}
+
+	pr := list.NewWriter()
+	for _, path := range paths {
+		pr.AppendItem(path)
+	}
+	pr.SetStyle(list.StyleDefault)
+	fmt.Println(pr.Render())
+	return nil
+}
+
+// searchTextCmd represents the search text command
+var searchTextCmd = &cobra.Command{
+	Use:   "text <words...>",
+	Short: "Find nodes by words of their tags, id or path",
+	Long: `Find the nodes matching every word given, a word matching a tag, a word of the id
+or an element of the path. Tags are case sensitive, the rest is not.
+Answers come from the search index (see "index"). With --all-contexts or --contexts a,b
+each of those contexts is searched and the results are printed by context.`,
+	Run:     searchText,
+	Aliases: []string{"t"},
+}
+
+func init() {
+	searchCmd.AddCommand(searchTextCmd)
+	addSearchContextsFlags(searchTextCmd)
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/services"
+	"github.com/spf13/cobra"
+)
+
+var sftpCmd = &cobra.Command{
+	Use:   "sftp",
+	Short: "SFTP commands (requires an sftp context)",
+	Long: `Commands for the SSH servers of the current context. Create one with "context create <name> --type sftp".
+Paths look like sftp://host/dir or sftp://user@host:port/dir. Hosts must be in known_hosts,
+authentication uses the SSH agent and the keys of ~/.ssh unless configured otherwise.`,
+}
+
+var sftpConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show or change how the current context authenticates",
+	Long:  `Without flags, prints the SSH settings of the current context. Flags change the given settings and keep the others.`,
+	Args:  cobra.NoArgs,
+	RunE:  runSFTPConfig,
+}
+
+var sftpLsCmd = &cobra.Command{
+	Use:   "ls <sftp://host/dir>",
+	Short: "List a remote directory",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSFTPLs,
+}
+
+func init() {
+	RootCmd.AddCommand(sftpCmd)
+	sftpCmd.AddCommand(sftpConfigCmd)
+	sftpCmd.AddCommand(sftpLsCmd)
+	sftpConfigCmd.Flags().String("user", "", "user for hosts given without one (default: the local user)")
+	sftpConfigCmd.Flags().StringSlice("identity", nil, "private key files to authenticate with (default: ~/.ssh/id_ed25519, id_ecdsa, id_rsa)")
+	sftpConfigCmd.Flags().String("known-hosts", "", "known_hosts file with the accepted host keys (default: ~/.ssh/known_hosts)")
+	sftpConfigCmd.Flags().Bool("no-agent", false, "do not use the SSH agent of SSH_AUTH_SOCK")
+}
+
+// requireSFTPContext returns the name of the current context, which must be an sftp one.
+func requireSFTPContext(cmdName string) (string, error) {
+	name, err := GetContext()
+	if err != nil {
+		return "", err
+	}
+	typ, err := GetContextType(name)
+	if err != nil {
+		return "", err
+	}
+	if typ != filesys.TypeSFTP {
+		return "", fmt.Errorf("sftp %s requires an sftp context; use \"context set <name>\" with an sftp context", cmdName)
+	}
+	return name, nil
+}
+
+// sftpConfigInternal changes the settings of ctxName with the flags of cmd that were set.
+func sftpConfigInternal(cmd *cobra.Command, ctxName string) (*services.SFTPSettings, error) {
+	settings, err := services.LoadSFTPSettings(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	flags := cmd.Flags()
+	if flags.NFlag() == 0 {
+		return settings, nil
+	}
+	if flags.Changed("user") {
+		settings.User, err = flags.GetString("user")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if flags.Changed("identity") {
+		settings.IdentityFiles, err = flags.GetStringSlice("identity")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if flags.Changed("known-hosts") {
+		settings.KnownHostsFile, err = flags.GetString("known-hosts")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if flags.Changed("no-agent") {
+		settings.NoAgent, err = flags.GetBool("no-agent")
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = services.SaveSFTPSettings(ctxName, settings)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func runSFTPConfig(cmd *cobra.Command, args []string) error {
+	ctxName, err := requireSFTPContext("config")
+	if err != nil {
+		return err
+	}
+	settings, err := sftpConfigInternal(cmd, ctxName)
+	if err != nil {
+		return err
+	}
+	orDefault := func(s, def string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	}
+	fmt.Printf("user:        %s\n", orDefault(settings.User, "(local user)"))
+	fmt.Printf("identity:    %s\n", orDefault(strings.Join(settings.IdentityFiles, ", "), "(default keys of ~/.ssh)"))
+	fmt.Printf("known hosts: %s\n", orDefault(settings.KnownHostsFile, "~/.ssh/known_hosts"))
+	fmt.Printf("ssh agent:   %v\n", !settings.NoAgent)
+	return nil
+}
+
+func runSFTPLs(cmd *cobra.Command, args []string) error {
+	ctxName, err := requireSFTPContext("ls")
+	if err != nil {
+		return err
+	}
+	target, err := filesys.SFTPResolver{}.Resolve(args[0])
+	if err != nil {
+		return err
+	}
+	host, remotePath, ok := file.SplitSFTPPath(target)
+	if !ok {
+		return fmt.Errorf("give a host to list, like sftp://host/dir")
+	}
+	svc, err := services.GetSFTPService(ctxName)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+	entries, err := svc.ListAtPath(context.Background(), host, remotePath)
+	if err != nil {
+		return fmt.Errorf("list %q: %w", target, err)
+	}
+
+	fmt.Println(target)
+	fmt.Println("---")
+	for _, e := range entries {
+		if e.IsFolder {
+			fmt.Printf("  %s/\n", e.Name)
+		} else {
+			fmt.Printf("  %s\n", e.Name)
+		}
+	}
+	if len(entries) == 0 {
+		fmt.Println("  (empty)")
+	}
+	return nil
+}
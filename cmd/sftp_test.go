@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/services/sftptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSFTPCmd(t *testing.T) {
+	const sftpCtxName = "sftp-test"
+	dir := t.TempDir()
+	os.Setenv("MM_TEST_CONTEXT_DIR", dir)
+	os.Setenv("MM_CONTEXT", sftpCtxName)
+	defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+	defer os.Unsetenv("MM_CONTEXT")
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	srv := sftptest.NewServer(t)
+	remote := filepath.Join(dir, "datasets")
+	require.NoError(t, os.MkdirAll(filepath.Join(remote, "run1", "raw"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "run1", "raw", "b.csv"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "run1", "a.csv"), nil, 0644))
+
+	require.NoError(t, defaultStore.Create(sftpCtxName, filesyspkg.TypeSFTP))
+	require.NoError(t, EnsureAppDataDir(sftpCtxName))
+	require.NoError(t, sftpConfigCmd.Flags().Set("identity", srv.IdentityFile))
+	require.NoError(t, sftpConfigCmd.Flags().Set("known-hosts", srv.KnownHostsFile))
+	_, err := sftpConfigInternal(sftpConfigCmd, sftpCtxName)
+	require.NoError(t, err)
+
+	base := file.JoinSFTPPath(srv.Addr, remote)
+	require.NoError(t, runSFTPLs(sftpLsCmd, []string{base}))
+	err = trackInternal(context.Background(), sftpCtxName, base+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+	require.NoError(t, err)
+
+	// Remote nodes take tags and ids like local ones.
+	aCsv := base + "/run1/a.csv"
+	require.NoError(t, tagAddInternal(sftpCtxName, []string{aCsv, "dataset"}))
+	require.NoError(t, idSetInternal(sftpCtxName, aCsv, "run1-a"))
+	require.NoError(t, idJumpInternal(sftpCtxName, "run1-a"))
+
+	findRemote := func(p string) *ds.TreeNode {
+		rw, err := tree.GetRW(sftpCtxName)
+		require.NoError(t, err)
+		root, err := rw.Read()
+		require.NoError(t, err)
+		require.Equal(t, file.SFTPPathPrefix, root.Info.(file.NodeInformable).GetAbsPath())
+		node, err := data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath(p)
+		require.NoError(t, err)
+		return node
+	}
+	node := findRemote(aCsv)
+	require.Equal(t, []string{"dataset"}, node.Info.(file.NodeInformable).GetTags())
+	require.Equal(t, "run1-a", node.Info.(file.NodeInformable).GetId())
+	// The host is a node of its own, right below the root.
+	findRemote(file.JoinSFTPPath(srv.Addr, "/"))
+
+	// Refresh follows removals on the host.
+	require.NoError(t, os.Remove(filepath.Join(remote, "run1", "raw", "b.csv")))
+	_, err = refreshInternal(context.Background(), sftpCtxName, "", data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+	require.NoError(t, err)
+	node = findRemote(base + "/run1/raw/b.csv")
+	require.True(t, node.Info.(file.Vanishable).IsVanished())
+	node = findRemote(aCsv)
+	require.Equal(t, "run1-a", node.Info.(file.NodeInformable).GetId())
+}
@@ -5,17 +5,22 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"runtime/debug"
+	"slices"
+	"strings"
 
 	"github.com/heroku/self/MetaManager/internal/cmderror"
 	"github.com/heroku/self/MetaManager/internal/data"
 	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
 	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/index"
+	"github.com/heroku/self/MetaManager/internal/output"
 	"github.com/heroku/self/MetaManager/internal/printer"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
 	"github.com/heroku/self/MetaManager/internal/repository/tree"
 	"github.com/heroku/self/MetaManager/internal/utils"
-	"github.com/jedib0t/go-pretty/v6/list"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -36,11 +41,15 @@ func init() {
 	tagCmd.AddCommand(searchTagCmd)
 	tagCmd.AddCommand(tagListCmd)
 
+	tagAddCmd.Flags().Bool("dry-run", false, "list the tracked nodes the path or glob matches without tagging them")
+	tagDeleteCmd.Flags().Bool("dry-run", false, "list the tracked nodes the path or glob matches without changing them")
+
 	// Register flags for searchTag command
 	searchTagCmd.Flags().BoolP("tree", "t", false, "Output results in tree format")
+	addSearchContextsFlags(searchTagCmd)
 }
 
-// tagAddInternal adds a tag to a file/directory
+// tagAddInternal adds a tag to a file/directory, or to every tracked node a glob matches
 func tagAddInternal(ctxName string, args []string) error {
 	rw, err := tree.GetRW(ctxName)
 	if err != nil {
@@ -52,19 +61,21 @@ func tagAddInternal(ctxName string, args []string) error {
 		return err
 	}
 
-	tgMg := data.NewTagManager(data.NewDirTreeManager(ds.NewTreeManager(root)))
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	tgMg := data.NewTagManager(drMg)
 
-	resolver := filesys.NewBasicResolver(defaultStore)
-	tagFilePath, err := resolver.Resolve(args[0])
+	tagFilePaths, err := resolveTargets(drMg, args[0])
 	if err != nil {
 		return err
 	}
 
 	tag := args[1]
 
-	err = tgMg.AddTag(tagFilePath, tag)
-	if err != nil {
-		return err
+	for _, tagFilePath := range tagFilePaths {
+		err = tgMg.AddTag(tagFilePath, tag)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = tgMg.Save(rw)
@@ -72,12 +83,13 @@ func tagAddInternal(ctxName string, args []string) error {
 		return err
 	}
 
-	return nil
+	return xattrAutoPush(ctxName, drMg, tagFilePaths, nil)
 }
 
 func tagAdd(cmd *cobra.Command, args []string) {
 	var err error
 	var ctxName string
+	var dryRun bool
 
 	if len(args) != 2 {
 		err = &cmderror.InvalidNumberOfArguments{}
@@ -93,6 +105,14 @@ func tagAdd(cmd *cobra.Command, args []string) {
 		goto finally
 	}
 
+	dryRun, err = dryRunTargets(cmd, ctxName, args[0], false)
+	if err != nil {
+		goto finally
+	}
+	if dryRun {
+		return
+	}
+
 	err = tagAddInternal(ctxName, args)
 	if err != nil {
 		goto finally
@@ -108,14 +128,15 @@ finally:
 
 // tagAddCmd represents the tagAdd command
 var tagAddCmd = &cobra.Command{
-	Use:     "tagAdd",
-	Short:   "Adds tag to a file/dir",
-	Long:    `Adds tag to a file/dir`,
+	Use:   "tagAdd",
+	Short: "Adds tag to a file/dir",
+	Long: `Adds tag to a file/dir. A glob tags every tracked node it matches,
+e.g. tag add "src/**/*_test.go" tests; --dry-run lists them without tagging.`,
 	Run:     tagAdd,
 	Aliases: []string{"add"},
 }
 
-// tagDeleteInternal deletes a tag from a file/directory
+// tagDeleteInternal deletes a tag from a file/directory, or from every tracked node a glob matches
 func tagDeleteInternal(ctxName, path, tag string) error {
 	rw, err := tree.GetRW(ctxName)
 	if err != nil {
@@ -127,20 +148,27 @@ func tagDeleteInternal(ctxName, path, tag string) error {
 		return err
 	}
 
-	tgMg := data.NewTagManager(data.NewDirTreeManager(ds.NewTreeManager(root)))
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	tgMg := data.NewTagManager(drMg)
 
-	resolver := filesys.NewBasicResolver(defaultStore)
-	absPath, err := resolver.Resolve(path)
+	absPaths, err := resolveTargets(drMg, path)
 	if err != nil {
 		return err
 	}
 
-	err = tgMg.DeleteTag(absPath, tag)
+	for _, absPath := range absPaths {
+		err = tgMg.DeleteTag(absPath, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rw.Write(root)
 	if err != nil {
 		return err
 	}
 
-	err = rw.Write(root)
+	err = xattrAutoPush(ctxName, drMg, absPaths, []string{tag})
 	if err != nil {
 		return err
 	}
@@ -153,6 +181,7 @@ func tagDeleteInternal(ctxName, path, tag string) error {
 func tagDelete(cmd *cobra.Command, args []string) {
 	var err error
 	var ctxName string
+	var dryRun bool
 
 	if len(args) != 2 {
 		err = &cmderror.InvalidNumberOfArguments{}
@@ -168,6 +197,14 @@ func tagDelete(cmd *cobra.Command, args []string) {
 		goto finally
 	}
 
+	dryRun, err = dryRunTargets(cmd, ctxName, args[0], false)
+	if err != nil {
+		goto finally
+	}
+	if dryRun {
+		return
+	}
+
 	err = tagDeleteInternal(ctxName, args[0], args[1])
 	if err != nil {
 		goto finally
@@ -183,45 +220,64 @@ finally:
 var tagDeleteCmd = &cobra.Command{
 	Use:     "tagDelete",
 	Short:   "Deletes tag from a node (i.e., file/dir)",
-	Long:    "Deletes tag from a node (i.e., file/dir)",
+	Long:    "Deletes tag from a node (i.e., file/dir), or from every tracked node a glob matches.",
 	Aliases: []string{"delete"},
 	Run:     tagDelete,
 }
 
-// tagGetInternal gets all files/directories with a particular tag
+// tagSearchInternal gets all files/directories with a particular tag, from the index
 func tagSearchInternal(ctxName, tag string) ([]string, error) {
-	rw, err := tree.GetRW(ctxName)
+	rw, err := tree.GetIndexedRW(ctxName)
 	if err != nil {
 		return nil, err
 	}
 
-	root, err := rw.Read()
+	ix, err := rw.Index()
 	if err != nil {
 		return nil, err
 	}
 
-	tgMg := data.NewTagManager(data.NewDirTreeManager(ds.NewTreeManager(root)))
-
-	paths, err := tgMg.GetTaggedNodes(tag)
-	if err != nil {
-		return nil, err
-	}
-
-	return paths, nil
+	return slices.Clone(ix.Lookup(index.Term(index.KindTag, tag))), nil
 }
 
 func tagSearch(cmd *cobra.Command, args []string) {
 	var err error
-	var paths []string
-	var pr list.Writer
 	var ctxName string
 	var treeFlag bool
+	var contexts []contextrepo.ContextEntry
+	var out *output.Printer
 
 	if len(args) != 1 {
 		err = &cmderror.InvalidNumberOfArguments{}
 		goto finally
 	}
 
+	treeFlag, err = cmd.Flags().GetBool("tree")
+	if err != nil {
+		goto finally
+	}
+
+	contexts, err = searchContextsFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	out, err = newOutputPrinter()
+	if err != nil {
+		goto finally
+	}
+	if contexts != nil && out.Structured() {
+		err = searchEachContextRecords(out, contexts, func(ctxName string) ([]output.Node, error) {
+			return tagSearchRecords(ctxName, args[0])
+		})
+		goto finally
+	}
+	if contexts != nil {
+		searchEachContext(contexts, func(ctxName string) error {
+			return tagSearchPrintInternal(ctxName, args[0], treeFlag)
+		})
+		return
+	}
+
 	ctxName, err = getContextRequired()
 	if err != nil {
 		goto finally
@@ -231,37 +287,77 @@ func tagSearch(cmd *cobra.Command, args []string) {
 		goto finally
 	}
 
-	treeFlag, err = cmd.Flags().GetBool("tree")
+	err = tagSearchPrintInternal(ctxName, args[0], treeFlag)
 	if err != nil {
 		goto finally
 	}
+finally:
+	if err != nil {
+		fmt.Println(err)
+		// Print stack trace in case of error
+		debug.PrintStack()
+	}
+}
 
-	paths, err = tagSearchInternal(ctxName, args[0])
+// tagSearchPrintInternal prints the nodes of ctxName tagged with tag as a list or, with
+// treeFlag, in tree format.
+func tagSearchPrintInternal(ctxName, tag string, treeFlag bool) error {
+	out, err := newOutputPrinter()
 	if err != nil {
-		goto finally
+		return err
+	}
+	if out.Structured() || (out.Template != nil && !treeFlag) {
+		records, err := tagSearchRecords(ctxName, tag)
+		if err != nil {
+			return err
+		}
+		return out.Print(records, nil)
+	}
+
+	paths, err := tagSearchInternal(ctxName, tag)
+	if err != nil {
+		return err
 	}
 
 	if treeFlag {
 		logrus.Debugf("[tagSearch] treeFlag is true")
-		err = tagSearchTreeInternal(ctxName, args[0], paths)
-		if err != nil {
-			goto finally
-		}
-		return
+		return tagSearchTreeInternal(ctxName, tag, paths)
 	}
 
-	pr = list.NewWriter()
-	for _, path := range paths {
-		pr.AppendItem(path)
+	return output.WriteList(os.Stdout, paths)
+}
+
+// tagSearchRecords returns the records of the nodes of ctxName tagged with tag, in path
+// order. The tagged paths are looked up in the index and only they are resolved in the
+// tree, which is not read when no node has the tag.
+func tagSearchRecords(ctxName, tag string) ([]output.Node, error) {
+	rw, err := tree.GetIndexedRW(ctxName)
+	if err != nil {
+		return nil, err
 	}
-	pr.SetStyle(list.StyleDefault)
-	fmt.Println(pr.Render())
-finally:
+	ix, err := rw.Index()
 	if err != nil {
-		fmt.Println(err)
-		// Print stack trace in case of error
-		debug.PrintStack()
+		return nil, err
+	}
+	paths := ix.Lookup(index.Term(index.KindTag, tag))
+	records := []output.Node{}
+	if len(paths) == 0 {
+		return records, nil
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	// Paths the index has but the tree no longer does are left out.
+	found, err := data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodesByPaths(paths)
+	if err != nil {
+		return nil, err
 	}
+	for _, node := range found {
+		records = append(records, output.NewNode(node))
+	}
+	slices.SortFunc(records, func(a, b output.Node) int { return strings.Compare(a.Path, b.Path) })
+	return records, nil
 }
 
 // tagSearchTreeInternal prints tagged nodes in tree format
@@ -303,14 +399,22 @@ func tagSearchTreeInternal(ctxName, tag string, paths []string) error {
 	}
 
 	// Build a tree from the found nodes
-	drMgFound, err := data.BuildCopyTree(rootPath, treeNodes)
+	drMgFound, err := data.BuildCopyTreeFrom(root, treeNodes)
 	if err != nil {
 		return err
 	}
 
 	// Print the tree
+	out, err := newOutputPrinter()
+	if err != nil {
+		return err
+	}
 	pr := printer.NewTreePrinterManager(drMgFound.TreeManager)
-	err = pr.TrPrint([]string{"node"})
+	if out.Template != nil {
+		err = pr.TrPrintV2(nodePrintingContexts(out))
+	} else {
+		err = pr.TrPrint([]string{"node"})
+	}
 	if err != nil {
 		return err
 	}
@@ -320,11 +424,12 @@ func tagSearchTreeInternal(ctxName, tag string, paths []string) error {
 
 // searchTagCmd represents the searchTag command
 var searchTagCmd = &cobra.Command{
-	Use:     "searchTag",
-	Short:   "Gets files/dirs with a particular tag",
-	Long:    `Gets files/dirs with a particular tag. Use --tree flag to output results in tree format.`,
-	Run:     tagSearch,
-	Aliases: []string{"search"},
+	Use:         "searchTag",
+	Short:       "Gets files/dirs with a particular tag",
+	Long:        `Gets files/dirs with a particular tag. Use --tree flag to output results in tree format. With --all-contexts or --contexts a,b the tag is looked up in each of those contexts and the results are printed by context. With --output json, ndjson, yaml or csv the nodes are printed as node records, with their context when several are searched. With --format every node is printed by a template of its node record, e.g. --format '{{.Path}}\t{{.Id}}'.`,
+	Run:         tagSearch,
+	Aliases:     []string{"search"},
+	Annotations: structuredOutput("node"),
 }
 
 // tagGetInternal lists tags for a file/directory
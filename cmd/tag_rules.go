@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/rules"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// applyTagRules applies the tagging rules of ctxName to node and the nodes below it,
+// writing what each rule added to explain when it is non-nil.
+func applyTagRules(ctxName string, node *ds.TreeNode, explain io.Writer) ([]rules.Applied, error) {
+	set, err := rules.Load(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := set.Apply(node)
+	if explain != nil {
+		for _, a := range applied {
+			fmt.Fprintln(explain, a)
+		}
+	}
+	return applied, err
+}
+
+// explainFromFlags returns where --explain output goes, nil when it was not asked for.
+func explainFromFlags(cmd *cobra.Command) (io.Writer, error) {
+	explain, err := cmd.Flags().GetBool("explain")
+	if err != nil || !explain {
+		return nil, err
+	}
+	return os.Stdout, nil
+}
+
+// tagApplyRulesInternal applies the tagging rules of ctxName to the node at pathExp and
+// the nodes below it, the whole tree when pathExp is empty. With dryRun nothing is
+// saved.
+func tagApplyRulesInternal(ctxName, pathExp string, explain io.Writer, dryRun bool) ([]rules.Applied, error) {
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	node := root
+	if pathExp != "" {
+		absPath, err := filesys.NewBasicResolver(defaultStore).Resolve(pathExp)
+		if err != nil {
+			return nil, err
+		}
+		node, err = data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath(absPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	applied, err := applyTagRules(ctxName, node, explain)
+	if err != nil || dryRun || len(applied) == 0 {
+		return applied, err
+	}
+	return applied, rw.Write(root)
+}
+
+func tagApplyRules(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName, pathExp string
+	var explain io.Writer
+	var dryRun bool
+	var applied []rules.Applied
+
+	if len(args) > 1 {
+		err = &cmderror.InvalidNumberOfArguments{}
+		goto finally
+	}
+	if len(args) == 1 {
+		pathExp = args[0]
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	explain, err = explainFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	dryRun, err = cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		goto finally
+	}
+	if dryRun {
+		explain = os.Stdout
+	}
+
+	applied, err = tagApplyRulesInternal(ctxName, pathExp, explain, dryRun)
+	if err != nil {
+		goto finally
+	}
+	if dryRun {
+		fmt.Printf("Rules would add %d tags (dry run)\n", len(applied))
+	} else {
+		fmt.Printf("Rules added %d tags\n", len(applied))
+	}
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// tagApplyRulesCmd represents the tag apply-rules command
+var tagApplyRulesCmd = &cobra.Command{
+	Use:   "apply-rules [path]",
+	Short: "Tag nodes with the tagging rules of the current context",
+	Long: `Applies the tagging rules of the current context to the node at path and the nodes
+below it, or to the whole tree. track and refresh apply them to what they scan.
+
+Rules are kept in the context's rules.json, next to config.json:
+  {"Rules": [
+    {"Name": "invoices", "Path": "invoices/**/*.pdf", "Tags": ["finance"]},
+    {"Name": "large", "Query": "size>1GB", "Tags": ["large"]},
+    {"Name": "go", "Contains": ["go.mod"], "Tags": ["go-project"]},
+    {"Name": "docs", "DriveMime": "application/vnd.google-apps.document", "Tags": ["gdoc"]}
+  ]}
+
+A rule applies when all of its conditions hold:
+  Path       glob on the node path, relative globs match at any depth
+  Ext        extensions, e.g. ["jpg", "png"]
+  Query      a query of "search query", e.g. "size>1GB AND modified<30d"
+  Mime       MIME type pattern, e.g. "image/*", from Drive or else the extension
+  DriveMime  MIME type pattern Drive reported, Drive nodes only
+  Contains   names of entries a directory holds among its tracked ones
+  Attributes patterns on the attributes of the --output node records, vanished,
+             target or mimeType, e.g. {"target": "/mnt/*"}
+It adds its Tags to the nodes. Rules never remove anything.`,
+	Run: tagApplyRules,
+}
+
+func init() {
+	tagCmd.AddCommand(tagApplyRulesCmd)
+	tagApplyRulesCmd.Flags().Bool("explain", false, "print which rule added which tag")
+	tagApplyRulesCmd.Flags().Bool("dry-run", false, "print what the rules would add without saving it")
+}
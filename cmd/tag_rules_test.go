@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/rules"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagRules(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "home",
+		Dirs: []*utils.MockDir{
+			{DirName: "invoices", Files: []string{"march.pdf", "march.txt"}},
+			{DirName: "proj", Files: []string{"go.mod", "main.go"}},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		rulesPath, err := rules.Path("default")
+		require.NoError(t, err)
+		require.NoError(t, utils.WriteJSON(rulesPath, rules.File{Rules: []rules.Rule{
+			{Name: "invoices", Path: "invoices/*.pdf", Tags: []string{"finance"}},
+			{Name: "go", Contains: []string{"go.mod"}, Tags: []string{"go-project"}},
+		}}, true))
+
+		var explain bytes.Buffer
+		err = trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, &explain)
+		require.NoError(t, err)
+		require.Contains(t, explain.String(), filepath.Join(root, "invoices", "march.pdf")+`: +finance (rule "invoices")`)
+		require.Contains(t, explain.String(), filepath.Join(root, "proj")+`: +go-project (rule "go")`)
+
+		paths, err := tagSearchInternal("default", "finance")
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "invoices", "march.pdf")}, paths)
+
+		// A new rule is applied by apply-rules, and not saved on a dry run.
+		require.NoError(t, utils.WriteJSON(rulesPath, rules.File{Rules: []rules.Rule{
+			{Name: "text", Ext: []string{"txt"}, Tags: []string{"text"}},
+		}}, true))
+		applied, err := tagApplyRulesInternal("default", filepath.Join(root, "invoices"), nil, true)
+		require.NoError(t, err)
+		require.Len(t, applied, 1)
+		paths, err = tagSearchInternal("default", "text")
+		require.NoError(t, err)
+		require.Empty(t, paths)
+		applied, err = tagApplyRulesInternal("default", "", nil, false)
+		require.NoError(t, err)
+		require.Equal(t, []rules.Applied{{Path: filepath.Join(root, "invoices", "march.txt"), Rule: "text", Tag: "text"}}, applied)
+		paths, err = tagSearchInternal("default", "text")
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "invoices", "march.txt")}, paths)
+
+		// Refresh tags new files.
+		require.NoError(t, os.WriteFile(filepath.Join(root, "proj", "notes.txt"), nil, 0o644))
+		explain.Reset()
+		_, err = refreshInternal(context.Background(), "default", "", data.VanishedMark, filesyspkg.ScanOptions{}, &explain)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(root, "proj", "notes.txt")+": +text (rule \"text\")\n", explain.String())
+
+		require.NoError(t, os.WriteFile(rulesPath, []byte(`{"Rules": [{"Path": "*.pdf"}]}`), 0o644))
+		_, err = tagApplyRulesInternal("default", "", nil, false)
+		require.ErrorContains(t, err, "no tags to add")
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
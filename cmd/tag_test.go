@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"archive/zip"
+	"context"
 	"os"
 	"path/filepath"
 	"sort"
 	"testing"
 
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
 	filesys "github.com/heroku/self/MetaManager/internal/repository/context"
 	"github.com/heroku/self/MetaManager/internal/utils"
 
@@ -21,7 +26,7 @@ func InitializeRootAndScan(rootPath string) error {
 	if err := EnsureAppDataDir("default"); err != nil {
 		return err
 	}
-	return trackInternal("default", rootPath+"*")
+	return trackInternal(context.Background(), "default", rootPath+"*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
 }
 
 func TestTagAddAndGetE2E(t *testing.T) {
@@ -108,3 +113,45 @@ func TestTagAddAndGetE2E(t *testing.T) {
 	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
 	testExectutor.Execute()
 }
+
+func TestTagInsideArchive(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"1_a"},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		zipPath := filepath.Join(root, "old.zip")
+		f, err := os.Create(zipPath)
+		require.NoError(t, err)
+		zw := zip.NewWriter(f)
+		_, err = zw.Create("src/main.go")
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		os.Setenv("MM_CONTEXT", "default")
+		require.NoError(t, defaultStore.Create("default", filesys.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		require.NoError(t, trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{IntoArchives: true}, filesyspkg.ScanOptions{}, nil))
+
+		inner := zipPath + "!/src/main.go"
+		require.NoError(t, tagAddInternal("default", []string{zipPath + "!/src/../src/main.go", "legacy"}))
+		result, err := tagSearchInternal("default", "legacy")
+		require.NoError(t, err)
+		require.Equal(t, []string{inner}, result)
+		require.NoError(t, tagSearchTreeInternal("default", "legacy", result))
+
+		// Entries inside archives are not vanished by a refresh.
+		results, err := refreshInternal(context.Background(), "default", "", data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 0, results[0].Vanished)
+		tags, err := tagGetInternal("default", inner)
+		require.NoError(t, err)
+		require.Equal(t, []string{"legacy"}, tags)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/heroku/self/MetaManager/internal/xattr"
+
+	"github.com/spf13/cobra"
+)
+
+// xattrSyncResult is what syncing tags with extended attributes did.
+type xattrSyncResult struct {
+	// Changed are the paths of the files or nodes whose tags changed.
+	Changed []string
+	// Conflicts are the paths whose tags differed on both sides.
+	Conflicts []string
+	// Skipped counts the files without extended attributes, or gone from disk.
+	Skipped int
+	// Policy is the policy that settled the conflicts.
+	Policy xattr.Policy
+}
+
+// requireLocalContext fails unless ctxName is a local context, whose nodes are files.
+func requireLocalContext(ctxName, cmdName string) error {
+	typ, err := GetContextType(ctxName)
+	if err != nil {
+		return err
+	}
+	if typ != contextrepo.TypeLocal {
+		return fmt.Errorf("%s requires a local context, %q is a %s context", cmdName, ctxName, typ)
+	}
+	return nil
+}
+
+// xattrPolicy returns policy, or the policy of cfg when policy is empty.
+func xattrPolicy(cfg *config.Config, policy string) (xattr.Policy, error) {
+	if policy == "" {
+		policy = cfg.XattrPolicy
+	}
+	return xattr.ParsePolicy(policy)
+}
+
+// xattrSyncable reports whether info stands for a file on disk.
+func xattrSyncable(info file.NodeInformable) bool {
+	if info.GetAbsPath() == file.RootsPath {
+		return false
+	}
+	if vanishable, ok := info.(file.Vanishable); ok && vanishable.IsVanished() {
+		return false
+	}
+	_, _, inArchive := file.SplitArchivePath(info.GetAbsPath())
+	return !inArchive
+}
+
+// pushNodeTags writes the tags of info to its file, settling conflicts with p. The
+// removed tags are dropped from the file first, so that deletions are pushed too.
+func pushNodeTags(info file.NodeInformable, p xattr.Policy, removed []string, res *xattrSyncResult) error {
+	path := info.GetAbsPath()
+	current, _, err := xattr.GetTags(path)
+	if errors.Is(err, xattr.ErrUnsupported) || errors.Is(err, fs.ErrNotExist) {
+		res.Skipped++
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dst := slices.DeleteFunc(slices.Clone(current), func(tag string) bool { return slices.Contains(removed, tag) })
+	tags, conflict := xattr.Resolve(info.GetTags(), dst, p)
+	if conflict {
+		res.Conflicts = append(res.Conflicts, path)
+	}
+	if xattr.SameTags(tags, current) {
+		return nil
+	}
+	err = xattr.SetTags(path, tags)
+	if errors.Is(err, xattr.ErrUnsupported) {
+		res.Skipped++
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	res.Changed = append(res.Changed, path)
+	return nil
+}
+
+// pullNodeTags sets the tags of info to those of its file, settling conflicts with p.
+// Files without the attribute leave the node alone.
+func pullNodeTags(info file.NodeInformable, p xattr.Policy, res *xattrSyncResult) error {
+	path := info.GetAbsPath()
+	fileTags, found, err := xattr.GetTags(path)
+	if errors.Is(err, xattr.ErrUnsupported) || errors.Is(err, fs.ErrNotExist) {
+		res.Skipped++
+		return nil
+	}
+	if err != nil || !found {
+		return err
+	}
+	current := info.GetTags()
+	tags, conflict := xattr.Resolve(fileTags, current, p)
+	if conflict {
+		res.Conflicts = append(res.Conflicts, path)
+	}
+	if xattr.SameTags(tags, current) {
+		return nil
+	}
+	for _, tag := range slices.Clone(current) {
+		if !slices.Contains(tags, tag) {
+			info.DeleteTag(tag)
+		}
+	}
+	for _, tag := range tags {
+		info.AddTag(tag)
+	}
+	res.Changed = append(res.Changed, path)
+	return nil
+}
+
+// tagXattrSyncInternal pushes the tags of the node at pathExp and the nodes below it,
+// the whole tree when pathExp is empty, to the extended attributes of their files, or
+// pulls them from there with pull. An empty policy is the one of the context.
+func tagXattrSyncInternal(ctxName, pathExp string, pull bool, policy string) (*xattrSyncResult, error) {
+	err := requireLocalContext(ctxName, "tag xattr")
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	p, err := xattrPolicy(cfg, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	node := root
+	if pathExp != "" {
+		absPath, err := filesys.NewBasicResolver(defaultStore).Resolve(pathExp)
+		if err != nil {
+			return nil, err
+		}
+		node, err = data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath(absPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := &xattrSyncResult{Policy: p}
+	it := ds.NewTreeIterator(ds.NewTreeManager(node))
+	for it.HasNext() {
+		n, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		info, ok := n.Info.(file.NodeInformable)
+		if !ok || !xattrSyncable(info) {
+			continue
+		}
+		if pull {
+			err = pullNodeTags(info, p, res)
+		} else {
+			err = pushNodeTags(info, p, nil, res)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pull && len(res.Changed) > 0 {
+		err = rw.Write(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// xattrAutoPush pushes the tags of the nodes at absPaths to their files when the
+// context asks for it, dropping the removed tags from the files.
+func xattrAutoPush(ctxName string, drMg *data.DirTreeManager, absPaths []string, removed []string) error {
+	typ, err := GetContextType(ctxName)
+	if err != nil || typ != contextrepo.TypeLocal {
+		return err
+	}
+	cfg, err := config.Load(ctxName)
+	if err != nil || !cfg.XattrAutoPush {
+		return err
+	}
+	p, err := xattrPolicy(cfg, "")
+	if err != nil {
+		return err
+	}
+	res := &xattrSyncResult{Policy: p}
+	for _, absPath := range absPaths {
+		info, err := drMg.FindNodeByAbsPath(absPath)
+		if err != nil {
+			return err
+		}
+		if info == nil || !xattrSyncable(info) {
+			continue
+		}
+		err = pushNodeTags(info, p, removed, res)
+		if err != nil {
+			return fmt.Errorf("push tags of %s to %s: %w", absPath, xattr.TagsName, err)
+		}
+	}
+	return nil
+}
+
+// tagXattrAutoInternal turns pushing tags on tag add and delete on or off, keeping
+// policy as the policy of the context unless it is empty.
+func tagXattrAutoInternal(ctxName string, on bool, policy string) error {
+	err := requireLocalContext(ctxName, "tag xattr auto")
+	if err != nil {
+		return err
+	}
+	if policy != "" {
+		if _, err := xattr.ParsePolicy(policy); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	cfg.XattrAutoPush = on
+	if policy != "" {
+		cfg.XattrPolicy = policy
+	}
+	return config.Save(ctxName, cfg)
+}
+
+func tagXattrSync(cmd *cobra.Command, args []string, pull bool) {
+	var err error
+	var ctxName, pathExp, policy string
+	var res *xattrSyncResult
+
+	if len(args) > 1 {
+		err = &cmderror.InvalidNumberOfArguments{}
+		goto finally
+	}
+	if len(args) == 1 {
+		pathExp = args[0]
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	policy, err = cmd.Flags().GetString("policy")
+	if err != nil {
+		goto finally
+	}
+
+	res, err = tagXattrSyncInternal(ctxName, pathExp, pull, policy)
+	if err != nil {
+		goto finally
+	}
+	if res.Policy == xattr.PolicySkip {
+		for _, path := range res.Conflicts {
+			fmt.Printf("conflict, left alone: %s\n", path)
+		}
+	}
+	fmt.Printf("%d changed, %d conflicts, %d skipped without extended attributes\n", len(res.Changed), len(res.Conflicts), res.Skipped)
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func tagXattrAuto(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName, policy string
+	var on bool
+
+	if len(args) != 1 {
+		err = &cmderror.InvalidNumberOfArguments{}
+		goto finally
+	}
+	switch args[0] {
+	case "on":
+		on = true
+	case "off":
+	default:
+		err = fmt.Errorf("expected on or off, got %q", args[0])
+		goto finally
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	policy, err = cmd.Flags().GetString("policy")
+	if err != nil {
+		goto finally
+	}
+
+	err = tagXattrAutoInternal(ctxName, on, policy)
+	if err != nil {
+		goto finally
+	}
+	fmt.Printf("Pushing tags to %s on tag add and delete is %s\n", xattr.TagsName, args[0])
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// tagXattrCmd represents the tag xattr command
+var tagXattrCmd = &cobra.Command{
+	Use:   "xattr",
+	Short: "Sync tags with the user.xdg.tags extended attribute of files",
+	Long: `Syncs the tags of a local context with the user.xdg.tags extended attribute of the
+tracked files, which Linux desktop file managers read and write.
+
+When both sides hold tags and they differ, --policy settles the conflict:
+  merge      keep the tags of both sides (default)
+  overwrite  replace the tags with those synced in
+  skip       leave the tags alone and list the conflict
+Files on file systems without extended attributes are skipped.`,
+}
+
+// tagXattrPushCmd represents the tag xattr push command
+var tagXattrPushCmd = &cobra.Command{
+	Use:   "push [path]",
+	Short: "Write the tags of nodes to the extended attribute of their files",
+	Long: `Writes the tags of the node at path and the nodes below it, or of the whole tree, to
+the user.xdg.tags extended attribute of their files.`,
+	Run: func(cmd *cobra.Command, args []string) { tagXattrSync(cmd, args, false) },
+}
+
+// tagXattrPullCmd represents the tag xattr pull command
+var tagXattrPullCmd = &cobra.Command{
+	Use:   "pull [path]",
+	Short: "Read the tags of nodes from the extended attribute of their files",
+	Long: `Reads the tags of the node at path and the nodes below it, or of the whole tree, from
+the user.xdg.tags extended attribute of their files. Nodes whose file has no such
+attribute keep their tags.`,
+	Run: func(cmd *cobra.Command, args []string) { tagXattrSync(cmd, args, true) },
+}
+
+// tagXattrAutoCmd represents the tag xattr auto command
+var tagXattrAutoCmd = &cobra.Command{
+	Use:   "auto <on|off>",
+	Short: "Push tags to files on every tag add and delete",
+	Long: `Turns pushing tags to the user.xdg.tags extended attribute on or off for tag add and
+tag delete in the current context. --policy sets the policy it pushes with, which is
+also the default of push and pull; a deleted tag is removed from the file whatever
+the policy.`,
+	Run: tagXattrAuto,
+}
+
+func init() {
+	tagCmd.AddCommand(tagXattrCmd)
+	tagXattrCmd.AddCommand(tagXattrPushCmd)
+	tagXattrCmd.AddCommand(tagXattrPullCmd)
+	tagXattrCmd.AddCommand(tagXattrAutoCmd)
+	for _, c := range []*cobra.Command{tagXattrPushCmd, tagXattrPullCmd, tagXattrAutoCmd} {
+		c.Flags().String("policy", "", "how conflicts are settled: merge, overwrite or skip (default: the context's, or merge)")
+	}
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/heroku/self/MetaManager/internal/xattr"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagXattr(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "home",
+		Files:   []string{"a.txt", "b.txt"},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		a := filepath.Join(root, "a.txt")
+		b := filepath.Join(root, "b.txt")
+		if errors.Is(xattr.SetTags(a, nil), xattr.ErrUnsupported) {
+			t.Skip("no extended attributes here")
+		}
+
+		require.NoError(t, defaultStore.Create("default", contextrepo.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		require.NoError(t, trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{}, filesys.ScanOptions{}, nil))
+		require.NoError(t, tagAddInternal("default", []string{a, "work"}))
+		require.NoError(t, xattr.SetTags(b, []string{"photos"}))
+		require.NoError(t, tagAddInternal("default", []string{b, "work"}))
+
+		// Auto-push is off, so the files keep their tags.
+		_, found, err := xattr.GetTags(a)
+		require.NoError(t, err)
+		require.False(t, found)
+
+		res, err := tagXattrSyncInternal("default", "", false, "skip")
+		require.NoError(t, err)
+		require.Equal(t, []string{a}, res.Changed)
+		require.Equal(t, []string{b}, res.Conflicts)
+		res, err = tagXattrSyncInternal("default", b, false, "")
+		require.NoError(t, err)
+		require.Equal(t, []string{b}, res.Changed)
+		tags, _, err := xattr.GetTags(b)
+		require.NoError(t, err)
+		require.Equal(t, []string{"photos", "work"}, tags)
+
+		res, err = tagXattrSyncInternal("default", "", true, "overwrite")
+		require.NoError(t, err)
+		require.Equal(t, []string{b}, res.Changed)
+		tags, err = tagGetInternal("default", b)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"photos", "work"}, tags)
+
+		require.NoError(t, tagXattrAutoInternal("default", true, ""))
+		require.NoError(t, tagAddInternal("default", []string{root + "/*.txt", "todo"}))
+		require.NoError(t, tagDeleteInternal("default", b, "photos"))
+		tags, _, err = xattr.GetTags(a)
+		require.NoError(t, err)
+		require.Equal(t, []string{"work", "todo"}, tags)
+		tags, _, err = xattr.GetTags(b)
+		require.NoError(t, err)
+		require.Equal(t, []string{"work", "todo"}, tags)
+
+		_, err = tagXattrSyncInternal("default", "", false, "newest")
+		require.ErrorContains(t, err, "invalid policy")
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
@@ -5,14 +5,22 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
 	"runtime/debug"
+	"syscall"
+	"time"
 
 	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/config"
 	"github.com/heroku/self/MetaManager/internal/data"
 	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
 	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/output"
 	"github.com/heroku/self/MetaManager/internal/printer"
 	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
 	"github.com/heroku/self/MetaManager/internal/repository/tree"
@@ -22,7 +30,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func trackInternal(ctxName, pathExp string) error {
+// trackInternal tracks pathExp in ctxName, scanning with settings on top of opts, and
+// records it as a tracked root for refresh. When some entries of a recursive scan could
+// not be read, the rest of the tree is still tracked and the returned error is the
+// filesys.ScanErrors describing the missing entries.
+func trackInternal(ctx context.Context, ctxName, pathExp string, settings config.TrackedRoot, opts filesys.ScanOptions, explain io.Writer) error {
 	logrus.Debugf("[track] trackInternal start ctx=%q pathExp=%q", ctxName, pathExp)
 
 	rw, err := tree.GetRW(ctxName)
@@ -51,17 +63,30 @@ func trackInternal(ctxName, pathExp string) error {
 	if err != nil {
 		return err
 	}
+	if isPattern(data.NewDirTreeManager(ds.NewTreeManager(root)), resolvedPath) {
+		return trackGlobInternal(ctx, ctxName, resolvedPath, settings, opts, explain)
+	}
 
-	tracker, err := filesys.GetTrackerFromContext(defaultStore)
+	err = applyTrackedRoot(&opts, settings)
 	if err != nil {
 		return err
 	}
-
-	subTree, err := tracker.Track(resolvedPath)
+	tracker, err := filesys.GetTrackerFromContextWithOptions(defaultStore, opts)
 	if err != nil {
-		logrus.Debugf("[track] track (gdrive/local) error: %v", err)
 		return err
 	}
+	defer func() {
+		if err := filesys.CloseTracker(tracker); err != nil {
+			logrus.Debugf("[track] close tracker: %v", err)
+		}
+	}()
+
+	subTree, scanErr := filesys.TrackWithContext(ctx, tracker, resolvedPath)
+	var partial filesys.ScanErrors
+	if scanErr != nil && !(errors.As(scanErr, &partial) && subTree != nil) {
+		logrus.Debugf("[track] track (gdrive/local) error: %v", scanErr)
+		return scanErr
+	}
 
 	logrus.Debugf("[track] merge subtree into root")
 	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
@@ -71,6 +96,14 @@ func trackInternal(ctxName, pathExp string) error {
 		logrus.Debugf("[track] MergeNode error: %v", err)
 		return err
 	}
+	tracked, err := drMg.FindTreeNodeByAbsPath(subTree.Info.(file.NodeInformable).GetAbsPath())
+	if err != nil {
+		return err
+	}
+	_, err = applyTagRules(ctxName, tracked, explain)
+	if err != nil {
+		return err
+	}
 
 	err = rw.Write(drMg.Root)
 	if err != nil {
@@ -78,12 +111,51 @@ func trackInternal(ctxName, pathExp string) error {
 		return err
 	}
 
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	settings.Path = resolvedPath
+	cfg.AddTrackedRoot(settings)
+	err = config.Save(ctxName, cfg)
+	if err != nil {
+		return err
+	}
+
 	logrus.Debugf("[track] trackInternal done")
+	return scanErr
+}
+
+// trackGlobInternal tracks every file and directory on disk matching the glob pattern,
+// each as if it was given to track. Entries that could not be scanned are collected
+// into a single filesys.ScanErrors.
+func trackGlobInternal(ctx context.Context, ctxName, pattern string, settings config.TrackedRoot, opts filesys.ScanOptions, explain io.Writer) error {
+	matches, err := diskTargets(ctxName, pattern)
+	if err != nil {
+		return err
+	}
+	var scanErrs filesys.ScanErrors
+	for _, match := range matches {
+		err = trackInternal(ctx, ctxName, match, settings, opts, explain)
+		var partial filesys.ScanErrors
+		if errors.As(err, &partial) {
+			scanErrs = append(scanErrs, partial...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(scanErrs) > 0 {
+		return scanErrs
+	}
 	return nil
 }
 
 // trackShowInternal lists tracked nodes from the current directory (local cwd or gdrive cwd) in a tree structure.
-func trackShowInternal(ctxName string, tagFlag, idFlag bool) error {
+// In a context with named roots, every root is listed when the current directory is outside them.
+// With listing.Long the nodes are printed as a table instead, named by their path.
+func trackShowInternal(ctxName string, tagFlag, idFlag bool, listing longListing) error {
 	rw, err := tree.GetRW(ctxName)
 	if err != nil {
 		return err
@@ -97,12 +169,6 @@ func trackShowInternal(ctxName string, tagFlag, idFlag bool) error {
 	if err != nil {
 		return err
 	}
-	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
-	requiredNode, err := drMg.FindTreeNodeByAbsPath(dirPath)
-	if err != nil {
-		return err
-	}
-	pr := printer.NewTreePrinterManager(ds.NewTreeManager(requiredNode))
 	typesOfPrinting := []string{"node"}
 	if idFlag {
 		typesOfPrinting = append(typesOfPrinting, "id")
@@ -110,7 +176,122 @@ func trackShowInternal(ctxName string, tagFlag, idFlag bool) error {
 	if tagFlag {
 		typesOfPrinting = append(typesOfPrinting, "tags")
 	}
-	return pr.TrPrint(typesOfPrinting)
+	out, err := newOutputPrinter()
+	if err != nil {
+		return err
+	}
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	requiredNode, err := drMg.FindTreeNodeByAbsPath(dirPath)
+	printTree := func(node *ds.TreeNode) error {
+		pr := printer.NewTreePrinterManager(ds.NewTreeManager(node))
+		if out.Template != nil {
+			return pr.TrPrintV2(nodePrintingContexts(out))
+		}
+		return pr.TrPrint(typesOfPrinting)
+	}
+	if err != nil && drMg.IsMultiRoot() {
+		if out.Structured() {
+			return trackShowRootsRecords(ctxName, drMg, out)
+		}
+		if listing.Long {
+			return trackShowRootsRows(ctxName, drMg, listing)
+		}
+		return trackShowRoots(ctxName, drMg, printTree)
+	}
+	if err != nil {
+		return err
+	}
+	if out.Structured() {
+		records, err := subtreeRecords(requiredNode)
+		if err != nil {
+			return err
+		}
+		return out.Print(records, nil)
+	}
+	if listing.Long {
+		rows, err := subtreeRows(requiredNode, "")
+		if err != nil {
+			return err
+		}
+		return listing.printRows(rows)
+	}
+	return printTree(requiredNode)
+}
+
+// subtreeRecords returns the records of node and the nodes below it, parents first.
+func subtreeRecords(node *ds.TreeNode) ([]output.Node, error) {
+	found, err := output.SubtreeNodes(node)
+	if err != nil {
+		return nil, err
+	}
+	return output.NewNodes(found), nil
+}
+
+// trackShowRootsRecords prints the records of every named root of ctxName and of the
+// nodes below them.
+func trackShowRootsRecords(ctxName string, drMg *data.DirTreeManager, out *output.Printer) error {
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	records := []output.Node{}
+	for _, r := range cfg.Roots {
+		node, err := drMg.FindTreeNodeByAbsPath(r.Path)
+		if err != nil {
+			return fmt.Errorf("root %q: %w", r.Alias, err)
+		}
+		found, err := subtreeRecords(node)
+		if err != nil {
+			return err
+		}
+		records = append(records, found...)
+	}
+	return out.Print(records, nil)
+}
+
+// trackShowRootsRows prints the rows of every named root of ctxName and of the nodes
+// below them, named by the alias of their root and their path below it.
+func trackShowRootsRows(ctxName string, drMg *data.DirTreeManager, listing longListing) error {
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	rows := []output.Row{}
+	for _, r := range cfg.Roots {
+		node, err := drMg.FindTreeNodeByAbsPath(r.Path)
+		if err != nil {
+			return fmt.Errorf("root %q: %w", r.Alias, err)
+		}
+		found, err := subtreeRows(node, r.Alias)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, found...)
+	}
+	return listing.printRows(rows)
+}
+
+// trackShowRoots lists the tree of every named root of ctxName under its alias.
+func trackShowRoots(ctxName string, drMg *data.DirTreeManager, printTree func(*ds.TreeNode) error) error {
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	for _, r := range cfg.Roots {
+		node, err := drMg.FindTreeNodeByAbsPath(r.Path)
+		if err != nil {
+			return fmt.Errorf("root %q: %w", r.Alias, err)
+		}
+		fmt.Printf("%s: %s\n", r.Alias, r.Path)
+		err = printTree(node)
+		if err != nil {
+			return err
+		}
+	}
+	if len(cfg.Roots) == 0 {
+		fmt.Println("  (no roots; use 'context root add <alias> <dir>')")
+	}
+	return nil
 }
 
 // isTrackGDriveByContext returns true when current context is gdrive and path looks like a Drive path (starts with / or is a single segment).
@@ -138,9 +319,123 @@ func trackGDrive(pathExp string) (*ds.TreeNode, error) {
 	return scanner.TrackGDrive(ctx, drivePath, recursive)
 }
 
+// isTerminal reports whether f is a character device, i.e. progress output is read by a person.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// trackedRootFromFlags reads the scan options of track that are remembered for refresh.
+// Path is left empty.
+func trackedRootFromFlags(cmd *cobra.Command) (config.TrackedRoot, error) {
+	var r config.TrackedRoot
+	var err error
+
+	r.NoIgnore, err = cmd.Flags().GetBool("no-ignore")
+	if err != nil {
+		return r, err
+	}
+	r.Symlinks, err = cmd.Flags().GetString("symlinks")
+	if err != nil {
+		return r, err
+	}
+	r.Depth, err = cmd.Flags().GetInt("depth")
+	if err != nil {
+		return r, err
+	}
+	r.Include, err = cmd.Flags().GetStringArray("include")
+	if err != nil {
+		return r, err
+	}
+	r.Exclude, err = cmd.Flags().GetStringArray("exclude")
+	if err != nil {
+		return r, err
+	}
+	r.DirsOnly, err = cmd.Flags().GetBool("dirs-only")
+	if err != nil {
+		return r, err
+	}
+	r.MinSize, err = cmd.Flags().GetString("min-size")
+	if err != nil {
+		return r, err
+	}
+	r.NewerThan, err = cmd.Flags().GetString("newer-than")
+	if err != nil {
+		return r, err
+	}
+	r.IntoArchives, err = cmd.Flags().GetBool("into-archives")
+	if err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// applyTrackedRoot sets the scan options of opts from r.
+func applyTrackedRoot(opts *filesys.ScanOptions, r config.TrackedRoot) error {
+	var err error
+
+	opts.NoIgnore = r.NoIgnore
+	opts.IntoArchives = r.IntoArchives
+	if r.Symlinks != "" {
+		opts.Symlinks, err = filesys.ParseSymlinkPolicy(r.Symlinks)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Depth < 0 {
+		return fmt.Errorf("--depth must not be negative")
+	}
+	opts.Filter = filesys.ScanFilter{
+		MaxDepth: r.Depth,
+		Include:  r.Include,
+		Exclude:  r.Exclude,
+		DirsOnly: r.DirsOnly,
+	}
+	if r.MinSize != "" {
+		opts.Filter.MinSize, err = utils.ParseSize(r.MinSize)
+		if err != nil {
+			return err
+		}
+	}
+	if r.NewerThan != "" {
+		opts.Filter.NewerThan, err = filesys.ParseNewerThan(r.NewerThan, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanRuntimeFromFlags reads the --workers and --no-progress flags shared by track and refresh.
+func scanRuntimeFromFlags(cmd *cobra.Command) (filesys.ScanOptions, error) {
+	var opts filesys.ScanOptions
+	var err error
+
+	opts.Workers, err = cmd.Flags().GetInt("workers")
+	if err != nil {
+		return opts, err
+	}
+	noProgress, err := cmd.Flags().GetBool("no-progress")
+	if err != nil {
+		return opts, err
+	}
+	if !noProgress && isTerminal(os.Stderr) {
+		opts.Progress = os.Stderr
+	}
+	return opts, nil
+}
+
 func track(cmd *cobra.Command, args []string) {
 	var err error
 	var ctxName string
+	var opts filesys.ScanOptions
+	var explain io.Writer
+	var settings config.TrackedRoot
+	var partial filesys.ScanErrors
+	var ctx context.Context
+	var stop context.CancelFunc
+	var dryRun bool
 
 	logrus.Debugf("[track] track command args=%v", args)
 	if len(args) != 1 {
@@ -160,7 +455,35 @@ func track(cmd *cobra.Command, args []string) {
 		goto finally
 	}
 
-	err = trackInternal(ctxName, args[0])
+	settings, err = trackedRootFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	opts, err = scanRuntimeFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	explain, err = explainFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+
+	dryRun, err = dryRunTargets(cmd, ctxName, args[0], true)
+	if err != nil {
+		goto finally
+	}
+	if dryRun {
+		return
+	}
+
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = trackInternal(ctx, ctxName, args[0], settings, opts, explain)
+	if errors.As(err, &partial) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", partial)
+		err = nil
+	}
 	if err != nil {
 		goto finally
 	}
@@ -179,14 +502,21 @@ finally:
 var trackShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show tracked files/dirs from current directory in a tree structure",
-	Long:  "Lists all tracked files/dirs from the current root (local cwd or gdrive cwd) in a tree structure. Works in both local and gdrive contexts.",
-	Run:   runTrackShow,
+	Long: `Lists all tracked files/dirs from the current root (local cwd or gdrive cwd) in a tree structure. Works in both local and gdrive contexts.
+With --output json, ndjson, yaml or csv every node is printed as a node record, parents first.
+With --format every node of the tree is printed by a template of its node record instead, e.g.
+  ./MetaManager track show --format '{{.Name}} {{humanSize .Size}} {{join .Tags ","}}'
+With --long the nodes are printed as a table of their path, kind, size, modification time,
+tags, id and Drive link, sorted by --sort name, size or mtime and cut to the terminal width.`,
+	Run:         runTrackShow,
+	Annotations: structuredOutput("node"),
 }
 
 func runTrackShow(cmd *cobra.Command, args []string) {
 	var err error
 	var tagFlag, idFlag bool
 	var ctxName string
+	var listing longListing
 	ctxName, err = getContextRequired()
 	if err != nil {
 		goto finally
@@ -203,7 +533,11 @@ func runTrackShow(cmd *cobra.Command, args []string) {
 	if err != nil {
 		goto finally
 	}
-	err = trackShowInternal(ctxName, tagFlag, idFlag)
+	listing, err = longListingFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+	err = trackShowInternal(ctxName, tagFlag, idFlag, listing)
 	if err != nil {
 		goto finally
 	}
@@ -235,6 +569,46 @@ With context set to gdrive, you can also use:
 After "gdrive cd /SomeFolder", relative paths use that directory:
   track .   track SubFolder   track SubFolder*
 
+Recursive scans skip entries matched by gitignore-style patterns in .mmignore
+files of scanned directories and by the context's ignore list (see
+"context ignore"). Drive scans honor the context's ignore list only.
+Pass --no-ignore to scan everything.
+
+Recursive scans can be narrowed down, for both local and Drive paths:
+  track "/home/dev/project*" --depth 2
+  track "/home/dev/docs*" --include '*.pdf' --exclude 'tmp/**'
+  track "gdrive:/Photos*" --dirs-only
+  track "/data*" --min-size 10M --newer-than 7d
+Patterns are gitignore-style and relative to the tracked path. --include,
+--min-size and --newer-than only drop files; directories are kept.
+
+Symbolic links found by recursive local scans are recorded with their target
+and not followed by default. --symlinks=skip leaves them out, and
+--symlinks=follow scans what they point to; a link leading back into a
+directory being scanned is recorded instead of followed.
+
+With --into-archives, local scans also track the entries of .zip, .tar,
+.tar.gz and .tgz archives as virtual nodes below the archive, so that they can
+be tagged and searched like files:
+  track "/home/dev/archive*" --into-archives
+  tag add "/home/dev/archive/old.zip!/src/main.go" legacy
+Ignore files and filters do not apply inside archives.
+
+Recursive local scans read up to --workers entries concurrently and report
+progress on stderr when it is a terminal (disable with --no-progress).
+Entries that cannot be read, e.g. because of permissions, are reported as
+warnings and the rest of the tree is still tracked. Ctrl-C aborts the scan
+without changing what is tracked.
+
+The tagging rules of the context (see "tag apply-rules") are applied to the
+tracked nodes; --explain prints which rule added which tag.
+
+Glob patterns track every matching file or directory on disk, each as given
+(local contexts only). "*", "?" and "[...]" match within a path segment and
+"**" spans directories; a single trailing "*" still means recursive:
+  track "src/**/*_test.go"
+  track "/home/dev/*/README.md" --dry-run   (list the matches only)
+
 Subcommands:
   track show   show tracked nodes from current directory (local or gdrive cwd)`,
 	Run: track,
@@ -243,6 +617,20 @@ Subcommands:
 func init() {
 	RootCmd.AddCommand(trackCmd)
 	trackCmd.AddCommand(trackShowCmd)
+	trackCmd.Flags().Bool("no-ignore", false, "do not honor .mmignore files and the context ignore list")
+	trackCmd.Flags().String("symlinks", string(filesys.SymlinksRecord), "what recursive local scans do with symlinks: skip, record or follow")
+	trackCmd.Flags().Int("depth", 0, "track at most this many levels below the path in recursive scans (0 for unlimited)")
+	trackCmd.Flags().StringArray("include", nil, "only track files matching this gitignore-style pattern (repeatable)")
+	trackCmd.Flags().StringArray("exclude", nil, "do not track files or directories matching this gitignore-style pattern (repeatable)")
+	trackCmd.Flags().Bool("dirs-only", false, "only track directories")
+	trackCmd.Flags().String("min-size", "", "only track files of at least this size, e.g. 100K or 2M")
+	trackCmd.Flags().String("newer-than", "", "only track files modified within this age (e.g. 36h, 7d, 2w) or since this date (2006-01-02), as modified takes it in search queries")
+	trackCmd.Flags().Bool("into-archives", false, "track the files inside zip and tar archives as path.zip!/inner/file")
+	trackCmd.Flags().Int("workers", filesys.DefaultScanWorkers, "number of entries scanned concurrently in recursive local scans")
+	trackCmd.Flags().Bool("no-progress", false, "do not report scan progress on stderr")
+	trackCmd.Flags().Bool("explain", false, "print which tagging rule added which tag")
+	trackCmd.Flags().Bool("dry-run", false, "list what the path or glob matches on disk without tracking it")
 	trackShowCmd.Flags().BoolP("tag", "t", false, "include tags for each node")
 	trackShowCmd.Flags().BoolP("id", "i", false, "include id for each node")
+	addLongListingFlags(trackShowCmd)
 }
@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/heroku/self/MetaManager/internal/config"
 	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
 	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
@@ -71,11 +73,11 @@ func TestTrackCmd(t *testing.T) {
 		}
 
 		outputs := []int{
-			2, 3, 8, 8, 8, 16, // last: root + "*" (tracked nodes under root)
+			2, 3, 8, 8, 8, 17, // last: root + "*" (tracked nodes under root, .mm with data.json and index.gob included)
 		}
 
 		for i, loc := range locs {
-			err = trackInternal("default", loc)
+			err = trackInternal(context.Background(), "default", loc, config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
 			require.NoError(t, err)
 
 			node, err := rw.Read()
@@ -242,3 +244,55 @@ func countNodes(node *ds.TreeNode) int {
 	}
 	return count
 }
+
+func TestTrackCmdGDriveIgnore(t *testing.T) {
+	mockSvc := setupMockGDriveService(t)
+
+	tracker := filesyspkg.NewGDriveTrackerWithOptions(mockSvc, filesyspkg.ScanOptions{
+		IgnorePatterns: []string{"Sub/", "file1.txt"},
+	})
+	tree, err := tracker.Track("gdrive:/*")
+	require.NoError(t, err)
+
+	// root + Folder1 + file2 (Sub and file1 are ignored)
+	require.Equal(t, 3, countNodes(tree))
+
+	tracker = filesyspkg.NewGDriveTrackerWithOptions(mockSvc, filesyspkg.ScanOptions{
+		NoIgnore:       true,
+		IgnorePatterns: []string{"Sub/", "file1.txt"},
+	})
+	tree, err = tracker.Track("gdrive:/*")
+	require.NoError(t, err)
+	require.Equal(t, 6, countNodes(tree))
+}
+
+func TestTrackCmdGDriveFilters(t *testing.T) {
+	mockSvc := setupMockGDriveService(t)
+
+	tests := []struct {
+		name   string
+		filter filesyspkg.ScanFilter
+		nodes  int
+	}{
+		// root + Folder1 + file1, Folder1 is not listed
+		{"depth", filesyspkg.ScanFilter{MaxDepth: 1}, 3},
+		// root + Folder1 + Sub + file2 + file1
+		{"depth 2", filesyspkg.ScanFilter{MaxDepth: 2}, 5},
+		// root + Folder1 + Sub
+		{"dirs only", filesyspkg.ScanFilter{DirsOnly: true}, 3},
+		// root + Folder1 + Sub + file3
+		{"include", filesyspkg.ScanFilter{Include: []string{"Folder1/Sub/*.txt"}}, 4},
+		// root + Folder1 + file2 + file1
+		{"exclude", filesyspkg.ScanFilter{Exclude: []string{"Folder1/Sub"}}, 4},
+		// Drive entries of the mock have no size
+		{"min size", filesyspkg.ScanFilter{MinSize: 1}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := filesyspkg.NewGDriveTrackerWithOptions(mockSvc, filesyspkg.ScanOptions{Filter: tt.filter})
+			tree, err := tracker.Track("gdrive:/*")
+			require.NoError(t, err)
+			require.Equal(t, tt.nodes, countNodes(tree))
+		})
+	}
+}
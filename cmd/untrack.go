@@ -7,9 +7,9 @@ import (
 	"fmt"
 
 	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/config"
 	"github.com/heroku/self/MetaManager/internal/data"
 	"github.com/heroku/self/MetaManager/internal/ds"
-	"github.com/heroku/self/MetaManager/internal/filesys"
 	"github.com/heroku/self/MetaManager/internal/repository/tree"
 	"github.com/heroku/self/MetaManager/internal/utils"
 	"github.com/sirupsen/logrus"
@@ -30,6 +30,10 @@ func HandleSubtreeRemoval(ctxName, pathExp string, drMg *data.DirTreeManager) er
 		return nil
 	}
 
+	if drMg.IsNamedRoot(pathExp) {
+		return fmt.Errorf("%s is a root of the context; use \"context root rm\" to remove it", pathExp)
+	}
+
 	found, rootDirPathAbs, err := utils.FindRootDir(ctxName)
 	if err != nil {
 		return err
@@ -51,6 +55,8 @@ func HandleSubtreeRemoval(ctxName, pathExp string, drMg *data.DirTreeManager) er
 	return nil
 }
 
+// untrackInternal untracks pathExp, or every tracked node a glob matches with
+// everything below it.
 func untrackInternal(ctxName, pathExp string) error {
 	rw, err := tree.GetRW(ctxName)
 	if err != nil {
@@ -64,17 +70,19 @@ func untrackInternal(ctxName, pathExp string) error {
 
 	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
 
-	resolver := filesys.NewBasicResolver(defaultStore)
-	resolvedPath, err := resolver.Resolve(pathExp)
+	resolvedPaths, err := resolveTargets(drMg, pathExp)
 	if err != nil {
 		return err
 	}
+	resolvedPaths = outermostPaths(resolvedPaths)
 
-	logrus.Debugf("[untrack] resolvedPath: %q", resolvedPath)
+	logrus.Debugf("[untrack] resolvedPaths: %q", resolvedPaths)
 
-	err = HandleSubtreeRemoval(ctxName, resolvedPath, drMg)
-	if err != nil {
-		return err
+	for _, resolvedPath := range resolvedPaths {
+		err = HandleSubtreeRemoval(ctxName, resolvedPath, drMg)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = rw.Write(drMg.Root)
@@ -82,12 +90,21 @@ func untrackInternal(ctxName, pathExp string) error {
 		return err
 	}
 
-	return nil
+	// Forget the tracked roots that are gone, so that refresh does not bring them back.
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return err
+	}
+	for _, resolvedPath := range resolvedPaths {
+		cfg.RemoveTrackedRoots(resolvedPath)
+	}
+	return config.Save(ctxName, cfg)
 }
 
 func untrack(cmd *cobra.Command, args []string) {
 	var err error
 	var ctxName string
+	var dryRun bool
 
 	if len(args) != 1 {
 		err = &cmderror.InvalidNumberOfArguments{}
@@ -103,6 +120,14 @@ func untrack(cmd *cobra.Command, args []string) {
 		goto finally
 	}
 
+	dryRun, err = dryRunTargets(cmd, ctxName, args[0], false)
+	if err != nil {
+		goto finally
+	}
+	if dryRun {
+		return
+	}
+
 	err = untrackInternal(ctxName, args[0])
 	if err != nil {
 		goto finally
@@ -120,12 +145,14 @@ finally:
 var untrackCmd = &cobra.Command{
 	Use:   "untrack",
 	Short: "Untracks an entire subtree rooted at node",
-	Long:  "Untracks an entire subtree rooted at node",
-	Run:   untrack,
+	Long: `Untracks an entire subtree rooted at node. A glob untracks every tracked node it matches,
+e.g. untrack "**/node_modules"; --dry-run lists them without untracking.`,
+	Run: untrack,
 }
 
 func init() {
 	RootCmd.AddCommand(untrackCmd)
+	untrackCmd.Flags().Bool("dry-run", false, "list the tracked nodes the path or glob matches without untracking them")
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/query"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/heroku/self/MetaManager/internal/view"
+
+	"github.com/spf13/cobra"
+)
+
+// viewGroup is a subdirectory of a view holding the nodes matching a query.
+type viewGroup struct {
+	name  string
+	query *query.Query
+}
+
+// parseViewGroups parses --group values like "unpaid=tag:unpaid AND ext:pdf".
+func parseViewGroups(values []string) ([]viewGroup, error) {
+	groups := []viewGroup{}
+	for _, v := range values {
+		name, expr, ok := strings.Cut(v, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid group %q, expected name=query", v)
+		}
+		q, err := query.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+		groups = append(groups, viewGroup{name: name, query: q})
+	}
+	return groups, nil
+}
+
+// viewLinks returns the links of the view of the tree at root: the nodes matching
+// selector, all when nil, in a group per tag, or else per group they match. Drive
+// nodes become shortcuts. Vanished nodes and archive entries are left out.
+func viewLinks(root *ds.TreeNode, contextType string, selector *query.Query, groups []viewGroup) []view.Link {
+	links := []view.Link{}
+	it := ds.NewTreeIterator(ds.NewTreeManager(root))
+	for it.HasNext() {
+		node, _ := it.Next()
+		info, ok := node.Info.(file.NodeInformable)
+		if !ok || info.GetAbsPath() == file.RootsPath {
+			continue
+		}
+		if vanishable, ok := info.(file.Vanishable); ok && vanishable.IsVanished() {
+			continue
+		}
+		if _, _, inArchive := file.SplitArchivePath(info.GetAbsPath()); inArchive {
+			continue
+		}
+		if selector != nil && !selector.Match(info) {
+			continue
+		}
+
+		link := view.Link{Target: info.GetAbsPath(), Shortcut: contextType == contextrepo.TypeGDrive}
+		if len(groups) == 0 {
+			for _, tag := range info.GetTags() {
+				link.Group = tag
+				links = append(links, link)
+			}
+			continue
+		}
+		for _, g := range groups {
+			if g.query.Match(info) {
+				link.Group = g.name
+				links = append(links, link)
+			}
+		}
+	}
+	return links
+}
+
+// viewMaterializeInternal materializes the view of ctxName in dir, see viewLinks.
+func viewMaterializeInternal(ctxName, dir, selector string, groupValues []string) (*view.Result, error) {
+	contextType, err := GetContextType(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	if contextType != contextrepo.TypeLocal && contextType != contextrepo.TypeGDrive {
+		return nil, fmt.Errorf("view materialize supports local and gdrive contexts, %q is a %s context", ctxName, contextType)
+	}
+
+	var q *query.Query
+	if selector != "" {
+		q, err = query.Parse(selector)
+		if err != nil {
+			return nil, err
+		}
+	}
+	groups, err := parseViewGroups(groupValues)
+	if err != nil {
+		return nil, err
+	}
+
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return view.Materialize(absDir, viewLinks(root, contextType, q, groups), storedDriveLink(root))
+}
+
+// storedDriveLink returns the web link of the Drive node at virtualPath below root,
+// made from the id and MIME type its scan stored, without asking Drive.
+func storedDriveLink(root *ds.TreeNode) func(virtualPath string) (string, error) {
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	return func(virtualPath string) (string, error) {
+		node, err := drMg.FindTreeNodeByAbsPath(virtualPath)
+		if err != nil {
+			return "", err
+		}
+		fn, ok := node.Info.(*file.FileNode)
+		if !ok || fn.DriveId == "" {
+			return "", fmt.Errorf("%s has no Drive id, refresh the context first", virtualPath)
+		}
+		return output.DriveLink(fn.DriveId, fn.MimeType), nil
+	}
+}
+
+func viewMaterialize(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName, selector string
+	var groupValues []string
+	var res *view.Result
+
+	if len(args) != 1 {
+		err = &cmderror.InvalidNumberOfArguments{}
+		goto finally
+	}
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	selector, err = cmd.Flags().GetString("query")
+	if err != nil {
+		goto finally
+	}
+	groupValues, err = cmd.Flags().GetStringArray("group")
+	if err != nil {
+		goto finally
+	}
+
+	res, err = viewMaterializeInternal(ctxName, args[0], selector, groupValues)
+	if err != nil {
+		goto finally
+	}
+	fmt.Printf("View %s: %d added, %d removed, %d unchanged\n", args[0], res.Added, res.Removed, res.Kept)
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// viewCmd represents the view command
+var viewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Make tags visible to other tools as directories of links",
+}
+
+// viewMaterializeCmd represents the view materialize command
+var viewMaterializeCmd = &cobra.Command{
+	Use:   "materialize <dir>",
+	Short: "Build a directory of links to tracked nodes, one subdirectory per tag or group",
+	Long: `Builds dir with a subdirectory per tag holding a symlink to every tracked node with
+that tag, so that file managers and other tools can browse by tag:
+  ./MetaManager view materialize ~/tags
+  ./MetaManager view materialize ~/finance --query 'ext:pdf AND modified<1y'
+
+--query only keeps the nodes matching a query of "search query". With --group, the
+subdirectories are groups of nodes matching a query instead of tags:
+  ./MetaManager view materialize ~/bills --group 'unpaid=tag:unpaid' --group 'paid=tag:paid'
+
+Running it again brings dir up to date: missing links are added and stale ones
+removed. What the view holds is recorded in dir/` + view.ManifestFileName + `, and anything else
+in dir is left alone; a new view needs a new or empty directory. In gdrive contexts
+the links are .url shortcut files opening the Drive web page of the node.`,
+	Run: viewMaterialize,
+}
+
+func init() {
+	RootCmd.AddCommand(viewCmd)
+	viewCmd.AddCommand(viewMaterializeCmd)
+	viewMaterializeCmd.Flags().String("query", "", "only link the nodes matching this query")
+	viewMaterializeCmd.Flags().StringArray("group", nil, "a subdirectory of the nodes matching a query, as name=query (repeatable)")
+}
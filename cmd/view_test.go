@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/heroku/self/MetaManager/internal/view"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewMaterialize(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "home",
+		Dirs: []*utils.MockDir{
+			{DirName: "invoices", Files: []string{"march.pdf", "april.pdf"}},
+		},
+		Files: []string{"notes.txt"},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		os.Setenv("MM_CONTEXT", "default")
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		defer os.Unsetenv("MM_CONTEXT")
+
+		require.NoError(t, defaultStore.Create("default", contextrepo.TypeLocal))
+		require.NoError(t, EnsureAppDataDir("default"))
+		require.NoError(t, trackInternal(context.Background(), "default", root+"*", config.TrackedRoot{}, filesys.ScanOptions{}, nil))
+
+		march := filepath.Join(root, "invoices", "march.pdf")
+		april := filepath.Join(root, "invoices", "april.pdf")
+		notes := filepath.Join(root, "notes.txt")
+		require.NoError(t, tagAddInternal("default", []string{march, "finance"}))
+		require.NoError(t, tagAddInternal("default", []string{april, "finance"}))
+		require.NoError(t, tagAddInternal("default", []string{april, "unpaid"}))
+		require.NoError(t, tagAddInternal("default", []string{notes, "todo"}))
+
+		dir := filepath.Join(t.TempDir(), "tags")
+		res, err := viewMaterializeInternal("default", dir, "ext:pdf", nil)
+		require.NoError(t, err)
+		require.Equal(t, &view.Result{Added: 3}, res)
+		target, err := os.Readlink(filepath.Join(dir, "unpaid", "april.pdf"))
+		require.NoError(t, err)
+		require.Equal(t, april, target)
+		require.NoDirExists(t, filepath.Join(dir, "todo"))
+
+		// Untagged nodes leave the view.
+		require.NoError(t, tagDeleteInternal("default", april, "unpaid"))
+		res, err = viewMaterializeInternal("default", dir, "ext:pdf", nil)
+		require.NoError(t, err)
+		require.Equal(t, &view.Result{Removed: 1, Kept: 2}, res)
+		require.NoDirExists(t, filepath.Join(dir, "unpaid"))
+
+		groups := filepath.Join(t.TempDir(), "groups")
+		res, err = viewMaterializeInternal("default", groups, "", []string{"pdfs=ext:pdf", "todo=tag:todo"})
+		require.NoError(t, err)
+		require.Equal(t, &view.Result{Added: 3}, res)
+		require.FileExists(t, filepath.Join(groups, "todo", "notes.txt"))
+
+		_, err = viewMaterializeInternal("default", groups, "", []string{"=tag:todo"})
+		require.ErrorContains(t, err, "expected name=query")
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestStoredDriveLink(t *testing.T) {
+	plan := ds.NewTreeNode(&file.FileNode{
+		GeneralNode: file.GeneralNode{AbsPath: "gdrive:/Plan"},
+		DriveId:     "d1",
+		MimeType:    "application/vnd.google-apps.document",
+	})
+	old := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "gdrive:/Old"}})
+	root := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "gdrive:/"}})
+	root.Children = []*ds.TreeNode{plan, old}
+
+	link := storedDriveLink(root)
+	u, err := link("gdrive:/Plan")
+	require.NoError(t, err)
+	require.Equal(t, "https://docs.google.com/document/d/d1/edit", u)
+	_, err = link("gdrive:/Old")
+	require.ErrorContains(t, err, "no Drive id")
+	_, err = link("gdrive:/Missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/orphans"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// DefaultWatchDebounce is how long watch collects changes before saving them.
+const DefaultWatchDebounce = 2 * time.Second
+
+// watchedRoots returns the tracked roots watch can follow. Only recursive roots
+// are: a single tracked file has nothing below it to pick up.
+func watchedRoots(roots []config.TrackedRoot) []config.TrackedRoot {
+	watched := []config.TrackedRoot{}
+	for _, r := range roots {
+		if r.Recursive() {
+			watched = append(watched, r)
+		} else {
+			logrus.Debugf("[watch] not watching %q, it is not tracked recursively", r.Path)
+		}
+	}
+	return watched
+}
+
+// watchInternal keeps the tree of ctxName in line with the recursive local roots it
+// tracks until ctx is cancelled. Changes are saved in batches, the first change of a
+// batch is saved at most debounce later. On shutdown pending changes are saved.
+func watchInternal(ctx context.Context, ctxName string, policy data.VanishedPolicy, opts filesys.ScanOptions, debounce time.Duration) error {
+	logrus.Debugf("[watch] watchInternal start ctx=%q policy=%s debounce=%s", ctxName, policy, debounce)
+
+	contextType, err := GetContextType(ctxName)
+	if err != nil {
+		return err
+	}
+	if contextType != contextrepo.TypeLocal {
+		return fmt.Errorf("watch only supports local contexts, %q is a %s context", ctxName, contextType)
+	}
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadTrackedRoots(ctxName, root)
+	if err != nil {
+		return err
+	}
+	roots := watchedRoots(cfg.TrackedRoots)
+	if len(roots) == 0 {
+		return fmt.Errorf("no recursively tracked root to watch; track a path ending in * first")
+	}
+
+	for {
+		overflowed, err := watchOnce(ctx, ctxName, cfg, roots, policy, opts, debounce)
+		if err != nil || !overflowed {
+			return err
+		}
+		// The kernel dropped events, start over from a full scan.
+		fmt.Fprintln(os.Stderr, "warning: changes came in faster than they could be followed, rescanning")
+	}
+}
+
+// watchOnce watches roots until ctx is cancelled or events were lost, which it reports.
+func watchOnce(ctx context.Context, ctxName string, cfg *config.Config, roots []config.TrackedRoot, policy data.VanishedPolicy, opts filesys.ScanOptions, debounce time.Duration) (bool, error) {
+	w, err := filesys.NewWatcher()
+	if err != nil {
+		return false, err
+	}
+	defer w.Close()
+
+	// The tree itself may be saved below a watched root.
+	appDir, err := utils.GetAppDataDir()
+	if err != nil {
+		return false, err
+	}
+	w.Skip(appDir)
+
+	// The first scan catches up with what changed while nobody was watching.
+	pending := []filesys.WatchEvent{}
+	for _, r := range roots {
+		rootOpts := opts
+		err = applyTrackedRoot(&rootOpts, r)
+		if err != nil {
+			return false, err
+		}
+		if !rootOpts.NoIgnore {
+			rootOpts.IgnorePatterns = append(rootOpts.IgnorePatterns, cfg.IgnorePatterns...)
+		}
+		node, err := w.AddRoot(ctx, r.Dir(), rootOpts)
+		if ctx.Err() != nil {
+			return false, nil
+		}
+		var partial filesys.ScanErrors
+		if err != nil && !errors.As(err, &partial) {
+			return false, fmt.Errorf("watch %s: %w", r.Path, err)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", r.Path, err)
+		}
+		pending = append(pending, filesys.WatchEvent{Op: filesys.WatchCreate, Path: r.Dir(), Node: node})
+		fmt.Printf("Watching %s\n", r.Path)
+	}
+
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := applyWatchEvents(ctxName, pending, policy)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s saved changes (%d events)\n", time.Now().Format(time.TimeOnly), len(pending))
+		pending = pending[:0]
+		return nil
+	}
+
+	timer := time.NewTimer(debounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, flush()
+		case ev, ok := <-w.Events():
+			if !ok {
+				return false, flush()
+			}
+			if ev.Op == filesys.WatchOverflow {
+				return true, flush()
+			}
+			if len(pending) == 0 {
+				timer.Reset(debounce)
+			}
+			pending = append(pending, ev)
+		case err := <-w.Errors():
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		case <-timer.C:
+			err = flush()
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+// applyWatchEvents applies events to the saved tree of ctxName in one read and write,
+// so that changes made by other commands in the meantime are kept.
+func applyWatchEvents(ctxName string, events []filesys.WatchEvent, policy data.VanishedPolicy) error {
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return err
+	}
+	root, err := rw.Read()
+	if err != nil {
+		return err
+	}
+
+	drMg := data.NewDirTreeManager(ds.NewTreeManager(root))
+	removed := []file.NodeInformable{}
+	for _, ev := range events {
+		res, err := applyWatchEvent(drMg, ev, policy)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", ev.Op, ev.Path, err)
+		}
+		removed = append(removed, res.Removed...)
+	}
+
+	// Orphans go first, like refresh does.
+	err = orphans.Append(ctxName, orphansOf(removed))
+	if err != nil {
+		return err
+	}
+	return rw.Write(drMg.Root)
+}
+
+func applyWatchEvent(drMg *data.DirTreeManager, ev filesys.WatchEvent, policy data.VanishedPolicy) (*data.RefreshResult, error) {
+	tracker := filesys.NewLocalTracker()
+	exists := func(p string) (bool, error) { return tracker.Exists(context.Background(), p) }
+
+	switch ev.Op {
+	case filesys.WatchCreate:
+		if ev.Node == nil {
+			return &data.RefreshResult{}, nil
+		}
+		return drMg.Refresh(ev.Path, true, ev.Node, exists, policy)
+	case filesys.WatchRemove:
+		gone := func(string) (bool, error) { return false, nil }
+		return drMg.Refresh(ev.Path, true, nil, gone, policy)
+	case filesys.WatchRename:
+		// Tags and ids move along; an entry that was not tracked before is just added.
+		if _, err := drMg.FindTreeNodeByAbsPath(ev.OldPath); err == nil {
+			err = drMg.MoveNode(ev.OldPath, ev.Path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if ev.Node == nil {
+			return &data.RefreshResult{}, nil
+		}
+		return drMg.Refresh(ev.Path, true, ev.Node, exists, policy)
+	}
+	return nil, &cmderror.InvalidOperation{}
+}
+
+func watch(cmd *cobra.Command, args []string) {
+	var err error
+	var ctxName, vanished string
+	var policy data.VanishedPolicy
+	var opts filesys.ScanOptions
+	var debounce time.Duration
+	var ctx context.Context
+	var stop context.CancelFunc
+
+	ctxName, err = getContextRequired()
+	if err != nil {
+		goto finally
+	}
+	_, err = utils.CommonAlreadyInitializedChecks(ctxName)
+	if err != nil {
+		goto finally
+	}
+
+	vanished, err = cmd.Flags().GetString("vanished")
+	if err != nil {
+		goto finally
+	}
+	policy, err = data.ParseVanishedPolicy(vanished)
+	if err != nil {
+		goto finally
+	}
+	debounce, err = cmd.Flags().GetDuration("debounce")
+	if err != nil {
+		goto finally
+	}
+	if debounce <= 0 {
+		err = fmt.Errorf("--debounce must be positive")
+		goto finally
+	}
+	opts, err = scanRuntimeFromFlags(cmd)
+	if err != nil {
+		goto finally
+	}
+
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = watchInternal(ctx, ctxName, policy, opts, debounce)
+	if err != nil {
+		goto finally
+	}
+	fmt.Println("Stopped watching")
+
+finally:
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Keep the tree up to date with changes to tracked local directories",
+	Long: `Watches every recursively tracked root of the current local context (Linux only)
+and applies files and directories being created, removed and renamed to the tree.
+
+Renamed entries keep their tags and ids. New entries are scanned with the options
+their root was tracked with. Removed entries are handled like refresh handles
+vanished nodes, see --vanished.
+
+Changes are saved in batches, at most --debounce after the first one. On start
+the roots are scanned to catch up with changes made while not watching. watch
+runs until interrupted or terminated and saves pending changes before exiting.`,
+	Args: cobra.NoArgs,
+	Run:  watch,
+}
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().String("vanished", string(data.VanishedMark), "what to do with tracked nodes that are removed: mark or remove")
+	watchCmd.Flags().Duration("debounce", DefaultWatchDebounce, "how long changes are collected before they are saved")
+	watchCmd.Flags().Int("workers", filesys.DefaultScanWorkers, "number of entries scanned concurrently in recursive local scans")
+	watchCmd.Flags().Bool("no-progress", false, "do not report scan progress on stderr")
+}
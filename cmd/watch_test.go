@@ -0,0 +1,76 @@
+//go:build linux
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/file"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchCmd(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"1_a"},
+		Dirs: []*utils.MockDir{
+			{
+				DirName: "2_1",
+				Files:   []string{"2_a", "2_b"},
+			},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		os.Setenv("MM_TEST_CONTEXT_DIR", root)
+		defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+		require.NoError(t, InitializeRootAndScan(root))
+
+		tagged := filepath.Join(root, "2_1", "2_a")
+		require.NoError(t, tagAddInternal("default", []string{tagged, "keep-me"}))
+		// Made while not watching, picked up by the first scan.
+		require.NoError(t, os.WriteFile(filepath.Join(root, "before"), nil, 0644))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- watchInternal(ctx, "default", data.VanishedMark, filesyspkg.ScanOptions{}, 20*time.Millisecond)
+		}()
+		require.Eventually(t, func() bool {
+			_, err := findTracked(t, filepath.Join(root, "before"))
+			return err == nil
+		}, 5*time.Second, 20*time.Millisecond)
+
+		// The tagged file moves with its directory.
+		require.NoError(t, os.Rename(filepath.Join(root, "2_1"), filepath.Join(root, "moved")))
+		moved := filepath.Join(root, "moved", "2_a")
+		require.Eventually(t, func() bool {
+			_, err := findTracked(t, moved)
+			return err == nil
+		}, 5*time.Second, 20*time.Millisecond)
+		node, err := findTracked(t, moved)
+		require.NoError(t, err)
+		require.Equal(t, []string{"keep-me"}, node.Info.(file.NodeInformable).GetTags())
+		_, err = findTracked(t, tagged)
+		require.Error(t, err)
+
+		// Removed entries are marked vanished, watch stops cleanly once cancelled.
+		require.NoError(t, os.Remove(filepath.Join(root, "1_a")))
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+		node, err = findTracked(t, filepath.Join(root, "1_a"))
+		require.NoError(t, err)
+		require.True(t, node.Info.(file.Vanishable).IsVanished())
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
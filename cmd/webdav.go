@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/services"
+	"github.com/spf13/cobra"
+)
+
+// WebDAVPasswordEnvVar is read by "webdav login" when --password is not given.
+const WebDAVPasswordEnvVar = "MM_WEBDAV_PASSWORD"
+
+var webdavCmd = &cobra.Command{
+	Use:   "webdav",
+	Short: "WebDAV commands (requires a webdav context)",
+	Long:  `Commands for the WebDAV server of the current context. Create one with "context create <name> --type webdav", then run "webdav login".`,
+}
+
+var webdavLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Set the WebDAV server and credentials of the current context",
+	Long: `Checks that the server answers and saves its URL and credentials in the current context.
+Every webdav context has its own server. The password is taken from --password or
+else from ` + WebDAVPasswordEnvVar + `. It is stored in the context's webdav.json, readable only by you.`,
+	Args: cobra.NoArgs,
+	RunE: runWebDAVLogin,
+}
+
+var webdavPwdCmd = &cobra.Command{
+	Use:   "pwd",
+	Short: "Print current WebDAV working directory",
+	Long:  `Prints the current WebDAV directory used for shell-style navigation and relative paths in "track".`,
+	Args:  cobra.NoArgs,
+	RunE:  runWebDAVPwd,
+}
+
+var webdavCdCmd = &cobra.Command{
+	Use:   "cd [path]",
+	Short: "Change current WebDAV working directory",
+	Long:  `Set the current WebDAV directory for relative paths. With no argument, prints current directory. Use "/" for root, "/Folder" or "Folder" for a subfolder, ".." to go up.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runWebDAVCd,
+}
+
+var webdavLsCmd = &cobra.Command{
+	Use:         "ls [path]",
+	Short:       "List current or given WebDAV directory",
+	Long:        `Lists files and folders at the current WebDAV directory (see "webdav pwd") or at the given path. Path is relative to current directory unless it starts with "/". With --output json, ndjson, yaml or csv every entry is an entry record. With -l every entry is a row of a table, see "track show --long".`,
+	Args:        cobra.MaximumNArgs(1),
+	RunE:        runWebDAVLs,
+	Annotations: structuredOutput("entry"),
+}
+
+func init() {
+	RootCmd.AddCommand(webdavCmd)
+	webdavCmd.AddCommand(webdavLoginCmd)
+	webdavCmd.AddCommand(webdavPwdCmd)
+	webdavCmd.AddCommand(webdavCdCmd)
+	webdavCmd.AddCommand(webdavLsCmd)
+	addLongListingFlags(webdavLsCmd)
+	webdavLoginCmd.Flags().String("url", "", "URL of the collection the context tracks, e.g. https://dav.example.com/files (required)")
+	webdavLoginCmd.Flags().String("user", "", "user name for basic authentication")
+	webdavLoginCmd.Flags().String("password", "", "password for basic authentication (default $"+WebDAVPasswordEnvVar+")")
+	if err := webdavLoginCmd.MarkFlagRequired("url"); err != nil {
+		fmt.Fprintln(os.Stderr, "webdav login: mark flag required:", err)
+		os.Exit(1)
+	}
+}
+
+// isWebDAVContext returns true if the current context type is webdav.
+func isWebDAVContext() (bool, error) {
+	name, err := GetContext()
+	if err != nil || name == "" {
+		return false, err
+	}
+	typ, err := GetContextType(name)
+	if err != nil || typ != filesys.TypeWebDAV {
+		return false, err
+	}
+	return true, nil
+}
+
+// requireWebDAVContext returns the name of the current context, which must be a webdav one.
+func requireWebDAVContext(cmdName string) (string, error) {
+	ok, err := isWebDAVContext()
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("webdav %s requires a webdav context; use \"context set <name>\" with a webdav context", cmdName)
+	}
+	return GetContext()
+}
+
+// webdavLoginInternal checks that the server of settings can be listed and saves settings
+// in ctxName. The working directory is kept when the URL stays the same.
+func webdavLoginInternal(ctxName string, settings *services.WebDAVSettings) error {
+	svc, err := services.NewWebDAVService(settings)
+	if err != nil {
+		return err
+	}
+	root, err := svc.Stat(context.Background(), "/")
+	if err != nil {
+		return err
+	}
+	if !root.IsFolder {
+		return fmt.Errorf("%s is not a WebDAV collection", settings.URL)
+	}
+	if old, err := services.LoadWebDAVSettings(ctxName); err == nil && old.URL == settings.URL {
+		settings.Cwd = old.Cwd
+	}
+	return services.SaveWebDAVSettings(ctxName, settings)
+}
+
+func runWebDAVLogin(cmd *cobra.Command, args []string) error {
+	ctxName, err := requireWebDAVContext("login")
+	if err != nil {
+		return err
+	}
+	settings := &services.WebDAVSettings{}
+	settings.URL, err = cmd.Flags().GetString("url")
+	if err != nil {
+		return err
+	}
+	settings.Username, err = cmd.Flags().GetString("user")
+	if err != nil {
+		return err
+	}
+	settings.Password, err = cmd.Flags().GetString("password")
+	if err != nil {
+		return err
+	}
+	if settings.Password == "" {
+		settings.Password = os.Getenv(WebDAVPasswordEnvVar)
+	}
+	err = webdavLoginInternal(ctxName, settings)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Logged in to %s for context %q\n", settings.URL, ctxName)
+	return nil
+}
+
+// webdavCwd returns the working directory of settings, "/" when none was set.
+func webdavCwd(settings *services.WebDAVSettings) string {
+	if settings.Cwd == "" {
+		return "/"
+	}
+	return settings.Cwd
+}
+
+// resolveWebDAVArg returns the server path args point to, the working directory without args.
+func resolveWebDAVArg(settings *services.WebDAVSettings, args []string) (string, error) {
+	if len(args) == 0 {
+		return webdavCwd(settings), nil
+	}
+	resolvedPath, err := filesys.NewBasicResolver(defaultStore).Resolve(args[0])
+	if err != nil {
+		return "", err
+	}
+	davPath, _ := filesys.NormalizeWebDAVTrackPath(resolvedPath)
+	return davPath, nil
+}
+
+func runWebDAVPwd(cmd *cobra.Command, args []string) error {
+	ctxName, err := requireWebDAVContext("pwd")
+	if err != nil {
+		return err
+	}
+	settings, err := services.LoadWebDAVSettings(ctxName)
+	if err != nil {
+		return err
+	}
+	fmt.Println(webdavCwd(settings))
+	return nil
+}
+
+func runWebDAVCd(cmd *cobra.Command, args []string) error {
+	ctxName, err := requireWebDAVContext("cd")
+	if err != nil {
+		return err
+	}
+	settings, err := services.LoadWebDAVSettings(ctxName)
+	if err != nil {
+		return err
+	}
+	target, err := resolveWebDAVArg(settings, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		svc, err := services.NewWebDAVService(settings)
+		if err != nil {
+			return err
+		}
+		entry, err := svc.Stat(context.Background(), target)
+		if err != nil {
+			return err
+		}
+		if !entry.IsFolder {
+			return fmt.Errorf("not a folder: %q", target)
+		}
+		settings.Cwd = target
+		err = services.SaveWebDAVSettings(ctxName, settings)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(target)
+	return nil
+}
+
+func runWebDAVLs(cmd *cobra.Command, args []string) error {
+	ctxName, err := requireWebDAVContext("ls")
+	if err != nil {
+		return err
+	}
+	listing, err := longListingFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	settings, err := services.LoadWebDAVSettings(ctxName)
+	if err != nil {
+		return err
+	}
+	target, err := resolveWebDAVArg(settings, args)
+	if err != nil {
+		return err
+	}
+	svc, err := services.NewWebDAVService(settings)
+	if err != nil {
+		return err
+	}
+	entries, err := svc.ListAtPath(context.Background(), target)
+	if err != nil {
+		return fmt.Errorf("list %q: %w", target, err)
+	}
+
+	return printRemoteListing(target, entries, listing)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	filesyspkg "github.com/heroku/self/MetaManager/internal/filesys"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/services"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+func TestWebDAVCmd(t *testing.T) {
+	const davCtxName = "dav-test"
+	dir := t.TempDir()
+	os.Setenv("MM_TEST_CONTEXT_DIR", dir)
+	os.Setenv("MM_CONTEXT", davCtxName)
+	defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+	defer os.Unsetenv("MM_CONTEXT")
+
+	served := filepath.Join(dir, "served")
+	require.NoError(t, os.MkdirAll(filepath.Join(served, "Folder1", "Sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(served, "Folder1", "Sub", "file.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(served, "Folder1", "other.txt"), nil, 0644))
+	h := &webdav.Handler{FileSystem: webdav.Dir(served), LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, _ := r.BasicAuth(); pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	require.NoError(t, defaultStore.Create(davCtxName, filesyspkg.TypeWebDAV))
+	require.NoError(t, EnsureAppDataDir(davCtxName))
+
+	// Tracking needs the server first, a wrong password is refused at login.
+	err := trackInternal(context.Background(), davCtxName, "*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil)
+	require.ErrorContains(t, err, "webdav login")
+	err = webdavLoginInternal(davCtxName, &services.WebDAVSettings{URL: srv.URL, Username: "me", Password: "wrong"})
+	require.ErrorContains(t, err, "401")
+	require.NoError(t, webdavLoginInternal(davCtxName, &services.WebDAVSettings{URL: srv.URL, Username: "me", Password: "secret"}))
+
+	// Relative paths are resolved against the WebDAV working directory.
+	require.NoError(t, runContextCd(contextCdCmd, []string{"Folder1"}))
+	require.Error(t, runContextCd(contextCdCmd, []string{"other.txt"}))
+	settings, err := services.LoadWebDAVSettings(davCtxName)
+	require.NoError(t, err)
+	require.Equal(t, "/Folder1", settings.Cwd)
+	require.NoError(t, runContextPwd(contextPwdCmd, nil))
+	require.NoError(t, runContextLs(contextLsCmd, []string{"Sub"}))
+
+	require.NoError(t, trackInternal(context.Background(), davCtxName, "Sub*", config.TrackedRoot{}, filesyspkg.ScanOptions{}, nil))
+	require.NoError(t, tagAddInternal(davCtxName, []string{"Sub/file.txt", "remote"}))
+
+	rw, err := tree.GetRW(davCtxName)
+	require.NoError(t, err)
+	root, err := rw.Read()
+	require.NoError(t, err)
+	node, err := data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath("webdav:/Folder1/Sub/file.txt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"remote"}, node.Info.(file.NodeInformable).GetTags())
+
+	// Refresh notices entries removed from the server.
+	require.NoError(t, os.Remove(filepath.Join(served, "Folder1", "Sub", "file.txt")))
+	_, err = refreshInternal(context.Background(), davCtxName, "", data.VanishedMark, filesyspkg.ScanOptions{}, nil)
+	require.NoError(t, err)
+	root, err = rw.Read()
+	require.NoError(t, err)
+	node, err = data.NewDirTreeManager(ds.NewTreeManager(root)).FindTreeNodeByAbsPath("webdav:/Folder1/Sub/file.txt")
+	require.NoError(t, err)
+	require.True(t, node.Info.(file.Vanishable).IsVanished())
+}
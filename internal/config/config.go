@@ -1,6 +1,189 @@
 // Package config holds MetaManager configuration types and helpers.
 package config
 
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
 type Config struct {
 	RootPath string
+	// IgnorePatterns are gitignore-style patterns applied to every scan in
+	// this context, in addition to any .mmignore files found while scanning.
+	IgnorePatterns []string `json:",omitempty"`
+	// TrackedRoots are the paths passed to track, rescanned by refresh.
+	TrackedRoots []TrackedRoot `json:",omitempty"`
+	// TrackedRootsRecorded is set once TrackedRoots are kept up to date, telling a
+	// context whose roots were all untracked from one tracked before they were recorded.
+	TrackedRootsRecorded bool `json:",omitempty"`
+	// Roots are the named roots of a local context spanning several directories.
+	Roots []NamedRoot `json:",omitempty"`
+	// XattrAutoPush pushes the tags of nodes to their files' extended attribute
+	// whenever they are added or deleted.
+	XattrAutoPush bool `json:",omitempty"`
+	// XattrPolicy settles conflicts when syncing tags with extended attributes, see
+	// xattr.Policy. Empty means merge.
+	XattrPolicy string `json:",omitempty"`
+}
+
+// NamedRoot is a directory of a multi-root context. Its alias stands for the directory
+// in paths, e.g. "work:/src" for "/home/me/work/src".
+type NamedRoot struct {
+	Alias string
+	Path  string
+}
+
+// TrackedRoot is a tracked path with the scan options it was tracked with. Options
+// are kept as given on the command line so that relative ones, such as an age for
+// NewerThan, are evaluated again on every refresh.
+type TrackedRoot struct {
+	// Path is the resolved path, ending in "*" when tracked recursively.
+	Path      string
+	NoIgnore  bool     `json:",omitempty"`
+	Symlinks  string   `json:",omitempty"`
+	Depth     int      `json:",omitempty"`
+	Include   []string `json:",omitempty"`
+	Exclude   []string `json:",omitempty"`
+	DirsOnly  bool     `json:",omitempty"`
+	MinSize   string   `json:",omitempty"`
+	NewerThan string   `json:",omitempty"`
+	// IntoArchives tracks the content of zip and tar archives.
+	IntoArchives bool `json:",omitempty"`
+}
+
+// Recursive reports whether the root was tracked with a trailing "*".
+func (r TrackedRoot) Recursive() bool {
+	return strings.HasSuffix(r.Path, "*")
+}
+
+// Dir returns Path without the trailing "*".
+func (r TrackedRoot) Dir() string {
+	return strings.TrimSuffix(r.Path, "*")
+}
+
+// AddTrackedRoot records r, replacing an earlier root with the same path.
+func (c *Config) AddTrackedRoot(r TrackedRoot) {
+	c.TrackedRootsRecorded = true
+	for i := range c.TrackedRoots {
+		if c.TrackedRoots[i].Path == r.Path {
+			c.TrackedRoots[i] = r
+			return
+		}
+	}
+	c.TrackedRoots = append(c.TrackedRoots, r)
+}
+
+// RemoveTrackedRoots forgets the roots that untracking pathExp removes from the tree:
+// everything at or below the path, or only below it when pathExp ends in "*".
+func (c *Config) RemoveTrackedRoots(pathExp string) {
+	dir := strings.TrimSuffix(pathExp, "*")
+	childrenOnly := dir != pathExp
+	kept := c.TrackedRoots[:0]
+	for _, r := range c.TrackedRoots {
+		removed := isUnder(dir, r.Dir())
+		if r.Dir() == dir {
+			// "untrack p*" keeps the node p itself
+			removed = !childrenOnly || r.Recursive()
+		}
+		if !removed {
+			kept = append(kept, r)
+		}
+	}
+	c.TrackedRoots = kept
+}
+
+// isUnder reports whether p is strictly below dir. Paths use "/" for both local and Drive nodes.
+func isUnder(dir, p string) bool {
+	return strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/") && p != dir
+}
+
+// Root returns the named root with the given alias.
+func (c *Config) Root(alias string) (NamedRoot, bool) {
+	for _, r := range c.Roots {
+		if r.Alias == alias {
+			return r, true
+		}
+	}
+	return NamedRoot{}, false
+}
+
+// RemoveRoot forgets the named root with the given alias and the tracked roots inside
+// it, returning the removed root.
+func (c *Config) RemoveRoot(alias string) (NamedRoot, bool) {
+	for i, r := range c.Roots {
+		if r.Alias == alias {
+			c.Roots = append(c.Roots[:i], c.Roots[i+1:]...)
+			c.RemoveTrackedRoots(r.Path)
+			return r, true
+		}
+	}
+	return NamedRoot{}, false
+}
+
+// SplitRootAlias splits a path like "work:/src/x" into the alias "work" and the path
+// "/src/x" inside the root. "work:" is the root itself and "work:*" everything below
+// it. ok is false when p does not start with an alias.
+func SplitRootAlias(p string) (alias, rest string, ok bool) {
+	i := strings.Index(p, ":")
+	if i == -1 || !ValidRootAlias(p[:i]) {
+		return "", "", false
+	}
+	rest = p[i+1:]
+	if rest != "" && rest != "*" && !strings.HasPrefix(rest, "/") {
+		return "", "", false
+	}
+	return p[:i], rest, true
+}
+
+// ValidRootAlias reports whether alias can name a root: letters, digits, "-" and "_",
+// starting with a letter.
+func ValidRootAlias(alias string) bool {
+	if alias == "" {
+		return false
+	}
+	for i, c := range alias {
+		letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || !(c >= '0' && c <= '9') && c != '-' && c != '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Path returns the path of config.json for the given context.
+func Path(contextName string) (string, error) {
+	appDir, err := utils.GetAppDataDirForContext(contextName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, utils.ConfigFileName), nil
+}
+
+// Load reads config.json for the given context. A missing file yields an empty Config.
+func Load(contextName string) (*Config, error) {
+	path, err := Path(contextName)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	err = utils.ReadJSON(path, &cfg)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &cfg, nil
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Save writes cfg to config.json of the given context.
+func Save(contextName string, cfg *Config) error {
+	path, err := Path(contextName)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, cfg, false)
 }
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackedRoots(t *testing.T) {
+	roots := func(paths ...string) []TrackedRoot {
+		list := []TrackedRoot{}
+		for _, p := range paths {
+			list = append(list, TrackedRoot{Path: p})
+		}
+		return list
+	}
+	all := []string{"/a", "/a*", "/a/b*", "/ab*", "gdrive:/", "gdrive:/F*"}
+
+	tests := []struct {
+		untrack string
+		kept    []string
+	}{
+		{"/a", []string{"/ab*", "gdrive:/", "gdrive:/F*"}},
+		{"/a*", []string{"/a", "/ab*", "gdrive:/", "gdrive:/F*"}},
+		{"/a/b", []string{"/a", "/a*", "/ab*", "gdrive:/", "gdrive:/F*"}},
+		{"gdrive:/*", []string{"/a", "/a*", "/a/b*", "/ab*", "gdrive:/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.untrack, func(t *testing.T) {
+			cfg := &Config{TrackedRoots: roots(all...)}
+			cfg.RemoveTrackedRoots(tt.untrack)
+			require.Equal(t, roots(tt.kept...), cfg.TrackedRoots)
+		})
+	}
+
+	cfg := &Config{}
+	cfg.AddTrackedRoot(TrackedRoot{Path: "/a*"})
+	cfg.AddTrackedRoot(TrackedRoot{Path: "/a*", Depth: 2})
+	require.Equal(t, []TrackedRoot{{Path: "/a*", Depth: 2}}, cfg.TrackedRoots)
+}
+
+func TestNamedRoots(t *testing.T) {
+	tests := map[string][]string{
+		"work:/src/x": {"work", "/src/x"},
+		"work:":       {"work", ""},
+		"nas_2:*":     {"nas_2", "*"},
+	}
+	for p, expected := range tests {
+		alias, rest, ok := SplitRootAlias(p)
+		require.True(t, ok, p)
+		require.Equal(t, expected, []string{alias, rest}, p)
+	}
+	for _, p := range []string{"/work:/x", "work", "work:x", "2nd:/x", "my root:/x"} {
+		_, _, ok := SplitRootAlias(p)
+		require.False(t, ok, p)
+	}
+
+	cfg := &Config{
+		Roots:        []NamedRoot{{Alias: "work", Path: "/home/me/work"}, {Alias: "data", Path: "/data"}},
+		TrackedRoots: []TrackedRoot{{Path: "/home/me/work*"}, {Path: "/data/sets*"}},
+	}
+	root, ok := cfg.Root("data")
+	require.True(t, ok)
+	require.Equal(t, "/data", root.Path)
+	_, ok = cfg.RemoveRoot("nas")
+	require.False(t, ok)
+	root, ok = cfg.RemoveRoot("data")
+	require.True(t, ok)
+	require.Equal(t, "/data", root.Path)
+	require.Equal(t, []NamedRoot{{Alias: "work", Path: "/home/me/work"}}, cfg.Roots)
+	require.Equal(t, []TrackedRoot{{Path: "/home/me/work*"}}, cfg.TrackedRoots)
+}
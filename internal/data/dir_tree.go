@@ -3,9 +3,9 @@ package data
 import (
 	"errors"
 	"fmt"
-	"path/filepath"
 	"regexp"
 	"slices"
+	"sort"
 	"strings"
 
 	"github.com/heroku/self/MetaManager/internal/cmderror"
@@ -89,6 +89,68 @@ func (mg *DirTreeManager) findTreeNodesByRegexInternal(pattern string, it ds.Tre
 	return nodesFound, nil
 }
 
+// NodeMatcher selects nodes, e.g. a search query.
+type NodeMatcher interface {
+	Match(file.NodeInformable) bool
+}
+
+// FindTreeNodesByMatcher returns the nodes selected by m, in tree order.
+func (mg *DirTreeManager) FindTreeNodesByMatcher(m NodeMatcher) ([]*ds.TreeNode, error) {
+	nodesFound := []*ds.TreeNode{}
+	it := ds.NewTreeIterator(mg.TreeManager)
+	for it.HasNext() {
+		curNode, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		nodeInfo, ok := curNode.Info.(file.NodeInformable)
+		if !ok {
+			return nil, errors.New("info not convertiable to NodeInformable")
+		}
+		if m.Match(nodeInfo) {
+			nodesFound = append(nodesFound, curNode)
+		}
+	}
+	return nodesFound, nil
+}
+
+// FindTreeNodesByPaths returns the nodes whose path is in the sorted list paths, in
+// tree order. Only the branches leading to one of them are walked.
+func (mg *DirTreeManager) FindTreeNodesByPaths(paths []string) ([]*ds.TreeNode, error) {
+	nodesFound := []*ds.TreeNode{}
+	if mg.Root == nil || len(paths) == 0 {
+		return nodesFound, nil
+	}
+	// below reports whether p or a path starting with it is wanted.
+	below := func(p string) bool {
+		i := sort.SearchStrings(paths, p)
+		return i < len(paths) && strings.HasPrefix(paths[i], p)
+	}
+	queue := []*ds.TreeNode{mg.Root}
+	for len(queue) > 0 {
+		curNode := queue[0]
+		queue = queue[1:]
+		nodeInfo, ok := curNode.Info.(file.NodeInformable)
+		if !ok {
+			return nil, errors.New("info not convertiable to NodeInformable")
+		}
+		p := nodeInfo.GetAbsPath()
+		if i := sort.SearchStrings(paths, p); i < len(paths) && paths[i] == p {
+			nodesFound = append(nodesFound, curNode)
+		}
+		for _, child := range curNode.Children {
+			if child == nil || child.Info == nil {
+				continue
+			}
+			childInfo, ok := child.Info.(file.NodeInformable)
+			if !ok || below(childInfo.GetAbsPath()) {
+				queue = append(queue, child)
+			}
+		}
+	}
+	return nodesFound, nil
+}
+
 func (mg *DirTreeManager) SplitChildrenFromPath(path string) error {
 	curTreeNode, err := mg.FindTreeNodeByAbsPath(path)
 	if err != nil {
@@ -104,7 +166,10 @@ func (mg *DirTreeManager) SplitNodeWithPath(path string) error {
 		return nil
 	}
 
-	parentPath := filepath.Join(path, "..")
+	parentPath, ok := file.ParentPath(path)
+	if !ok {
+		return &cmderror.InvalidPath{}
+	}
 	parentTreeNode, err := mg.FindTreeNodeByAbsPath(parentPath)
 	if err != nil {
 		return err
@@ -135,6 +200,57 @@ func (mg *DirTreeManager) MergeNodeWithPath(path string) error {
 	return mg.MergeNode(treeNode)
 }
 
+// MoveNode moves the node at oldPath, with everything below it, to newPath so
+// that tags and ids follow a renamed file. A node already at newPath is replaced.
+func (mg *DirTreeManager) MoveNode(oldPath, newPath string) error {
+	treeNode, err := mg.FindTreeNodeByAbsPath(oldPath)
+	if err != nil {
+		return err
+	}
+	rootPath := mg.Root.Info.(file.NodeInformable).GetAbsPath()
+	inTree := isPathPrefixOrEqual(rootPath, newPath) && newPath != rootPath
+	if mg.IsMultiRoot() {
+		inTree = mg.rootFor(newPath) != nil && !mg.IsNamedRoot(newPath)
+	}
+	parentPath, ok := file.ParentPath(newPath)
+	if treeNode == mg.Root || mg.isNamedRootNode(treeNode) || !ok ||
+		isPathPrefixOrEqual(oldPath+"/", newPath) || !inTree {
+		return &cmderror.InvalidOperation{}
+	}
+
+	mg.removeNode(mg.Root, treeNode)
+	if replaced, err := mg.FindTreeNodeByAbsPath(newPath); err == nil {
+		mg.removeNode(mg.Root, replaced)
+	}
+	if err := mg.MergeNodeWithPath(parentPath); err != nil {
+		return err
+	}
+	parent, err := mg.FindTreeNodeByAbsPath(parentPath)
+	if err != nil {
+		return err
+	}
+
+	iter := ds.NewTreeIterator(ds.NewTreeManager(treeNode))
+	for iter.HasNext() {
+		curNode, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		info, ok := curNode.Info.(file.NodeInformable)
+		if !ok {
+			return &cmderror.Unexpected{}
+		}
+		relocatable, ok := curNode.Info.(file.Relocatable)
+		if !ok {
+			return &cmderror.Unexpected{}
+		}
+		relocatable.SetAbsPath(newPath + strings.TrimPrefix(info.GetAbsPath(), oldPath))
+	}
+	logrus.Debugf("[merge] moved %q to %q", oldPath, newPath)
+	parent.AddChild(treeNode)
+	return nil
+}
+
 // isPathPrefixOrEqual returns true when root is equal to path or path is under root (root is a path prefix of path).
 // Uses "/" as the path separator for consistent behavior with local and gdrive paths.
 func isPathPrefixOrEqual(root, path string) bool {
@@ -186,30 +302,40 @@ func (mg *DirTreeManager) MergeNode(treeNode *ds.TreeNode) error {
 		secPath := secPathOrig
 		nodeCount++
 
-		// Absolute path of root (firPath) must be a prefix of the node path (secPath), or equal.
-		if !isPathPrefixOrEqual(rootPath, secPathOrig) {
+		firPath := rootPath
+		if rootPath == file.RootsPath {
+			// With named roots, the node goes below the root containing it.
+			top := mg.rootFor(secPathOrig)
+			if top == nil {
+				return fmt.Errorf("merge: node path %q is not under a root of the tree", secPathOrig)
+			}
+			firPath = top.Info.(file.NodeInformable).GetAbsPath()
+		} else if !isPathPrefixOrEqual(rootPath, secPathOrig) {
+			// Absolute path of root (firPath) must be a prefix of the node path (secPath), or equal.
 			return fmt.Errorf("merge: root path %q is not a prefix of node path %q", rootPath, secPathOrig)
 		}
 
 		midPaths := make([]string, 0)
-		firPath := rootPath
 
 		for firPath != secPath && len(secPath) > 0 {
 			midPaths = append(midPaths, secPath)
 			// secPath = filepath.Join(secPath, "..")
-			lastIndex := strings.LastIndex(secPath, "/")
-			if lastIndex == -1 {
+			parentPath, ok := file.ParentPath(secPath)
+			if !ok {
 				break
 			}
-			secPath = secPath[:strings.LastIndex(secPath, "/")]
+			secPath = parentPath
 
 			logrus.Debugf("[merge] secPath=%q", secPath)
 		}
 
 		slices.Reverse(midPaths)
+		if rootPath == file.RootsPath {
+			midPaths = append([]string{firPath}, midPaths...)
+		}
 		logrus.Debugf("[merge] node %d path=%q midPaths=%v", nodeCount, secPathOrig, midPaths)
 
-		err = mg.createPathNodes(midPaths)
+		err = mg.createPathNodes(midPaths, got)
 		if err != nil {
 			logrus.Debugf("[merge] createPathNodes error: %v", err)
 			return err
@@ -220,11 +346,14 @@ func (mg *DirTreeManager) MergeNode(treeNode *ds.TreeNode) error {
 	return nil
 }
 
-func (mg *DirTreeManager) createPathNodes(paths []string) error {
-	return mg.createPathNodesInternal(mg.Root, paths, 0)
+// createPathNodes makes sure a node exists for every path in paths, each a child of the previous one.
+// A node created for the last path takes info, so what the scanner found about it (e.g. the
+// kind of node) is kept; intermediate nodes only get their path.
+func (mg *DirTreeManager) createPathNodes(paths []string, info ds.TreeNodeInformable) error {
+	return mg.createPathNodesInternal(mg.Root, paths, 0, info)
 }
 
-func (mg *DirTreeManager) createPathNodesInternal(curNode *ds.TreeNode, paths []string, index int) error {
+func (mg *DirTreeManager) createPathNodesInternal(curNode *ds.TreeNode, paths []string, index int, info ds.TreeNodeInformable) error {
 	if curNode == nil {
 		return &cmderror.InvalidOperation{}
 	}
@@ -252,17 +381,56 @@ func (mg *DirTreeManager) createPathNodesInternal(curNode *ds.TreeNode, paths []
 		}
 	}
 
+	last := index == len(paths)-1
 	if nextNode == nil {
 		logrus.Debugf("[merge] createPathNodes index=%d curPath=%q creating node for %q", index, curPath, reqPath)
-		nextNode, err = file.CreateTreeNodeFromPath(reqPath)
-		if err != nil {
-			logrus.Debugf("[merge] CreateTreeNodeFromPath %q error: %v", reqPath, err)
-			return err
+		if last && info != nil {
+			nextNode = ds.NewTreeNode(info)
+		} else {
+			nextNode, err = file.CreateTreeNodeFromPath(reqPath)
+			if err != nil {
+				logrus.Debugf("[merge] CreateTreeNodeFromPath %q error: %v", reqPath, err)
+				return err
+			}
 		}
 		curNode.Children = append(curNode.Children, nextNode)
+	} else if last && info != nil && info.Name() != nextNode.Info.Name() {
+		// The node changed kind since it was tracked (e.g. a directory replaced by a symlink).
+		// Take the new info but keep what the user attached to the node.
+		logrus.Debugf("[merge] node %q changed kind %s -> %s", reqPath, nextNode.Info.Name(), info.Name())
+		if err := carryOverUserData(nextNode.Info, info); err != nil {
+			return err
+		}
+		nextNode.Info = info
+	} else if last && info != nil {
+		// A rescan of a tracked file refreshes what was recorded about it.
+		from, okFrom := info.(file.Stated)
+		to, okTo := nextNode.Info.(file.Stated)
+		if okFrom && okTo {
+			to.CopyStat(from)
+		}
 	}
 
-	return mg.createPathNodesInternal(nextNode, paths, index+1)
+	return mg.createPathNodesInternal(nextNode, paths, index+1, info)
+}
+
+// carryOverUserData copies tags and id from one node info to another.
+func carryOverUserData(from, to ds.TreeNodeInformable) error {
+	src, ok := from.(file.NodeInformable)
+	if !ok {
+		return &cmderror.Unexpected{}
+	}
+	dst, ok := to.(file.NodeInformable)
+	if !ok {
+		return &cmderror.Unexpected{}
+	}
+	for _, tag := range src.GetTags() {
+		dst.AddTag(tag)
+	}
+	if src.GetId() != "" {
+		dst.SetId(src.GetId())
+	}
+	return nil
 }
 
 func (mg *DirTreeManager) FindFileNodeById(id string) (file.NodeInformable, error) {
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
 	"github.com/heroku/self/MetaManager/internal/utils"
 	"github.com/stretchr/testify/require"
 )
@@ -115,3 +116,68 @@ func TestSplitNodeWithPath(t *testing.T) {
 	testExectutor.Execute()
 
 }
+
+func TestMergeNodeKeepsScannedInfo(t *testing.T) {
+	dm := NewDirTreeManager(ds.NewTreeManager(nil))
+	require.NoError(t, dm.MergeNodeWithPath("/r"))
+	require.NoError(t, dm.MergeNodeWithPath("/r/a/link"))
+
+	linkNode, err := dm.FindTreeNodeByAbsPath("/r/a/link")
+	require.NoError(t, err)
+	linkNode.Info.(file.NodeInformable).AddTag("keep")
+	linkNode.Info.(file.NodeInformable).SetId("l1")
+
+	// A rescan finds that the path is a symlink: the kind changes, tags and id stay.
+	scanned := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/r/a"}})
+	scanned.AddChild(ds.NewTreeNode(file.NewSymlinkNode("/r/a/link", "../b", nil)))
+	scanned.AddChild(ds.NewTreeNode(file.NewSymlinkNode("/r/a/other", "/tmp", nil)))
+	require.NoError(t, dm.MergeNode(scanned))
+	utils.ValidateNodeCnt(t, dm.Root, 4)
+
+	for path, target := range map[string]string{"/r/a/link": "../b", "/r/a/other": "/tmp"} {
+		node, err := dm.FindTreeNodeByAbsPath(path)
+		require.NoError(t, err)
+		sn, ok := node.Info.(*file.SymlinkNode)
+		require.True(t, ok, path)
+		require.Equal(t, target, sn.Target)
+	}
+	info, err := dm.FindNodeByAbsPath("/r/a/link")
+	require.NoError(t, err)
+	require.Equal(t, []string{"keep"}, info.GetTags())
+	require.Equal(t, "l1", info.GetId())
+}
+
+func TestMoveNode(t *testing.T) {
+	dm := NewDirTreeManager(ds.NewTreeManager(nil))
+	for _, path := range []string{"/r", "/r/a/b/f", "/r/a/g", "/r/c/old"} {
+		require.NoError(t, dm.MergeNodeWithPath(path))
+	}
+	utils.ValidateNodeCnt(t, dm.Root, 7)
+	fNode, err := dm.FindNodeByAbsPath("/r/a/b/f")
+	require.NoError(t, err)
+	fNode.AddTag("keep")
+	fNode.SetId("f1")
+
+	// Moving a directory moves everything below it, parents are created as needed.
+	require.NoError(t, dm.MoveNode("/r/a/b", "/r/x/y"))
+	utils.ValidateNodeCnt(t, dm.Root, 8)
+	_, err = dm.FindTreeNodeByAbsPath("/r/a/b")
+	require.Error(t, err)
+	info, err := dm.FindNodeByAbsPath("/r/x/y/f")
+	require.NoError(t, err)
+	require.Equal(t, []string{"keep"}, info.GetTags())
+	require.Equal(t, "f1", info.GetId())
+
+	// A node at the destination is replaced.
+	require.NoError(t, dm.MoveNode("/r/x/y/f", "/r/c/old"))
+	utils.ValidateNodeCnt(t, dm.Root, 7)
+	info, err = dm.FindNodeByAbsPath("/r/c/old")
+	require.NoError(t, err)
+	require.Equal(t, "f1", info.GetId())
+
+	require.Error(t, dm.MoveNode("/r/missing", "/r/z"))
+	require.Error(t, dm.MoveNode("/r/a", "/r/a/g/a"))
+	require.Error(t, dm.MoveNode("/r/a", "/elsewhere/a"))
+	require.Error(t, dm.MoveNode("/r", "/r/z"))
+	utils.ValidateNodeCnt(t, dm.Root, 7)
+}
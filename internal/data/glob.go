@@ -0,0 +1,130 @@
+package data
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+)
+
+// globMeta are the characters that make a path segment a pattern.
+const globMeta = "*?["
+
+// Glob matches absolute paths, local or virtual like "gdrive:/Folder/x", against a
+// pattern. "*", "?" and "[...]" match within a path segment as in path.Match, and a
+// "**" segment spans any number of segments, none included. A "\" escapes the
+// character after it, so "\[" matches "[".
+type Glob struct {
+	pattern string
+	segs    []string
+}
+
+// IsGlob reports whether p is a glob pattern. A single trailing "*" keeps its meaning
+// of "recursive", so "/src*" and "/src/*" are not globs while "/src/*.go" and
+// "/src/**" are.
+func IsGlob(p string) bool {
+	return strings.ContainsAny(strings.TrimSuffix(p, "*"), globMeta)
+}
+
+// CompileGlob checks pattern and returns its Glob.
+func CompileGlob(pattern string) (*Glob, error) {
+	segs := strings.Split(pattern, "/")
+	for _, seg := range segs {
+		if seg == "**" {
+			continue
+		}
+		if strings.Contains(seg, "**") {
+			return nil, fmt.Errorf("invalid glob %q: ** must be a whole path segment", pattern)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	return &Glob{pattern: pattern, segs: segs}, nil
+}
+
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Match reports whether p matches the whole pattern.
+func (g *Glob) Match(p string) bool {
+	return matchGlobSegments(g.segs, strings.Split(p, "/"))
+}
+
+// CanMatchBelow reports whether paths below dir may match, so that walks can skip
+// directories that cannot hold a match.
+func (g *Glob) CanMatchBelow(dir string) bool {
+	pattern, names := g.segs, strings.Split(strings.TrimSuffix(dir, "/"), "/")
+	for ; len(names) > 0; pattern, names = pattern[1:], names[1:] {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pattern[0], names[0]); !ok {
+			return false
+		}
+	}
+	return len(pattern) > 0
+}
+
+// Base returns the longest leading directory of the pattern without pattern
+// characters, where a walk for matches starts.
+func (g *Glob) Base() string {
+	i := 0
+	for i < len(g.segs)-1 && !strings.ContainsAny(g.segs[i], globMeta) {
+		i++
+	}
+	base := strings.Join(g.segs[:i], "/")
+	if base == "" {
+		return "/"
+	}
+	return base
+}
+
+// matchGlobSegments matches path segments against pattern segments.
+func matchGlobSegments(pattern, names []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchGlobSegments(pattern[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], names[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, names = pattern[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// FindTreeNodesByGlob returns the nodes whose path matches g, in tree order.
+func (mg *DirTreeManager) FindTreeNodesByGlob(g *Glob) ([]*ds.TreeNode, error) {
+	found := []*ds.TreeNode{}
+	it := ds.NewTreeIterator(mg.TreeManager)
+	for it.HasNext() {
+		curNode, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		info, ok := curNode.Info.(file.NodeInformable)
+		if !ok {
+			return nil, fmt.Errorf("info not convertible to NodeInformable")
+		}
+		if g.Match(info.GetAbsPath()) {
+			found = append(found, curNode)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/r/src/**/*_test.go", "/r/src/a_test.go", true},
+		{"/r/src/**/*_test.go", "/r/src/x/y/b_test.go", true},
+		{"/r/src/**/*_test.go", "/r/src/x/y/b.go", false},
+		{"/r/**/node_modules", "/r/node_modules", true},
+		{"/r/**/node_modules", "/r/a/node_modules", true},
+		{"/r/**/node_modules", "/r/a/node_modules/x", false},
+		{"/r/**", "/r", true},
+		{"/r/*.md", "/r/a/b.md", false},
+		{"/r/doc?/[ab].md", "/r/docs/a.md", true},
+		{"gdrive:/**/Reports", "gdrive:/Work/Reports", true},
+		{"/r/old.zip!/**/*.go", "/r/old.zip!/src/main.go", true},
+	}
+	for _, tt := range tests {
+		g, err := CompileGlob(tt.pattern)
+		require.NoError(t, err, tt.pattern)
+		require.Equal(t, tt.match, g.Match(tt.path), "%s %s", tt.pattern, tt.path)
+	}
+
+	_, err := CompileGlob("/r/a**/b")
+	require.Error(t, err)
+	_, err = CompileGlob("/r/[a")
+	require.Error(t, err)
+
+	require.True(t, IsGlob("/r/*.go"))
+	require.True(t, IsGlob("/r/**"))
+	require.False(t, IsGlob("/r/src*"))
+	require.False(t, IsGlob("/r/src/*"))
+
+	g, err := CompileGlob("/r/src/*/x/**")
+	require.NoError(t, err)
+	require.Equal(t, "/r/src", g.Base())
+	require.True(t, g.CanMatchBelow("/r"))
+	require.True(t, g.CanMatchBelow("/r/src/a/x/deep"))
+	require.False(t, g.CanMatchBelow("/r/lib"))
+	require.False(t, g.CanMatchBelow("/r/src/a/y"))
+	g, err = CompileGlob("/*.md")
+	require.NoError(t, err)
+	require.Equal(t, "/", g.Base())
+}
+
+func TestFindTreeNodesByGlob(t *testing.T) {
+	dm := NewDirTreeManager(ds.NewTreeManager(nil))
+	for _, path := range []string{"/r", "/r/a/node_modules/x", "/r/b/c/node_modules", "/r/b/main_test.go"} {
+		require.NoError(t, dm.MergeNodeWithPath(path))
+	}
+	g, err := CompileGlob("/r/**/node_modules")
+	require.NoError(t, err)
+	nodes, err := dm.FindTreeNodesByGlob(g)
+	require.NoError(t, err)
+	paths := []string{}
+	for _, n := range nodes {
+		paths = append(paths, n.Info.(file.NodeInformable).GetAbsPath())
+	}
+	require.ElementsMatch(t, []string{"/r/a/node_modules", "/r/b/c/node_modules"}, paths)
+}
+
+func TestFindTreeNodesByPaths(t *testing.T) {
+	dm := NewDirTreeManager(ds.NewTreeManager(nil))
+	for _, path := range []string{"/r", "/r/a/x", "/r/a-b/y", "/r/b/c/z"} {
+		require.NoError(t, dm.MergeNodeWithPath(path))
+	}
+	nodes, err := dm.FindTreeNodesByPaths([]string{"/r/a", "/r/b/c/z", "/r/missing"})
+	require.NoError(t, err)
+	paths := []string{}
+	for _, n := range nodes {
+		paths = append(paths, n.Info.(file.NodeInformable).GetAbsPath())
+	}
+	require.Equal(t, []string{"/r/a", "/r/b/c/z"}, paths)
+}
@@ -0,0 +1,241 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/sirupsen/logrus"
+)
+
+// VanishedPolicy is what refresh does with tracked nodes whose file no longer exists.
+type VanishedPolicy string
+
+const (
+	// VanishedMark keeps vanished nodes, with their metadata, flagged as vanished.
+	VanishedMark VanishedPolicy = "mark"
+	// VanishedRemove removes vanished nodes from the tree.
+	VanishedRemove VanishedPolicy = "remove"
+)
+
+// ParseVanishedPolicy parses the value of a --vanished flag.
+func ParseVanishedPolicy(s string) (VanishedPolicy, error) {
+	switch p := VanishedPolicy(s); p {
+	case VanishedMark, VanishedRemove:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid vanished policy %q, expected mark or remove", s)
+}
+
+// ExistsFunc reports whether the file behind a tracked path still exists.
+type ExistsFunc func(path string) (bool, error)
+
+// RefreshResult counts what refreshing one tracked root changed.
+type RefreshResult struct {
+	// Added are scanned nodes that were not tracked yet
+	Added int
+	// Restored are nodes marked vanished earlier that exist again
+	Restored int
+	// Vanished are tracked nodes whose file no longer exists, including nodes below them
+	Vanished int
+	// Removed holds the nodes taken out of the tree under VanishedRemove
+	Removed []file.NodeInformable
+}
+
+// Refresh reconciles the tracked subtree at rootPath with scanned, a fresh scan of it.
+// Scanned nodes are merged in. Tracked nodes missing from scanned are only considered
+// vanished once exists confirms it, since filters or unreadable directories also keep
+// files out of a scan. Below a recursive root every tracked node is checked; otherwise
+// only the root itself. scanned may be nil when the scan failed.
+func (mg *DirTreeManager) Refresh(rootPath string, recursive bool, scanned *ds.TreeNode, exists ExistsFunc, policy VanishedPolicy) (*RefreshResult, error) {
+	res := &RefreshResult{}
+	seen := map[string]bool{}
+	if scanned != nil {
+		if err := collectPaths(scanned, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	tracked := map[string]*ds.TreeNode{}
+	rootNode, err := mg.FindTreeNodeByAbsPath(rootPath)
+	if err == nil {
+		if recursive {
+			err = indexPaths(rootNode, tracked)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			tracked[rootPath] = rootNode
+		}
+	} else {
+		rootNode = nil
+	}
+
+	for p := range seen {
+		node, ok := tracked[p]
+		if !ok {
+			res.Added++
+			continue
+		}
+		if v, ok := node.Info.(file.Vanishable); ok && v.IsVanished() {
+			v.SetVanished(false)
+			res.Restored++
+		}
+	}
+
+	if scanned != nil {
+		err = mg.MergeNode(scanned)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rootNode == nil {
+		return res, nil
+	}
+
+	r := &refresher{seen: seen, exists: exists, policy: policy, res: res}
+	rootVanished, err := r.isVanished(rootNode, false)
+	if err != nil {
+		return nil, err
+	}
+	if rootVanished && (rootNode == mg.Root || mg.isNamedRootNode(rootNode)) {
+		logrus.Debugf("[refresh] root of the tree %q vanished, marking it instead of removing it", rootPath)
+		r.policy = VanishedMark
+	}
+	if recursive || rootVanished {
+		err = r.walk(rootNode, rootVanished)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rootVanished {
+		err = r.vanish(rootNode)
+		if err != nil {
+			return nil, err
+		}
+		if r.policy == VanishedRemove {
+			mg.removeNode(mg.Root, rootNode)
+		}
+	}
+	return res, nil
+}
+
+type refresher struct {
+	seen   map[string]bool
+	exists ExistsFunc
+	policy VanishedPolicy
+	res    *RefreshResult
+}
+
+func (r *refresher) isVanished(node *ds.TreeNode, parentVanished bool) (bool, error) {
+	if parentVanished {
+		return true, nil
+	}
+	info, ok := node.Info.(file.NodeInformable)
+	if !ok {
+		return false, &cmderror.Unexpected{}
+	}
+	p := info.GetAbsPath()
+	if r.seen[p] {
+		return false, nil
+	}
+	found, err := r.exists(p)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		logrus.Debugf("[refresh] %q vanished", p)
+		return true, nil
+	}
+	if v, ok := node.Info.(file.Vanishable); ok && v.IsVanished() {
+		v.SetVanished(false)
+		r.res.Restored++
+	}
+	return false, nil
+}
+
+// walk handles the children of node, removing vanished ones under VanishedRemove.
+func (r *refresher) walk(node *ds.TreeNode, nodeVanished bool) error {
+	kept := node.Children[:0]
+	for _, child := range node.Children {
+		vanished, err := r.isVanished(child, nodeVanished)
+		if err != nil {
+			return err
+		}
+		err = r.walk(child, vanished)
+		if err != nil {
+			return err
+		}
+		if vanished {
+			err = r.vanish(child)
+			if err != nil {
+				return err
+			}
+			if r.policy == VanishedRemove {
+				continue
+			}
+		}
+		kept = append(kept, child)
+	}
+	node.Children = kept
+	return nil
+}
+
+func (r *refresher) vanish(node *ds.TreeNode) error {
+	r.res.Vanished++
+	if r.policy == VanishedRemove {
+		info, ok := node.Info.(file.NodeInformable)
+		if !ok {
+			return &cmderror.Unexpected{}
+		}
+		r.res.Removed = append(r.res.Removed, info)
+		return nil
+	}
+	if v, ok := node.Info.(file.Vanishable); ok {
+		v.SetVanished(true)
+	}
+	return nil
+}
+
+// removeNode removes target from the subtree at cur and reports whether it was found.
+func (mg *DirTreeManager) removeNode(cur, target *ds.TreeNode) bool {
+	for i, child := range cur.Children {
+		if child == target {
+			cur.Children = append(cur.Children[:i], cur.Children[i+1:]...)
+			return true
+		}
+		if mg.removeNode(child, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func collectPaths(node *ds.TreeNode, paths map[string]bool) error {
+	info, ok := node.Info.(file.NodeInformable)
+	if !ok {
+		return &cmderror.Unexpected{}
+	}
+	paths[info.GetAbsPath()] = true
+	for _, child := range node.Children {
+		if err := collectPaths(child, paths); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexPaths(node *ds.TreeNode, index map[string]*ds.TreeNode) error {
+	info, ok := node.Info.(file.NodeInformable)
+	if !ok {
+		return &cmderror.Unexpected{}
+	}
+	index[info.GetAbsPath()] = node
+	for _, child := range node.Children {
+		if err := indexPaths(child, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func trackedTree(t *testing.T, paths ...string) *DirTreeManager {
+	dm := NewDirTreeManager(ds.NewTreeManager(nil))
+	for _, p := range paths {
+		require.NoError(t, dm.MergeNodeWithPath(p))
+	}
+	return dm
+}
+
+func TestRefresh(t *testing.T) {
+	existing := map[string]bool{"/r": true, "/r/a": true, "/r/a/filtered": true, "/r/new": true}
+	exists := func(p string) (bool, error) { return existing[p], nil }
+
+	t.Run("mark", func(t *testing.T) {
+		dm := trackedTree(t, "/r", "/r/a/filtered", "/r/a/gone", "/r/b/x", "/r/b/y")
+		node, err := dm.FindNodeByAbsPath("/r/b/x")
+		require.NoError(t, err)
+		node.AddTag("t")
+
+		// filtered is not in the scan but still exists
+		res, err := dm.Refresh("/r", true, trackedTree(t, "/r", "/r/a", "/r/new").Root, exists, VanishedMark)
+		require.NoError(t, err)
+		require.Equal(t, 1, res.Added)
+		// gone, b, x, y
+		require.Equal(t, 4, res.Vanished)
+		require.Empty(t, res.Removed)
+		// r, a, filtered, gone, b, x, y, new
+		utils.ValidateNodeCnt(t, dm.Root, 8)
+
+		for p, vanished := range map[string]bool{"/r/a/filtered": false, "/r/a/gone": true, "/r/b": true, "/r/b/x": true, "/r/new": false} {
+			node, err := dm.FindNodeByAbsPath(p)
+			require.NoError(t, err)
+			require.Equal(t, vanished, node.(file.Vanishable).IsVanished(), p)
+		}
+
+		existing["/r/b"] = true
+		defer delete(existing, "/r/b")
+		res, err = dm.Refresh("/r", true, trackedTree(t, "/r", "/r/b").Root, exists, VanishedMark)
+		require.NoError(t, err)
+		require.Equal(t, 1, res.Restored)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		dm := trackedTree(t, "/r", "/r/a/filtered", "/r/a/gone", "/r/b/x")
+		res, err := dm.Refresh("/r", true, trackedTree(t, "/r", "/r/a").Root, exists, VanishedRemove)
+		require.NoError(t, err)
+		require.Equal(t, 3, res.Vanished)
+		require.Len(t, res.Removed, 3)
+		// r, a, filtered
+		utils.ValidateNodeCnt(t, dm.Root, 3)
+	})
+
+	t.Run("non recursive root", func(t *testing.T) {
+		dm := trackedTree(t, "/r", "/r/b/x", "/r/b/y")
+		res, err := dm.Refresh("/r/b/x", false, nil, exists, VanishedRemove)
+		require.NoError(t, err)
+		require.Equal(t, 1, res.Vanished)
+		// y is not below the refreshed root and left alone
+		utils.ValidateNodeCnt(t, dm.Root, 3)
+	})
+}
+
+func TestParseVanishedPolicy(t *testing.T) {
+	p, err := ParseVanishedPolicy("remove")
+	require.NoError(t, err)
+	require.Equal(t, VanishedRemove, p)
+
+	_, err = ParseVanishedPolicy("delete")
+	require.Error(t, err)
+}
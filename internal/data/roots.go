@@ -0,0 +1,174 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/sirupsen/logrus"
+)
+
+// IsMultiRoot reports whether the tree has named roots, i.e. its root is the
+// file.RootsPath node and each child is a root directory.
+func (mg *DirTreeManager) IsMultiRoot() bool {
+	if mg.TreeManager == nil || mg.Root == nil {
+		return false
+	}
+	info, ok := mg.Root.Info.(file.NodeInformable)
+	return ok && info.GetAbsPath() == file.RootsPath
+}
+
+// IsNamedRoot reports whether p is the path of one of the named roots.
+func (mg *DirTreeManager) IsNamedRoot(p string) bool {
+	top := mg.rootFor(p)
+	return top != nil && top.Info.(file.NodeInformable).GetAbsPath() == p
+}
+
+// rootFor returns the named root containing p, or nil when p is outside every root
+// or the tree has a single root.
+func (mg *DirTreeManager) rootFor(p string) *ds.TreeNode {
+	if !mg.IsMultiRoot() {
+		return nil
+	}
+	for _, top := range mg.Root.Children {
+		info, ok := top.Info.(file.NodeInformable)
+		if ok && isInRoot(info.GetAbsPath(), p) {
+			return top
+		}
+	}
+	return nil
+}
+
+// isNamedRootNode reports whether node is one of the named roots.
+func (mg *DirTreeManager) isNamedRootNode(node *ds.TreeNode) bool {
+	return mg.IsMultiRoot() && slices.Contains(mg.Root.Children, node)
+}
+
+// isInRoot reports whether p is root or below it. Unlike isPathPrefixOrEqual, "/data2"
+// is not in "/data".
+func isInRoot(root, p string) bool {
+	return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
+
+// AddRoot adds the directory p as a named root. A tree with a single root is turned
+// into one with named roots first; everything it tracks must then be inside p, as
+// nodes outside the roots cannot be kept.
+func (mg *DirTreeManager) AddRoot(p string) error {
+	logrus.Debugf("[roots] AddRoot %q multiRoot=%v", p, mg.IsMultiRoot())
+	if mg.TreeManager == nil || mg.Root == nil {
+		return &cmderror.InvalidOperation{}
+	}
+	if !mg.IsMultiRoot() {
+		return mg.convertToRoots(p)
+	}
+	for _, top := range mg.Root.Children {
+		topPath := top.Info.(file.NodeInformable).GetAbsPath()
+		if isInRoot(topPath, p) || isInRoot(p, topPath) {
+			return fmt.Errorf("%q overlaps the root %q", p, topPath)
+		}
+	}
+	node, err := file.CreateTreeNodeFromPath(p)
+	if err != nil {
+		return err
+	}
+	mg.Root.AddChild(node)
+	return nil
+}
+
+// convertToRoots replaces the single root of the tree with a file.RootsPath root whose
+// only named root is p, keeping the nodes inside p.
+func (mg *DirTreeManager) convertToRoots(p string) error {
+	var kept *ds.TreeNode
+	iter := ds.NewTreeIterator(mg.TreeManager)
+	for iter.HasNext() {
+		curNode, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		info, ok := curNode.Info.(file.NodeInformable)
+		if !ok {
+			return &cmderror.Unexpected{}
+		}
+		q := info.GetAbsPath()
+		switch {
+		case q == p:
+			kept = curNode
+		case isInRoot(p, q):
+		case isInRoot(q, p) || curNode == mg.Root:
+			// The old root and the directories above p only lead to it and are dropped,
+			// unless the user put something on them.
+			if len(info.GetTags()) > 0 || info.GetId() != "" {
+				return fmt.Errorf("%q has tags or an id and is outside the root %q", q, p)
+			}
+		default:
+			return fmt.Errorf("%q is tracked outside the root %q; untrack it first", q, p)
+		}
+	}
+
+	old := mg.Root
+	root, err := file.CreateTreeNodeFromPath(file.RootsPath)
+	if err != nil {
+		return err
+	}
+	mg.Root = root
+	if kept != nil {
+		mg.Root.AddChild(kept)
+		return nil
+	}
+	top, err := file.CreateTreeNodeFromPath(p)
+	if err != nil {
+		return err
+	}
+	mg.Root.AddChild(top)
+	if isInRoot(p, old.Info.(file.NodeInformable).GetAbsPath()) {
+		// The old root is inside p, so is everything below it.
+		return mg.MergeNode(old)
+	}
+	return nil
+}
+
+// RemoveRoot removes the named root at p with everything tracked in it.
+func (mg *DirTreeManager) RemoveRoot(p string) error {
+	if !mg.IsNamedRoot(p) {
+		return fmt.Errorf("%q is not a root", p)
+	}
+	mg.removeNode(mg.Root, mg.rootFor(p))
+	return nil
+}
+
+// BuildCopyTreeFrom is like BuildCopyTree for the tree rooted at root. When the tree
+// has named roots, the roots holding some of treeNodes are kept so the copies land
+// below them.
+func BuildCopyTreeFrom(root *ds.TreeNode, treeNodes []*ds.TreeNode) (*DirTreeManager, error) {
+	info, ok := root.Info.(file.NodeInformable)
+	if !ok {
+		return nil, &cmderror.Unexpected{}
+	}
+	if info.GetAbsPath() != file.RootsPath {
+		return BuildCopyTree(info.GetAbsPath(), treeNodes)
+	}
+
+	rootNode, err := file.CreateTreeNodeFromPath(file.RootsPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, top := range root.Children {
+		topPath := top.Info.(file.NodeInformable).GetAbsPath()
+		if slices.ContainsFunc(treeNodes, func(n *ds.TreeNode) bool {
+			return isInRoot(topPath, n.Info.(file.NodeInformable).GetAbsPath())
+		}) {
+			rootNode.AddChild(&ds.TreeNode{Info: top.Info})
+		}
+	}
+
+	copyTreeNodes := []*ds.TreeNode{}
+	for _, node := range treeNodes {
+		copyNode := *node
+		copyNode.Children = []*ds.TreeNode{}
+		copyTreeNodes = append(copyTreeNodes, &copyNode)
+	}
+	return buildTree(rootNode, copyTreeNodes)
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedRoots(t *testing.T) {
+	dm := NewDirTreeManager(ds.NewTreeManager(nil))
+	for _, path := range []string{"/", "/home/me/work/src/x", "/home/me/work/notes"} {
+		require.NoError(t, dm.MergeNodeWithPath(path))
+	}
+	// The directories above the root are dropped.
+	require.NoError(t, dm.AddRoot("/home/me/work"))
+	require.True(t, dm.IsMultiRoot())
+	utils.ValidateNodeCnt(t, dm.Root, 5)
+	require.True(t, dm.IsNamedRoot("/home/me/work"))
+	require.False(t, dm.IsNamedRoot("/home/me/work/src"))
+
+	require.NoError(t, dm.AddRoot("/data"))
+	require.NoError(t, dm.MergeNodeWithPath("/data/sets/a.csv"))
+	require.Error(t, dm.AddRoot("/data/sets"))
+	require.Error(t, dm.AddRoot("/home"))
+	require.ErrorContains(t, dm.MergeNodeWithPath("/mnt/nas/b"), "not under a root")
+
+	// Roots are the top-level nodes, whatever is above them.
+	paths := []string{}
+	for _, child := range dm.Root.Children {
+		paths = append(paths, child.Info.(file.NodeInformable).GetAbsPath())
+	}
+	require.Equal(t, []string{"/home/me/work", "/data"}, paths)
+	utils.ValidateNodeCnt(t, dm.Root, 8)
+
+	// Nodes move between roots, roots themselves stay.
+	require.NoError(t, dm.MoveNode("/data/sets/a.csv", "/home/me/work/a.csv"))
+	require.Error(t, dm.MoveNode("/data", "/home/me/work/data"))
+	require.Error(t, dm.MoveNode("/home/me/work/a.csv", "/mnt/a.csv"))
+
+	found, err := dm.FindTreeNodeByAbsPath("/home/me/work/src/x")
+	require.NoError(t, err)
+	copied, err := BuildCopyTreeFrom(dm.Root, []*ds.TreeNode{found})
+	require.NoError(t, err)
+	// roots: + work + src + x, the data root holds nothing found
+	utils.ValidateNodeCnt(t, copied.Root, 4)
+
+	require.NoError(t, dm.RemoveRoot("/data"))
+	require.Error(t, dm.RemoveRoot("/data"))
+	require.Error(t, dm.RemoveRoot("/home/me/work/src"))
+	utils.ValidateNodeCnt(t, dm.Root, 6)
+}
+
+func TestAddRootKeepsTrackedNodes(t *testing.T) {
+	tests := []struct {
+		tracked []string
+		root    string
+		nodes   int
+		err     string
+	}{
+		// The old root is inside the new one.
+		{[]string{"/tmp/ctx", "/tmp/ctx/a"}, "/tmp", 4, ""},
+		{[]string{"/", "/data/a"}, "/data", 3, ""},
+		// Nothing was tracked, the old root goes away.
+		{[]string{"/tmp/ctx"}, "/data", 2, ""},
+		{[]string{"/", "/data/a", "/srv/b"}, "/data", 0, "outside the root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.root, func(t *testing.T) {
+			dm := NewDirTreeManager(ds.NewTreeManager(nil))
+			for _, path := range tt.tracked {
+				require.NoError(t, dm.MergeNodeWithPath(path))
+			}
+			err := dm.AddRoot(tt.root)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				require.False(t, dm.IsMultiRoot())
+				return
+			}
+			require.NoError(t, err)
+			utils.ValidateNodeCnt(t, dm.Root, tt.nodes)
+		})
+	}
+
+	// Tags above the new root would be lost.
+	dm := NewDirTreeManager(ds.NewTreeManager(nil))
+	require.NoError(t, dm.MergeNodeWithPath("/"))
+	require.NoError(t, dm.MergeNodeWithPath("/data/a"))
+	info, err := dm.FindNodeByAbsPath("/data")
+	require.NoError(t, err)
+	info.AddTag("keep")
+	require.ErrorContains(t, dm.AddRoot("/data/a"), "tags")
+}
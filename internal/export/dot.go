@@ -0,0 +1,106 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/output"
+)
+
+// Edge classes of DOT graphs, what the edges stand for.
+const (
+	// EdgeContains goes from a directory to a node in it.
+	EdgeContains = "contains"
+	// EdgeSymlink goes from a symlink to its target, dashed.
+	EdgeSymlink = "symlink"
+)
+
+// WriteDOT writes the tree below root as a Graphviz digraph. Nodes are labelled by
+// name, tags and id, Drive files linking to their web page. Edges have the class of
+// the link they stand for, EdgeContains or EdgeSymlink; symlinks to targets outside
+// the tree point to a node of the target path.
+func WriteDOT(w io.Writer, root *ds.TreeNode) error {
+	found, err := output.SubtreeNodes(root)
+	if err != nil {
+		return err
+	}
+	ids := make(map[*ds.TreeNode]string, len(found))
+	byPath := make(map[string]string, len(found))
+	var b strings.Builder
+	b.WriteString("digraph tree {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n")
+	for i, node := range found {
+		id := fmt.Sprintf("n%d", i)
+		ids[node] = id
+		n := output.NewNode(node)
+		byPath[n.Path] = id
+		fmt.Fprintf(&b, "  %s [%s];\n", id, strings.Join(dotNodeAttrs(n), ", "))
+	}
+
+	external := map[string]string{}
+	for _, node := range found {
+		for _, child := range node.Children {
+			if childId, ok := ids[child]; ok {
+				fmt.Fprintf(&b, "  %s -> %s [class=%s];\n", ids[node], childId, dotQuote(EdgeContains))
+			}
+		}
+
+		n := output.NewNode(node)
+		if n.Kind != output.KindSymlink || n.Target == "" {
+			continue
+		}
+		target := n.Target
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(n.Path), target)
+		}
+		targetId, ok := byPath[target]
+		if !ok {
+			targetId, ok = external[target]
+		}
+		if !ok {
+			targetId = fmt.Sprintf("x%d", len(external))
+			external[target] = targetId
+			fmt.Fprintf(&b, "  %s [label=%s, shape=plaintext, class=\"external\"];\n", targetId, dotQuote(target))
+		}
+		fmt.Fprintf(&b, "  %s -> %s [class=%s, label=%s, style=dashed, constraint=false];\n",
+			ids[node], targetId, dotQuote(EdgeSymlink), dotQuote(EdgeSymlink))
+	}
+	b.WriteString("}\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// dotNodeAttrs returns the attributes of the graph node of n.
+func dotNodeAttrs(n output.Node) []string {
+	lines := []string{n.Name}
+	if len(n.Tags) > 0 {
+		lines = append(lines, "#"+strings.Join(n.Tags, " #"))
+	}
+	if n.Id != "" {
+		lines = append(lines, "id: "+n.Id)
+	}
+	attrs := []string{"label=" + dotQuote(strings.Join(lines, "\n")), "class=" + dotQuote(n.Kind)}
+	switch n.Kind {
+	case output.KindDir:
+		attrs = append(attrs, "shape=folder")
+	case output.KindSymlink:
+		attrs = append(attrs, "style=dashed")
+	}
+	if n.Link != "" {
+		attrs = append(attrs, "URL="+dotQuote(n.Link))
+	}
+	if n.Vanished {
+		attrs = append(attrs, "color=gray", "fontcolor=gray")
+	}
+	return attrs
+}
+
+// dotQuote returns s as a DOT string, newlines being line breaks of labels.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,64 @@
+// Package export renders a tracked subtree as a document to share: a Markdown list, a
+// self-contained HTML page, a Graphviz DOT graph or a CSV table. Every format shows
+// the tags and id of the nodes.
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/output"
+)
+
+// Format is the kind of document Write renders.
+type Format string
+
+const (
+	// FormatMarkdown is a nested list, a node per item.
+	FormatMarkdown Format = "md"
+	// FormatHTML is a single HTML file with a collapsible tree and a tag filter.
+	FormatHTML Format = "html"
+	// FormatDOT is a Graphviz digraph of the nodes, with an edge from every directory
+	// to its children and one from every symlink to its target.
+	FormatDOT Format = "dot"
+	// FormatCSV has a row per node, the columns of the node records of --output csv.
+	FormatCSV Format = "csv"
+)
+
+// Formats lists the valid formats.
+var Formats = []Format{FormatMarkdown, FormatHTML, FormatDOT, FormatCSV}
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid export format %q, expected md, html, dot or csv", s)
+}
+
+// Write renders the tree below root to w in format f.
+func Write(w io.Writer, f Format, root *ds.TreeNode) error {
+	switch f {
+	case FormatMarkdown:
+		return WriteMarkdown(w, root)
+	case FormatHTML:
+		return WriteHTML(w, root)
+	case FormatDOT:
+		return WriteDOT(w, root)
+	case FormatCSV:
+		return WriteCSV(w, root)
+	}
+	return fmt.Errorf("invalid export format %q", f)
+}
+
+// WriteCSV writes the record of every node below root, root included, parents first.
+func WriteCSV(w io.Writer, root *ds.TreeNode) error {
+	found, err := output.SubtreeNodes(root)
+	if err != nil {
+		return err
+	}
+	return output.NewPrinter(w, output.FormatCSV).Print(output.NewNodes(found), nil)
+}
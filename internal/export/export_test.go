@@ -0,0 +1,74 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/stretchr/testify/require"
+)
+
+// testTree returns /h holding a.txt, tagged and with an id, the symlink cur to it and
+// the symlink out to a path outside of the tree.
+func testTree() *ds.TreeNode {
+	a := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h/a_1.txt", Tags: []string{"work", "2025"}, Id: "ida", Size: 2048, ModTime: 1740823200}})
+	cur := ds.NewTreeNode(&file.SymlinkNode{GeneralNode: file.GeneralNode{AbsPath: "/h/cur"}, Kind: file.KindSymlink, Target: "a_1.txt"})
+	out := ds.NewTreeNode(&file.SymlinkNode{GeneralNode: file.GeneralNode{AbsPath: "/h/out"}, Kind: file.KindSymlink, Target: "/etc/hosts"})
+	root := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h", Tags: []string{"home"}}})
+	root.Children = []*ds.TreeNode{a, cur, out}
+	return root
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteMarkdown(&buf, testTree()))
+	require.Equal(t, "# /h\n\n"+
+		"  * **/h/** · tags: `home`\n"+
+		"    * a\\_1.txt · tags: `work`, `2025` · id: `ida`\n"+
+		"    * cur → a\\_1.txt\n"+
+		"    * out → /etc/hosts\n", buf.String())
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteDOT(&buf, testTree()))
+	dot := buf.String()
+	require.True(t, strings.HasPrefix(dot, "digraph tree {\n"))
+	require.Contains(t, dot, `n1 [label="a_1.txt\n#work #2025\nid: ida", class="file"];`)
+	require.Contains(t, dot, `n0 [label="h\n#home", class="dir", shape=folder];`)
+	require.Contains(t, dot, `n0 -> n1 [class="contains"];`)
+	require.Contains(t, dot, `n2 -> n1 [class="symlink", label="symlink", style=dashed, constraint=false];`)
+	require.Contains(t, dot, `x0 [label="/etc/hosts", shape=plaintext, class="external"];`)
+	require.Contains(t, dot, `n3 -> x0 [class="symlink"`)
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, testTree()))
+	page := buf.String()
+	require.Contains(t, page, "<title>/h</title>")
+	require.Contains(t, page, "<option>2025</option>\n<option>home</option>\n<option>work</option>")
+	require.Contains(t, page, `<li data-tags="[&#34;work&#34;,&#34;2025&#34;]">`)
+	require.Contains(t, page, "<details open><summary>")
+	require.Contains(t, page, `<span class="id">id: ida</span>`)
+	require.Contains(t, page, "4 nodes")
+	require.NotContains(t, page, "<link")
+	require.NotContains(t, page, "src=")
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatCSV, testTree()))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 5)
+	require.True(t, strings.HasPrefix(lines[0], "path,name,kind,size,mtime,tags,id,vanished"))
+	require.True(t, strings.HasPrefix(lines[2], `/h/a_1.txt,a_1.txt,file,2048,`))
+
+	_, err := ParseFormat("pdf")
+	require.Error(t, err)
+	f, err := ParseFormat("html")
+	require.NoError(t, err)
+	require.Equal(t, FormatHTML, f)
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/output"
+)
+
+// htmlNode is a node of the tree of an HTML page.
+type htmlNode struct {
+	output.Node
+	// TagsJSON is the tags as a JSON array, read by the tag filter.
+	TagsJSON string
+	Children []htmlNode
+}
+
+// htmlPage is what htmlTemplate renders.
+type htmlPage struct {
+	Title string
+	Root  htmlNode
+	// Tags are those of every node, sorted, the options of the tag filter.
+	Tags  []string
+	Count int
+}
+
+var htmlTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"humanSize": output.HumanSize,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; word-break: break-all; }
+.bar { margin: 1em 0; display: flex; gap: .5em; align-items: center; flex-wrap: wrap; }
+ul.tree, ul.tree ul { list-style: none; padding-left: 1.2em; margin: 0; }
+ul.tree { padding-left: 0; }
+li { margin: .15em 0; }
+li[hidden] { display: none; }
+summary { cursor: pointer; }
+.dir { font-weight: 600; }
+.tag { display: inline-block; background: #e8eefc; color: #2a4a9a; border-radius: .8em; padding: 0 .5em; margin-left: .3em; font-size: .85em; }
+.id, .size { color: #777; font-size: .85em; margin-left: .4em; }
+.vanished { text-decoration: line-through; color: #999; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="bar">
+<label for="tag">Tag</label>
+<select id="tag">
+<option value="">All nodes</option>
+{{- range .Tags}}
+<option>{{.}}</option>
+{{- end}}
+</select>
+<button type="button" id="expand">Expand all</button>
+<button type="button" id="collapse">Collapse all</button>
+<span class="size">{{.Count}} nodes</span>
+</div>
+<ul class="tree">
+{{template "node" .Root}}
+</ul>
+<script>
+(function () {
+  var items = Array.prototype.slice.call(document.querySelectorAll("ul.tree li")).reverse();
+  function details(open) {
+    document.querySelectorAll("ul.tree details").forEach(function (d) { d.open = open; });
+  }
+  document.getElementById("expand").onclick = function () { details(true); };
+  document.getElementById("collapse").onclick = function () { details(false); };
+  document.getElementById("tag").onchange = function () {
+    var tag = this.value;
+    // Children come before their parents, so a parent shows when one of them does.
+    items.forEach(function (li) {
+      var match = tag === "" || JSON.parse(li.dataset.tags).indexOf(tag) >= 0;
+      var child = li.querySelector(":scope > details > ul > li:not([hidden])") !== null;
+      li.hidden = !(match || child);
+      var d = li.querySelector(":scope > details");
+      if (d && tag !== "") { d.open = child; }
+    });
+  };
+})();
+</script>
+</body>
+</html>
+{{define "label" -}}
+<span class="{{if eq .Kind "dir"}}dir{{end}}{{if .Vanished}} vanished{{end}}">
+{{- if .Link}}<a href="{{.Link}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}
+{{- if eq .Kind "dir"}}/{{end}}{{if .Target}} → {{.Target}}{{end}}</span>
+{{- if and (ne .Kind "dir") .ModTime}}<span class="size">{{humanSize .Size}}</span>{{end}}
+{{- range .Tags}}<span class="tag">{{.}}</span>{{end}}
+{{- if .Id}}<span class="id">id: {{.Id}}</span>{{end}}
+{{- end}}
+{{define "node" -}}
+<li data-tags="{{.TagsJSON}}">
+{{- if .Children}}<details open><summary>{{template "label" .}}</summary><ul>
+{{range .Children}}{{template "node" .}}{{end}}</ul></details>
+{{- else}}{{template "label" .}}{{end}}</li>
+{{end}}`))
+
+// WriteHTML writes the tree below root as a single HTML page without external
+// resources. Directories fold, and a tag filter shows only the nodes with a tag and
+// the directories leading to them.
+func WriteHTML(w io.Writer, root *ds.TreeNode) error {
+	found, err := output.SubtreeNodes(root)
+	if err != nil {
+		return err
+	}
+	page := htmlPage{Tags: []string{}}
+	for _, node := range found {
+		page.Count++
+		for _, t := range output.NewNode(node).Tags {
+			if !slices.Contains(page.Tags, t) {
+				page.Tags = append(page.Tags, t)
+			}
+		}
+	}
+	slices.Sort(page.Tags)
+
+	page.Root, err = newHTMLNode(root)
+	if err != nil {
+		return err
+	}
+	// Directories get their trailing "/" when printed
+	page.Root.Name = strings.TrimSuffix(page.Root.Path, "/")
+	page.Title = page.Root.Path
+	return htmlTemplate.Execute(w, page)
+}
+
+// newHTMLNode returns the page node of node and those below it.
+func newHTMLNode(node *ds.TreeNode) (htmlNode, error) {
+	n := htmlNode{Node: output.NewNode(node)}
+	tags, err := json.Marshal(n.Tags)
+	if err != nil {
+		return n, err
+	}
+	n.TagsJSON = string(tags)
+	for _, child := range node.Children {
+		if child == nil || child.Info == nil {
+			continue
+		}
+		c, err := newHTMLNode(child)
+		if err != nil {
+			return n, err
+		}
+		n.Children = append(n.Children, c)
+	}
+	return n, nil
+}
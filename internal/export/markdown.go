@@ -0,0 +1,95 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/printer"
+
+	"github.com/jedib0t/go-pretty/v6/list"
+)
+
+// WriteMarkdown writes a heading with the path of root, then the tree as a nested list.
+// Directories are in bold, Drive files link to their web page.
+func WriteMarkdown(w io.Writer, root *ds.TreeNode) error {
+	pr := printer.NewTreePrinterManager(ds.NewTreeManager(root))
+	text, err := pr.TrRenderMarkdown([]printer.PrintingContext{&markdownPrinter{root: root}})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "# %s\n\n%s\n", markdownEscape(output.NewNode(root).Path), text)
+	return err
+}
+
+// markdownPrinter appends a list item per node, the root by path and the nodes below
+// it by name.
+type markdownPrinter struct {
+	root *ds.TreeNode
+}
+
+func (mp *markdownPrinter) GetPrinter(info any) (file.PrinterFunc, error) {
+	nodeInfo, ok := info.(ds.TreeNodeInformable)
+	if !ok {
+		return nil, errors.New("info not convertible to TreeNodeInformable")
+	}
+	return mp.GetTreePrinter(ds.NewTreeNode(nodeInfo))
+}
+
+func (mp *markdownPrinter) GetTreePrinter(node *ds.TreeNode) (file.PrinterFunc, error) {
+	return func(wr list.Writer) error {
+		n := output.NewNode(node)
+		if node == mp.root {
+			// Directories get their trailing "/" when printed
+			n.Name = strings.TrimSuffix(n.Path, "/")
+		}
+		wr.AppendItem(markdownItem(n))
+		return nil
+	}, nil
+}
+
+// markdownItem returns the list item of n.
+func markdownItem(n output.Node) string {
+	name := markdownEscape(n.Name)
+	switch {
+	case n.Kind == output.KindDir:
+		name = "**" + name + "/**"
+	case n.Link != "":
+		name = "[" + name + "](" + n.Link + ")"
+	}
+	if n.Kind == output.KindSymlink {
+		name += " → " + markdownEscape(n.Target)
+	}
+
+	parts := []string{name}
+	if len(n.Tags) > 0 {
+		tags := make([]string, 0, len(n.Tags))
+		for _, t := range n.Tags {
+			tags = append(tags, "`"+strings.ReplaceAll(t, "`", "'")+"`")
+		}
+		parts = append(parts, "tags: "+strings.Join(tags, ", "))
+	}
+	if n.Id != "" {
+		parts = append(parts, "id: `"+strings.ReplaceAll(n.Id, "`", "'")+"`")
+	}
+	if n.Vanished {
+		parts = append(parts, "(vanished)")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// markdownReplacer escapes the characters Markdown could take for markup, and puts
+// text on a single line.
+var markdownReplacer = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `>`, `\>`, `#`, `\#`, "\r\n", " ", "\n", " ",
+)
+
+// markdownEscape returns s as literal Markdown text.
+func markdownEscape(s string) string {
+	return markdownReplacer.Replace(s)
+}
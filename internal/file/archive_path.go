@@ -0,0 +1,65 @@
+package file
+
+import (
+	"path"
+	"strings"
+)
+
+// ArchiveSeparator separates the path of an archive from the path of an entry inside
+// it, e.g. "/projects/old.zip!/src/main.go".
+const ArchiveSeparator = "!/"
+
+// archiveExtensions are the archives whose content can be tracked.
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchivePath returns true if the file at p is an archive that can be looked into.
+func IsArchivePath(p string) bool {
+	lower := strings.ToLower(p)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitArchivePath splits a path inside an archive into the archive path and the entry
+// path, which starts with "/". ok is false for paths that are not inside an archive.
+func SplitArchivePath(p string) (archive, inner string, ok bool) {
+	i := strings.Index(p, ArchiveSeparator)
+	if i == -1 || !IsArchivePath(p[:i]) {
+		return "", "", false
+	}
+	return p[:i], p[i+1:], true
+}
+
+// JoinArchivePath returns the path of the entry inner in archive. The entry path is
+// cleaned and cannot leave the archive; an empty entry path is the archive itself.
+func JoinArchivePath(archive, inner string) string {
+	inner = path.Clean("/" + inner)
+	if inner == "/" {
+		return archive
+	}
+	return archive + "!" + inner
+}
+
+// ParentPath returns the path of the node above p, knowing that the entries at the
+// top of an archive are below the archive itself and hosts below "sftp://". ok is
+// false when p has no parent.
+func ParentPath(p string) (string, bool) {
+	i := strings.LastIndex(p, "/")
+	if i == -1 {
+		return "", false
+	}
+	if archive, inner, ok := SplitArchivePath(p); ok && strings.LastIndex(inner, "/") == 0 {
+		return archive, true
+	}
+	// Hosts, like "sftp://lab", are right below the root of their scheme.
+	if strings.HasSuffix(p[:i+1], "://") {
+		if i == len(p)-1 {
+			return "", false
+		}
+		return p[:i+1], true
+	}
+	return p[:i], true
+}
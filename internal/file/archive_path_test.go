@@ -0,0 +1,41 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchivePaths(t *testing.T) {
+	archive, inner, ok := SplitArchivePath("/p/old.TAR.GZ!/src/main.go")
+	require.True(t, ok)
+	require.Equal(t, "/p/old.TAR.GZ", archive)
+	require.Equal(t, "/src/main.go", inner)
+
+	_, _, ok = SplitArchivePath("/p/dir!/file")
+	require.False(t, ok)
+	_, _, ok = SplitArchivePath("/p/old.zip")
+	require.False(t, ok)
+
+	require.Equal(t, "/p/a.zip!/x", JoinArchivePath("/p/a.zip", "/../x"))
+	require.Equal(t, "/p/a.zip", JoinArchivePath("/p/a.zip", "/"))
+
+	parents := map[string]string{
+		"/p/a.zip!/dir/file": "/p/a.zip!/dir",
+		"/p/a.zip!/dir":      "/p/a.zip",
+		"/p/a.zip":           "/p",
+		"/p/dir!/file":       "/p/dir!",
+		"gdrive:/Folder":     "gdrive:",
+		"sftp://lab/data":    "sftp://lab",
+		"sftp://lab":         "sftp://",
+	}
+	for p, expected := range parents {
+		parent, ok := ParentPath(p)
+		require.True(t, ok, p)
+		require.Equal(t, expected, parent, p)
+	}
+	_, ok = ParentPath("gdrive:")
+	require.False(t, ok)
+	_, ok = ParentPath("sftp://")
+	require.False(t, ok)
+}
@@ -18,7 +18,7 @@ func IsGDrivePath(path string) bool {
 // NewDriveDirNode creates a tree node for a Drive folder.
 func NewDriveDirNode(virtualPath, driveId string) *ds.TreeNode {
 	return ds.NewTreeNode(&FileNode{
-		GeneralNode: GeneralNode{AbsPath: virtualPath},
+		GeneralNode: GeneralNode{AbsPath: virtualPath, Dir: true},
 		DriveId:     driveId,
 	})
 }
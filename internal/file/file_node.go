@@ -2,6 +2,9 @@ package file
 
 import (
 	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
 )
 
 /*Common node operations which should be provided by all nodes*/
@@ -15,18 +18,45 @@ type NodeInformable interface {
 }
 
 type GeneralNode struct {
-	AbsPath string      `json:"AbsPath" mapstructure:"AbsPath"`
-	Entry   fs.FileInfo `json:"Entry" mapstructure:"Entry"`
-	Tags    []string    `json:"Tags" mapstructure:"Tags"`
+	AbsPath string `json:"AbsPath" mapstructure:"AbsPath"`
+	// Entry is what the scanner found on disk. It is not persisted.
+	Entry fs.FileInfo `json:"-" mapstructure:"-"`
+	Tags  []string    `json:"Tags" mapstructure:"Tags"`
 	// User friendly id, which uniquely finds a node
 	// exception: empty string
 	Id string `json:"Id" mapstructure:"Id"`
+	// Vanished is set by refresh when the file no longer exists
+	Vanished bool `json:"Vanished,omitempty" mapstructure:"Vanished"`
+	// Dir is set for directories by the scan, so that an empty directory is still
+	// told apart from a file
+	Dir bool `json:"Dir,omitempty" mapstructure:"Dir"`
+	// Size in bytes and modification time (Unix seconds) as last scanned. Not set for
+	// directories and nodes the scanner knows nothing about. Local scans leave them to
+	// GetStat.
+	Size    int64 `json:"Size,omitempty" mapstructure:"Size"`
+	ModTime int64 `json:"ModTime,omitempty" mapstructure:"ModTime"`
+	// statRead is set once Size and ModTime hold what could be read of the file
+	statRead bool
 }
 
 func NewGeneralNode(absPath string, entry fs.FileInfo) GeneralNode {
+	gn := GeneralNode{
+		AbsPath: absPath,
+		Entry:   entry,
+		Dir:     entry != nil && entry.IsDir(),
+	}
+	gn.SetStat(entry)
+	return gn
+}
+
+// NewLazyGeneralNode is like NewGeneralNode, but the size and modification time are
+// only read, from entry or else from disk, when first asked for. Scanners use it for
+// entries they have not stat'ed, so that a scan does not stat every file.
+func NewLazyGeneralNode(absPath string, entry fs.FileInfo) GeneralNode {
 	return GeneralNode{
 		AbsPath: absPath,
 		Entry:   entry,
+		Dir:     entry != nil && entry.IsDir(),
 	}
 }
 
@@ -66,6 +96,119 @@ func (gn *GeneralNode) GetId() string {
 	return gn.Id
 }
 
+func (gn *GeneralNode) IsVanished() bool {
+	return gn.Vanished
+}
+
+func (gn *GeneralNode) SetVanished(vanished bool) {
+	gn.Vanished = vanished
+}
+
+func (gn *GeneralNode) IsDir() bool {
+	return gn.Dir
+}
+
+func (gn *GeneralNode) SetAbsPath(absPath string) {
+	gn.AbsPath = absPath
+}
+
+// SetStat records the size and modification time of entry. A nil entry is ignored.
+func (gn *GeneralNode) SetStat(entry fs.FileInfo) {
+	if entry == nil {
+		return
+	}
+	gn.statRead = true
+	gn.Size = 0
+	if !entry.IsDir() {
+		gn.Size = entry.Size()
+	}
+	gn.ModTime = 0
+	if !entry.ModTime().IsZero() {
+		gn.ModTime = entry.ModTime().Unix()
+	}
+}
+
+// GetStat returns the recorded size and modification time. When the scanner recorded
+// neither, they are read from the scanned entry or, for local files, from disk, once.
+// ok is false when they are still unknown.
+func (gn *GeneralNode) GetStat() (size int64, modTime time.Time, ok bool) {
+	if gn.Size == 0 && gn.ModTime == 0 && !gn.statRead {
+		gn.readStat()
+	}
+	if gn.Size == 0 && gn.ModTime == 0 {
+		return 0, time.Time{}, false
+	}
+	if gn.ModTime != 0 {
+		modTime = time.Unix(gn.ModTime, 0)
+	}
+	return gn.Size, modTime, true
+}
+
+// readStat records the stat of the scanned entry, or else of the local file.
+func (gn *GeneralNode) readStat() {
+	gn.statRead = true
+	if gn.Entry != nil {
+		gn.SetStat(gn.Entry)
+		return
+	}
+	if !filepath.IsAbs(gn.AbsPath) {
+		return
+	}
+	if info, err := os.Lstat(gn.AbsPath); err == nil {
+		gn.SetStat(info)
+	}
+}
+
+// pendingStat returns the entry the stat is to be read from, and whether it is yet
+// to be read.
+func (gn *GeneralNode) pendingStat() (fs.FileInfo, bool) {
+	return gn.Entry, !gn.statRead && gn.Size == 0 && gn.ModTime == 0
+}
+
+// CopyStat copies whether from is a directory and its recorded size and modification
+// time. When from has not read them yet, gn is left to read them lazily as well.
+func (gn *GeneralNode) CopyStat(from Stated) {
+	if dir, ok := from.(Directory); ok {
+		gn.Dir = dir.IsDir()
+	}
+	if lazy, ok := from.(interface{ pendingStat() (fs.FileInfo, bool) }); ok {
+		if entry, pending := lazy.pendingStat(); pending {
+			gn.Size, gn.ModTime, gn.Entry, gn.statRead = 0, 0, entry, false
+			return
+		}
+	}
+	size, modTime, ok := from.GetStat()
+	if !ok {
+		return
+	}
+	gn.Size, gn.ModTime = size, 0
+	if !modTime.IsZero() {
+		gn.ModTime = modTime.Unix()
+	}
+}
+
+// Relocatable is implemented by nodes that can follow their file to a new path.
+type Relocatable interface {
+	SetAbsPath(string)
+}
+
+// Stated is implemented by nodes that record what a scan found about their file.
+type Stated interface {
+	GetStat() (size int64, modTime time.Time, ok bool)
+	CopyStat(Stated)
+}
+
+// Directory is implemented by nodes that record whether they are a directory.
+type Directory interface {
+	IsDir() bool
+}
+
+// Vanishable is implemented by nodes that refresh can mark as vanished.
+type Vanishable interface {
+	IsVanished() bool
+	SetVanished(bool)
+}
+
 type SerializableNode interface {
 	MarshalJSON() ([]byte, error)
 	UnmarshalJSON(data []byte) error
@@ -75,6 +218,8 @@ type SerializableNode interface {
 type FileNode struct {
 	GeneralNode `mapstructure:",squash"`
 	DriveId     string `json:"DriveId" mapstructure:"DriveId"` // non-empty for Google Drive nodes
+	// MimeType is the MIME type Google Drive reported, empty for other nodes
+	MimeType string `json:"MimeType,omitempty" mapstructure:"MimeType"`
 }
 
 func (fn *FileNode) GetInfoProvider() NodeInformable {
@@ -71,6 +71,9 @@ func (gn *GeneralNode) PrintNode(wr list.Writer) error {
 		return err
 	}
 
+	if gn.Vanished {
+		curNodeName += " (vanished)"
+	}
 	wr.AppendItem(curNodeName)
 
 	return nil
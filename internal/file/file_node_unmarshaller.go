@@ -9,6 +9,15 @@ import (
 type FileNodeJSONSerializer struct{}
 
 func (FileNodeJSONSerializer) InfoUnmarshal(info map[string]interface{}) (ds.TreeNodeInformable, error) {
+	if info["Kind"] == KindSymlink {
+		var sn SymlinkNode
+		err := mapstructure.Decode(info, &sn)
+		if err != nil {
+			return nil, err
+		}
+		return &sn, nil
+	}
+
 	var fn FileNode
 	err := mapstructure.Decode(info, &fn)
 	if err != nil {
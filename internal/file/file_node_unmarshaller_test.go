@@ -15,6 +15,7 @@ func TestFileNodeJSONSerializer_InfoUnmarshal(t *testing.T) {
 			"Tags":    []interface{}{"tag1", "tag2"},
 			"Id":      "node-id-123",
 			"DriveId": "",
+			"Dir":     true,
 		}
 
 		result, err := serializer.InfoUnmarshal(info)
@@ -27,6 +28,7 @@ func TestFileNodeJSONSerializer_InfoUnmarshal(t *testing.T) {
 		require.Equal(t, []string{"tag1", "tag2"}, fn.Tags)
 		require.Equal(t, "node-id-123", fn.Id)
 		require.Equal(t, "", fn.DriveId)
+		require.True(t, fn.IsDir())
 		require.Equal(t, "FILE", fn.Name())
 	})
 
@@ -50,6 +52,24 @@ func TestFileNodeJSONSerializer_InfoUnmarshal(t *testing.T) {
 		require.Equal(t, "1a2b3c4d5e6f7g8h", fn.DriveId)
 	})
 
+	t.Run("node with stat data", func(t *testing.T) {
+		info := map[string]interface{}{
+			"AbsPath": "/path/to/report.pdf",
+			"Size":    float64(2048),
+			"ModTime": float64(1735689600),
+		}
+
+		result, err := serializer.InfoUnmarshal(info)
+		require.NoError(t, err)
+
+		fn, ok := result.(*FileNode)
+		require.True(t, ok, "result should be *FileNode")
+		size, modTime, ok := fn.GetStat()
+		require.True(t, ok)
+		require.Equal(t, int64(2048), size)
+		require.Equal(t, int64(1735689600), modTime.Unix())
+	})
+
 	t.Run("minimal node with only AbsPath", func(t *testing.T) {
 		info := map[string]interface{}{
 			"AbsPath": "/root",
@@ -150,4 +170,23 @@ func TestFileNodeJSONSerializer_InfoUnmarshal(t *testing.T) {
 		require.Equal(t, "", fn.Id)
 		require.Equal(t, "", fn.DriveId)
 	})
+
+	t.Run("symlink node", func(t *testing.T) {
+		info := map[string]interface{}{
+			"AbsPath": "/path/to/link",
+			"Tags":    []interface{}{"tag1"},
+			"Kind":    KindSymlink,
+			"Target":  "../target",
+		}
+
+		result, err := serializer.InfoUnmarshal(info)
+		require.NoError(t, err)
+
+		sn, ok := result.(*SymlinkNode)
+		require.True(t, ok, "result should be *SymlinkNode")
+		require.Equal(t, "/path/to/link", sn.AbsPath)
+		require.Equal(t, []string{"tag1"}, sn.Tags)
+		require.Equal(t, "../target", sn.Target)
+		require.Equal(t, KindSymlink, sn.Name())
+	})
 }
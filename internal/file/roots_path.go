@@ -0,0 +1,5 @@
+package file
+
+// RootsPath is the path of the tree root of a context with named roots. The roots are
+// its children and keep their own absolute paths, like "/home/me/work".
+const RootsPath = "roots:"
@@ -0,0 +1,40 @@
+package file
+
+import (
+	"path"
+	"strings"
+)
+
+// SFTPPathPrefix is the virtual path prefix for nodes on SSH servers, followed by the
+// host and the remote path (e.g. "sftp://lab.example.com/data/sets").
+const SFTPPathPrefix = "sftp://"
+
+// IsSFTPPath returns true if path is an SFTP virtual path.
+func IsSFTPPath(path string) bool {
+	return strings.HasPrefix(path, SFTPPathPrefix)
+}
+
+// SplitSFTPPath splits an SFTP virtual path into its host, which may carry a user and
+// a port ("user@host:2222"), and the absolute remote path. ok is false for paths that
+// are not SFTP paths or have no host.
+func SplitSFTPPath(p string) (host, remotePath string, ok bool) {
+	rest, ok := strings.CutPrefix(p, SFTPPathPrefix)
+	if !ok {
+		return "", "", false
+	}
+	host, remotePath, _ = strings.Cut(rest, "/")
+	if host == "" {
+		return "", "", false
+	}
+	return host, path.Clean("/" + remotePath), true
+}
+
+// JoinSFTPPath returns the virtual path of remotePath on host. The host itself stands
+// for the remote root.
+func JoinSFTPPath(host, remotePath string) string {
+	remotePath = path.Clean("/" + remotePath)
+	if remotePath == "/" {
+		return SFTPPathPrefix + host
+	}
+	return SFTPPathPrefix + host + remotePath
+}
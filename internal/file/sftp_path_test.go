@@ -0,0 +1,27 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSFTPPath(t *testing.T) {
+	host, remotePath, ok := SplitSFTPPath("sftp://me@lab:2222/data/../sets/")
+	require.True(t, ok)
+	require.Equal(t, "me@lab:2222", host)
+	require.Equal(t, "/sets", remotePath)
+
+	host, remotePath, ok = SplitSFTPPath("sftp://lab")
+	require.True(t, ok)
+	require.Equal(t, "lab", host)
+	require.Equal(t, "/", remotePath)
+
+	for _, p := range []string{"sftp://", "sftp:///data", "/data", "gdrive:/data"} {
+		_, _, ok = SplitSFTPPath(p)
+		require.False(t, ok, p)
+	}
+
+	require.Equal(t, "sftp://lab", JoinSFTPPath("lab", "/"))
+	require.Equal(t, "sftp://lab/data/sets", JoinSFTPPath("lab", "data/sets/"))
+}
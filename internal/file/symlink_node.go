@@ -0,0 +1,52 @@
+package file
+
+import (
+	"io/fs"
+
+	"github.com/jedib0t/go-pretty/v6/list"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// KindSymlink is the Kind stored for SymlinkNode, used to tell it apart from FileNode when unmarshalling.
+const KindSymlink = "SYMLINK"
+
+// SymlinkNode represents a symbolic link that was recorded instead of followed.
+type SymlinkNode struct {
+	GeneralNode `mapstructure:",squash"`
+	Kind        string `json:"Kind" mapstructure:"Kind"`
+	// Target is the link target as stored in the link, not resolved
+	Target string `json:"Target" mapstructure:"Target"`
+}
+
+// NewSymlinkNode creates a SymlinkNode for the link at absPath pointing to target.
+func NewSymlinkNode(absPath, target string, entry fs.FileInfo) *SymlinkNode {
+	return &SymlinkNode{
+		GeneralNode: NewGeneralNode(absPath, entry),
+		Kind:        KindSymlink,
+		Target:      target,
+	}
+}
+
+func (sn *SymlinkNode) GetInfoProvider() NodeInformable {
+	return sn
+}
+
+func (sn *SymlinkNode) Name() string {
+	return KindSymlink
+}
+
+func (sn *SymlinkNode) PrintNode(wr list.Writer) error {
+	curNodeName, err := utils.GetCurNodeFromAbsPath(sn.AbsPath)
+	if err != nil {
+		return err
+	}
+
+	curNodeName += " -> " + sn.Target
+	if sn.Vanished {
+		curNodeName += " (vanished)"
+	}
+	wr.AppendItem(curNodeName)
+
+	return nil
+}
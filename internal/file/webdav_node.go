@@ -0,0 +1,12 @@
+package file
+
+import "strings"
+
+// WebDAVPathPrefix is the virtual path prefix for WebDAV nodes (e.g. "webdav:/Folder/file").
+const WebDAVPathPrefix = "webdav:/"
+const WebDAVPathRoot = "webdav:"
+
+// IsWebDAVPath returns true if path is a WebDAV virtual path.
+func IsWebDAVPath(path string) bool {
+	return strings.HasPrefix(path, WebDAVPathPrefix)
+}
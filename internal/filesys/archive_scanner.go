@@ -0,0 +1,247 @@
+package filesys
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+)
+
+// ArchiveScannableNode is a scannable node for a zip or tar archive. The entries of
+// the archive become virtual children with paths like "/a.zip!/inner/file.txt".
+type ArchiveScannableNode struct {
+	strAbsPath string
+	info       fs.FileInfo
+	cTreeNode  *ds.TreeNode
+	children   []ScannableNode
+}
+
+// NewArchiveScannableNode creates a scannable node for the archive at absPath.
+// info describes the archive file and may be nil.
+func NewArchiveScannableNode(absPath string, info fs.FileInfo) *ArchiveScannableNode {
+	return &ArchiveScannableNode{strAbsPath: absPath, info: info}
+}
+
+// EvalNode reads the listing of the archive.
+func (a *ArchiveScannableNode) EvalNode(cxt ScannableCxt) error {
+	a.children = []ScannableNode{}
+	if a.info == nil {
+		info, err := os.Stat(a.strAbsPath)
+		if err != nil {
+			return err
+		}
+		a.info = info
+	}
+
+	// The archive is recorded as a file even if it cannot be read.
+	a.cTreeNode = ds.NewTreeNode(&file.FileNode{
+		GeneralNode: file.NewGeneralNode(a.strAbsPath, a.info),
+	})
+
+	entries, err := readArchive(a.strAbsPath)
+	if err != nil {
+		return err
+	}
+
+	// Archives need not list directories, nor list them before their content.
+	top := &archiveEntryNode{}
+	byPath := map[string]*archiveEntryNode{"/": top}
+	var ensure func(inner string) *archiveEntryNode
+	ensure = func(inner string) *archiveEntryNode {
+		if n, ok := byPath[inner]; ok {
+			return n
+		}
+		parent := ensure(path.Dir(inner))
+		n := &archiveEntryNode{
+			absPath: file.JoinArchivePath(a.strAbsPath, inner),
+			info:    virtualDirInfo{name: path.Base(inner), modTime: a.info.ModTime()},
+		}
+		parent.children = append(parent.children, n)
+		byPath[inner] = n
+		return n
+	}
+	for _, entry := range entries {
+		n := ensure(entry.name)
+		n.info = entry.info
+		n.linkTarget = entry.linkTarget
+	}
+
+	for _, child := range top.children {
+		a.children = append(a.children, child)
+	}
+	return nil
+}
+
+// ConstructTreeNode returns the tree node of the archive file.
+func (a *ArchiveScannableNode) ConstructTreeNode() (*ds.TreeNode, error) {
+	return a.cTreeNode, nil
+}
+
+// GetChildren returns the entries at the top of the archive.
+func (a *ArchiveScannableNode) GetChildren() ([]ScannableNode, []ScannableCxt, error) {
+	return a.children, make([]ScannableCxt, len(a.children)), nil
+}
+
+func (a *ArchiveScannableNode) scanPath() string {
+	return a.strAbsPath
+}
+
+// Make sure that ArchiveScannableNode implements ScannableNode
+var _ ScannableNode = (*ArchiveScannableNode)(nil)
+
+// archiveEntryNode is an entry of an archive, known once the archive was read.
+type archiveEntryNode struct {
+	absPath    string
+	info       fs.FileInfo
+	linkTarget string
+	children   []*archiveEntryNode
+}
+
+func (e *archiveEntryNode) EvalNode(cxt ScannableCxt) error {
+	return nil
+}
+
+func (e *archiveEntryNode) ConstructTreeNode() (*ds.TreeNode, error) {
+	if e.info.Mode()&fs.ModeSymlink != 0 {
+		return ds.NewTreeNode(file.NewSymlinkNode(e.absPath, e.linkTarget, e.info)), nil
+	}
+	return ds.NewTreeNode(&file.FileNode{
+		GeneralNode: file.NewGeneralNode(e.absPath, e.info),
+	}), nil
+}
+
+func (e *archiveEntryNode) GetChildren() ([]ScannableNode, []ScannableCxt, error) {
+	children := make([]ScannableNode, len(e.children))
+	for i, child := range e.children {
+		children[i] = child
+	}
+	return children, make([]ScannableCxt, len(children)), nil
+}
+
+func (e *archiveEntryNode) scanPath() string {
+	return e.absPath
+}
+
+func (e *archiveEntryNode) isDir() bool {
+	return e.info.IsDir()
+}
+
+// archiveEntry is an entry as listed in an archive. name is cleaned and starts with "/".
+type archiveEntry struct {
+	name       string
+	info       fs.FileInfo
+	linkTarget string
+}
+
+// readArchive lists the entries of the archive at p, its format is told by its extension.
+func readArchive(p string) ([]archiveEntry, error) {
+	lower := strings.ToLower(p)
+	if strings.HasSuffix(lower, ".zip") {
+		return readZip(p)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return readTar(r)
+}
+
+func readZip(p string) ([]archiveEntry, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	entries := []archiveEntry{}
+	for _, zf := range zr.File {
+		name := path.Clean("/" + zf.Name)
+		if name == "/" {
+			continue
+		}
+		entry := archiveEntry{name: name, info: zf.FileInfo()}
+		if zf.Mode()&os.ModeSymlink != 0 {
+			// Zip keeps the target of a symlink as the content of its entry.
+			entry.linkTarget, err = readZipLinkTarget(zf)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", zf.Name, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// maxZipLinkTarget bounds what is read of a symlink entry, targets being paths.
+const maxZipLinkTarget = 4096
+
+// readZipLinkTarget returns the target of the symlink entry zf.
+func readZipLinkTarget(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	target, err := io.ReadAll(io.LimitReader(rc, maxZipLinkTarget))
+	if err != nil {
+		return "", err
+	}
+	return string(target), nil
+}
+
+func readTar(r io.Reader) ([]archiveEntry, error) {
+	tr := tar.NewReader(r)
+	entries := []archiveEntry{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+		entry := archiveEntry{name: name, info: hdr.FileInfo()}
+		if hdr.Typeflag == tar.TypeSymlink {
+			entry.linkTarget = hdr.Linkname
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// virtualDirInfo describes a directory that is only implied by the paths in an archive.
+type virtualDirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (v virtualDirInfo) Name() string       { return v.name }
+func (v virtualDirInfo) Size() int64        { return 0 }
+func (v virtualDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (v virtualDirInfo) ModTime() time.Time { return v.modTime }
+func (v virtualDirInfo) IsDir() bool        { return true }
+func (v virtualDirInfo) Sys() any           { return nil }
@@ -0,0 +1,140 @@
+package filesys
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestZip(t *testing.T, p string, names ...string) {
+	f, err := os.Create(p)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, name := range names {
+		_, err := zw.Create(name)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+}
+
+func writeTestTarGz(t *testing.T, p string, hdrs ...*tar.Header) {
+	f, err := os.Create(p)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range hdrs {
+		require.NoError(t, tw.WriteHeader(hdr))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+}
+
+func TestScanIntoArchives(t *testing.T) {
+	mockDir := &utils.MockDir{DirName: "archives"}
+	testExecFunc := func(t *testing.T, root string) {
+		zipPath := filepath.Join(root, "old.zip")
+		// Directories are implied, entries may escape with "..".
+		writeTestZip(t, zipPath, "src/main.go", "src/", "README", "../escape")
+		tgzPath := filepath.Join(root, "old.tar.gz")
+		writeTestTarGz(t, tgzPath,
+			&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+			&tar.Header{Name: "./lib/a.c", Typeflag: tar.TypeReg, Mode: 0644},
+			&tar.Header{Name: "./lib/link", Typeflag: tar.TypeSymlink, Linkname: "a.c"},
+		)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "broken.zip"), []byte("not a zip"), 0644))
+
+		node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{})
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, node, 4)
+
+		node, err = ScanDirectoryV2(context.Background(), root, ScanOptions{IntoArchives: true})
+		var partial ScanErrors
+		require.ErrorAs(t, err, &partial)
+		require.Len(t, partial, 1)
+		require.Equal(t, filepath.Join(root, "broken.zip"), partial[0].Path)
+
+		paths := preorderPaths(node)
+		require.Contains(t, paths, zipPath+"!/src")
+		require.Contains(t, paths, zipPath+"!/src/main.go")
+		require.Contains(t, paths, zipPath+"!/README")
+		require.Contains(t, paths, zipPath+"!/escape")
+		require.Contains(t, paths, tgzPath+"!/lib/a.c")
+		require.Contains(t, paths, filepath.Join(root, "broken.zip"))
+		// root, 3 archives, 4 + 3 entries
+		require.Len(t, paths, 11)
+
+		for _, child := range node.Children {
+			info := child.Info.(file.NodeInformable)
+			if info.GetAbsPath() != tgzPath {
+				continue
+			}
+			link := child.Children[0].Children[1].Info.(*file.SymlinkNode)
+			require.Equal(t, tgzPath+"!/lib/link", link.GetAbsPath())
+			require.Equal(t, "a.c", link.Target)
+		}
+
+		// An archive can be tracked by itself.
+		node, err = ScanDirectoryV2(context.Background(), zipPath, ScanOptions{IntoArchives: true})
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, node, 5)
+
+		tracker := NewLocalTracker()
+		for p, expected := range map[string]bool{
+			zipPath + "!/src":         true,
+			zipPath + "!/src/main.go": true,
+			zipPath + "!/missing":     false,
+			root + "/gone.zip!/x":     false,
+		} {
+			exists, err := tracker.Exists(context.Background(), p)
+			require.NoError(t, err)
+			require.Equal(t, expected, exists, p)
+		}
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, mockDir, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestScanZipSymlink(t *testing.T) {
+	mockDir := &utils.MockDir{DirName: "archives"}
+	testExecFunc := func(t *testing.T, root string) {
+		zipPath := filepath.Join(root, "links.zip")
+		f, err := os.Create(zipPath)
+		require.NoError(t, err)
+		zw := zip.NewWriter(f)
+		_, err = zw.Create("main.go")
+		require.NoError(t, err)
+		hdr := &zip.FileHeader{Name: "current"}
+		hdr.SetMode(os.ModeSymlink | 0777)
+		w, err := zw.CreateHeader(hdr)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("main.go"))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		require.NoError(t, f.Close())
+
+		node, err := ScanDirectoryV2(context.Background(), zipPath, ScanOptions{IntoArchives: true})
+		require.NoError(t, err)
+		var link *file.SymlinkNode
+		for _, child := range node.Children {
+			if sn, ok := child.Info.(*file.SymlinkNode); ok {
+				link = sn
+			}
+		}
+		require.NotNil(t, link)
+		require.Equal(t, zipPath+"!/current", link.GetAbsPath())
+		require.Equal(t, "main.go", link.Target)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, mockDir, testExecFunc)
+	testExectutor.Execute()
+}
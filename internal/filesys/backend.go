@@ -0,0 +1,88 @@
+package filesys
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+)
+
+// Backend is everything a context type provides to scan, track and resolve its paths.
+// A backend registers itself with RegisterBackend, typically from an init function of
+// its package.
+type Backend struct {
+	// Type is the context type, as given to "context create --type".
+	Type string
+	// RootPath is the path of the root node of a new context's tree.
+	RootPath string
+	// NewScanner creates a Scanner for the context type.
+	NewScanner func() (Scanner, error)
+	// NewTracker creates the Tracker of the current context. The context's ignore
+	// patterns are already part of opts.
+	NewTracker func(cxtRepo contextrepo.ContextRepository, opts ScanOptions) (Tracker, error)
+	// NewResolver creates the Resolver turning user given paths into absolute ones.
+	NewResolver func(cxtRepo contextrepo.ContextRepository) Resolver
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]*Backend{}
+)
+
+// RegisterBackend makes a context type available. It panics if the type is
+// registered twice or a constructor is missing.
+func RegisterBackend(b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if b.Type == "" || b.NewScanner == nil || b.NewTracker == nil || b.NewResolver == nil {
+		panic(fmt.Sprintf("filesys: incomplete backend %q", b.Type))
+	}
+	if _, dup := backends[b.Type]; dup {
+		panic(fmt.Sprintf("filesys: backend %q registered twice", b.Type))
+	}
+	backends[b.Type] = &b
+}
+
+// LookupBackend returns the backend registered for contextType.
+func LookupBackend(contextType string) (*Backend, error) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	b, ok := backends[contextType]
+	if !ok {
+		return nil, fmt.Errorf("unknown context type %q, expected one of: %s", contextType, strings.Join(backendTypesLocked(), ", "))
+	}
+	return b, nil
+}
+
+// BackendTypes returns the registered context types, sorted.
+func BackendTypes() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return backendTypesLocked()
+}
+
+func backendTypesLocked() []string {
+	types := make([]string, 0, len(backends))
+	for t := range backends {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// currentBackend returns the backend of the current context of cxtRepo.
+func currentBackend(cxtRepo contextrepo.ContextRepository) (*Backend, error) {
+	ctxName, err := cxtRepo.GetContext()
+	if err != nil {
+		return nil, err
+	}
+	contextType, err := cxtRepo.GetContextType(ctxName)
+	if err != nil {
+		return nil, err
+	}
+	return LookupBackend(contextType)
+}
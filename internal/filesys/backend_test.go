@@ -0,0 +1,38 @@
+package filesys
+
+import (
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/file"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendRegistry(t *testing.T) {
+	require.Equal(t, []string{contextrepo.TypeGDrive, contextrepo.TypeLocal, TypeSFTP, TypeWebDAV}, BackendTypes())
+
+	local, err := LookupBackend(contextrepo.TypeLocal)
+	require.NoError(t, err)
+	require.Equal(t, "/", local.RootPath)
+	scanner, err := local.NewScanner()
+	require.NoError(t, err)
+	require.IsType(t, &UnixFileSystemScanner{}, scanner)
+	require.IsType(t, LocalResolver{}, local.NewResolver(nil))
+
+	gdrive, err := LookupBackend(contextrepo.TypeGDrive)
+	require.NoError(t, err)
+	require.Equal(t, file.GDrivePathPrefix, gdrive.RootPath)
+
+	webdav, err := LookupBackend(TypeWebDAV)
+	require.NoError(t, err)
+	require.Equal(t, file.WebDAVPathPrefix, webdav.RootPath)
+
+	_, err = LookupBackend("ftp")
+	require.ErrorContains(t, err, "expected one of: gdrive, local, sftp, webdav")
+
+	require.Panics(t, func() { RegisterBackend(*local) })
+	require.Panics(t, func() { RegisterBackend(Backend{Type: "incomplete"}) })
+	_, err = LookupBackend("incomplete")
+	require.Error(t, err)
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package filesys
+
+import "io/fs"
+
+// fileIDOf is not supported on this platform. Without it cycles cannot be
+// detected, so directory symlinks are recorded even under SymlinksFollow.
+func fileIDOf(info fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
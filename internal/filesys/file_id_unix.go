@@ -0,0 +1,17 @@
+//go:build unix
+
+package filesys
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIDOf returns the device and inode identifying the file described by info.
+func fileIDOf(info fs.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -1,17 +1,29 @@
 package filesys
 
 import (
+	"context"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/heroku/self/MetaManager/internal/cmderror"
 	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
 	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/sirupsen/logrus"
 )
 
+// cxtKeyIgnore holds the IgnoreStack in effect for a FSScannableNode.
+const cxtKeyIgnore = "ignore"
+
+// cxtKeyAncestors holds the *dirChain of directories above a FSScannableNode.
+const cxtKeyAncestors = "ancestors"
+
 // UnixFileSystemScanner scans local file system directories.
 type UnixFileSystemScanner struct {
+	opts ScanOptions
 }
 
 // NewUnixFileSystemScanner creates a new UnixFileSystemScanner.
@@ -19,9 +31,19 @@ func NewUnixFileSystemScanner() *UnixFileSystemScanner {
 	return &UnixFileSystemScanner{}
 }
 
+// NewUnixFileSystemScannerWithOptions creates a UnixFileSystemScanner using opts.
+func NewUnixFileSystemScannerWithOptions(opts ScanOptions) *UnixFileSystemScanner {
+	return &UnixFileSystemScanner{opts: opts}
+}
+
 // Scan scans a directory path and returns a tree node.
 func (u *UnixFileSystemScanner) Scan(path string) (*ds.TreeNode, error) {
-	return ScanDirectoryV2(path)
+	return u.ScanContext(context.Background(), path)
+}
+
+// ScanContext is like Scan but stops early once ctx is cancelled.
+func (u *UnixFileSystemScanner) ScanContext(ctx context.Context, path string) (*ds.TreeNode, error) {
+	return ScanDirectoryV2(ctx, path, u.opts)
 }
 
 // Make sure that UnixFileSystemScanner implements Scanner
@@ -30,20 +52,58 @@ var _ Scanner = (*UnixFileSystemScanner)(nil)
 // FSScannableNode is a scannable node for the file system.
 type FSScannableNode struct {
 	strAbsPath string
-	cTreeNode  *ds.TreeNode
-	children   []ScannableNode
+	// info is known up front for entries discovered through their parent's
+	// directory listing, sparing a stat per entry. It is nil for the scan root.
+	info fs.FileInfo
+	// isLink is set for symbolic links, info then describes the link target
+	// when it is being followed and the link itself otherwise
+	isLink    bool
+	opts      *ScanOptions
+	cTreeNode *ds.TreeNode
+	children  []ScannableNode
+	// ignore is the stack handed down to the children of this node
+	ignore IgnoreStack
+	// ancestors are the directories from the scan root down to this node
+	ancestors *dirChain
+	depth     int
+}
+
+// fileID identifies a file independently of the path it was reached by.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// dirChain is a linked list of directory identities, innermost first. Children
+// share their parent's chain, so it is never modified once built.
+type dirChain struct {
+	id     fileID
+	parent *dirChain
+}
+
+func (c *dirChain) contains(id fileID) bool {
+	for ; c != nil; c = c.parent {
+		if c.id == id {
+			return true
+		}
+	}
+	return false
 }
 
 // NewFSScannableNode creates a new file system scannable node.
 func NewFSScannableNode(absPath string) *FSScannableNode {
 	return &FSScannableNode{
 		strAbsPath: absPath,
+		opts:       &ScanOptions{},
 	}
 }
 
 // GetChildren returns the children nodes and their contexts.
 func (f *FSScannableNode) GetChildren() ([]ScannableNode, []ScannableCxt, error) {
 	scCxt := make([]ScannableCxt, len(f.children))
+	for i := range scCxt {
+		scCxt[i] = f.childCxt()
+	}
 	return f.children, scCxt, nil
 }
 
@@ -52,90 +112,222 @@ func (f *FSScannableNode) ConstructTreeNode() (*ds.TreeNode, error) {
 	return f.cTreeNode, nil
 }
 
+func (f *FSScannableNode) scanPath() string {
+	return f.strAbsPath
+}
+
+func (f *FSScannableNode) isDir() bool {
+	return f.info != nil && f.info.IsDir()
+}
+
 // EvalNode evaluates the node by reading file system information.
 func (f *FSScannableNode) EvalNode(cxt ScannableCxt) error {
 	f.children = []ScannableNode{}
 
-	node, err := os.Stat(f.strAbsPath)
-	if err != nil {
-		return err
+	if f.isLink && f.opts.Symlinks != SymlinksFollow {
+		return f.recordLink()
+	}
+
+	node := f.info
+	if node == nil {
+		var err error
+		node, err = os.Stat(f.strAbsPath)
+		if err != nil {
+			if f.isLink {
+				logrus.Debugf("[scan] dangling symlink %q: %v", f.strAbsPath, err)
+				return f.recordLink()
+			}
+			return err
+		}
+		f.info = node
 	}
 
-	if node.IsDir() {
+	if !node.IsDir() {
 		nodeInfo := &file.FileNode{
-			GeneralNode: file.NewGeneralNode(f.strAbsPath, node),
+			GeneralNode: file.NewLazyGeneralNode(f.strAbsPath, node),
 		}
 		f.cTreeNode = ds.NewTreeNode(nodeInfo)
+		return nil
+	}
+
+	f.ancestors, _ = cxt[cxtKeyAncestors].(*dirChain)
+	id, ok := fileIDOf(node)
+	if f.isLink && (!ok || f.ancestors.contains(id)) {
+		logrus.Debugf("[scan] not following symlink %q, it would revisit a directory", f.strAbsPath)
+		return f.recordLink()
+	}
+	if ok {
+		f.ancestors = &dirChain{id: id, parent: f.ancestors}
+	}
+
+	// The tree node is set before reading the directory so that an unreadable
+	// directory is still recorded, only without its children.
+	nodeInfo := &file.FileNode{
+		GeneralNode: file.NewLazyGeneralNode(f.strAbsPath, node),
+	}
+	f.cTreeNode = ds.NewTreeNode(nodeInfo)
 
-		entries, err := os.ReadDir(f.strAbsPath)
+	f.depth, _ = cxt[cxtKeyDepth].(int)
+	if !f.opts.filter.descend(f.depth) {
+		return nil
+	}
+
+	f.ignore, _ = cxt[cxtKeyIgnore].(IgnoreStack)
+	if !f.opts.NoIgnore {
+		matcher, err := LoadIgnoreFile(f.strAbsPath)
 		if err != nil {
 			return err
 		}
-		for _, entry := range entries {
-			absEntryPath, err := filepath.Abs(f.strAbsPath + "/" + entry.Name())
-			if err != nil {
-				return err
-			}
-			nextNode := &FSScannableNode{
-				strAbsPath: absEntryPath,
-			}
-			f.children = append(f.children, nextNode)
+		f.ignore = f.ignore.Push(matcher)
+	}
+
+	// Visiting before reading the directory lets a watcher see entries created meanwhile.
+	if f.opts.visitDir != nil && !f.isLink {
+		err := f.opts.visitDir(f)
+		if err != nil {
+			return err
 		}
-	} else {
-		nodeInfo := &file.FileNode{
-			GeneralNode: file.NewGeneralNode(f.strAbsPath, node),
+	}
+
+	entries, err := os.ReadDir(f.strAbsPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if nextNode := f.newChild(entry); nextNode != nil {
+			f.children = append(f.children, nextNode)
 		}
-		f.cTreeNode = ds.NewTreeNode(nodeInfo)
 	}
 
 	return nil
 }
 
-// ScanDirectoryV2 scans a directory and returns a tree node representation.
-func ScanDirectoryV2(dirPath string) (*ds.TreeNode, error) {
-	present, err := utils.IsFilePresent(dirPath)
+// newChild returns the scannable node for an entry of this directory, or nil when
+// the entry is not tracked because of the symlink policy, ignore files or filters.
+func (f *FSScannableNode) newChild(entry fs.DirEntry) ScannableNode {
+	absEntryPath := filepath.Join(f.strAbsPath, entry.Name())
+	nextNode := &FSScannableNode{
+		strAbsPath: absEntryPath,
+		info:       &dirEntryInfo{DirEntry: entry},
+		isLink:     entry.Type()&fs.ModeSymlink != 0,
+		opts:       f.opts,
+	}
+	if nextNode.isLink {
+		if f.opts.Symlinks == SymlinksSkip {
+			return nil
+		}
+		if f.opts.Symlinks == SymlinksFollow {
+			// A nil info (dangling link) is stat'ed again, and recorded, by the child.
+			nextNode.info, _ = os.Stat(absEntryPath)
+		}
+	}
+	if f.ignore.Ignored(absEntryPath, nextNode.isDir()) {
+		return nil
+	}
+	if !f.opts.filter.keep(absEntryPath, nextNode.isDir(), nextNode.info) {
+		return nil
+	}
+	if f.opts.IntoArchives && !nextNode.isLink && file.IsArchivePath(absEntryPath) && entry.Type().IsRegular() {
+		return NewArchiveScannableNode(absEntryPath, nextNode.info)
+	}
+	return nextNode
+}
+
+// childCxt is the context handed to the children of this node.
+func (f *FSScannableNode) childCxt() ScannableCxt {
+	return ScannableCxt{cxtKeyIgnore: f.ignore, cxtKeyAncestors: f.ancestors, cxtKeyDepth: f.depth + 1}
+}
+
+// recordLink stores the node as a file.SymlinkNode without looking behind the link.
+func (f *FSScannableNode) recordLink() error {
+	target, err := os.Readlink(f.strAbsPath)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	info, err := os.Lstat(f.strAbsPath)
+	if err != nil {
+		return err
 	}
+	f.info = info
+	f.cTreeNode = ds.NewTreeNode(file.NewSymlinkNode(f.strAbsPath, target, info))
+	return nil
+}
 
-	if !present {
-		return nil, &cmderror.InvalidPath{}
+// dirEntryInfo adapts a fs.DirEntry to fs.FileInfo. Size and ModTime need a stat
+// and are only fetched if asked for.
+type dirEntryInfo struct {
+	fs.DirEntry
+	once sync.Once
+	info fs.FileInfo
+}
+
+func (d *dirEntryInfo) stat() fs.FileInfo {
+	d.once.Do(func() {
+		d.info, _ = d.DirEntry.Info()
+	})
+	return d.info
+}
+
+func (d *dirEntryInfo) Mode() fs.FileMode {
+	if info := d.stat(); info != nil {
+		return info.Mode()
 	}
+	return d.Type()
+}
 
-	dirPathAbs, err := filepath.Abs(dirPath)
-	if err != nil {
-		return nil, err
+func (d *dirEntryInfo) Size() int64 {
+	if info := d.stat(); info != nil {
+		return info.Size()
 	}
+	return 0
+}
 
-	scNode := NewFSScannableNode(dirPathAbs)
-	scCxt := make(map[string]any)
-	return scanDirV2(scNode, scCxt)
+func (d *dirEntryInfo) ModTime() time.Time {
+	if info := d.stat(); info != nil {
+		return info.ModTime()
+	}
+	return time.Time{}
 }
 
-// scanDirV2 recursively scans a scannable node and its children.
-func scanDirV2(sc ScannableNode, scCxt ScannableCxt) (*ds.TreeNode, error) {
-	err := sc.EvalNode(scCxt)
-	if err != nil {
-		return nil, err
+func (d *dirEntryInfo) Sys() any {
+	if info := d.stat(); info != nil {
+		return info.Sys()
 	}
+	return nil
+}
 
-	curTreeNode, err := sc.ConstructTreeNode()
+// ScanDirectoryV2 scans a directory with a bounded pool of opts.Workers workers and
+// returns a tree node representation. Children keep the order of the directory
+// listing regardless of scheduling, so the result does not depend on the pool size.
+// Entries that cannot be read are reported through ScanErrors alongside the
+// partial tree; cancelling ctx aborts the scan.
+func ScanDirectoryV2(ctx context.Context, dirPath string, opts ScanOptions) (*ds.TreeNode, error) {
+	present, err := utils.IsFilePresent(dirPath)
 	if err != nil {
 		return nil, err
 	}
 
-	chs, chCxts, err := sc.GetChildren()
+	if !present {
+		return nil, &cmderror.InvalidPath{}
+	}
+
+	dirPathAbs, err := filepath.Abs(dirPath)
 	if err != nil {
 		return nil, err
 	}
 
-	for i := range chs {
-		childTreeNode, err := scanDirV2(chs[i], chCxts[i])
-		if err != nil {
-			return nil, err
+	opts.filter = opts.Filter.compile(dirPathAbs)
+	var scNode ScannableNode = &FSScannableNode{
+		strAbsPath: dirPathAbs,
+		opts:       &opts,
+	}
+	if opts.IntoArchives && file.IsArchivePath(dirPathAbs) {
+		if info, err := os.Stat(dirPathAbs); err == nil && info.Mode().IsRegular() {
+			scNode = NewArchiveScannableNode(dirPathAbs, info)
 		}
-		curTreeNode.AddChild(childTreeNode)
 	}
-
-	return curTreeNode, nil
+	scCxt := ScannableCxt{
+		cxtKeyIgnore: IgnoreStack{}.Push(opts.rootIgnoreMatcher(dirPathAbs)),
+	}
+	return scanParallel(ctx, scNode, scCxt, opts)
 }
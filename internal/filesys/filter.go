@@ -0,0 +1,89 @@
+package filesys
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// cxtKeyDepth holds the depth of a scannable node below the scan root, which is at depth 0.
+const cxtKeyDepth = "depth"
+
+// ScanFilter narrows down what a recursive scan tracks. The zero value keeps everything.
+type ScanFilter struct {
+	// MaxDepth is the deepest level tracked below the scanned root, whose
+	// children are at depth 1. Zero means unlimited.
+	MaxDepth int
+	// Include, when set, keeps only files matching one of these gitignore-style
+	// patterns. Directories are always kept so that matches stay reachable.
+	Include []string
+	// Exclude drops files and whole directories matching gitignore-style patterns.
+	Exclude []string
+	// DirsOnly drops every entry that is not a directory.
+	DirsOnly bool
+	// MinSize drops files smaller than this many bytes.
+	MinSize int64
+	// NewerThan drops files last modified before this time.
+	NewerThan time.Time
+}
+
+// compiledFilter is a ScanFilter bound to the root of a scan.
+type compiledFilter struct {
+	ScanFilter
+	include *IgnoreMatcher
+	exclude *IgnoreMatcher
+}
+
+func (f ScanFilter) compile(root string) *compiledFilter {
+	c := &compiledFilter{ScanFilter: f}
+	if len(f.Include) > 0 {
+		c.include = NewIgnoreMatcher(root, f.Include)
+	}
+	if len(f.Exclude) > 0 {
+		c.exclude = NewIgnoreMatcher(root, f.Exclude)
+	}
+	return c
+}
+
+// descend reports whether the children of a directory at depth are scanned.
+func (c *compiledFilter) descend(depth int) bool {
+	return c == nil || c.MaxDepth <= 0 || depth < c.MaxDepth
+}
+
+// keep reports whether the entry at p is tracked. info may be nil when the entry
+// could not be described, which only passes filters that do not need it.
+func (c *compiledFilter) keep(p string, isDir bool, info fs.FileInfo) bool {
+	if c == nil {
+		return true
+	}
+	if _, excluded := c.exclude.Match(p, isDir); excluded {
+		return false
+	}
+	if isDir {
+		return true
+	}
+	if c.DirsOnly {
+		return false
+	}
+	if c.include != nil {
+		if _, included := c.include.Match(p, false); !included {
+			return false
+		}
+	}
+	if c.MinSize > 0 && (info == nil || info.Size() < c.MinSize) {
+		return false
+	}
+	if !c.NewerThan.IsZero() && (info == nil || info.ModTime().Before(c.NewerThan)) {
+		return false
+	}
+	return true
+}
+
+// ParseNewerThan parses an age such as "36h" or "7d", or a date ("2006-01-02") or RFC 3339
+// timestamp, into the time before which files are considered old. It takes what
+// "modified" takes in search queries, see utils.ParseTimeRange.
+func ParseNewerThan(s string, now time.Time) (time.Time, error) {
+	from, _, _, err := utils.ParseTimeRange(s, now)
+	return from, err
+}
@@ -0,0 +1,97 @@
+package filesys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanFilter(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"a.pdf", "b.txt"},
+		Dirs: []*utils.MockDir{
+			{
+				DirName: "tmp",
+				Files:   []string{"c.pdf"},
+			},
+			{
+				DirName: "2_1",
+				Files:   []string{"d.pdf"},
+				Dirs: []*utils.MockDir{
+					{
+						DirName: "3_1",
+						Files:   []string{"e.txt"},
+					},
+				},
+			},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "a.pdf"), make([]byte, 2048), 0644))
+		old := time.Now().Add(-48 * time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(root, "b.txt"), old, old))
+
+		tests := []struct {
+			name   string
+			filter ScanFilter
+			nodes  int
+		}{
+			// root, 2 files, tmp, c.pdf, 2_1, d.pdf, 3_1, e.txt
+			{"none", ScanFilter{}, 9},
+			// root, 2 files, tmp, 2_1
+			{"depth", ScanFilter{MaxDepth: 1}, 5},
+			// root, a.pdf, tmp, c.pdf, 2_1, d.pdf, 3_1
+			{"include", ScanFilter{Include: []string{"*.pdf"}}, 7},
+			// root, 2 files, 2_1, d.pdf, 3_1, e.txt
+			{"exclude dir", ScanFilter{Exclude: []string{"tmp/"}}, 7},
+			// root, 2 files, tmp, 2_1, d.pdf, 3_1, e.txt
+			{"exclude contents", ScanFilter{Exclude: []string{"tmp/**"}}, 8},
+			// root, tmp, 2_1, 3_1
+			{"dirs only", ScanFilter{DirsOnly: true}, 4},
+			// root, a.pdf and the directories
+			{"min size", ScanFilter{MinSize: 1024}, 5},
+			// everything but b.txt
+			{"newer than", ScanFilter{NewerThan: time.Now().Add(-time.Hour)}, 8},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{Filter: tt.filter})
+				require.NoError(t, err)
+				utils.ValidateNodeCnt(t, node, tt.nodes)
+			})
+		}
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestParseNewerThan(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	got, err := ParseNewerThan("7d", now)
+	require.NoError(t, err)
+	require.Equal(t, now.AddDate(0, 0, -7), got)
+
+	got, err = ParseNewerThan("36h", now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(-36*time.Hour), got)
+
+	got, err = ParseNewerThan("2w", now)
+	require.NoError(t, err)
+	require.Equal(t, now.AddDate(0, 0, -14), got)
+
+	got, err = ParseNewerThan("2024-01-02T03:04:05Z", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), got)
+
+	_, err = ParseNewerThan("last week", now)
+	require.Error(t, err)
+}
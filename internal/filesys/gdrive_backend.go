@@ -0,0 +1,55 @@
+package filesys
+
+import (
+	"context"
+
+	"github.com/heroku/self/MetaManager/internal/file"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/services"
+)
+
+func init() {
+	RegisterBackend(Backend{
+		Type:     contextrepo.TypeGDrive,
+		RootPath: file.GDrivePathPrefix,
+		NewScanner: func() (Scanner, error) {
+			return createGDriveScanner()
+		},
+		NewTracker: func(cxtRepo contextrepo.ContextRepository, opts ScanOptions) (Tracker, error) {
+			svc, err := services.GetGDriveService(context.Background())
+			if err != nil {
+				return nil, err
+			}
+			return NewGDriveTrackerWithOptions(svc, opts), nil
+		},
+		NewResolver: func(cxtRepo contextrepo.ContextRepository) Resolver {
+			return NewGDriveResolver(cxtRepo)
+		},
+	})
+}
+
+// GDriveResolver resolves Drive paths against the context's Drive working directory
+// (see "gdrive cd"). Paths are absolute when they start with "/" or "gdrive:/".
+type GDriveResolver struct {
+	ctxRepo contextrepo.ContextRepository
+}
+
+func NewGDriveResolver(ctxRepo contextrepo.ContextRepository) *GDriveResolver {
+	return &GDriveResolver{ctxRepo: ctxRepo}
+}
+
+func (r *GDriveResolver) Resolve(path string) (string, error) {
+	if file.IsGDrivePath(path) {
+		path = ResolvePath("/", path[len(file.GDrivePathRoot):])
+	} else {
+		cwd, err := r.ctxRepo.GetGDriveCwd()
+		if err != nil {
+			return "", err
+		}
+		path = ResolvePath(cwd, path)
+	}
+	if path == "/" {
+		return file.GDrivePathPrefix, nil
+	}
+	return file.GDrivePathRoot + path, nil
+}
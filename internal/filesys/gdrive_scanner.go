@@ -2,8 +2,10 @@ package filesys
 
 import (
 	"context"
+	"io/fs"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/heroku/self/MetaManager/internal/cmderror"
 	"github.com/heroku/self/MetaManager/internal/ds"
@@ -19,7 +21,8 @@ const driveShortcutMimeType = "application/vnd.google-apps.shortcut"
 
 // GDriveScanner scans Google Drive directories.
 type GDriveScanner struct {
-	svc services.GDriveServiceInterface
+	svc  services.GDriveServiceInterface
+	opts ScanOptions
 }
 
 // NewGDriveScanner creates a new GDriveScanner with the given service.
@@ -27,6 +30,12 @@ func NewGDriveScanner(svc services.GDriveServiceInterface) *GDriveScanner {
 	return &GDriveScanner{svc: svc}
 }
 
+// NewGDriveScannerWithOptions creates a GDriveScanner using opts. Only name patterns of
+// opts.IgnorePatterns apply, as .mmignore files are not read from Drive.
+func NewGDriveScannerWithOptions(svc services.GDriveServiceInterface, opts ScanOptions) *GDriveScanner {
+	return &GDriveScanner{svc: svc, opts: opts}
+}
+
 // createGDriveScanner creates a new GDriveScanner.
 func createGDriveScanner() (*GDriveScanner, error) {
 	svc, err := services.GetGDriveService(context.Background())
@@ -66,11 +75,13 @@ func (g *GDriveScanner) TrackGDrive(ctx context.Context, drivePath string, recur
 
 	visited := make(map[string]bool)
 	visited[folderID] = true // avoid cycling back to root
-	return g.trackGDriveFolder(ctx, folderID, baseVirtual, recursive, 0, visited)
+	ignore := g.opts.rootIgnoreMatcher(baseVirtual)
+	g.opts.filter = g.opts.Filter.compile(baseVirtual)
+	return g.trackGDriveFolder(ctx, folderID, baseVirtual, recursive, 0, visited, ignore)
 }
 
 // trackGDriveFolder recursively tracks a Google Drive folder.
-func (g *GDriveScanner) trackGDriveFolder(ctx context.Context, folderID, virtualPath string, recursive bool, depth int, visited map[string]bool) (*ds.TreeNode, error) {
+func (g *GDriveScanner) trackGDriveFolder(ctx context.Context, folderID, virtualPath string, recursive bool, depth int, visited map[string]bool, ignore *IgnoreMatcher) (*ds.TreeNode, error) {
 	logrus.Debugf("[track-gdrive] trackGDriveFolder depth=%d folderID=%q path=%q", depth, folderID, virtualPath)
 
 	if depth > maxTrackDepth {
@@ -95,13 +106,22 @@ func (g *GDriveScanner) trackGDriveFolder(ctx context.Context, folderID, virtual
 	rootNode := file.NewDriveDirNode(virtualPath, folderID)
 	for _, e := range entries {
 		childVirtual := path.Join(virtualPath, e.Name)
+		if _, ignored := ignore.Match(childVirtual, e.IsFolder); ignored {
+			logrus.Debugf("[track-gdrive] ignoring %q", childVirtual)
+			continue
+		}
+		if !g.opts.filter.keep(childVirtual, e.IsFolder, driveEntryInfo{e}) {
+			logrus.Debugf("[track-gdrive] filtered out %q", childVirtual)
+			continue
+		}
 		if e.IsFolder {
 			// Do not recurse into shortcuts (they point to other folders and cause cycles).
 			isShortcut := e.MimeType == driveShortcutMimeType
 			childNode := file.NewDriveDirNode(childVirtual, e.Id)
-			if recursive && !isShortcut && !visited[e.Id] {
+			childNode.Info.(*file.FileNode).MimeType = e.MimeType
+			if recursive && !isShortcut && !visited[e.Id] && g.opts.filter.descend(depth+1) {
 				logrus.Debugf("[track-gdrive] recursing into folder %q id=%q", e.Name, e.Id)
-				sub, err := g.trackGDriveFolder(ctx, e.Id, childVirtual, true, depth+1, visited)
+				sub, err := g.trackGDriveFolder(ctx, e.Id, childVirtual, true, depth+1, visited, ignore)
 				if err != nil {
 					return nil, err
 				}
@@ -110,15 +130,38 @@ func (g *GDriveScanner) trackGDriveFolder(ctx context.Context, folderID, virtual
 				logrus.Debugf("[track-gdrive] skipping shortcut %q id=%q", e.Name, e.Id)
 			} else if visited[e.Id] {
 				logrus.Debugf("[track-gdrive] skipping already visited folder %q id=%q", e.Name, e.Id)
+			} else if recursive {
+				logrus.Debugf("[track-gdrive] depth limit reached at folder %q", e.Name)
 			}
 			rootNode.Children = append(rootNode.Children, childNode)
 		} else {
-			rootNode.Children = append(rootNode.Children, file.NewDriveFileNode(childVirtual, e.Id))
+			fileNode := file.NewDriveFileNode(childVirtual, e.Id)
+			fileNode.Info.(*file.FileNode).SetStat(driveEntryInfo{e})
+			fileNode.Info.(*file.FileNode).MimeType = e.MimeType
+			rootNode.Children = append(rootNode.Children, fileNode)
 		}
 	}
 	return rootNode, nil
 }
 
+// driveEntryInfo describes a Drive entry as a fs.FileInfo for scan filters.
+type driveEntryInfo struct {
+	e services.RootEntry
+}
+
+func (d driveEntryInfo) Name() string       { return d.e.Name }
+func (d driveEntryInfo) Size() int64        { return d.e.Size }
+func (d driveEntryInfo) ModTime() time.Time { return d.e.ModifiedTime }
+func (d driveEntryInfo) IsDir() bool        { return d.e.IsFolder }
+func (d driveEntryInfo) Sys() any           { return d.e }
+
+func (d driveEntryInfo) Mode() fs.FileMode {
+	if d.e.IsFolder {
+		return fs.ModeDir
+	}
+	return 0
+}
+
 // NormalizeTrackPath returns the path to use for tracking (strips trailing * and normalizes).
 func (g *GDriveScanner) NormalizeTrackPath(pathExp string) (path string, recursive bool) {
 	pathExp = strings.TrimSpace(pathExp)
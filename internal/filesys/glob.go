@@ -0,0 +1,35 @@
+package filesys
+
+import (
+	"io/fs"
+	"path/filepath"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/sirupsen/logrus"
+)
+
+// GlobLocal returns the local files and directories matching g, in lexical order.
+// Directories that cannot hold a match are not walked and unreadable ones are
+// skipped. Symbolic links are matched but not followed.
+func GlobLocal(g *data.Glob) ([]string, error) {
+	base := g.Base()
+	matches := []string{}
+	err := filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			logrus.Debugf("[glob] skipping %q: %v", p, err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		slashed := filepath.ToSlash(p)
+		if g.Match(slashed) {
+			matches = append(matches, p)
+		}
+		if d.IsDir() && p != base && !g.CanMatchBelow(slashed) {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	return matches, err
+}
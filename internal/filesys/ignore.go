@@ -0,0 +1,176 @@
+package filesys
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the name of the per-directory ignore file honored while scanning.
+const IgnoreFileName = ".mmignore"
+
+// ignoreRule is a single parsed line of an ignore file.
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// IgnoreMatcher matches paths against gitignore-style rules declared relative to a base path.
+type IgnoreMatcher struct {
+	base  string
+	rules []ignoreRule
+}
+
+// NewIgnoreMatcher parses gitignore-style lines. Patterns are interpreted relative to base,
+// which is an absolute local path or a virtual path such as "gdrive:/Folder".
+func NewIgnoreMatcher(base string, lines []string) *IgnoreMatcher {
+	m := &IgnoreMatcher{base: strings.TrimSuffix(base, "/")}
+	for _, line := range lines {
+		if rule, ok := parseIgnoreLine(line); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return m
+}
+
+// LoadIgnoreFile reads the .mmignore file in dir. It returns nil, nil when there is none.
+func LoadIgnoreFile(dir string) (*IgnoreMatcher, error) {
+	f, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return NewIgnoreMatcher(dir, lines), nil
+}
+
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	// A pattern without a slash matches at any depth, one with a slash is
+	// anchored to the directory holding the ignore file.
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	line = strings.TrimPrefix(line, "/")
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// Match reports whether any rule matched p and, if so, whether p is ignored.
+// The last matching rule wins, so negated rules can re-include paths.
+func (m *IgnoreMatcher) Match(p string, isDir bool) (matched, ignored bool) {
+	if m == nil {
+		return false, false
+	}
+	rel, ok := m.relative(p)
+	if !ok {
+		return false, false
+	}
+	names := strings.Split(rel, "/")
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, names) {
+			matched, ignored = true, !rule.negate
+		}
+	}
+	return matched, ignored
+}
+
+func (m *IgnoreMatcher) relative(p string) (string, bool) {
+	p = filepath.ToSlash(p)
+	base := filepath.ToSlash(m.base)
+	if base == "" {
+		return strings.TrimPrefix(p, "/"), p != ""
+	}
+	if !strings.HasPrefix(p, base+"/") {
+		return "", false
+	}
+	return p[len(base)+1:], true
+}
+
+// matchSegments matches path segments against pattern segments, where "**" spans any number of segments.
+func matchSegments(pattern, names []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				// "dir/**" matches everything inside dir, not dir itself.
+				return len(names) > 0
+			}
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(rest, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], names[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, names = pattern[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// IgnoreStack is the chain of matchers in effect for a directory, outermost first.
+type IgnoreStack []*IgnoreMatcher
+
+// Push returns a new stack with m appended. The receiver is left untouched so that
+// sibling directories can share the parent stack.
+func (s IgnoreStack) Push(m *IgnoreMatcher) IgnoreStack {
+	if m == nil {
+		return s
+	}
+	next := make(IgnoreStack, len(s), len(s)+1)
+	copy(next, s)
+	return append(next, m)
+}
+
+// Ignored reports whether p is ignored. Deeper ignore files override outer ones.
+func (s IgnoreStack) Ignored(p string, isDir bool) bool {
+	for i := len(s) - 1; i >= 0; i-- {
+		if matched, ignored := s[i].Match(p, isDir); matched {
+			return ignored
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package filesys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := NewIgnoreMatcher("/base", []string{
+		"# comment",
+		"",
+		"node_modules/",
+		"*.log",
+		"!keep.log",
+		"/target",
+		"docs/**/*.tmp",
+		`\#literal`,
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"dir pattern matches dir at any depth", "/base/a/node_modules", true, true},
+		{"dir pattern skips files", "/base/a/node_modules", false, false},
+		{"glob at any depth", "/base/x/y/debug.log", false, true},
+		{"negation re-includes", "/base/x/keep.log", false, false},
+		{"anchored matches at base", "/base/target", true, true},
+		{"anchored does not match deeper", "/base/sub/target", true, false},
+		{"double star spans dirs", "/base/docs/a/b/c.tmp", false, true},
+		{"double star matches zero dirs", "/base/docs/c.tmp", false, true},
+		{"escaped hash", "/base/#literal", false, true},
+		{"unrelated path", "/base/src/main.go", false, false},
+		{"outside base", "/other/debug.log", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ignored := m.Match(tt.path, tt.isDir)
+			assert.Equal(t, tt.ignored, ignored)
+		})
+	}
+}
+
+func TestIgnoreStackDeeperWins(t *testing.T) {
+	outer := NewIgnoreMatcher("/base", []string{"*.txt"})
+	inner := NewIgnoreMatcher("/base/sub", []string{"!notes.txt"})
+	stack := IgnoreStack{}.Push(outer).Push(inner)
+
+	require.True(t, stack.Ignored("/base/a.txt", false))
+	require.True(t, stack.Ignored("/base/sub/a.txt", false))
+	require.False(t, stack.Ignored("/base/sub/notes.txt", false))
+	require.False(t, IgnoreStack{}.Push(outer).Ignored("/base/sub/b.go", false))
+}
+
+func TestScanHonorsIgnore(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"a.go", "a.log"},
+		Dirs: []*utils.MockDir{
+			{
+				DirName: "node_modules",
+				Files:   []string{"x.js", "y.js"},
+			},
+			{
+				DirName: "src",
+				Files:   []string{"b.go", "b.tmp"},
+			},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, IgnoreFileName), []byte("node_modules/\n*.log\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "src", IgnoreFileName), []byte("*.tmp\n"), 0644))
+
+		// root, .mmignore, a.go, src, src/.mmignore, src/b.go
+		node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{})
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, node, 6)
+
+		// global patterns apply on top of .mmignore files
+		node, err = ScanDirectoryV2(context.Background(), root, ScanOptions{IgnorePatterns: []string{IgnoreFileName}})
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, node, 4)
+
+		// everything: root, 2 ignore files, 2 root files, 2 dirs, 4 nested files
+		node, err = ScanDirectoryV2(context.Background(), root, ScanOptions{NoIgnore: true, IgnorePatterns: []string{"*.go"}})
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, node, 11)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
@@ -0,0 +1,72 @@
+package filesys
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/config"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+)
+
+func init() {
+	RegisterBackend(Backend{
+		Type:     contextrepo.TypeLocal,
+		RootPath: "/",
+		NewScanner: func() (Scanner, error) {
+			return NewUnixFileSystemScanner(), nil
+		},
+		NewTracker: func(cxtRepo contextrepo.ContextRepository, opts ScanOptions) (Tracker, error) {
+			return NewLocalTrackerWithOptions(opts), nil
+		},
+		NewResolver: func(cxtRepo contextrepo.ContextRepository) Resolver {
+			return LocalResolver{ctxRepo: cxtRepo}
+		},
+	})
+}
+
+// LocalResolver resolves local paths against the working directory. "~" is the home
+// directory, and paths starting with the alias of a named root of the context, like
+// "work:/src", resolve inside that root.
+type LocalResolver struct {
+	ctxRepo contextrepo.ContextRepository
+}
+
+func (r LocalResolver) Resolve(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, path[1:]), nil
+	}
+	if alias, rest, ok := config.SplitRootAlias(path); ok && r.ctxRepo != nil {
+		root, found, err := r.namedRoot(alias)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			recursive := strings.HasSuffix(rest, "*")
+			resolved := filepath.Join(root.Path, strings.TrimSuffix(rest, "*"))
+			if recursive {
+				resolved += "*"
+			}
+			return resolved, nil
+		}
+	}
+	return filepath.Abs(path)
+}
+
+// namedRoot looks alias up in the roots of the current context.
+func (r LocalResolver) namedRoot(alias string) (config.NamedRoot, bool, error) {
+	ctxName, err := r.ctxRepo.GetContext()
+	if err != nil || ctxName == "" {
+		return config.NamedRoot{}, false, err
+	}
+	cfg, err := config.Load(ctxName)
+	if err != nil {
+		return config.NamedRoot{}, false, err
+	}
+	root, found := cfg.Root(alias)
+	return root, found, nil
+}
@@ -0,0 +1,37 @@
+package filesys
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/services"
+)
+
+// newRemoteNode creates a tree node for an entry listed by a remote service, found at
+// virtualPath.
+func newRemoteNode(virtualPath string, e services.RootEntry) *ds.TreeNode {
+	return ds.NewTreeNode(&file.FileNode{
+		GeneralNode: file.NewGeneralNode(virtualPath, remoteEntryInfo{e}),
+	})
+}
+
+// remoteEntryInfo describes an entry listed by a remote service as a fs.FileInfo for
+// scan filters.
+type remoteEntryInfo struct {
+	e services.RootEntry
+}
+
+func (d remoteEntryInfo) Name() string       { return d.e.Name }
+func (d remoteEntryInfo) Size() int64        { return d.e.Size }
+func (d remoteEntryInfo) ModTime() time.Time { return d.e.ModifiedTime }
+func (d remoteEntryInfo) IsDir() bool        { return d.e.IsFolder }
+func (d remoteEntryInfo) Sys() any           { return d.e }
+
+func (d remoteEntryInfo) Mode() fs.FileMode {
+	if d.e.IsFolder {
+		return fs.ModeDir
+	}
+	return 0
+}
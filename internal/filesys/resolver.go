@@ -2,12 +2,16 @@ package filesys
 
 import (
 	"fmt"
+	"os"
 	"path"
-	"path/filepath"
 	"strings"
 
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
 	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/sirupsen/logrus"
 )
 
 type Resolver interface {
@@ -15,6 +19,7 @@ type Resolver interface {
 	Resolve(path string) (string, error)
 }
 
+// BasicResolver resolves paths with the Resolver of the current context's backend.
 type BasicResolver struct {
 	ctxRepo contextrepo.ContextRepository
 }
@@ -23,39 +28,122 @@ func NewBasicResolver(ctxRepo contextrepo.ContextRepository) *BasicResolver {
 	return &BasicResolver{ctxRepo: ctxRepo}
 }
 
+// Resolve resolves path in the current context. $VAR and ${VAR} are replaced by the
+// environment variables that are set. A path starting with "@id", like
+// "@proj/docs/spec.md", is relative to the node with that id. A path inside an
+// archive, like "old.zip!/src/main.go", resolves the archive path and keeps the
+// entry path.
 func (r *BasicResolver) Resolve(path string) (string, error) {
-	contextName, err := r.ctxRepo.GetContext()
+	return r.resolve(expandEnv(path))
+}
+
+func (r *BasicResolver) resolve(path string) (string, error) {
+	if id, rest, ok := splitIdAnchor(path); ok {
+		anchor, err := r.idPath(id)
+		if err != nil {
+			return "", err
+		}
+		logrus.Debugf("[resolve] @%s is %q", id, anchor)
+		return r.resolve(anchor + rest)
+	}
+
+	if archive, inner, ok := file.SplitArchivePath(path); ok {
+		archiveAbs, err := r.resolve(archive)
+		if err != nil {
+			return "", err
+		}
+		return file.JoinArchivePath(archiveAbs, inner), nil
+	}
+
+	backend, err := currentBackend(r.ctxRepo)
 	if err != nil {
 		return "", err
 	}
-	contextType, err := r.ctxRepo.GetContextType(contextName)
+	return backend.NewResolver(r.ctxRepo).Resolve(path)
+}
+
+// idPath returns the path of the node with the given id in the current context.
+func (r *BasicResolver) idPath(id string) (string, error) {
+	ctxName, err := r.ctxRepo.GetContext()
 	if err != nil {
 		return "", err
 	}
-	switch contextType {
-	case contextrepo.TypeGDrive:
-		return r.resolveGDrive(path)
-	case contextrepo.TypeLocal:
-		return r.resolveLocal(path)
-	default:
-		return "", fmt.Errorf("invalid context type: %s", contextType)
+	rw, err := tree.GetRW(ctxName)
+	if err != nil {
+		return "", err
 	}
-}
-
-func (r *BasicResolver) resolveGDrive(path string) (string, error) {
-	cwd, err := r.ctxRepo.GetGDriveCwd()
+	root, err := rw.Read()
 	if err != nil {
 		return "", err
 	}
-	path = ResolvePath(cwd, path)
-	if path == "/" {
-		return file.GDrivePathPrefix, nil
+	node, err := data.NewDirTreeManager(ds.NewTreeManager(root)).FindFileNodeById(id)
+	if err != nil {
+		return "", fmt.Errorf("no node with id %q in context %q", id, ctxName)
+	}
+	return node.GetAbsPath(), nil
+}
+
+// splitIdAnchor splits "@proj/docs" into the id "proj" and "/docs". A trailing "*"
+// is kept in rest, so "@proj*" tracks the node recursively. ok is false for paths
+// that do not start with "@".
+func splitIdAnchor(p string) (id, rest string, ok bool) {
+	if !strings.HasPrefix(p, "@") {
+		return "", "", false
+	}
+	body := p[1:]
+	i := strings.IndexAny(body, "/*")
+	if i == -1 {
+		i = len(body)
+	}
+	if i == 0 {
+		return "", "", false
 	}
-	return file.GDrivePathRoot + path, nil
+	return body[:i], body[i:], true
 }
 
-func (r *BasicResolver) resolveLocal(path string) (string, error) {
-	return filepath.Abs(path)
+// expandEnv replaces $VAR and ${VAR} with the value of environment variables that are
+// set; others are kept as written, braces included, so a "$" in a file name survives.
+func expandEnv(p string) string {
+	if !strings.Contains(p, "$") {
+		return p
+	}
+	var b strings.Builder
+	for i := 0; i < len(p); {
+		if p[i] != '$' {
+			b.WriteByte(p[i])
+			i++
+			continue
+		}
+		name, end := envName(p[i+1:])
+		if v, ok := os.LookupEnv(name); ok && name != "" {
+			b.WriteString(v)
+		} else {
+			b.WriteString(p[i : i+1+end])
+		}
+		i += 1 + end
+	}
+	return b.String()
+}
+
+// envName returns the name of the variable s, what follows a "$", starts with, and
+// the length of its reference: "{NAME}" or NAME. The name is empty if there is none.
+func envName(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		j := strings.IndexByte(s, '}')
+		if j == -1 {
+			return "", 0
+		}
+		return s[1:j], j + 1
+	}
+	j := 0
+	for j < len(s) {
+		c := s[j]
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			break
+		}
+		j++
+	}
+	return s[:j], j
 }
 
 // NormalizePath returns a path like "/" or "/Folder/Sub" (leading slash, no trailing).
@@ -73,7 +161,7 @@ func NormalizePath(p string) string {
 }
 
 // ResolvePath resolves target against cwd (absolute path or relative). Returns an absolute path like "/" or "/Folder/Sub".
-// If target starts with "/", it is normalized and returned. Otherwise path.Join(cwd, target) is cleaned and normalized.
+// If target starts with "/", it is cleaned, normalized and returned. Otherwise path.Join(cwd, target) is cleaned and normalized.
 func ResolvePath(cwd, target string) string {
 	if cwd == "" {
 		cwd = "/"
@@ -83,7 +171,8 @@ func ResolvePath(cwd, target string) string {
 		return NormalizePath(cwd)
 	}
 	if strings.HasPrefix(target, "/") {
-		return NormalizePath(target)
+		// Clean so that ".." works in absolute paths too
+		return NormalizePath(path.Clean(target))
 	}
 	// Relative: join with cwd and clean (handles ".." and ".")
 	joined := path.Join(cwd, target)
@@ -5,9 +5,12 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/heroku/self/MetaManager/internal/config"
 	"github.com/heroku/self/MetaManager/internal/file"
 	contextmocks "github.com/heroku/self/MetaManager/internal/mocks/repository/context"
 	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+	"github.com/heroku/self/MetaManager/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -69,6 +72,8 @@ func TestResolvePath(t *testing.T) {
 		{"nested cwd, parent relative", "/Folder/Sub", "..", "/Folder"},
 		{"nested cwd, parent parent relative", "/Folder/Sub", "../..", "/"},
 		{"nested cwd, complex relative", "/Folder/Sub", "../Other/File", "/Folder/Other/File"},
+		{"nested cwd, absolute with parent", "/Folder", "/A/../B/", "/B"},
+		{"nested cwd, absolute above root", "/Folder", "/../B", "/B"},
 
 		// Whitespace handling
 		{"whitespace cwd", "  /Folder  ", "Sub", "/Folder  /Sub"},
@@ -122,6 +127,45 @@ func TestBasicResolver_Resolve_Local(t *testing.T) {
 			},
 			wantError: false,
 		},
+		{
+			name: "resolve path inside archive",
+			path: "old.tar.gz!/src/../main.go",
+			setupMock: func(m *contextmocks.MockContextRepository) {
+				m.On("GetContext").Return("localctx", nil)
+				m.On("GetContextType", "localctx").Return(contextrepo.TypeLocal, nil)
+			},
+			checkPath: func(t *testing.T, resolved string) {
+				abs, _ := filepath.Abs("old.tar.gz")
+				assert.Equal(t, abs+"!/main.go", resolved)
+			},
+			wantError: false,
+		},
+		{
+			name: "resolve archive root",
+			path: "old.zip!/",
+			setupMock: func(m *contextmocks.MockContextRepository) {
+				m.On("GetContext").Return("localctx", nil)
+				m.On("GetContextType", "localctx").Return(contextrepo.TypeLocal, nil)
+			},
+			checkPath: func(t *testing.T, resolved string) {
+				abs, _ := filepath.Abs("old.zip")
+				assert.Equal(t, abs, resolved)
+			},
+			wantError: false,
+		},
+		{
+			name: "separator after a directory",
+			path: "dir!/file.txt",
+			setupMock: func(m *contextmocks.MockContextRepository) {
+				m.On("GetContext").Return("localctx", nil)
+				m.On("GetContextType", "localctx").Return(contextrepo.TypeLocal, nil)
+			},
+			checkPath: func(t *testing.T, resolved string) {
+				abs, _ := filepath.Abs("dir!/file.txt")
+				assert.Equal(t, abs, resolved)
+			},
+			wantError: false,
+		},
 		{
 			name: "get context error",
 			path: "testfile.txt",
@@ -251,6 +295,29 @@ func TestBasicResolver_Resolve_GDrive(t *testing.T) {
 			expected:  file.GDrivePathRoot + "/Folder",
 			wantError: false,
 		},
+		{
+			name: "resolve parent steps in absolute path",
+			path: "/Folder/Sub/../../Other",
+			cwd:  "/Folder",
+			setupMock: func(m *contextmocks.MockContextRepository, cwd string) {
+				m.On("GetContext").Return("gdrivectx", nil)
+				m.On("GetContextType", "gdrivectx").Return(contextrepo.TypeGDrive, nil)
+				m.On("GetGDriveCwd").Return(cwd, nil)
+			},
+			expected:  file.GDrivePathRoot + "/Other",
+			wantError: false,
+		},
+		{
+			name: "resolve virtual path",
+			path: "gdrive:/Folder/../Other/",
+			cwd:  "/Folder",
+			setupMock: func(m *contextmocks.MockContextRepository, cwd string) {
+				m.On("GetContext").Return("gdrivectx", nil)
+				m.On("GetContextType", "gdrivectx").Return(contextrepo.TypeGDrive, nil)
+			},
+			expected:  file.GDrivePathRoot + "/Other",
+			wantError: false,
+		},
 		{
 			name: "resolve nested absolute path",
 			path: "/Folder/Sub/File",
@@ -306,15 +373,12 @@ func TestBasicResolver_Resolve_GDrive(t *testing.T) {
 	}
 }
 
-func TestBasicResolver_resolveLocal(t *testing.T) {
+func TestLocalResolver(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "testfile.txt")
 	require.NoError(t, os.WriteFile(testFile, []byte("test"), 0644))
 
-	mockRepo := contextmocks.NewMockContextRepository(t)
-	resolver := NewBasicResolver(mockRepo)
-
 	tests := []struct {
 		name     string
 		path     string
@@ -350,7 +414,7 @@ func TestBasicResolver_resolveLocal(t *testing.T) {
 				defer os.Chdir(oldWd)
 			}
 
-			result, err := resolver.resolveLocal(tt.path)
+			result, err := LocalResolver{}.Resolve(tt.path)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -367,7 +431,7 @@ func TestBasicResolver_resolveLocal(t *testing.T) {
 	}
 }
 
-func TestBasicResolver_resolveGDrive(t *testing.T) {
+func TestGDriveResolver(t *testing.T) {
 	tests := []struct {
 		name      string
 		path      string
@@ -432,8 +496,7 @@ func TestBasicResolver_resolveGDrive(t *testing.T) {
 			mockRepo := contextmocks.NewMockContextRepository(t)
 			tt.setupMock(mockRepo, tt.cwd)
 
-			resolver := NewBasicResolver(mockRepo)
-			result, err := resolver.resolveGDrive(tt.path)
+			result, err := NewGDriveResolver(mockRepo).Resolve(tt.path)
 
 			if tt.wantError {
 				assert.Error(t, err)
@@ -452,3 +515,87 @@ func TestNewBasicResolver(t *testing.T) {
 	assert.NotNil(t, resolver)
 	assert.Equal(t, mockRepo, resolver.ctxRepo)
 }
+
+func TestLocalResolverNamedRoots(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("MM_TEST_CONTEXT_DIR", dir)
+	defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+	appDir, err := utils.GetAppDataDirForContext("multi")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(appDir, 0755))
+	require.NoError(t, config.Save("multi", &config.Config{Roots: []config.NamedRoot{{Alias: "work", Path: "/home/me/work"}}}))
+
+	m := contextmocks.NewMockContextRepository(t)
+	m.On("GetContext").Return("multi", nil)
+	m.On("GetContextType", "multi").Return(contextrepo.TypeLocal, nil)
+	resolver := NewBasicResolver(m)
+
+	notARoot, err := filepath.Abs("nas:/x")
+	require.NoError(t, err)
+	tests := map[string]string{
+		"work:":                 "/home/me/work",
+		"work:/":                "/home/me/work",
+		"work:*":                "/home/me/work*",
+		"work:/src/../x*":       "/home/me/work/x*",
+		"work:/old.zip!/a/b.go": "/home/me/work/old.zip!/a/b.go",
+		"nas:/x":                notARoot,
+	}
+	for p, expected := range tests {
+		resolved, err := resolver.Resolve(p)
+		require.NoError(t, err, p)
+		require.Equal(t, expected, resolved, p)
+	}
+}
+
+func TestBasicResolverExpressions(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("MM_TEST_CONTEXT_DIR", dir)
+	defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("PROJECTS", "/srv")
+	appDir, err := utils.GetAppDataDirForContext("exprs")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(appDir, 0755))
+	root, err := file.CreateTreeNodeFromPath("/")
+	require.NoError(t, err)
+	proj, err := file.CreateTreeNodeFromPath("/srv/proj")
+	require.NoError(t, err)
+	proj.Info.(file.NodeInformable).SetId("proj")
+	root.AddChild(proj)
+	rw, err := tree.NewFileStorageRW(filepath.Join(appDir, utils.DataFileName))
+	require.NoError(t, err)
+	require.NoError(t, rw.Write(root))
+
+	m := contextmocks.NewMockContextRepository(t)
+	m.On("GetContext").Return("exprs", nil)
+	m.On("GetContextType", "exprs").Return(contextrepo.TypeLocal, nil)
+	resolver := NewBasicResolver(m)
+
+	unset, err := filepath.Abs("$MM_UNSET_VAR/x")
+	require.NoError(t, err)
+	unsetBraced, err := filepath.Abs("${MM_UNSET_VAR}/x")
+	require.NoError(t, err)
+	price, err := filepath.Abs("$5 ${x")
+	require.NoError(t, err)
+	tests := map[string]string{
+		"$PROJECTS/x":              "/srv/x",
+		"${PROJECTS}/x*":           "/srv/x*",
+		"$MM_UNSET_VAR/x":          unset,
+		"${MM_UNSET_VAR}/x":        unsetBraced,
+		"$5 ${x":                   price,
+		"~":                        "/home/me",
+		"~/notes/../todo.md":       "/home/me/todo.md",
+		"@proj":                    "/srv/proj",
+		"@proj*":                   "/srv/proj*",
+		"@proj/docs/../spec.md":    "/srv/proj/spec.md",
+		"@proj/old.zip!/a/b.go":    "/srv/proj/old.zip!/a/b.go",
+		"$PROJECTS/proj/docs/x.md": "/srv/proj/docs/x.md",
+	}
+	for p, expected := range tests {
+		resolved, err := resolver.Resolve(p)
+		require.NoError(t, err, p)
+		require.Equal(t, expected, resolved, p)
+	}
+	_, err = resolver.Resolve("@nope/x")
+	require.ErrorContains(t, err, "no node with id")
+}
@@ -0,0 +1,307 @@
+package filesys
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultScanWorkers is the worker pool size used when ScanOptions.Workers is not set.
+// Scans are dominated by I/O latency (especially on network mounts), so this is
+// deliberately larger than the CPU count.
+const DefaultScanWorkers = 16
+
+// progressInterval is how often scan progress is written to ScanOptions.Progress.
+const progressInterval = 500 * time.Millisecond
+
+// ScanError records an entry that could not be scanned.
+type ScanError struct {
+	Path string
+	Err  error
+}
+
+func (e ScanError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e ScanError) Unwrap() error {
+	return e.Err
+}
+
+// ScanErrors is returned together with a partial tree when some entries could not be scanned.
+// The entries listed here (and anything below them) are missing from the tree.
+type ScanErrors []ScanError
+
+func (e ScanErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, se := range e {
+		msgs = append(msgs, se.Error())
+	}
+	return fmt.Sprintf("%d entries could not be scanned:\n  %s", len(e), strings.Join(msgs, "\n  "))
+}
+
+// pathScannable is implemented by scannable nodes that know their own path. It is
+// only used to label errors.
+type pathScannable interface {
+	scanPath() string
+}
+
+// dirScannable is implemented by scannable nodes that know whether they are a
+// directory before being evaluated. It is only used for progress reporting.
+type dirScannable interface {
+	isDir() bool
+}
+
+type scanJob struct {
+	node ScannableNode
+	cxt  ScannableCxt
+	// parent has a preallocated slot at index for the tree node built by this job,
+	// which keeps the resulting tree ordered regardless of scheduling.
+	parent *ds.TreeNode
+	index  int
+}
+
+// scanPool evaluates scannable nodes with a bounded number of workers.
+type scanPool struct {
+	ctx     context.Context
+	workers int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []scanJob
+	pending int
+	// holes are parents with children that failed to scan, compacted at the end
+	holes map[*ds.TreeNode]bool
+	errs  ScanErrors
+
+	files       atomic.Int64
+	dirs        atomic.Int64
+	dirsPending atomic.Int64
+}
+
+// scanParallel builds the tree rooted at root. Failing entries below the root are
+// collected into ScanErrors and returned with the partial tree; a failing root,
+// or cancellation of ctx, aborts the scan.
+func scanParallel(ctx context.Context, root ScannableNode, rootCxt ScannableCxt, opts ScanOptions) (*ds.TreeNode, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultScanWorkers
+	}
+
+	if err := root.EvalNode(rootCxt); err != nil {
+		return nil, err
+	}
+	rootTreeNode, err := root.ConstructTreeNode()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &scanPool{
+		ctx:     ctx,
+		workers: workers,
+		holes:   map[*ds.TreeNode]bool{},
+	}
+	p.cond = sync.NewCond(&p.mu)
+	if err := p.enqueueChildren(root, rootTreeNode); err != nil {
+		return nil, err
+	}
+
+	stopProgress := p.reportProgress(opts.Progress)
+	stopWatch := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work()
+		}()
+	}
+	wg.Wait()
+	stopWatch()
+	stopProgress()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for parent := range p.holes {
+		parent.Children = compactChildren(parent.Children)
+	}
+	logrus.Debugf("[scan] done files=%d dirs=%d errors=%d", p.files.Load(), p.dirs.Load(), len(p.errs))
+	if len(p.errs) > 0 {
+		return rootTreeNode, p.errs
+	}
+	return rootTreeNode, nil
+}
+
+func (p *scanPool) work() {
+	for {
+		job, ok := p.next()
+		if !ok {
+			return
+		}
+		p.run(job)
+		p.done()
+	}
+}
+
+// next blocks until a job is available. It returns false once the scan is
+// finished or cancelled.
+func (p *scanPool) next() (scanJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.queue) == 0 && p.pending > 0 && p.ctx.Err() == nil {
+		p.cond.Wait()
+	}
+	if p.pending == 0 || p.ctx.Err() != nil {
+		return scanJob{}, false
+	}
+	// LIFO keeps the scan depth-first, bounding the queue by breadth x depth.
+	job := p.queue[len(p.queue)-1]
+	p.queue = p.queue[:len(p.queue)-1]
+	return job, true
+}
+
+func (p *scanPool) done() {
+	p.mu.Lock()
+	p.pending--
+	if p.pending == 0 {
+		p.cond.Broadcast()
+	}
+	p.mu.Unlock()
+}
+
+func (p *scanPool) run(job scanJob) {
+	if d, ok := job.node.(dirScannable); ok && d.isDir() {
+		p.dirsPending.Add(-1)
+	}
+
+	evalErr := job.node.EvalNode(job.cxt)
+	treeNode, err := job.node.ConstructTreeNode()
+	if err == nil && treeNode != nil {
+		job.parent.Children[job.index] = treeNode
+	}
+	if evalErr == nil {
+		evalErr = err
+	}
+	if evalErr != nil {
+		// A node that could be constructed is kept, only its children are lost.
+		p.fail(job, evalErr, treeNode == nil)
+		return
+	}
+
+	if err := p.enqueueChildren(job.node, treeNode); err != nil {
+		p.fail(job, err, false)
+		return
+	}
+}
+
+func (p *scanPool) fail(job scanJob, err error, dropped bool) {
+	se := ScanError{Err: err}
+	if ps, ok := job.node.(pathScannable); ok {
+		se.Path = ps.scanPath()
+	}
+	logrus.Debugf("[scan] %v", se)
+
+	p.mu.Lock()
+	p.errs = append(p.errs, se)
+	if dropped {
+		p.holes[job.parent] = true
+	}
+	p.mu.Unlock()
+}
+
+func (p *scanPool) enqueueChildren(sc ScannableNode, treeNode *ds.TreeNode) error {
+	chs, chCxts, err := sc.GetChildren()
+	if err != nil {
+		return err
+	}
+
+	if d, ok := sc.(dirScannable); (ok && d.isDir()) || (!ok && len(chs) > 0) {
+		p.dirs.Add(1)
+	} else {
+		p.files.Add(1)
+	}
+	if len(chs) == 0 {
+		return nil
+	}
+
+	treeNode.Children = make([]*ds.TreeNode, len(chs))
+	jobs := make([]scanJob, len(chs))
+	for i := range chs {
+		if d, ok := chs[i].(dirScannable); ok && d.isDir() {
+			p.dirsPending.Add(1)
+		}
+		// Reverse order so that the LIFO queue pops the first child first.
+		jobs[len(chs)-1-i] = scanJob{node: chs[i], cxt: chCxts[i], parent: treeNode, index: i}
+	}
+
+	p.mu.Lock()
+	p.queue = append(p.queue, jobs...)
+	p.pending += len(jobs)
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	return nil
+}
+
+// reportProgress periodically writes scan throughput to w until the returned func is called.
+func (p *scanPool) reportProgress(w io.Writer) func() {
+	if w == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	report := func() {
+		files := p.files.Load()
+		rate := float64(files) / time.Since(start).Seconds()
+		fmt.Fprintf(w, "\rscanned %d files, %d dirs (%.0f files/s), %d dirs remaining   ",
+			files, p.dirs.Load(), rate, p.dirsPending.Load())
+	}
+
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report()
+			case <-stop:
+				report()
+				fmt.Fprintln(w)
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-finished
+	}
+}
+
+func compactChildren(children []*ds.TreeNode) []*ds.TreeNode {
+	kept := children[:0]
+	for _, child := range children {
+		if child != nil {
+			kept = append(kept, child)
+		}
+	}
+	return kept
+}
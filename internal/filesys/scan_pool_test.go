@@ -0,0 +1,161 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+// preorderPaths flattens a tree into its absolute paths, parents before children.
+func preorderPaths(node *ds.TreeNode) []string {
+	paths := []string{node.Info.(file.NodeInformable).GetAbsPath()}
+	for _, child := range node.Children {
+		paths = append(paths, preorderPaths(child)...)
+	}
+	return paths
+}
+
+func wideMockDir(name string, depth int) *utils.MockDir {
+	dir := &utils.MockDir{DirName: name}
+	for i := 0; i < 5; i++ {
+		dir.Files = append(dir.Files, fmt.Sprintf("f%d", i))
+		if depth > 0 {
+			dir.Dirs = append(dir.Dirs, wideMockDir(fmt.Sprintf("d%d", i), depth-1))
+		}
+	}
+	return dir
+}
+
+func TestScanParallelDeterministic(t *testing.T) {
+	testExecFunc := func(t *testing.T, root string) {
+		expected, err := ScanDirectoryV2(context.Background(), root, ScanOptions{Workers: 1})
+		require.NoError(t, err)
+		// 1 + 5 files + 5 dirs, three levels deep, with 5 files at the leaves
+		utils.ValidateNodeCnt(t, expected, 1+10+50+250+625)
+
+		for _, workers := range []int{2, 8, 64} {
+			node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{Workers: workers})
+			require.NoError(t, err)
+			require.Equal(t, preorderPaths(expected), preorderPaths(node), "workers=%d", workers)
+		}
+
+		// Children follow the directory listing order.
+		entries, err := os.ReadDir(root)
+		require.NoError(t, err)
+		require.Len(t, expected.Children, len(entries))
+		for i, entry := range entries {
+			require.Equal(t, filepath.Join(root, entry.Name()), expected.Children[i].Info.(file.NodeInformable).GetAbsPath())
+		}
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, wideMockDir("1_1", 3), testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestScanParallelCancelled(t *testing.T) {
+	testExecFunc := func(t *testing.T, root string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		node, err := ScanDirectoryV2(ctx, root, ScanOptions{})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Nil(t, node)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, wideMockDir("1_1", 2), testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestScanParallelCollectsErrors(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"a"},
+		Dirs: []*utils.MockDir{
+			{
+				DirName: "locked",
+				Files:   []string{"secret"},
+			},
+			{
+				DirName: "open",
+				Files:   []string{"b", "c"},
+			},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		// An unreadable ignore file fails its directory even when running as root.
+		broken := filepath.Join(root, "open", "broken")
+		require.NoError(t, os.MkdirAll(filepath.Join(broken, IgnoreFileName), 0755))
+
+		expectedErrs := []string{broken}
+		// root: 1_1, a, locked, locked/secret, open, open/b, open/broken, open/c
+		expectedCnt := 8
+		if os.Geteuid() != 0 {
+			locked := filepath.Join(root, "locked")
+			require.NoError(t, os.Chmod(locked, 0))
+			defer os.Chmod(locked, 0755)
+			expectedErrs = append(expectedErrs, locked)
+			// the unreadable directory is kept, only its contents are lost
+			expectedCnt--
+		}
+
+		node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{Workers: 4})
+		var scanErrs ScanErrors
+		require.True(t, errors.As(err, &scanErrs))
+		require.NotNil(t, node)
+		utils.ValidateNodeCnt(t, node, expectedCnt)
+
+		paths := []string{}
+		for _, se := range scanErrs {
+			paths = append(paths, se.Path)
+		}
+		require.ElementsMatch(t, expectedErrs, paths)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestScanStatsLazily(t *testing.T) {
+	testExecFunc := func(t *testing.T, root string) {
+		p := filepath.Join(root, "f0")
+		require.NoError(t, os.WriteFile(p, []byte("hello"), 0o644))
+		empty := filepath.Join(root, "empty")
+		require.NoError(t, os.Mkdir(empty, 0o755))
+
+		node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{})
+		require.NoError(t, err)
+		var f *file.FileNode
+		for _, child := range node.Children {
+			switch child.Info.(file.NodeInformable).GetAbsPath() {
+			case p:
+				f = child.Info.(*file.FileNode)
+			case empty:
+				// Directories are recorded as such even without children.
+				require.True(t, child.Info.(*file.FileNode).IsDir())
+			}
+		}
+		require.NotNil(t, f)
+		require.False(t, f.IsDir())
+		// Nothing is read until asked for.
+		require.Zero(t, f.Size)
+		size, modTime, ok := f.GetStat()
+		require.True(t, ok)
+		require.Equal(t, int64(5), size)
+		require.False(t, modTime.IsZero())
+
+		// Nodes read back from storage stat the file themselves.
+		stored := &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: p}}
+		size, _, ok = stored.GetStat()
+		require.True(t, ok)
+		require.Equal(t, int64(5), size)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, wideMockDir("lazy", 0), testExecFunc)
+	testExectutor.Execute()
+}
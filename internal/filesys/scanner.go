@@ -1,6 +1,9 @@
 package filesys
 
 import (
+	"fmt"
+	"io"
+
 	"github.com/heroku/self/MetaManager/internal/cmderror"
 	"github.com/heroku/self/MetaManager/internal/ds"
 )
@@ -12,13 +15,67 @@ type Scanner interface {
 
 // CreateScannerFromContextType creates a scanner based on the context type.
 func CreateScannerFromContextType(contextType string) (Scanner, error) {
-	switch contextType {
-	case "local":
-		return NewUnixFileSystemScanner(), nil
-	case "gdrive":
-		return createGDriveScanner()
+	backend, err := LookupBackend(contextType)
+	if err != nil {
+		return nil, &cmderror.InvalidOperation{}
+	}
+	return backend.NewScanner()
+}
+
+// ScanOptions tunes how scanners and trackers walk a tree.
+type ScanOptions struct {
+	// NoIgnore disables .mmignore files and IgnorePatterns.
+	NoIgnore bool
+	// IgnorePatterns are gitignore-style patterns relative to the scanned root,
+	// typically the context's global ignore list.
+	IgnorePatterns []string
+	// Workers bounds the number of entries scanned concurrently. Zero means DefaultScanWorkers.
+	Workers int
+	// Progress receives periodic progress lines while scanning when non-nil.
+	Progress io.Writer
+	// Symlinks decides what local scans do with symbolic links. Zero means SymlinksRecord.
+	Symlinks SymlinkPolicy
+	// Filter narrows down which entries are tracked.
+	Filter ScanFilter
+	// IntoArchives makes local scans track the entries of zip and tar archives
+	// as virtual children of the archive, see file.ArchiveSeparator.
+	IntoArchives bool
+
+	// filter is Filter bound to the scanned root, set once a scan starts
+	filter *compiledFilter
+	// visitDir, when set, is called for every local directory whose entries are
+	// scanned, after its ignore file was read. It may be called concurrently.
+	visitDir func(*FSScannableNode) error
+}
+
+// SymlinkPolicy is what a local scan does with a symbolic link.
+type SymlinkPolicy string
+
+const (
+	// SymlinksSkip leaves symbolic links out of the tree.
+	SymlinksSkip SymlinkPolicy = "skip"
+	// SymlinksRecord stores symbolic links as file.SymlinkNode without following them.
+	SymlinksRecord SymlinkPolicy = "record"
+	// SymlinksFollow scans what symbolic links point to. Links that would revisit
+	// a directory on the current path, and dangling links, are recorded instead.
+	SymlinksFollow SymlinkPolicy = "follow"
+)
+
+// ParseSymlinkPolicy parses the value of a --symlinks flag.
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(s); p {
+	case SymlinksSkip, SymlinksRecord, SymlinksFollow:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid symlink policy %q, expected one of skip, record, follow", s)
+}
+
+// rootIgnoreMatcher returns the matcher for IgnorePatterns rooted at root, or nil when there is none.
+func (o ScanOptions) rootIgnoreMatcher(root string) *IgnoreMatcher {
+	if o.NoIgnore || len(o.IgnorePatterns) == 0 {
+		return nil
 	}
-	return nil, &cmderror.InvalidOperation{}
+	return NewIgnoreMatcher(root, o.IgnorePatterns)
 }
 
 // ScannableCxt is the context passed to scannable nodes during evaluation.
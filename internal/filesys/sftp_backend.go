@@ -0,0 +1,110 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/services"
+)
+
+// TypeSFTP is the type of contexts tracking directories on SSH servers.
+const TypeSFTP = "sftp"
+
+func init() {
+	RegisterBackend(Backend{
+		Type:     TypeSFTP,
+		RootPath: file.SFTPPathPrefix,
+		NewScanner: func() (Scanner, error) {
+			// The settings to connect with are part of a context, see NewTracker.
+			return nil, fmt.Errorf("an SFTP scanner needs the settings of a context")
+		},
+		NewTracker: func(cxtRepo contextrepo.ContextRepository, opts ScanOptions) (Tracker, error) {
+			ctxName, err := cxtRepo.GetContext()
+			if err != nil {
+				return nil, err
+			}
+			svc, err := services.GetSFTPService(ctxName)
+			if err != nil {
+				return nil, err
+			}
+			return NewSFTPTracker(svc, opts), nil
+		},
+		NewResolver: func(cxtRepo contextrepo.ContextRepository) Resolver {
+			return SFTPResolver{}
+		},
+	})
+}
+
+// SFTPTracker tracks SFTP paths using a SFTPServiceInterface.
+type SFTPTracker struct {
+	svc  services.SFTPServiceInterface
+	opts ScanOptions
+}
+
+// NewSFTPTracker creates a SFTPTracker whose scans honor opts.
+func NewSFTPTracker(svc services.SFTPServiceInterface, opts ScanOptions) *SFTPTracker {
+	return &SFTPTracker{svc: svc, opts: opts}
+}
+
+// Track tracks a path like "sftp://host/data/file", or "sftp://host/data*" for a
+// directory and everything below it.
+func (s *SFTPTracker) Track(path string) (*ds.TreeNode, error) {
+	return s.TrackContext(context.Background(), path)
+}
+
+// TrackContext is like Track but stops early once ctx is cancelled.
+func (s *SFTPTracker) TrackContext(ctx context.Context, path string) (*ds.TreeNode, error) {
+	if len(path) == 0 {
+		return nil, &cmderror.InvalidPath{}
+	}
+	virtualPath, recursive := NormalizeSFTPTrackPath(path)
+	return NewSFTPScanner(s.svc, s.opts).TrackSFTP(ctx, virtualPath, recursive)
+}
+
+// Exists reports whether the SFTP path is still on its host.
+func (s *SFTPTracker) Exists(ctx context.Context, p string) (bool, error) {
+	host, remotePath, ok := file.SplitSFTPPath(p)
+	if !ok {
+		return false, &cmderror.InvalidPath{}
+	}
+	_, err := s.svc.Stat(ctx, host, remotePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Close closes the connections of the tracker's service, if it has any.
+func (s *SFTPTracker) Close() error {
+	if c, ok := s.svc.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SFTPResolver cleans SFTP paths. There is no remote working directory, so paths must
+// be complete, like "sftp://host/data"; a trailing "*" is kept. "." is the root of
+// the context, above every host.
+type SFTPResolver struct{}
+
+func (SFTPResolver) Resolve(path string) (string, error) {
+	if p := strings.TrimSpace(path); p == "" || p == "." {
+		return file.SFTPPathPrefix, nil
+	}
+	virtualPath, recursive := NormalizeSFTPTrackPath(path)
+	if _, _, ok := file.SplitSFTPPath(virtualPath); !ok {
+		return "", sftpPathError(strings.TrimSpace(path))
+	}
+	if recursive {
+		virtualPath += "*"
+	}
+	return virtualPath, nil
+}
@@ -0,0 +1,118 @@
+package filesys
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/services"
+	"github.com/sirupsen/logrus"
+)
+
+// SFTPScanner scans remote directories over SSH.
+type SFTPScanner struct {
+	svc  services.SFTPServiceInterface
+	opts ScanOptions
+}
+
+// NewSFTPScanner creates a SFTPScanner using opts. Only name patterns of
+// opts.IgnorePatterns apply, as .mmignore files are not read from remote hosts.
+func NewSFTPScanner(svc services.SFTPServiceInterface, opts ScanOptions) *SFTPScanner {
+	return &SFTPScanner{svc: svc, opts: opts}
+}
+
+// Scan scans a path like "sftp://host/data" or, recursively, "sftp://host/data*".
+func (s *SFTPScanner) Scan(path string) (*ds.TreeNode, error) {
+	virtualPath, recursive := NormalizeSFTPTrackPath(path)
+	return s.TrackSFTP(context.Background(), virtualPath, recursive)
+}
+
+// Make sure that SFTPScanner implements Scanner
+var _ Scanner = (*SFTPScanner)(nil)
+
+// TrackSFTP returns the tree of the SFTP path virtualPath. The content of directories
+// is only listed when recursive is set, down to the depth limit of the scan filter.
+func (s *SFTPScanner) TrackSFTP(ctx context.Context, virtualPath string, recursive bool) (*ds.TreeNode, error) {
+	logrus.Debugf("[track-sftp] TrackSFTP start path=%q recursive=%v", virtualPath, recursive)
+	if s.svc == nil {
+		return nil, &cmderror.InvalidOperation{}
+	}
+	host, remotePath, ok := file.SplitSFTPPath(virtualPath)
+	if !ok {
+		return nil, sftpPathError(virtualPath)
+	}
+	entry, err := s.svc.Stat(ctx, host, remotePath)
+	if err != nil {
+		logrus.Debugf("[track-sftp] Stat error: %v", err)
+		return nil, err
+	}
+
+	baseVirtual := file.JoinSFTPPath(host, remotePath)
+	if !entry.IsFolder || !recursive {
+		return newRemoteNode(baseVirtual, *entry), nil
+	}
+	ignore := s.opts.rootIgnoreMatcher(baseVirtual)
+	s.opts.filter = s.opts.Filter.compile(baseVirtual)
+	return s.trackSFTPDir(ctx, host, *entry, baseVirtual, 0, ignore)
+}
+
+// trackSFTPDir recursively tracks a remote directory.
+func (s *SFTPScanner) trackSFTPDir(ctx context.Context, host string, dir services.RootEntry, virtualPath string, depth int, ignore *IgnoreMatcher) (*ds.TreeNode, error) {
+	logrus.Debugf("[track-sftp] trackSFTPDir depth=%d path=%q", depth, virtualPath)
+	if depth > maxTrackDepth {
+		logrus.Debugf("[track-sftp] max depth %d exceeded, stopping", maxTrackDepth)
+		return nil, &cmderror.InvalidOperation{}
+	}
+
+	entries, err := s.svc.ListAtPath(ctx, host, dir.Id)
+	if err != nil {
+		logrus.Debugf("[track-sftp] ListAtPath %q error: %v", dir.Id, err)
+		return nil, err
+	}
+	logrus.Debugf("[track-sftp] depth=%d path=%q listed %d entries", depth, virtualPath, len(entries))
+
+	rootNode := newRemoteNode(virtualPath, dir)
+	for _, e := range entries {
+		childVirtual := file.JoinSFTPPath(host, e.Id)
+		if _, ignored := ignore.Match(childVirtual, e.IsFolder); ignored {
+			logrus.Debugf("[track-sftp] ignoring %q", childVirtual)
+			continue
+		}
+		if !s.opts.filter.keep(childVirtual, e.IsFolder, remoteEntryInfo{e}) {
+			logrus.Debugf("[track-sftp] filtered out %q", childVirtual)
+			continue
+		}
+		if e.IsFolder && s.opts.filter.descend(depth+1) {
+			sub, err := s.trackSFTPDir(ctx, host, e, childVirtual, depth+1, ignore)
+			if err != nil {
+				return nil, err
+			}
+			rootNode.Children = append(rootNode.Children, sub)
+		} else {
+			rootNode.Children = append(rootNode.Children, newRemoteNode(childVirtual, e))
+		}
+	}
+	return rootNode, nil
+}
+
+func sftpPathError(p string) error {
+	return fmt.Errorf("path is not an SFTP path: %s. It should be like 'sftp://host/dir' or 'sftp://user@host:port/dir'", p)
+}
+
+// NormalizeSFTPTrackPath returns the SFTP path to track, cleaned, and whether it is
+// tracked recursively (a trailing "*").
+func NormalizeSFTPTrackPath(pathExp string) (path string, recursive bool) {
+	pathExp = strings.TrimSpace(pathExp)
+	recursive = strings.HasSuffix(pathExp, "*")
+	if recursive {
+		pathExp = strings.TrimSuffix(pathExp, "*")
+	}
+	host, remotePath, ok := file.SplitSFTPPath(pathExp)
+	if !ok {
+		return pathExp, recursive
+	}
+	return file.JoinSFTPPath(host, remotePath), recursive
+}
@@ -0,0 +1,107 @@
+package filesys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/services"
+	"github.com/heroku/self/MetaManager/internal/services/sftptest"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSFTPTracker(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	srv := sftptest.NewServer(t)
+	mockDir := &utils.MockDir{
+		DirName: "lab",
+		Files:   []string{"notes.txt", "skip.tmp"},
+		Dirs: []*utils.MockDir{
+			{DirName: "sets", Files: []string{"a.csv"}, Dirs: []*utils.MockDir{
+				{DirName: "raw", Files: []string{"b.csv"}},
+			}},
+		},
+	}
+	testExecFunc := func(t *testing.T, root string) {
+		svc := services.NewSFTPService(&services.SFTPSettings{IdentityFiles: []string{srv.IdentityFile}, KnownHostsFile: srv.KnownHostsFile})
+		defer svc.Close()
+		base := file.JoinSFTPPath(srv.Addr, root)
+		ctx := context.Background()
+
+		tracker := NewSFTPTracker(svc, ScanOptions{IgnorePatterns: []string{"*.tmp"}})
+		tree, err := tracker.Track(base + "/sets")
+		require.NoError(t, err)
+		require.Equal(t, base+"/sets", tree.Info.(*file.FileNode).AbsPath)
+		require.Empty(t, tree.Children)
+
+		// root + notes + sets + a + raw + b, skip.tmp is ignored
+		tree, err = tracker.Track(base + "/*")
+		require.NoError(t, err)
+		require.Equal(t, base, tree.Info.(*file.FileNode).AbsPath)
+		utils.ValidateNodeCnt(t, tree, 6)
+
+		// Depth limits stop at the listed directory.
+		tracker = NewSFTPTracker(svc, ScanOptions{Filter: ScanFilter{MaxDepth: 1}})
+		tree, err = tracker.Track(base + "*")
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, tree, 4)
+
+		_, err = tracker.Track(base + "/missing")
+		require.Error(t, err)
+		_, err = tracker.Track("/data")
+		require.ErrorContains(t, err, "not an SFTP path")
+
+		exists, err := tracker.Exists(ctx, base+"/sets/raw/b.csv")
+		require.NoError(t, err)
+		require.True(t, exists)
+		require.NoError(t, os.Remove(filepath.Join(root, "sets", "raw", "b.csv")))
+		exists, err = tracker.Exists(ctx, base+"/sets/raw/b.csv")
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, mockDir, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestSFTPResolver(t *testing.T) {
+	tests := map[string]string{
+		".":                          "sftp://",
+		"sftp://lab/data/":           "sftp://lab/data",
+		"sftp://me@lab:2222/a/../b*": "sftp://me@lab:2222/b*",
+		"sftp://lab*":                "sftp://lab*",
+	}
+	for p, expected := range tests {
+		resolved, err := SFTPResolver{}.Resolve(p)
+		require.NoError(t, err, p)
+		require.Equal(t, expected, resolved, p)
+	}
+	_, err := SFTPResolver{}.Resolve("data/sets")
+	require.ErrorContains(t, err, "sftp://host/dir")
+}
+
+// closingSFTP counts the calls to Close of an SFTP service.
+type closingSFTP struct {
+	services.SFTPServiceInterface
+	closed int
+}
+
+func (c *closingSFTP) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestCloseSFTPTracker(t *testing.T) {
+	svc := &closingSFTP{}
+	require.NoError(t, CloseTracker(NewSFTPTracker(svc, ScanOptions{})))
+	require.Equal(t, 1, svc.closed)
+
+	// Trackers holding nothing are left alone
+	require.NoError(t, CloseTracker(NewLocalTracker()))
+
+	_, err := CreateScannerFromContextType(TypeSFTP)
+	require.ErrorContains(t, err, "settings of a context")
+}
@@ -0,0 +1,97 @@
+package filesys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func findChild(t *testing.T, node *ds.TreeNode, name string) *ds.TreeNode {
+	for _, child := range node.Children {
+		if filepath.Base(child.Info.(file.NodeInformable).GetAbsPath()) == name {
+			return child
+		}
+	}
+	require.Failf(t, "child not found", "%q", name)
+	return nil
+}
+
+func TestScanSymlinkPolicies(t *testing.T) {
+	dirStructure := &utils.MockDir{
+		DirName: "1_1",
+		Files:   []string{"a"},
+		Dirs: []*utils.MockDir{
+			{
+				DirName: "2_1",
+				Files:   []string{"b", "c"},
+			},
+		},
+	}
+
+	testExecFunc := func(t *testing.T, root string) {
+		// loop points back to the scan root, shared to a sibling directory
+		require.NoError(t, os.Symlink(root, filepath.Join(root, "2_1", "loop")))
+		require.NoError(t, os.Symlink("2_1", filepath.Join(root, "shared")))
+		require.NoError(t, os.Symlink("missing", filepath.Join(root, "dangling")))
+
+		t.Run("skip", func(t *testing.T) {
+			node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{Symlinks: SymlinksSkip})
+			require.NoError(t, err)
+			// 1_1, a, 2_1, b, c
+			utils.ValidateNodeCnt(t, node, 5)
+		})
+
+		t.Run("record", func(t *testing.T) {
+			node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{Symlinks: SymlinksRecord})
+			require.NoError(t, err)
+			// 1_1, a, 2_1, b, c, loop, shared, dangling
+			utils.ValidateNodeCnt(t, node, 8)
+
+			shared, ok := findChild(t, node, "shared").Info.(*file.SymlinkNode)
+			require.True(t, ok)
+			require.Equal(t, "2_1", shared.Target)
+			dangling, ok := findChild(t, node, "dangling").Info.(*file.SymlinkNode)
+			require.True(t, ok)
+			require.Equal(t, "missing", dangling.Target)
+		})
+
+		t.Run("follow", func(t *testing.T) {
+			for _, workers := range []int{1, 8} {
+				node, err := ScanDirectoryV2(context.Background(), root, ScanOptions{Symlinks: SymlinksFollow, Workers: workers})
+				require.NoError(t, err)
+				// 1_1, a, 2_1, b, c, 2_1/loop, dangling,
+				// shared (followed), shared/b, shared/c, shared/loop
+				utils.ValidateNodeCnt(t, node, 11)
+
+				loop, ok := findChild(t, findChild(t, node, "2_1"), "loop").Info.(*file.SymlinkNode)
+				require.True(t, ok, "a link back to the root is recorded, not followed")
+				require.Equal(t, root, loop.Target)
+
+				shared := findChild(t, node, "shared")
+				_, ok = shared.Info.(*file.FileNode)
+				require.True(t, ok)
+				require.Len(t, shared.Children, 3)
+				_, ok = findChild(t, node, "dangling").Info.(*file.SymlinkNode)
+				require.True(t, ok)
+			}
+		})
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, dirStructure, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	p, err := ParseSymlinkPolicy("follow")
+	require.NoError(t, err)
+	require.Equal(t, SymlinksFollow, p)
+
+	_, err = ParseSymlinkPolicy("sometimes")
+	require.Error(t, err)
+}
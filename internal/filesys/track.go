@@ -2,15 +2,22 @@ package filesys
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/config"
 	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
 	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
 	"github.com/heroku/self/MetaManager/internal/services"
+	"github.com/sirupsen/logrus"
 )
 
 type Tracker interface {
@@ -18,7 +25,40 @@ type Tracker interface {
 	Track(path string) (*ds.TreeNode, error)
 }
 
+// ContextTracker is implemented by trackers whose scans can be cancelled.
+type ContextTracker interface {
+	TrackContext(ctx context.Context, path string) (*ds.TreeNode, error)
+}
+
+// ExistenceChecker is implemented by trackers that can tell whether a tracked path still exists.
+type ExistenceChecker interface {
+	Exists(ctx context.Context, path string) (bool, error)
+}
+
+// TrackWithContext tracks path with t, using TrackContext when t supports it.
+func TrackWithContext(ctx context.Context, t Tracker, path string) (*ds.TreeNode, error) {
+	if ct, ok := t.(ContextTracker); ok {
+		return ct.TrackContext(ctx, path)
+	}
+	return t.Track(path)
+}
+
+// CloseTracker releases what t holds, like the connections of SFTP trackers, when t
+// is an io.Closer.
+func CloseTracker(t Tracker) error {
+	if c, ok := t.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 func GetTrackerFromContext(cxtRepo contextrepo.ContextRepository) (Tracker, error) {
+	return GetTrackerFromContextWithOptions(cxtRepo, ScanOptions{})
+}
+
+// GetTrackerFromContextWithOptions returns the tracker for the current context. The context's
+// global ignore patterns are appended to opts.IgnorePatterns unless opts.NoIgnore is set.
+func GetTrackerFromContextWithOptions(cxtRepo contextrepo.ContextRepository, opts ScanOptions) (Tracker, error) {
 	ctxName, err := cxtRepo.GetContext()
 	if err != nil {
 		return nil, err
@@ -27,24 +67,27 @@ func GetTrackerFromContext(cxtRepo contextrepo.ContextRepository) (Tracker, erro
 	if err != nil {
 		return nil, err
 	}
-	switch contextType {
-	case contextrepo.TypeGDrive:
-		svc, err := services.GetGDriveService(context.Background())
+	if !opts.NoIgnore && ctxName != "" {
+		cfg, err := config.Load(ctxName)
 		if err != nil {
 			return nil, err
 		}
-		return NewGDriveTracker(svc), nil
-	case contextrepo.TypeLocal:
-		return NewLocalTracker(), nil
-	default:
+		opts.IgnorePatterns = append(opts.IgnorePatterns, cfg.IgnorePatterns...)
+	}
+	backend, err := LookupBackend(contextType)
+	if err != nil {
 		return nil, &cmderror.InvalidOperation{}
 	}
+	return backend.NewTracker(cxtRepo, opts)
 }
 
 // GDriveTracker tracks Google Drive paths using a GDriveServiceInterface.
 // This allows for dependency injection and mocking in tests.
 type GDriveTracker struct {
-	svc services.GDriveServiceInterface
+	svc  services.GDriveServiceInterface
+	opts ScanOptions
+	// listed caches folder listings by virtual path for Exists
+	listed map[string][]services.RootEntry
 }
 
 // NewGDriveTracker creates a new GDriveTracker with the given GDriveServiceInterface.
@@ -52,8 +95,18 @@ func NewGDriveTracker(svc services.GDriveServiceInterface) *GDriveTracker {
 	return &GDriveTracker{svc: svc}
 }
 
+// NewGDriveTrackerWithOptions creates a GDriveTracker whose scans honor opts.
+func NewGDriveTrackerWithOptions(svc services.GDriveServiceInterface, opts ScanOptions) *GDriveTracker {
+	return &GDriveTracker{svc: svc, opts: opts}
+}
+
 // The path should be an absolute path like "gdrive:/Folder/SubFolder" or "gdrive:/".
 func (g *GDriveTracker) Track(path string) (*ds.TreeNode, error) {
+	return g.TrackContext(context.Background(), path)
+}
+
+// TrackContext is like Track but stops early once ctx is cancelled.
+func (g *GDriveTracker) TrackContext(ctx context.Context, path string) (*ds.TreeNode, error) {
 	if len(path) == 0 {
 		return nil, &cmderror.InvalidPath{}
 	}
@@ -62,8 +115,7 @@ func (g *GDriveTracker) Track(path string) (*ds.TreeNode, error) {
 		return nil, fmt.Errorf("path is not a Google Drive path: %s. It should be like 'gdrive:/Folder/SubFolder' or 'gdrive:/'", path)
 	}
 
-	scanner := NewGDriveScanner(g.svc)
-	ctx := context.Background()
+	scanner := NewGDriveScannerWithOptions(g.svc, g.opts)
 
 	if path[len(path)-1] != '*' {
 		return file.CreateTreeNodeFromPath(path)
@@ -74,9 +126,42 @@ func (g *GDriveTracker) Track(path string) (*ds.TreeNode, error) {
 	return scanner.TrackGDrive(ctx, drivePath, true)
 }
 
+// Exists reports whether the Drive path still exists by listing its parent folder.
+// Listings are cached, so a tracker should not outlive a single refresh.
+func (g *GDriveTracker) Exists(ctx context.Context, p string) (bool, error) {
+	if !file.IsGDrivePath(p) {
+		return false, &cmderror.InvalidPath{}
+	}
+	drivePath := strings.TrimPrefix(p, file.GDrivePathRoot)
+	if drivePath == "/" {
+		return true, nil
+	}
+	parent, name := path.Split(drivePath)
+	if g.listed == nil {
+		g.listed = map[string][]services.RootEntry{}
+	}
+	entries, ok := g.listed[parent]
+	if !ok {
+		var err error
+		entries, err = g.svc.ListAtPath(ctx, parent)
+		if err != nil {
+			return false, err
+		}
+		g.listed[parent] = entries
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // LocalTracker tracks local filesystem paths.
 type LocalTracker struct {
 	scanner *UnixFileSystemScanner
+	// archives caches the entries of archives looked into by Exists
+	archives map[string]map[string]bool
 }
 
 // NewLocalTracker creates a new LocalTracker.
@@ -86,7 +171,19 @@ func NewLocalTracker() *LocalTracker {
 	}
 }
 
+// NewLocalTrackerWithOptions creates a LocalTracker whose scans honor opts.
+func NewLocalTrackerWithOptions(opts ScanOptions) *LocalTracker {
+	return &LocalTracker{
+		scanner: NewUnixFileSystemScannerWithOptions(opts),
+	}
+}
+
 func (l *LocalTracker) Track(path string) (*ds.TreeNode, error) {
+	return l.TrackContext(context.Background(), path)
+}
+
+// TrackContext is like Track but stops early once ctx is cancelled.
+func (l *LocalTracker) TrackContext(ctx context.Context, path string) (*ds.TreeNode, error) {
 	if len(path) == 0 {
 		return nil, &cmderror.InvalidPath{}
 	}
@@ -98,9 +195,60 @@ func (l *LocalTracker) Track(path string) (*ds.TreeNode, error) {
 		if err != nil {
 			return nil, err
 		}
-		return l.scanner.Scan(absPath)
+		return l.scanner.ScanContext(ctx, absPath)
 	}
 
 	// Non-recursive tracking: just create a node for the path
 	return file.CreateTreeNodeFromPath(path)
 }
+
+// Exists reports whether the local path still exists. Paths that cannot be
+// checked, e.g. for lack of permissions, are assumed to exist.
+func (l *LocalTracker) Exists(ctx context.Context, p string) (bool, error) {
+	if archive, inner, ok := file.SplitArchivePath(p); ok {
+		return l.existsInArchive(archive, inner)
+	}
+	_, err := os.Lstat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		logrus.Debugf("[refresh] cannot check %q, assuming it exists: %v", p, err)
+	}
+	return true, nil
+}
+
+// existsInArchive reports whether the archive still holds the entry inner, or a
+// directory implied by its entries.
+func (l *LocalTracker) existsInArchive(archive, inner string) (bool, error) {
+	if l.archives == nil {
+		l.archives = map[string]map[string]bool{}
+	}
+	listed, ok := l.archives[archive]
+	if !ok {
+		entries, err := readArchive(archive)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			logrus.Debugf("[refresh] cannot read archive %q, assuming its entries exist: %v", archive, err)
+			return true, nil
+		}
+		listed = map[string]bool{}
+		for _, entry := range entries {
+			for name := entry.name; name != "/"; name = path.Dir(name) {
+				listed[name] = true
+			}
+		}
+		l.archives[archive] = listed
+	}
+	return listed[path.Clean(inner)], nil
+}
+
+// Make sure that both trackers can be cancelled
+var _ ContextTracker = (*GDriveTracker)(nil)
+var _ ContextTracker = (*LocalTracker)(nil)
+
+// Make sure that both trackers can check for vanished paths
+var _ ExistenceChecker = (*GDriveTracker)(nil)
+var _ ExistenceChecker = (*LocalTracker)(nil)
@@ -0,0 +1,54 @@
+package filesys
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+)
+
+// WatchOp is the kind of change reported by a Watcher.
+type WatchOp int
+
+const (
+	// WatchCreate reports a new entry, Node holds it along with its scanned children.
+	WatchCreate WatchOp = iota + 1
+	// WatchRemove reports an entry that is gone, together with everything below it.
+	WatchRemove
+	// WatchRename reports an entry moved from OldPath to Path, Node holds it as
+	// scanned at its new place.
+	WatchRename
+	// WatchOverflow reports that events were dropped and the watched trees have
+	// to be scanned again to catch up.
+	WatchOverflow
+)
+
+func (op WatchOp) String() string {
+	switch op {
+	case WatchCreate:
+		return "create"
+	case WatchRemove:
+		return "remove"
+	case WatchRename:
+		return "rename"
+	case WatchOverflow:
+		return "overflow"
+	}
+	return "unknown"
+}
+
+// WatchEvent is a change to a watched tree.
+type WatchEvent struct {
+	Op      WatchOp
+	Path    string
+	OldPath string
+	Node    *ds.TreeNode
+}
+
+// pathWithin reports whether p is dir or lies below it.
+func pathWithin(dir, p string) bool {
+	if p == dir {
+		return true
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
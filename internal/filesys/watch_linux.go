@@ -0,0 +1,372 @@
+//go:build linux
+
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR | unix.IN_DONT_FOLLOW | unix.IN_EXCL_UNLINK
+
+// moveTimeout is how long the first half of a rename waits for its second half.
+// The kernel queues both together, so an unpaired half means the entry was moved
+// out of, or into, the watched trees.
+const moveTimeout = 100 * time.Millisecond
+
+// Watcher reports changes below local directories using inotify. Every directory
+// scanned through AddRoot, or found later on, gets a watch of its own and the
+// same ignore rules, filters and symlink policy as the scan applies to it.
+type Watcher struct {
+	file *os.File
+	fd   int
+
+	mu    sync.Mutex
+	dirs  map[int]*FSScannableNode
+	wds   map[string]int
+	roots map[string]bool
+	skip  []string
+
+	// moves holds the first halves of renames by cookie, only used by the reader
+	moves map[uint32]pendingMove
+
+	events    chan WatchEvent
+	errors    chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type pendingMove struct {
+	path string
+	at   time.Time
+}
+
+// NewWatcher creates a Watcher, it watches nothing until AddRoot is called.
+func NewWatcher() (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	w := &Watcher{
+		// A non blocking descriptor goes through the runtime poller, so Close
+		// interrupts a pending Read and read deadlines work.
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		fd:     fd,
+		dirs:   map[int]*FSScannableNode{},
+		wds:    map[string]int{},
+		roots:  map[string]bool{},
+		moves:  map[uint32]pendingMove{},
+		events: make(chan WatchEvent, 64),
+		errors: make(chan error, 16),
+		done:   make(chan struct{}),
+	}
+	go w.read()
+	return w, nil
+}
+
+// AddRoot scans dirPath with opts and watches every directory the scan descends
+// into. The scan result is returned like ScanDirectoryV2 does.
+func (w *Watcher) AddRoot(ctx context.Context, dirPath string, opts ScanOptions) (*ds.TreeNode, error) {
+	dirPathAbs, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	opts.visitDir = w.watchDir
+	node, err := ScanDirectoryV2(ctx, dirPathAbs, opts)
+	if node != nil {
+		w.mu.Lock()
+		w.roots[dirPathAbs] = true
+		w.mu.Unlock()
+	}
+	return node, err
+}
+
+// Skip drops all events at or below path, e.g. for the directory the tree is saved in.
+func (w *Watcher) Skip(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.skip = append(w.skip, path)
+}
+
+// Events returns the reported changes, it is closed once the Watcher is closed.
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Errors returns problems met while watching. They do not stop the Watcher.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+	})
+	return err
+}
+
+// watchDir is the visitDir hook of the scans run by the Watcher.
+func (w *Watcher) watchDir(f *FSScannableNode) error {
+	wd, err := unix.InotifyAddWatch(w.fd, f.strAbsPath, watchMask)
+	if err != nil {
+		if !errors.Is(err, unix.ENOENT) {
+			w.report(fmt.Errorf("watching %s: %w", f.strAbsPath, err))
+		}
+		return nil
+	}
+
+	// Only what is needed to evaluate new entries is kept, not the scanned children.
+	dir := &FSScannableNode{
+		strAbsPath: f.strAbsPath,
+		info:       f.info,
+		opts:       f.opts,
+		ignore:     f.ignore,
+		ancestors:  f.ancestors,
+		depth:      f.depth,
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dirs[wd] = dir
+	w.wds[f.strAbsPath] = wd
+	return nil
+}
+
+func (w *Watcher) read() {
+	defer close(w.events)
+
+	buf := make([]byte, 64*1024)
+	for {
+		var deadline time.Time
+		for _, m := range w.moves {
+			if at := m.at.Add(moveTimeout); deadline.IsZero() || at.Before(deadline) {
+				deadline = at
+			}
+		}
+		if err := w.file.SetReadDeadline(deadline); err != nil {
+			w.report(err)
+			return
+		}
+
+		n, err := w.file.Read(buf)
+		switch {
+		case errors.Is(err, os.ErrDeadlineExceeded):
+		case errors.Is(err, os.ErrClosed):
+			return
+		case err != nil:
+			w.report(err)
+			return
+		default:
+			w.handleBuffer(buf[:n])
+		}
+		w.expireMoves()
+	}
+}
+
+func (w *Watcher) handleBuffer(buf []byte) {
+	for off := 0; off+unix.SizeofInotifyEvent <= len(buf); {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		off += unix.SizeofInotifyEvent
+
+		var name string
+		if raw.Len > 0 {
+			end := off + int(raw.Len)
+			if end > len(buf) {
+				return
+			}
+			name = strings.TrimRight(string(buf[off:end]), "\x00")
+			off = end
+		}
+		w.handleEvent(int(raw.Wd), raw.Mask, raw.Cookie, name)
+	}
+}
+
+func (w *Watcher) handleEvent(wd int, mask, cookie uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		logrus.Debugf("[watch] event queue overflowed")
+		w.emit(WatchEvent{Op: WatchOverflow})
+		return
+	}
+
+	w.mu.Lock()
+	dir := w.dirs[wd]
+	isRoot := dir != nil && w.roots[dir.strAbsPath]
+	w.mu.Unlock()
+	if dir == nil {
+		return
+	}
+
+	if mask&unix.IN_IGNORED != 0 {
+		w.mu.Lock()
+		delete(w.dirs, wd)
+		if w.wds[dir.strAbsPath] == wd {
+			delete(w.wds, dir.strAbsPath)
+		}
+		w.mu.Unlock()
+		return
+	}
+
+	// Other directories are reported by the events of their parents.
+	if mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0 {
+		if isRoot {
+			w.removed(dir.strAbsPath)
+		}
+		return
+	}
+
+	if name == "" {
+		return
+	}
+	entryPath := filepath.Join(dir.strAbsPath, name)
+	if w.skipped(entryPath) {
+		return
+	}
+
+	switch {
+	case mask&unix.IN_MOVED_FROM != 0:
+		w.moves[cookie] = pendingMove{path: entryPath, at: time.Now()}
+	case mask&unix.IN_MOVED_TO != 0:
+		if m, ok := w.moves[cookie]; ok {
+			delete(w.moves, cookie)
+			w.renamed(m.path, dir, entryPath)
+		} else {
+			w.created(dir, entryPath)
+		}
+	case mask&unix.IN_CREATE != 0:
+		w.created(dir, entryPath)
+	case mask&unix.IN_DELETE != 0:
+		w.removed(entryPath)
+	}
+}
+
+// expireMoves reports the entries moved out of the watched trees as removed.
+func (w *Watcher) expireMoves() {
+	for cookie, m := range w.moves {
+		if time.Since(m.at) >= moveTimeout {
+			delete(w.moves, cookie)
+			w.removed(m.path)
+		}
+	}
+}
+
+func (w *Watcher) created(dir *FSScannableNode, entryPath string) {
+	node := w.scanEntry(dir, entryPath)
+	if node == nil {
+		return
+	}
+	logrus.Debugf("[watch] created %s", entryPath)
+	w.emit(WatchEvent{Op: WatchCreate, Path: entryPath, Node: node})
+}
+
+func (w *Watcher) removed(entryPath string) {
+	w.unwatch(w.forget(entryPath))
+	logrus.Debugf("[watch] removed %s", entryPath)
+	w.emit(WatchEvent{Op: WatchRemove, Path: entryPath})
+}
+
+func (w *Watcher) renamed(oldPath string, dir *FSScannableNode, entryPath string) {
+	stale := w.forget(oldPath)
+	node := w.scanEntry(dir, entryPath)
+	// A moved directory keeps its watch descriptors, the scan above registered
+	// them again under the new path. Those not seen again are below what the new
+	// place ignores.
+	w.unwatch(stale)
+	if node == nil {
+		logrus.Debugf("[watch] moved %s out of view", oldPath)
+		w.emit(WatchEvent{Op: WatchRemove, Path: oldPath})
+		return
+	}
+	logrus.Debugf("[watch] renamed %s to %s", oldPath, entryPath)
+	w.emit(WatchEvent{Op: WatchRename, Path: entryPath, OldPath: oldPath, Node: node})
+}
+
+// scanEntry scans a new entry of dir the way a scan of dir would, it returns nil
+// for entries that are gone again or not tracked.
+func (w *Watcher) scanEntry(dir *FSScannableNode, entryPath string) *ds.TreeNode {
+	info, err := os.Lstat(entryPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			w.report(err)
+		}
+		return nil
+	}
+	child := dir.newChild(fs.FileInfoToDirEntry(info))
+	if child == nil {
+		return nil
+	}
+	node, err := scanParallel(context.Background(), child, dir.childCxt(), ScanOptions{Workers: dir.opts.Workers})
+	if err != nil {
+		w.report(err)
+	}
+	return node
+}
+
+// forget drops the watches at or below p from the bookkeeping and returns them.
+func (w *Watcher) forget(p string) []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var wds []int
+	for dirPath, wd := range w.wds {
+		if pathWithin(p, dirPath) {
+			delete(w.wds, dirPath)
+			delete(w.dirs, wd)
+			wds = append(wds, wd)
+		}
+	}
+	delete(w.roots, p)
+	return wds
+}
+
+// unwatch removes the watches among wds that were not registered again.
+func (w *Watcher) unwatch(wds []int) {
+	for _, wd := range wds {
+		w.mu.Lock()
+		_, inUse := w.dirs[wd]
+		w.mu.Unlock()
+		if !inUse {
+			// Fails for deleted directories, the kernel dropped their watch already.
+			_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+		}
+	}
+}
+
+func (w *Watcher) skipped(p string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, s := range w.skip {
+		if pathWithin(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Watcher) emit(ev WatchEvent) {
+	select {
+	case w.events <- ev:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) report(err error) {
+	select {
+	case w.errors <- err:
+	default:
+		logrus.Debugf("[watch] dropped error: %v", err)
+	}
+}
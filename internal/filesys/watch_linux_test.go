@@ -0,0 +1,91 @@
+//go:build linux
+
+package filesys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func nextWatchEvent(t *testing.T, w *Watcher) WatchEvent {
+	t.Helper()
+	select {
+	case ev := <-w.Events():
+		return ev
+	case err := <-w.Errors():
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no watch event")
+	}
+	return WatchEvent{}
+}
+
+func TestWatcher(t *testing.T) {
+	mockDir := &utils.MockDir{
+		DirName: "watched",
+		Files:   []string{".mmignore", "a.txt"},
+		Dirs:    []*utils.MockDir{{DirName: "sub", Files: []string{"b.txt"}}},
+	}
+	testExecFunc := func(t *testing.T, root string) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, ".mmignore"), []byte("*.tmp\n"), 0644))
+
+		w, err := NewWatcher()
+		require.NoError(t, err)
+		defer w.Close()
+
+		node, err := w.AddRoot(context.Background(), root, ScanOptions{})
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, node, 5)
+
+		// Ignored entries are not reported.
+		require.NoError(t, os.WriteFile(filepath.Join(root, "skip.tmp"), nil, 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "c.txt"), nil, 0644))
+		ev := nextWatchEvent(t, w)
+		require.Equal(t, WatchCreate, ev.Op)
+		require.Equal(t, filepath.Join(root, "sub", "c.txt"), ev.Path)
+
+		// New directories are reported with their content and watched.
+		newDir := filepath.Join(root, "new")
+		require.NoError(t, os.Mkdir(newDir, 0755))
+		ev = nextWatchEvent(t, w)
+		require.Equal(t, WatchCreate, ev.Op)
+		require.Equal(t, newDir, ev.Path)
+		require.NoError(t, os.WriteFile(filepath.Join(newDir, "d.txt"), nil, 0644))
+		ev = nextWatchEvent(t, w)
+		require.Equal(t, WatchCreate, ev.Op)
+		require.Equal(t, filepath.Join(newDir, "d.txt"), ev.Path)
+
+		// Renaming a directory keeps watching its content at the new path.
+		renamed := filepath.Join(root, "renamed")
+		require.NoError(t, os.Rename(filepath.Join(root, "sub"), renamed))
+		ev = nextWatchEvent(t, w)
+		require.Equal(t, WatchRename, ev.Op)
+		require.Equal(t, filepath.Join(root, "sub"), ev.OldPath)
+		require.Equal(t, renamed, ev.Path)
+		utils.ValidateNodeCnt(t, ev.Node, 3)
+		require.NoError(t, os.Remove(filepath.Join(renamed, "b.txt")))
+		ev = nextWatchEvent(t, w)
+		require.Equal(t, WatchRemove, ev.Op)
+		require.Equal(t, filepath.Join(renamed, "b.txt"), ev.Path)
+
+		// Moving out of the watched tree is a removal.
+		outside := t.TempDir()
+		require.NoError(t, os.Rename(filepath.Join(root, "a.txt"), filepath.Join(outside, "a.txt")))
+		ev = nextWatchEvent(t, w)
+		require.Equal(t, WatchRemove, ev.Op)
+		require.Equal(t, filepath.Join(root, "a.txt"), ev.Path)
+
+		require.NoError(t, w.Close())
+		for range w.Events() {
+		}
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, mockDir, testExecFunc)
+	testExectutor.Execute()
+}
@@ -0,0 +1,37 @@
+//go:build !linux
+
+package filesys
+
+import (
+	"context"
+	"errors"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+)
+
+// Watcher reports changes below local directories. It needs inotify and is only
+// available on Linux.
+type Watcher struct{}
+
+// NewWatcher always fails outside of Linux.
+func NewWatcher() (*Watcher, error) {
+	return nil, errors.New("watching is only supported on Linux")
+}
+
+func (w *Watcher) AddRoot(ctx context.Context, dirPath string, opts ScanOptions) (*ds.TreeNode, error) {
+	return nil, errors.New("watching is only supported on Linux")
+}
+
+func (w *Watcher) Skip(path string) {}
+
+func (w *Watcher) Events() <-chan WatchEvent {
+	return nil
+}
+
+func (w *Watcher) Errors() <-chan error {
+	return nil
+}
+
+func (w *Watcher) Close() error {
+	return nil
+}
@@ -0,0 +1,109 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	contextrepo "github.com/heroku/self/MetaManager/internal/repository/context"
+	"github.com/heroku/self/MetaManager/internal/services"
+)
+
+// TypeWebDAV is the type of contexts tracking a WebDAV server.
+const TypeWebDAV = "webdav"
+
+func init() {
+	RegisterBackend(Backend{
+		Type:     TypeWebDAV,
+		RootPath: file.WebDAVPathPrefix,
+		NewScanner: func() (Scanner, error) {
+			// The server to scan is part of a context, see NewTracker.
+			return nil, fmt.Errorf("a WebDAV scanner needs the server of a context")
+		},
+		NewTracker: func(cxtRepo contextrepo.ContextRepository, opts ScanOptions) (Tracker, error) {
+			ctxName, err := cxtRepo.GetContext()
+			if err != nil {
+				return nil, err
+			}
+			svc, err := services.GetWebDAVService(ctxName)
+			if err != nil {
+				return nil, err
+			}
+			return NewWebDAVTracker(svc, opts), nil
+		},
+		NewResolver: func(cxtRepo contextrepo.ContextRepository) Resolver {
+			return NewWebDAVResolver(cxtRepo)
+		},
+	})
+}
+
+// WebDAVTracker tracks WebDAV paths using a WebDAVServiceInterface.
+type WebDAVTracker struct {
+	svc  services.WebDAVServiceInterface
+	opts ScanOptions
+}
+
+// NewWebDAVTracker creates a WebDAVTracker whose scans honor opts.
+func NewWebDAVTracker(svc services.WebDAVServiceInterface, opts ScanOptions) *WebDAVTracker {
+	return &WebDAVTracker{svc: svc, opts: opts}
+}
+
+// Track tracks a path like "webdav:/Folder/file", or "webdav:/Folder*" for a collection
+// and everything below it.
+func (w *WebDAVTracker) Track(path string) (*ds.TreeNode, error) {
+	return w.TrackContext(context.Background(), path)
+}
+
+// TrackContext is like Track but stops early once ctx is cancelled.
+func (w *WebDAVTracker) TrackContext(ctx context.Context, path string) (*ds.TreeNode, error) {
+	if len(path) == 0 {
+		return nil, &cmderror.InvalidPath{}
+	}
+	if _, err := webDAVServerPath(path); err != nil {
+		return nil, err
+	}
+	davPath, recursive := NormalizeWebDAVTrackPath(path)
+	return NewWebDAVScanner(w.svc, w.opts).TrackWebDAV(ctx, davPath, recursive)
+}
+
+// Exists reports whether the WebDAV path is still on the server.
+func (w *WebDAVTracker) Exists(ctx context.Context, p string) (bool, error) {
+	davPath, err := webDAVServerPath(p)
+	if err != nil {
+		return false, &cmderror.InvalidPath{}
+	}
+	_, err = w.svc.Stat(ctx, davPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// WebDAVResolver resolves WebDAV paths against the context's working directory (see
+// "webdav cd"). Paths are absolute when they start with "/".
+type WebDAVResolver struct {
+	ctxRepo contextrepo.ContextRepository
+}
+
+func NewWebDAVResolver(ctxRepo contextrepo.ContextRepository) *WebDAVResolver {
+	return &WebDAVResolver{ctxRepo: ctxRepo}
+}
+
+func (r *WebDAVResolver) Resolve(path string) (string, error) {
+	if file.IsWebDAVPath(path) {
+		return webDAVVirtualPath(ResolvePath("/", path[len(file.WebDAVPathRoot):])), nil
+	}
+	ctxName, err := r.ctxRepo.GetContext()
+	if err != nil {
+		return "", err
+	}
+	settings, err := services.LoadWebDAVSettings(ctxName)
+	if err != nil {
+		return "", err
+	}
+	return webDAVVirtualPath(ResolvePath(settings.Cwd, path)), nil
+}
@@ -0,0 +1,126 @@
+package filesys
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/cmderror"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/services"
+	"github.com/sirupsen/logrus"
+)
+
+// WebDAVScanner scans collections of a WebDAV server.
+type WebDAVScanner struct {
+	svc  services.WebDAVServiceInterface
+	opts ScanOptions
+}
+
+// NewWebDAVScanner creates a WebDAVScanner using opts. Only name patterns of
+// opts.IgnorePatterns apply, as .mmignore files are not read from the server.
+func NewWebDAVScanner(svc services.WebDAVServiceInterface, opts ScanOptions) *WebDAVScanner {
+	return &WebDAVScanner{svc: svc, opts: opts}
+}
+
+// Scan scans a WebDAV path like "webdav:/Folder" or, recursively, "webdav:/Folder*".
+func (w *WebDAVScanner) Scan(path string) (*ds.TreeNode, error) {
+	davPath, recursive := NormalizeWebDAVTrackPath(path)
+	return w.TrackWebDAV(context.Background(), davPath, recursive)
+}
+
+// Make sure that WebDAVScanner implements Scanner
+var _ Scanner = (*WebDAVScanner)(nil)
+
+// TrackWebDAV returns the tree of the server path davPath, like "/" or "/Folder". The
+// content of collections is only listed when recursive is set.
+func (w *WebDAVScanner) TrackWebDAV(ctx context.Context, davPath string, recursive bool) (*ds.TreeNode, error) {
+	logrus.Debugf("[track-webdav] TrackWebDAV start path=%q recursive=%v", davPath, recursive)
+	if w.svc == nil {
+		return nil, &cmderror.InvalidOperation{}
+	}
+	entry, err := w.svc.Stat(ctx, davPath)
+	if err != nil {
+		logrus.Debugf("[track-webdav] Stat error: %v", err)
+		return nil, err
+	}
+
+	baseVirtual := webDAVVirtualPath(entry.Id)
+	if !entry.IsFolder || !recursive {
+		return newRemoteNode(baseVirtual, *entry), nil
+	}
+	ignore := w.opts.rootIgnoreMatcher(baseVirtual)
+	w.opts.filter = w.opts.Filter.compile(baseVirtual)
+	return w.trackWebDAVCollection(ctx, *entry, baseVirtual, 0, ignore)
+}
+
+// trackWebDAVCollection recursively tracks a WebDAV collection.
+func (w *WebDAVScanner) trackWebDAVCollection(ctx context.Context, dir services.RootEntry, virtualPath string, depth int, ignore *IgnoreMatcher) (*ds.TreeNode, error) {
+	logrus.Debugf("[track-webdav] trackWebDAVCollection depth=%d path=%q", depth, virtualPath)
+	if depth > maxTrackDepth {
+		logrus.Debugf("[track-webdav] max depth %d exceeded, stopping", maxTrackDepth)
+		return nil, &cmderror.InvalidOperation{}
+	}
+
+	entries, err := w.svc.ListAtPath(ctx, dir.Id)
+	if err != nil {
+		logrus.Debugf("[track-webdav] ListAtPath %q error: %v", dir.Id, err)
+		return nil, err
+	}
+	logrus.Debugf("[track-webdav] depth=%d path=%q listed %d entries", depth, virtualPath, len(entries))
+
+	rootNode := newRemoteNode(virtualPath, dir)
+	for _, e := range entries {
+		childVirtual := path.Join(virtualPath, e.Name)
+		if _, ignored := ignore.Match(childVirtual, e.IsFolder); ignored {
+			logrus.Debugf("[track-webdav] ignoring %q", childVirtual)
+			continue
+		}
+		if !w.opts.filter.keep(childVirtual, e.IsFolder, remoteEntryInfo{e}) {
+			logrus.Debugf("[track-webdav] filtered out %q", childVirtual)
+			continue
+		}
+		if e.IsFolder && w.opts.filter.descend(depth+1) {
+			sub, err := w.trackWebDAVCollection(ctx, e, childVirtual, depth+1, ignore)
+			if err != nil {
+				return nil, err
+			}
+			rootNode.Children = append(rootNode.Children, sub)
+		} else {
+			rootNode.Children = append(rootNode.Children, newRemoteNode(childVirtual, e))
+		}
+	}
+	return rootNode, nil
+}
+
+// webDAVVirtualPath returns the virtual path of the server path davPath.
+func webDAVVirtualPath(davPath string) string {
+	davPath = strings.Trim(davPath, "/")
+	if davPath == "" {
+		return file.WebDAVPathPrefix
+	}
+	return file.WebDAVPathPrefix + davPath
+}
+
+// webDAVServerPath returns the server path of a virtual WebDAV path, e.g. "/Folder" for
+// "webdav:/Folder".
+func webDAVServerPath(virtualPath string) (string, error) {
+	if !file.IsWebDAVPath(virtualPath) {
+		return "", fmt.Errorf("path is not a WebDAV path: %s. It should be like 'webdav:/Folder/SubFolder' or 'webdav:/'", virtualPath)
+	}
+	return NormalizePath(strings.TrimPrefix(virtualPath, file.WebDAVPathRoot)), nil
+}
+
+// NormalizeWebDAVTrackPath returns the server path to track, like "/Folder", and whether
+// it is tracked recursively (a trailing "*").
+func NormalizeWebDAVTrackPath(pathExp string) (path string, recursive bool) {
+	pathExp = strings.TrimSpace(pathExp)
+	pathExp = strings.TrimPrefix(pathExp, file.WebDAVPathRoot)
+	recursive = strings.HasSuffix(pathExp, "*")
+	if recursive {
+		pathExp = strings.TrimSuffix(pathExp, "*")
+	}
+	return NormalizePath(pathExp), recursive
+}
@@ -0,0 +1,103 @@
+package filesys
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/file"
+	contextmocks "github.com/heroku/self/MetaManager/internal/mocks/repository/context"
+	"github.com/heroku/self/MetaManager/internal/services"
+	"github.com/heroku/self/MetaManager/internal/utils"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVTestService serves dir over WebDAV and returns a client for it.
+func newWebDAVTestService(t *testing.T, dir string) *services.WebDAVService {
+	srv := httptest.NewServer(&webdav.Handler{FileSystem: webdav.Dir(dir), LockSystem: webdav.NewMemLS()})
+	t.Cleanup(srv.Close)
+	svc, err := services.NewWebDAVService(&services.WebDAVSettings{URL: srv.URL})
+	require.NoError(t, err)
+	return svc
+}
+
+func TestWebDAVTracker(t *testing.T) {
+	mockDir := &utils.MockDir{
+		DirName: "dav",
+		Files:   []string{"file1.txt", "skip.tmp"},
+		Dirs: []*utils.MockDir{
+			{DirName: "Folder1", Files: []string{"file2.txt"}, Dirs: []*utils.MockDir{
+				{DirName: "Sub", Files: []string{"file3.txt"}},
+			}},
+		},
+	}
+	testExecFunc := func(t *testing.T, root string) {
+		svc := newWebDAVTestService(t, root)
+		ctx := context.Background()
+
+		tracker := NewWebDAVTracker(svc, ScanOptions{IgnorePatterns: []string{"*.tmp"}})
+		tree, err := tracker.Track("webdav:/Folder1")
+		require.NoError(t, err)
+		require.Equal(t, "webdav:/Folder1", tree.Info.(*file.FileNode).AbsPath)
+		require.Empty(t, tree.Children)
+
+		// root + file1 + Folder1 + file2 + Sub + file3, skip.tmp is ignored
+		tree, err = tracker.Track("webdav:/*")
+		require.NoError(t, err)
+		require.Equal(t, file.WebDAVPathPrefix, tree.Info.(*file.FileNode).AbsPath)
+		utils.ValidateNodeCnt(t, tree, 6)
+
+		tracker = NewWebDAVTracker(svc, ScanOptions{Filter: ScanFilter{MaxDepth: 1}})
+		tree, err = tracker.Track("webdav:/Folder1*")
+		require.NoError(t, err)
+		utils.ValidateNodeCnt(t, tree, 3)
+
+		_, err = tracker.Track("webdav:/missing")
+		require.Error(t, err)
+		_, err = tracker.Track("/Folder1")
+		require.ErrorContains(t, err, "not a WebDAV path")
+
+		exists, err := tracker.Exists(ctx, "webdav:/Folder1/Sub/file3.txt")
+		require.NoError(t, err)
+		require.True(t, exists)
+		require.NoError(t, os.Remove(root+"/Folder1/Sub/file3.txt"))
+		exists, err = tracker.Exists(ctx, "webdav:/Folder1/Sub/file3.txt")
+		require.NoError(t, err)
+		require.False(t, exists)
+	}
+	testExectutor := utils.NewDirLifeCycleTester(t, mockDir, testExecFunc)
+	testExectutor.Execute()
+}
+
+func TestWebDAVResolver(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("MM_TEST_CONTEXT_DIR", dir)
+	defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+	appDir, err := utils.GetAppDataDirForContext("dav")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(appDir, 0755))
+	require.NoError(t, services.SaveWebDAVSettings("dav", &services.WebDAVSettings{URL: "http://localhost", Cwd: "/Folder"}))
+
+	m := contextmocks.NewMockContextRepository(t)
+	m.On("GetContext").Return("dav", nil)
+	m.On("GetContextType", "dav").Return(TypeWebDAV, nil)
+	resolver := NewBasicResolver(m)
+
+	tests := map[string]string{
+		"file.txt":             "webdav:/Folder/file.txt",
+		"..":                   "webdav:/",
+		"/Other/x":             "webdav:/Other/x",
+		"Sub*":                 "webdav:/Folder/Sub*",
+		"webdav:/Other/":       "webdav:/Other",
+		"webdav:/Other/../a":   "webdav:/a",
+		"archive.zip!/inner/x": "webdav:/Folder/archive.zip!/inner/x",
+	}
+	for p, expected := range tests {
+		resolved, err := resolver.Resolve(p)
+		require.NoError(t, err, p)
+		require.Equal(t, expected, resolved, p)
+	}
+}
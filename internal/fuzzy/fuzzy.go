@@ -0,0 +1,177 @@
+// Package fuzzy scores texts against a pattern whose characters must appear in order,
+// the way fzf does: matches at word starts and consecutive matches score higher, gaps
+// cost, and the shortest occurrence of the pattern is the one scored.
+package fuzzy
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scores, as in fzf.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+	// A match right after a separator such as "/", "-" or "_".
+	bonusBoundary = scoreMatch / 2
+	// A match right after "/", a path element starting with it.
+	bonusBoundaryDelimiter = bonusBoundary + 1
+	// A lowercase to uppercase or letter to digit step, as in "camelCase" or "file2".
+	bonusCamel123 = bonusBoundary + scoreGapExtension
+	// Each match following another one, at least as much as the gap it avoids.
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+	// The bonus of the first pattern character counts this many times.
+	bonusFirstCharMultiplier = 2
+)
+
+type charClass int
+
+const (
+	classWhite charClass = iota
+	classNonWord
+	classDelimiter
+	classLower
+	classUpper
+	classNumber
+)
+
+func classOf(r rune) charClass {
+	switch {
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsUpper(r):
+		return classUpper
+	case unicode.IsDigit(r):
+		return classNumber
+	case unicode.IsLetter(r):
+		return classLower
+	case unicode.IsSpace(r):
+		return classWhite
+	case r == '/' || r == ':':
+		return classDelimiter
+	}
+	return classNonWord
+}
+
+// bonusFor is the bonus of a match on a character of class cur following one of class prev.
+func bonusFor(prev, cur charClass) int {
+	if cur > classNonWord {
+		switch prev {
+		case classWhite:
+			return bonusBoundary + 2
+		case classDelimiter:
+			return bonusBoundaryDelimiter
+		case classNonWord:
+			return bonusBoundary
+		}
+	}
+	if prev == classLower && cur == classUpper || prev != classNumber && cur == classNumber {
+		return bonusCamel123
+	}
+	switch cur {
+	case classNonWord, classDelimiter:
+		return bonusBoundary
+	case classWhite:
+		return bonusBoundary + 2
+	}
+	return 0
+}
+
+// Match scores text against pattern. The match ignores case unless pattern has an
+// uppercase letter. positions are the byte offsets in text of the matched characters.
+// ok is false when the characters of pattern do not all appear, in order, in text.
+func Match(pattern, text string) (score int, positions []int, ok bool) {
+	pat := []rune(pattern)
+	if len(pat) == 0 {
+		return 0, nil, true
+	}
+	caseSensitive := false
+	for _, r := range pat {
+		if unicode.IsUpper(r) {
+			caseSensitive = true
+			break
+		}
+	}
+	runes := []rune(text)
+	fold := func(r rune) rune {
+		if caseSensitive {
+			return r
+		}
+		return unicode.ToLower(r)
+	}
+
+	// Find where the first occurrence ends, then walk back from there to its latest
+	// start, the shortest window holding the pattern.
+	pi, end := 0, -1
+	for i, r := range runes {
+		if fold(r) == pat[pi] {
+			pi++
+			if pi == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end == -1 {
+		return 0, nil, false
+	}
+	pi, start := len(pat)-1, end
+	for i := end; i >= 0; i-- {
+		if fold(runes[i]) == pat[pi] {
+			pi--
+			if pi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	// Score the window, matching each pattern character as early as possible.
+	offsets := make([]int, len(runes)+1)
+	for i, r := range runes {
+		offsets[i+1] = offsets[i] + utf8.RuneLen(r)
+	}
+	prevClass := classDelimiter
+	if start > 0 {
+		prevClass = classOf(runes[start-1])
+	}
+	pi = 0
+	inGap, consecutive, firstBonus := false, 0, 0
+	for i := start; i <= end; i++ {
+		class := classOf(runes[i])
+		if pi < len(pat) && fold(runes[i]) == pat[pi] {
+			positions = append(positions, offsets[i])
+			score += scoreMatch
+			bonus := bonusFor(prevClass, class)
+			if consecutive == 0 {
+				firstBonus = bonus
+			} else {
+				// A run is worth at least its start, and at least bonusConsecutive.
+				if bonus >= bonusBoundary && bonus > firstBonus {
+					firstBonus = bonus
+				}
+				bonus = max(bonus, firstBonus, bonusConsecutive)
+			}
+			if pi == 0 {
+				score += bonus * bonusFirstCharMultiplier
+			} else {
+				score += bonus
+			}
+			inGap = false
+			consecutive++
+			pi++
+		} else {
+			if inGap {
+				score += scoreGapExtension
+			} else {
+				score += scoreGapStart
+			}
+			inGap = true
+			consecutive = 0
+			firstBonus = 0
+		}
+		prevClass = class
+	}
+	return score, positions, true
+}
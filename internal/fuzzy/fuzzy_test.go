@@ -0,0 +1,76 @@
+package fuzzy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	_, positions, ok := Match("rdme", "/src/README.md")
+	require.True(t, ok)
+	require.Equal(t, []int{5, 8, 9, 10}, positions)
+
+	_, _, ok = Match("rdme", "/src/main.go")
+	require.False(t, ok)
+	_, _, ok = Match("Readme", "/src/README.md")
+	require.False(t, ok, "uppercase in the pattern makes the match case sensitive")
+
+	// The shortest occurrence is scored.
+	_, positions, ok = Match("ab", "a-x-a-b")
+	require.True(t, ok)
+	require.Equal(t, []int{4, 6}, positions)
+
+	// Word starts and runs beat scattered characters.
+	boundary, _, _ := Match("fb", "/foo/bar")
+	scattered, _, _ := Match("fb", "/xfxxbx")
+	require.Greater(t, boundary, scattered)
+	run, _, _ := Match("repo", "/reports")
+	split, _, _ := Match("repo", "/r/e/p/o")
+	require.Greater(t, run, split)
+
+	// Byte offsets, not rune offsets.
+	_, positions, ok = Match("ü", "/grün")
+	require.True(t, ok)
+	require.Equal(t, []int{3}, positions)
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	node := func(p, id string, tags ...string) file.NodeInformable {
+		return &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: p, Id: id, Tags: tags}}
+	}
+	nodes := []file.NodeInformable{
+		node("/work/budget/notes.txt", ""),
+		node("/work/budget.xlsx", ""),
+		node("gdrive:/Finance/budget-2025.xlsx", ""),
+		node("/work/plans.txt", "", "budget"),
+		node("/work/other.txt", ""),
+	}
+
+	results := Rank("budget", nodes, nil, now, 0)
+	paths := []string{}
+	for _, res := range results {
+		paths = append(paths, res.Path)
+	}
+	// Name matches first, then path matches; tags match on their own.
+	require.Equal(t, []string{"/work/budget.xlsx", "gdrive:/Finance/budget-2025.xlsx", "/work/budget/notes.txt", "/work/plans.txt"}, paths)
+	require.Equal(t, "budget", results[3].Tag)
+	require.Empty(t, results[3].Positions)
+	require.Equal(t, []int{6, 7, 8, 9, 10, 11}, results[0].Positions)
+
+	// A node used lately, or with a matching id, moves up.
+	recent := map[string]time.Time{"/work/budget/notes.txt": now.Add(-time.Hour)}
+	results = Rank("budget", nodes, recent, now, 2)
+	require.Len(t, results, 2)
+	require.Equal(t, "/work/budget/notes.txt", results[0].Path)
+	nodes[0] = node("/work/budget/notes.txt", "budget-notes")
+	results = Rank("budget", nodes, nil, now, 1)
+	require.Equal(t, "/work/budget/notes.txt", results[0].Path)
+	require.Equal(t, "budget-notes", results[0].Id)
+
+	require.Equal(t, bonusRecent, recencyBonus(0))
+	require.Equal(t, bonusRecent/2, recencyBonus(recentHalfLife))
+}
@@ -0,0 +1,108 @@
+package fuzzy
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/file"
+)
+
+const (
+	// bonusName favors matches within the last path element over ones spread along
+	// the path.
+	bonusName = 2 * scoreMatch
+	// bonusRecent is the boost of a node used just now. It halves every
+	// recentHalfLife.
+	bonusRecent    = 4 * scoreMatch
+	recentHalfLife = 7 * 24 * time.Hour
+)
+
+// Result is a node matching a pattern.
+type Result struct {
+	Path  string
+	Score int
+	// Positions are the byte offsets in Path of the matched characters. There are none
+	// when only a tag or the id matched.
+	Positions []int
+	// Tag and Id are the tag and id that matched, if any
+	Tag string
+	Id  string
+}
+
+// Rank scores nodes against pattern and returns the best limit results, best first;
+// limit <= 0 returns them all. A node matches on its name or path, and matches on a
+// tag or its id boost it, or make it match on their own. recent holds when nodes
+// were last used, recently used nodes being boosted.
+func Rank(pattern string, nodes []file.NodeInformable, recent map[string]time.Time, now time.Time, limit int) []Result {
+	results := []Result{}
+	for _, info := range nodes {
+		res, ok := scoreNode(pattern, info)
+		if !ok {
+			continue
+		}
+		if usedAt, ok := recent[res.Path]; ok {
+			res.Score += recencyBonus(now.Sub(usedAt))
+		}
+		results = append(results, res)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if len(results[i].Path) != len(results[j].Path) {
+			return len(results[i].Path) < len(results[j].Path)
+		}
+		return results[i].Path < results[j].Path
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func scoreNode(pattern string, info file.NodeInformable) (Result, bool) {
+	p := info.GetAbsPath()
+	res := Result{Path: p}
+	matched := false
+
+	nameStart := strings.LastIndex(strings.TrimSuffix(p, "/"), "/") + 1
+	if score, positions, ok := Match(pattern, p[nameStart:]); ok {
+		for i := range positions {
+			positions[i] += nameStart
+		}
+		res.Score, res.Positions, matched = score+bonusName, positions, true
+	}
+	if score, positions, ok := Match(pattern, p); ok && (!matched || score > res.Score) {
+		res.Score, res.Positions, matched = score, positions, true
+	}
+
+	// Tags and ids are chosen by the user: a match there is worth half its score on top.
+	bestTag := 0
+	for _, tag := range info.GetTags() {
+		if score, _, ok := Match(pattern, tag); ok && (res.Tag == "" || score > bestTag) {
+			res.Tag, bestTag = tag, score
+		}
+	}
+	if res.Tag != "" {
+		res.Score += bestTag / 2
+		matched = true
+	}
+	if id := info.GetId(); id != "" {
+		if score, _, ok := Match(pattern, id); ok {
+			res.Id = id
+			res.Score += score / 2
+			matched = true
+		}
+	}
+	return res, matched
+}
+
+// recencyBonus is the boost of a node last used age ago.
+func recencyBonus(age time.Duration) int {
+	if age < 0 {
+		age = 0
+	}
+	return int(float64(bonusRecent) * math.Pow(0.5, float64(age)/float64(recentHalfLife)))
+}
@@ -0,0 +1,258 @@
+// Package index implements the inverted index of a tree, mapping tags, id tokens and
+// path tokens to the paths of the nodes having them, so that searches need not read
+// and walk the whole tree.
+package index
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+)
+
+// Version is the version of the index format. Indexes of another version are rebuilt.
+const Version = 2
+
+// Kinds of terms. A term is "<kind>:<value>", e.g. "tag:acme" or "path:reports".
+const (
+	KindTag  = "tag"
+	KindId   = "id"
+	KindPath = "path"
+)
+
+// textKinds are the kinds a free text word is looked up in.
+var textKinds = []string{KindTag, KindId, KindPath}
+
+// Index maps terms to node paths.
+type Index struct {
+	Version int
+	// DataSize and DataModTime stamp the tree file the index was built from, so that
+	// an index gone stale, e.g. after data.json was restored, is noticed.
+	DataSize    int64
+	DataModTime int64
+	// Docs holds the sorted terms of every node, by node path
+	Docs map[string][]string
+	// Postings holds the sorted node paths of every term
+	Postings map[string][]string
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{
+		Version:  Version,
+		Docs:     map[string][]string{},
+		Postings: map[string][]string{},
+	}
+}
+
+// Build returns the index of the tree at root.
+func Build(root *ds.TreeNode) (*Index, error) {
+	ix := New()
+	if _, err := ix.Update(root); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// Term returns the term of value in kind. Tags are indexed as they are, ids as they
+// are and as lowercase tokens, other text as lowercase tokens.
+func Term(kind, value string) string {
+	return kind + ":" + value
+}
+
+// Tokens splits text into lowercase words of letters and digits.
+func Tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// NodeTerms returns the sorted terms a node is indexed under.
+func NodeTerms(info file.NodeInformable) []string {
+	set := map[string]bool{}
+	for _, tag := range info.GetTags() {
+		set[Term(KindTag, tag)] = true
+	}
+	if id := info.GetId(); id != "" {
+		set[Term(KindId, id)] = true
+		for _, token := range Tokens(id) {
+			set[Term(KindId, token)] = true
+		}
+	}
+	for _, token := range Tokens(info.GetAbsPath()) {
+		set[Term(KindPath, token)] = true
+	}
+	terms := make([]string, 0, len(set))
+	for term := range set {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// Update brings the index in line with the tree at root. Only the postings of nodes
+// whose terms changed, and of nodes added or removed, are touched. It returns the
+// number of nodes whose entry changed.
+func (ix *Index) Update(root *ds.TreeNode) (int, error) {
+	seen := make(map[string]bool, len(ix.Docs))
+	changed := 0
+	if root != nil {
+		it := ds.NewTreeIterator(ds.NewTreeManager(root))
+		for it.HasNext() {
+			node, err := it.Next()
+			if err != nil {
+				return 0, err
+			}
+			info, ok := node.Info.(file.NodeInformable)
+			if !ok {
+				continue
+			}
+			p := info.GetAbsPath()
+			seen[p] = true
+			if ix.setDoc(p, NodeTerms(info)) {
+				changed++
+			}
+		}
+	}
+	for p := range ix.Docs {
+		if !seen[p] {
+			ix.setDoc(p, nil)
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// setDoc replaces the terms of the node at p, nil removing it. It reports whether
+// anything changed.
+func (ix *Index) setDoc(p string, terms []string) bool {
+	old, exists := ix.Docs[p]
+	if exists && terms != nil && equalTerms(old, terms) {
+		return false
+	}
+	// Both term lists are sorted: walk them together.
+	i, j := 0, 0
+	for i < len(old) || j < len(terms) {
+		switch {
+		case j == len(terms) || (i < len(old) && old[i] < terms[j]):
+			ix.Postings[old[i]] = removeSorted(ix.Postings[old[i]], p)
+			if len(ix.Postings[old[i]]) == 0 {
+				delete(ix.Postings, old[i])
+			}
+			i++
+		case i == len(old) || terms[j] < old[i]:
+			ix.Postings[terms[j]] = insertSorted(ix.Postings[terms[j]], p)
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	if terms == nil {
+		delete(ix.Docs, p)
+	} else {
+		ix.Docs[p] = terms
+	}
+	return true
+}
+
+// Lookup returns the sorted paths of the nodes having term. The slice must not be
+// modified.
+func (ix *Index) Lookup(term string) []string {
+	return ix.Postings[term]
+}
+
+// Search returns the sorted paths of the nodes matching every word, a word matching
+// a tag, a word of the id or a path element. Tags are case sensitive, the rest is not.
+func (ix *Index) Search(words []string) []string {
+	var result []string
+	for i, word := range words {
+		matches := ix.Postings[Term(KindTag, word)]
+		for _, kind := range textKinds {
+			matches = Union(matches, ix.Postings[Term(kind, strings.ToLower(word))])
+		}
+		if i == 0 {
+			result = matches
+		} else {
+			result = Intersect(result, matches)
+		}
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+func equalTerms(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func insertSorted(list []string, s string) []string {
+	i := sort.SearchStrings(list, s)
+	if i < len(list) && list[i] == s {
+		return list
+	}
+	list = append(list, "")
+	copy(list[i+1:], list[i:])
+	list[i] = s
+	return list
+}
+
+func removeSorted(list []string, s string) []string {
+	i := sort.SearchStrings(list, s)
+	if i == len(list) || list[i] != s {
+		return list
+	}
+	return append(list[:i], list[i+1:]...)
+}
+
+// Union merges two sorted lists into a new one.
+func Union(a, b []string) []string {
+	out := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case b[j] < a[i]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
+
+// Intersect returns the paths in both sorted lists.
+func Intersect(a, b []string) []string {
+	out := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case b[j] < a[i]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
@@ -0,0 +1,151 @@
+package index_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/index"
+	"github.com/heroku/self/MetaManager/internal/repository/tree"
+)
+
+func newNode(p string, tags ...string) *ds.TreeNode {
+	return ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: p, Tags: tags}})
+}
+
+// largeTree returns a tree of 100 projects of 10 directories of 100 files, about
+// 100k nodes, every tenth file tagged "review".
+func largeTree() *ds.TreeNode {
+	root := newNode("/data")
+	for p := 0; p < 100; p++ {
+		project := newNode(fmt.Sprintf("/data/project%d", p))
+		root.AddChild(project)
+		for d := 0; d < 10; d++ {
+			dir := newNode(fmt.Sprintf("/data/project%d/dir%d", p, d))
+			project.AddChild(dir)
+			for f := 0; f < 100; f++ {
+				var tags []string
+				if f%10 == 0 {
+					tags = []string{"review"}
+				}
+				dir.AddChild(newNode(fmt.Sprintf("/data/project%d/dir%d/file%d.txt", p, d, f), tags...))
+			}
+		}
+	}
+	return root
+}
+
+func BenchmarkLookupTag(b *testing.B) {
+	ix, err := index.Build(largeTree())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(ix.Lookup(index.Term(index.KindTag, "review"))) != 10000 {
+			b.Fatal("unexpected result")
+		}
+	}
+}
+
+func BenchmarkSearchWords(b *testing.B) {
+	ix, err := index.Build(largeTree())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(ix.Search([]string{"project42", "dir7", "file99"})) != 1 {
+			b.Fatal("unexpected result")
+		}
+	}
+}
+
+// storeLargeTree stores largeTree and its index in a temporary directory, and returns
+// the paths of the tree and index files.
+func storeLargeTree(b *testing.B) (string, string) {
+	dir := b.TempDir()
+	dataFilePath := filepath.Join(dir, "data.json")
+	indexFilePath := filepath.Join(dir, "index.gob")
+	rw, err := tree.NewIndexedRW(dataFilePath, indexFilePath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := rw.Write(largeTree()); err != nil {
+		b.Fatal(err)
+	}
+	return dataFilePath, indexFilePath
+}
+
+// BenchmarkLoadLookupTag is a tag search as commands run it: the stored index is
+// loaded, then looked up.
+func BenchmarkLoadLookupTag(b *testing.B) {
+	dataFilePath, indexFilePath := storeLargeTree(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ix, err := index.Load(indexFilePath, dataFilePath)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(ix.Lookup(index.Term(index.KindTag, "review"))) != 10000 {
+			b.Fatal("unexpected result")
+		}
+	}
+}
+
+// BenchmarkReadWalkTag is the tag search BenchmarkLoadLookupTag replaces: the stored
+// tree is read, then walked.
+func BenchmarkReadWalkTag(b *testing.B) {
+	dataFilePath, _ := storeLargeTree(b)
+	rw, err := tree.NewFileStorageRW(dataFilePath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root, err := rw.Read()
+		if err != nil {
+			b.Fatal(err)
+		}
+		tgMg := data.NewTagManager(data.NewDirTreeManager(ds.NewTreeManager(root)))
+		paths, err := tgMg.GetTaggedNodes("review")
+		if err != nil || len(paths) != 10000 {
+			b.Fatal("unexpected result")
+		}
+	}
+}
+
+// BenchmarkWalkTag is the tree walk that index lookups replace.
+func BenchmarkWalkTag(b *testing.B) {
+	tgMg := data.NewTagManager(data.NewDirTreeManager(ds.NewTreeManager(largeTree())))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		paths, err := tgMg.GetTaggedNodes("review")
+		if err != nil || len(paths) != 10000 {
+			b.Fatal("unexpected result")
+		}
+	}
+}
+
+func BenchmarkUpdateOneTag(b *testing.B) {
+	root := largeTree()
+	ix, err := index.Build(root)
+	if err != nil {
+		b.Fatal(err)
+	}
+	node := root.Children[0].Children[0].Children[1].Info.(*file.FileNode)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%2 == 0 {
+			node.AddTag("review")
+		} else {
+			node.DeleteTag("review")
+		}
+		if _, err := ix.Update(root); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/stretchr/testify/require"
+)
+
+func newNode(p string, tags ...string) *ds.TreeNode {
+	return ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: p, Tags: tags}})
+}
+
+func TestIndexUpdate(t *testing.T) {
+	root := newNode("/r")
+	reports := newNode("/r/Reports", "acme")
+	q1 := newNode("/r/Reports/q1-summary.pdf", "acme", "Final")
+	root.AddChild(reports)
+	reports.AddChild(q1)
+
+	ix, err := Build(root)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/r/Reports", "/r/Reports/q1-summary.pdf"}, ix.Lookup(Term(KindTag, "acme")))
+	require.Equal(t, []string{"/r/Reports/q1-summary.pdf"}, ix.Lookup(Term(KindTag, "Final")))
+	require.Empty(t, ix.Lookup(Term(KindTag, "final")))
+
+	require.Equal(t, []string{"/r/Reports/q1-summary.pdf"}, ix.Search([]string{"reports", "SUMMARY"}))
+	require.Equal(t, []string{"/r/Reports/q1-summary.pdf"}, ix.Search([]string{"Final", "pdf"}))
+	require.Empty(t, ix.Search([]string{"acme", "nothing"}))
+
+	// Only what changed is touched.
+	q1.Info.(*file.FileNode).DeleteTag("acme")
+	q1.Info.(*file.FileNode).SetId("q1-report")
+	changed, err := ix.Update(root)
+	require.NoError(t, err)
+	require.Equal(t, 1, changed)
+	require.Equal(t, []string{"/r/Reports"}, ix.Lookup(Term(KindTag, "acme")))
+	require.Equal(t, []string{"/r/Reports/q1-summary.pdf"}, ix.Lookup(Term(KindId, "q1-report")))
+	require.Equal(t, []string{"/r/Reports/q1-summary.pdf"}, ix.Search([]string{"q1", "report"}))
+
+	root.Children = nil
+	changed, err = ix.Update(root)
+	require.NoError(t, err)
+	require.Equal(t, 2, changed)
+	require.Empty(t, ix.Lookup(Term(KindTag, "acme")))
+	require.Len(t, ix.Docs, 1)
+	rebuilt, err := Build(root)
+	require.NoError(t, err)
+	require.Equal(t, rebuilt.Postings, ix.Postings)
+}
+
+func TestIndexStore(t *testing.T) {
+	dir := t.TempDir()
+	dataFilePath := filepath.Join(dir, "data.json")
+	indexFilePath := filepath.Join(dir, "index.gob")
+	require.NoError(t, os.WriteFile(dataFilePath, []byte("{}"), 0o644))
+
+	_, err := Load(indexFilePath, dataFilePath)
+	require.ErrorIs(t, err, ErrStale)
+
+	root := newNode("/r", "acme")
+	ix, err := Build(root)
+	require.NoError(t, err)
+	require.NoError(t, ix.Save(indexFilePath, dataFilePath))
+	loaded, err := Load(indexFilePath, dataFilePath)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/r"}, loaded.Lookup(Term(KindTag, "acme")))
+
+	// A tree written behind the index makes it stale.
+	require.NoError(t, os.WriteFile(dataFilePath, []byte("{ }"), 0o644))
+	_, err = Load(indexFilePath, dataFilePath)
+	require.ErrorIs(t, err, ErrStale)
+
+	require.NoError(t, os.WriteFile(indexFilePath, []byte("garbage"), 0o644))
+	_, err = Load(indexFilePath, dataFilePath)
+	require.ErrorIs(t, err, ErrStale)
+}
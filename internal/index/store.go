@@ -0,0 +1,77 @@
+package index
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrStale is returned by Load when the index is not usable as is and has to be
+// rebuilt from the tree.
+var ErrStale = errors.New("index is stale")
+
+// Load reads the index at indexFilePath, built from the tree file at dataFilePath.
+// It returns ErrStale when the index is missing, of another format version or older
+// than the tree file.
+func Load(indexFilePath, dataFilePath string) (*Index, error) {
+	f, err := os.Open(indexFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrStale
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ix := &Index{}
+	if err := gob.NewDecoder(f).Decode(ix); err != nil || ix.Version != Version {
+		return nil, ErrStale
+	}
+	size, modTime, err := stamp(dataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if ix.DataSize != size || ix.DataModTime != modTime {
+		return nil, ErrStale
+	}
+	if ix.Docs == nil {
+		ix.Docs = map[string][]string{}
+	}
+	if ix.Postings == nil {
+		ix.Postings = map[string][]string{}
+	}
+	return ix, nil
+}
+
+// Save writes ix to indexFilePath, stamped with the tree file at dataFilePath. The
+// index is a cache of the tree, so it is stored in a compact binary form.
+func (ix *Index) Save(indexFilePath, dataFilePath string) error {
+	size, modTime, err := stamp(dataFilePath)
+	if err != nil {
+		return err
+	}
+	ix.Version, ix.DataSize, ix.DataModTime = Version, size, modTime
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(indexFilePath), filepath.Base(indexFilePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if err := gob.NewEncoder(tmpFile).Encode(ix); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), indexFilePath)
+}
+
+func stamp(dataFilePath string) (int64, int64, error) {
+	fi, err := os.Stat(dataFilePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	return fi.Size(), fi.ModTime().UnixNano(), nil
+}
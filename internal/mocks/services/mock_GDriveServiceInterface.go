@@ -38,6 +38,72 @@ func (_m *MockGDriveServiceInterface) EXPECT() *MockGDriveServiceInterface_Expec
 	return &MockGDriveServiceInterface_Expecter{mock: &_m.Mock}
 }
 
+// GetFileLink provides a mock function for the type MockGDriveServiceInterface
+func (_mock *MockGDriveServiceInterface) GetFileLink(ctx context.Context, path string) (string, error) {
+	ret := _mock.Called(ctx, path)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetFileLink")
+	}
+
+	var r0 string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return returnFunc(ctx, path)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = returnFunc(ctx, path)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, path)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockGDriveServiceInterface_GetFileLink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetFileLink'
+type MockGDriveServiceInterface_GetFileLink_Call struct {
+	*mock.Call
+}
+
+// GetFileLink is a helper method to define mock.On call
+//   - ctx context.Context
+//   - path string
+func (_e *MockGDriveServiceInterface_Expecter) GetFileLink(ctx interface{}, path interface{}) *MockGDriveServiceInterface_GetFileLink_Call {
+	return &MockGDriveServiceInterface_GetFileLink_Call{Call: _e.mock.On("GetFileLink", ctx, path)}
+}
+
+func (_c *MockGDriveServiceInterface_GetFileLink_Call) Run(run func(ctx context.Context, path string)) *MockGDriveServiceInterface_GetFileLink_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockGDriveServiceInterface_GetFileLink_Call) Return(s string, err error) *MockGDriveServiceInterface_GetFileLink_Call {
+	_c.Call.Return(s, err)
+	return _c
+}
+
+func (_c *MockGDriveServiceInterface_GetFileLink_Call) RunAndReturn(run func(ctx context.Context, path string) (string, error)) *MockGDriveServiceInterface_GetFileLink_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ListAtPath provides a mock function for the type MockGDriveServiceInterface
 func (_mock *MockGDriveServiceInterface) ListAtPath(ctx context.Context, path string) ([]services.RootEntry, error) {
 	ret := _mock.Called(ctx, path)
@@ -0,0 +1,60 @@
+// Package orphans keeps the metadata of nodes that refresh removed because they vanished.
+package orphans
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// Orphan is what was attached to a removed node.
+type Orphan struct {
+	Path      string
+	Tags      []string `json:",omitempty"`
+	Id        string   `json:",omitempty"`
+	RemovedAt time.Time
+}
+
+// Path returns the path of orphans.json for the given context.
+func Path(contextName string) (string, error) {
+	appDir, err := utils.GetAppDataDirForContext(contextName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, utils.OrphansFileName), nil
+}
+
+// Load reads the orphans of the given context. A missing file yields no orphans.
+func Load(contextName string) ([]Orphan, error) {
+	path, err := Path(contextName)
+	if err != nil {
+		return nil, err
+	}
+	var list []Orphan
+	err = utils.ReadJSON(path, &list)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+// Append adds list to the orphans of the given context.
+func Append(contextName string, list []Orphan) error {
+	if len(list) == 0 {
+		return nil
+	}
+	existing, err := Load(contextName)
+	if err != nil {
+		return err
+	}
+	path, err := Path(contextName)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, append(existing, list...), true)
+}
@@ -0,0 +1,228 @@
+// Package output renders what commands print, as text for people or in a structured
+// format for scripts. Structured formats write a list of records of one of the
+// schemas of this package: Node, Entry, Context or IdRecord.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/list"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is how a Printer writes records.
+type Format string
+
+const (
+	// FormatText leaves printing to the text function of the command.
+	FormatText Format = "text"
+	// FormatJSON writes a JSON array of records.
+	FormatJSON Format = "json"
+	// FormatNDJSON writes a JSON object per record, one per line.
+	FormatNDJSON Format = "ndjson"
+	// FormatYAML writes a YAML sequence of records.
+	FormatYAML Format = "yaml"
+	// FormatCSV writes a header row with the field names, then a row per record.
+	// Lists are joined with commas and times are RFC 3339.
+	FormatCSV Format = "csv"
+)
+
+// Formats lists the valid formats.
+var Formats = []Format{FormatText, FormatJSON, FormatNDJSON, FormatYAML, FormatCSV}
+
+// ParseFormat returns the format named s, FormatText when s is empty.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatText, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q, expected text, json, ndjson, yaml or csv", s)
+}
+
+// Printer writes records to w in a Format.
+type Printer struct {
+	Format Format
+	// Template, when set, formats every record on a line in FormatText.
+	Template *Template
+	w        io.Writer
+}
+
+// NewPrinter returns a Printer writing to w in format f.
+func NewPrinter(w io.Writer, f Format) *Printer {
+	return &Printer{Format: f, w: w}
+}
+
+// Structured reports whether the printer writes records rather than text.
+func (p *Printer) Structured() bool {
+	return p.Format != FormatText
+}
+
+// Print writes records, a slice of one of the schemas, in a structured format or with
+// the template of p, or else calls text with the writer of p.
+func (p *Printer) Print(records any, text func(w io.Writer) error) error {
+	v := reflect.ValueOf(records)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("records must be a slice, got %T", records)
+	}
+	if v.IsNil() {
+		// Scripts get an empty list rather than null.
+		v = reflect.MakeSlice(v.Type(), 0, 0)
+	}
+
+	switch p.Format {
+	case FormatText:
+		if p.Template != nil {
+			return p.Template.Write(p.w, v.Interface())
+		}
+		return text(p.w)
+	case FormatJSON:
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v.Interface())
+	case FormatNDJSON:
+		enc := json.NewEncoder(p.w)
+		for i := 0; i < v.Len(); i++ {
+			err := enc.Encode(v.Index(i).Interface())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatYAML:
+		enc := yaml.NewEncoder(p.w)
+		enc.SetIndent(2)
+		err := enc.Encode(v.Interface())
+		if err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatCSV:
+		return writeCSV(p.w, v)
+	}
+	return fmt.Errorf("invalid output format %q", p.Format)
+}
+
+// writeCSV writes the records of v, a slice of structs, as CSV.
+func writeCSV(w io.Writer, v reflect.Value) error {
+	elem := v.Type().Elem()
+	header := []string{}
+	fields := []int{}
+	for i := 0; i < elem.NumField(); i++ {
+		name := fieldName(elem.Field(i))
+		if name == "" {
+			continue
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+
+	cw := csv.NewWriter(w)
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		row := make([]string, 0, len(fields))
+		for _, f := range fields {
+			row = append(row, csvValue(v.Index(i).Field(f)))
+		}
+		err = cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// fieldName returns the JSON name of an exported field, "" for fields left out.
+func fieldName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// csvValue formats a field of a record for CSV.
+func csvValue(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case *time.Time:
+		if x == nil {
+			return ""
+		}
+		return x.Format(time.RFC3339)
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case []string:
+		return strings.Join(x, ",")
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// WriteList writes items as a bulleted list.
+func WriteList(w io.Writer, items []string) error {
+	wr := list.NewWriter()
+	for _, item := range items {
+		wr.AppendItem(item)
+	}
+	wr.SetStyle(list.StyleDefault)
+	_, err := fmt.Fprintln(w, wr.Render())
+	return err
+}
+
+// WriteListing writes the entries of the directory dir the way ls prints them, names
+// of directories ending in "/".
+func WriteListing(w io.Writer, dir string, entries []Entry) error {
+	fmt.Fprintln(w, dir)
+	fmt.Fprintln(w, "---")
+	for _, e := range entries {
+		name := e.Name
+		if e.Kind == KindDir && !strings.HasSuffix(name, "/") {
+			name += "/"
+		}
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "  (empty)")
+	}
+	return nil
+}
+
+// WriteContexts writes contexts as a table of names and types, the current one
+// marked with "*".
+func WriteContexts(w io.Writer, contexts []Context) error {
+	const nameCol, typeCol, currentCol = 20, 12, 8
+	fmt.Fprintf(w, "%-*s %-*s %-*s\n", nameCol, "NAME", typeCol, "TYPE", currentCol, "CURRENT")
+	fmt.Fprintln(w, strings.Repeat("-", nameCol+typeCol+currentCol+2))
+	for _, c := range contexts {
+		cur := ""
+		if c.Current {
+			cur = "*"
+		}
+		fmt.Fprintf(w, "%-*s %-*s %-*s\n", nameCol, c.Name, typeCol, c.Type, currentCol, cur)
+	}
+	if len(contexts) == 0 {
+		fmt.Fprintln(w, "  (no contexts; use 'context create <name> --type local|gdrive')")
+	}
+	return nil
+}
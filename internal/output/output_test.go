@@ -0,0 +1,202 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/services"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrint(t *testing.T) {
+	mtime := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []Node{
+		{Path: "/h/a.txt", Name: "a.txt", Kind: KindFile, Size: 3, ModTime: &mtime, Tags: []string{"x", "y"}, Id: "a"},
+		{Path: "/h", Name: "h", Kind: KindDir, Tags: []string{}},
+	}
+	print := func(f Format, records any) string {
+		var buf bytes.Buffer
+		err := NewPrinter(&buf, f).Print(records, func(w io.Writer) error {
+			_, err := io.WriteString(w, "text\n")
+			return err
+		})
+		require.NoError(t, err)
+		return buf.String()
+	}
+
+	require.Equal(t, "text\n", print(FormatText, records))
+	require.Equal(t, `{"path":"/h/a.txt","name":"a.txt","kind":"file","size":3,"mtime":"2025-03-01T10:00:00Z","tags":["x","y"],"id":"a","vanished":false,"target":"","driveId":"","mimeType":"","link":""}
+{"path":"/h","name":"h","kind":"dir","size":0,"mtime":null,"tags":[],"id":"","vanished":false,"target":"","driveId":"","mimeType":"","link":""}
+`, print(FormatNDJSON, records))
+	require.Equal(t, `path,name,kind,size,mtime,tags,id,vanished,target,driveId,mimeType,link,context
+/h/a.txt,a.txt,file,3,2025-03-01T10:00:00Z,"x,y",a,false,,,,,
+/h,h,dir,0,,,,false,,,,,
+`, print(FormatCSV, records))
+	require.Contains(t, print(FormatYAML, records), "- path: /h/a.txt\n  name: a.txt\n")
+	require.Contains(t, print(FormatJSON, records), "[\n  {\n    \"path\": \"/h/a.txt\",")
+
+	require.Equal(t, "[]\n", print(FormatJSON, []Context(nil)))
+	require.Equal(t, "name,type,current\n", print(FormatCSV, []Context{}))
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("")
+	require.NoError(t, err)
+	require.Equal(t, FormatText, f)
+	f, err = ParseFormat("ndjson")
+	require.NoError(t, err)
+	require.Equal(t, FormatNDJSON, f)
+	_, err = ParseFormat("xml")
+	require.ErrorContains(t, err, "invalid output format")
+}
+
+func TestNewNode(t *testing.T) {
+	child := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h/a.txt", Size: 3, ModTime: 100}})
+	dir := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h", Tags: []string{"t"}}})
+	dir.Children = []*ds.TreeNode{child}
+	link := ds.NewTreeNode(file.NewSymlinkNode("/h/l", "a.txt", nil))
+	empty := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h/empty", Dir: true}})
+	folder := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "gdrive:/Docs"}, DriveId: "d1", MimeType: services.DriveFolderMimeType})
+
+	mtime := time.Unix(100, 0)
+	require.Equal(t, []Node{
+		{Path: "/h", Name: "h", Kind: KindDir, Tags: []string{"t"}},
+		{Path: "/h/a.txt", Name: "a.txt", Kind: KindFile, Size: 3, ModTime: &mtime, Tags: []string{}},
+		{Path: "/h/l", Name: "l", Kind: KindSymlink, Tags: []string{}, Target: "a.txt"},
+		{Path: "/h/empty", Name: "empty", Kind: KindDir, Tags: []string{}},
+		{Path: "gdrive:/Docs", Name: "Docs", Kind: KindDir, Tags: []string{}, DriveId: "d1", MimeType: services.DriveFolderMimeType, Link: "https://drive.google.com/drive/folders/d1"},
+	}, NewNodes([]*ds.TreeNode{dir, child, link, empty, folder}))
+}
+
+func TestSubtreeNodes(t *testing.T) {
+	a := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h/a.txt"}})
+	h := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h"}})
+	h.Children = []*ds.TreeNode{a}
+	roots := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: file.RootsPath}})
+	roots.Children = []*ds.TreeNode{h}
+
+	found, err := SubtreeNodes(roots)
+	require.NoError(t, err)
+	require.Equal(t, []*ds.TreeNode{h, a}, found)
+}
+
+func TestWriteListing(t *testing.T) {
+	var buf bytes.Buffer
+	entries := []Entry{
+		NewRemoteEntry("/Docs", services.RootEntry{Name: "sub", IsFolder: true}),
+		NewRemoteEntry("/Docs", services.RootEntry{Id: "f1", Name: "a.txt", Size: 3}),
+	}
+	require.Equal(t, "/Docs/a.txt", entries[1].Path)
+	require.NoError(t, WriteListing(&buf, "/Docs", entries))
+	require.Equal(t, "/Docs\n---\n  sub/\n  a.txt\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteListing(&buf, "/", nil))
+	require.Equal(t, "/\n---\n  (empty)\n", buf.String())
+}
+
+func TestWriteContexts(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteContexts(&buf, []Context{{Name: "home", Type: "local", Current: true}, {Name: "drive", Type: "gdrive"}}))
+	lines := strings.Split(buf.String(), "\n")
+	require.Equal(t, "NAME                 TYPE         CURRENT ", lines[0])
+	require.Equal(t, "home                 local        *       ", lines[2])
+	require.Equal(t, "drive                gdrive               ", lines[3])
+
+	buf.Reset()
+	require.NoError(t, WriteContexts(&buf, nil))
+	require.Contains(t, buf.String(), "(no contexts;")
+}
+
+func TestTemplate(t *testing.T) {
+	mtime := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []Node{
+		{Path: "/h/a.txt", Size: 1536, ModTime: &mtime, Tags: []string{"x", "y"}, Id: "a", Target: "n"},
+		{Path: "/h/b.txt", Tags: []string{}},
+	}
+	tmpl, err := ParseTemplate(`{{.Path}}\t{{join .Tags ","}}\t{{.Id}}\t{{humanSize .Size}}\t{{time "2006-01-02" .ModTime}}\t{{hasTag . "x"}}\t{{.Attributes.target}}`)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Write(&buf, records))
+	require.Equal(t, "/h/a.txt\tx,y\ta\t1.5 KB\t2025-03-01\ttrue\tn\n/h/b.txt\t\t\t0 B\t\tfalse\t\n", buf.String())
+
+	buf.Reset()
+	p := NewPrinter(&buf, FormatText)
+	p.Template, err = ParseTemplate(`{{upper .Name}} {{pad 5 .Type}}|`)
+	require.NoError(t, err)
+	require.NoError(t, p.Print([]Context{{Name: "work", Type: "local"}}, nil))
+	require.Equal(t, "WORK local|\n", buf.String())
+
+	_, err = ParseTemplate("{{.Path")
+	require.ErrorContains(t, err, "invalid format")
+	tmpl, err = ParseTemplate("{{.Missing}}")
+	require.NoError(t, err)
+	_, err = tmpl.Render(records[0])
+	require.Error(t, err)
+}
+
+func TestDriveLink(t *testing.T) {
+	require.Equal(t, "", DriveLink("", "text/plain"))
+	require.Equal(t, "https://drive.google.com/file/d/f1/view", DriveLink("f1", "text/plain"))
+	require.Equal(t, "https://docs.google.com/spreadsheets/d/s1/edit", DriveLink("s1", "application/vnd.google-apps.spreadsheet"))
+}
+
+func TestSortRows(t *testing.T) {
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows := []Row{
+		{Name: "b", Size: 10, ModTime: &older},
+		{Name: "c", Size: 300},
+		{Name: "a", Size: 10, ModTime: &newer},
+	}
+	names := func(key SortKey) []string {
+		SortRows(rows, key)
+		out := []string{}
+		for _, r := range rows {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	require.Equal(t, []string{"a", "b", "c"}, names(SortName))
+	require.Equal(t, []string{"c", "a", "b"}, names(SortSize))
+	require.Equal(t, []string{"a", "b", "c"}, names(SortMtime))
+
+	key, err := ParseSortKey("")
+	require.NoError(t, err)
+	require.Equal(t, SortName, key)
+	_, err = ParseSortKey("tags")
+	require.Error(t, err)
+}
+
+func TestWriteTable(t *testing.T) {
+	mtime := time.Date(2025, 3, 1, 10, 0, 0, 0, time.Local)
+	rows := []Row{
+		{Name: "docs", Kind: KindDir},
+		{Name: "docs/report-of-the-year.pdf", Kind: KindFile, Size: 1536, ModTime: &mtime, Tags: []string{"work", "2025"}, Id: "r1",
+			Link: DriveLink("abc", "application/pdf")},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteTable(&buf, rows, 0))
+	text := buf.String()
+	require.Contains(t, text, "NAME")
+	require.Contains(t, text, "docs/report-of-the-year.pdf")
+	require.Contains(t, text, "1.5 KB")
+	require.Contains(t, text, "2025-03-01 10:00")
+	require.Contains(t, text, "work,2025")
+	require.Contains(t, text, "https://drive.google.com/file/d/abc/view")
+
+	buf.Reset()
+	require.NoError(t, WriteTable(&buf, rows, 80))
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		require.LessOrEqual(t, len([]rune(line)), 80, line)
+	}
+	require.Contains(t, buf.String(), "~")
+	require.Contains(t, buf.String(), "1.5 KB")
+}
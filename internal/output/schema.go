@@ -0,0 +1,224 @@
+package output
+
+import (
+	"path"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/services"
+)
+
+// Kinds of nodes and entries.
+const (
+	KindFile    = "file"
+	KindDir     = "dir"
+	KindSymlink = "symlink"
+)
+
+// Node is the schema of a tracked node, printed by track show, tag searchTag, search
+// searchNode and search query. It is also what --format templates of those format.
+type Node struct {
+	// Path is the absolute path of the node, e.g. "/home/me/a.txt" or "gdrive:/Docs".
+	Path string `json:"path" yaml:"path"`
+	// Name is the last element of Path.
+	Name string `json:"name" yaml:"name"`
+	// Kind is "file", "dir" or "symlink".
+	Kind string `json:"kind" yaml:"kind"`
+	// Size in bytes as last scanned, 0 for directories and when unknown.
+	Size int64 `json:"size" yaml:"size"`
+	// ModTime is the modification time as last scanned, null when unknown.
+	ModTime *time.Time `json:"mtime" yaml:"mtime"`
+	Tags    []string   `json:"tags" yaml:"tags"`
+	Id      string     `json:"id" yaml:"id"`
+	// Vanished is set for nodes refresh no longer found.
+	Vanished bool `json:"vanished" yaml:"vanished"`
+	// Target is what a symlink points to.
+	Target string `json:"target" yaml:"target"`
+	// DriveId is the Google Drive file id of Drive nodes.
+	DriveId string `json:"driveId" yaml:"driveId"`
+	// MimeType is the MIME type Drive reported for Drive nodes.
+	MimeType string `json:"mimeType" yaml:"mimeType"`
+	// Link is the web page of Drive nodes, see DriveLink.
+	Link string `json:"link" yaml:"link"`
+	// Context is the context of the node when several were searched.
+	Context string `json:"context,omitempty" yaml:"context,omitempty"`
+}
+
+// NewNode returns the record of a tree node. Directories are the nodes the scan found
+// to be one, and those with children, which older trees did not record.
+func NewNode(node *ds.TreeNode) Node {
+	info, _ := node.Info.(file.NodeInformable)
+	if info == nil {
+		return Node{Kind: KindFile, Tags: []string{}}
+	}
+	n := Node{
+		Path: info.GetAbsPath(),
+		Name: path.Base(info.GetAbsPath()),
+		Kind: KindFile,
+		Tags: append([]string{}, info.GetTags()...),
+		Id:   info.GetId(),
+	}
+	if dir, ok := info.(file.Directory); ok && dir.IsDir() || len(node.Children) > 0 {
+		n.Kind = KindDir
+	}
+	if stated, ok := info.(file.Stated); ok {
+		size, modTime, ok := stated.GetStat()
+		if ok {
+			n.Size = size
+			if !modTime.IsZero() {
+				n.ModTime = &modTime
+			}
+		}
+	}
+	if vanishable, ok := info.(file.Vanishable); ok {
+		n.Vanished = vanishable.IsVanished()
+	}
+	switch x := info.(type) {
+	case *file.SymlinkNode:
+		n.Kind = KindSymlink
+		n.Target = x.Target
+	case *file.FileNode:
+		n.DriveId = x.DriveId
+		n.MimeType = x.MimeType
+		n.Link = DriveLink(x.DriveId, x.MimeType)
+		if x.MimeType == services.DriveFolderMimeType {
+			n.Kind = KindDir
+		}
+	}
+	return n
+}
+
+// Attributes returns the attributes of n that are set among vanished, target and
+// mimeType, by name.
+func (n Node) Attributes() map[string]string {
+	attrs := map[string]string{}
+	if n.Vanished {
+		attrs["vanished"] = "true"
+	}
+	if n.Target != "" {
+		attrs["target"] = n.Target
+	}
+	if n.MimeType != "" {
+		attrs["mimeType"] = n.MimeType
+	}
+	return attrs
+}
+
+// driveEditors are the web editors of the Google Workspace file types by MIME type.
+var driveEditors = map[string]string{
+	"application/vnd.google-apps.document":     "document",
+	"application/vnd.google-apps.spreadsheet":  "spreadsheets",
+	"application/vnd.google-apps.presentation": "presentation",
+}
+
+// DriveLink returns the web page of the Drive file with the given id and MIME type,
+// "" without an id. It is what Drive reports as webViewLink, without asking it.
+func DriveLink(id, mimeType string) string {
+	if id == "" {
+		return ""
+	}
+	if mimeType == services.DriveFolderMimeType {
+		return "https://drive.google.com/drive/folders/" + id
+	}
+	if editor, ok := driveEditors[mimeType]; ok {
+		return "https://docs.google.com/" + editor + "/d/" + id + "/edit"
+	}
+	return "https://drive.google.com/file/d/" + id + "/view"
+}
+
+// NewNodes returns the records of nodes.
+func NewNodes(nodes []*ds.TreeNode) []Node {
+	records := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		records = append(records, NewNode(node))
+	}
+	return records
+}
+
+// SubtreeNodes returns root and the nodes below it, parents first, but for the tree
+// root of a context with named roots.
+func SubtreeNodes(root *ds.TreeNode) ([]*ds.TreeNode, error) {
+	found := []*ds.TreeNode{}
+	it := ds.NewTreeIterator(ds.NewTreeManager(root))
+	for it.HasNext() {
+		n, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if info, ok := n.Info.(file.NodeInformable); ok && info.GetAbsPath() == file.RootsPath {
+			continue
+		}
+		found = append(found, n)
+	}
+	return found, nil
+}
+
+// Entry is the schema of a directory entry, printed by ls, gdrive ls and gdrive list.
+type Entry struct {
+	Name string `json:"name" yaml:"name"`
+	// Path is the path of the entry, e.g. "/Docs/a.txt" on Drive.
+	Path string `json:"path" yaml:"path"`
+	// Kind is "file" or "dir".
+	Kind string `json:"kind" yaml:"kind"`
+	// Size in bytes, 0 for directories and when unknown.
+	Size int64 `json:"size" yaml:"size"`
+	// ModTime is the modification time, null when unknown.
+	ModTime  *time.Time `json:"mtime" yaml:"mtime"`
+	MimeType string     `json:"mimeType" yaml:"mimeType"`
+	// DriveId is the Google Drive file id, empty elsewhere.
+	DriveId string `json:"driveId" yaml:"driveId"`
+}
+
+// NewRemoteEntry returns the record of e, listed in dir of Drive or a WebDAV server.
+func NewRemoteEntry(dir string, e services.RootEntry) Entry {
+	entry := Entry{
+		Name:     e.Name,
+		Path:     path.Join(dir, e.Name),
+		Kind:     KindFile,
+		Size:     e.Size,
+		MimeType: e.MimeType,
+		DriveId:  e.Id,
+	}
+	if e.IsFolder {
+		entry.Kind = KindDir
+	}
+	if !e.ModifiedTime.IsZero() {
+		modTime := e.ModifiedTime
+		entry.ModTime = &modTime
+	}
+	return entry
+}
+
+// NewNodeEntry returns the entry of a tracked node.
+func NewNodeEntry(node *ds.TreeNode) Entry {
+	n := NewNode(node)
+	kind := n.Kind
+	if kind == KindSymlink {
+		kind = KindFile
+	}
+	return Entry{
+		Name:    n.Name,
+		Path:    n.Path,
+		Kind:    kind,
+		Size:    n.Size,
+		ModTime: n.ModTime,
+		DriveId: n.DriveId,
+	}
+}
+
+// Context is the schema of a context, printed by context list.
+type Context struct {
+	Name string `json:"name" yaml:"name"`
+	// Type is "local", "gdrive", "webdav" or "sftp".
+	Type string `json:"type" yaml:"type"`
+	// Current is set for the current context.
+	Current bool `json:"current" yaml:"current"`
+}
+
+// IdRecord is the schema of the id of a node, printed by id get.
+type IdRecord struct {
+	Path string `json:"path" yaml:"path"`
+	// Id is empty when the node has none.
+	Id string `json:"id" yaml:"id"`
+}
@@ -0,0 +1,199 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// Row is a line of a long listing, printed by track show --long and ls -l.
+type Row struct {
+	// Name is what the row is listed by, e.g. the path below the directory shown.
+	Name    string
+	Kind    string
+	Size    int64
+	ModTime *time.Time
+	Tags    []string
+	Id      string
+	// Link is the web page of Drive files.
+	Link string
+}
+
+// NodeRow returns the row of a node record listed by name.
+func NodeRow(name string, n Node) Row {
+	return Row{Name: name, Kind: n.Kind, Size: n.Size, ModTime: n.ModTime, Tags: n.Tags, Id: n.Id, Link: n.Link}
+}
+
+// EntryRow returns the row of an entry record, listed by its name.
+func EntryRow(e Entry) Row {
+	return Row{Name: e.Name, Kind: e.Kind, Size: e.Size, ModTime: e.ModTime, Link: DriveLink(e.DriveId, e.MimeType)}
+}
+
+// SortKey is the column a long listing is sorted by.
+type SortKey string
+
+const (
+	// SortName sorts by name, so a path comes right before the paths below it.
+	SortName SortKey = "name"
+	// SortSize sorts the largest first, as ls -S.
+	SortSize SortKey = "size"
+	// SortMtime sorts the most recently modified first, as ls -t. Rows without a
+	// modification time come last.
+	SortMtime SortKey = "mtime"
+)
+
+// ParseSortKey returns the sort key named s, SortName when s is empty.
+func ParseSortKey(s string) (SortKey, error) {
+	switch SortKey(s) {
+	case "":
+		return SortName, nil
+	case SortName, SortSize, SortMtime:
+		return SortKey(s), nil
+	}
+	return "", fmt.Errorf("invalid sort key %q, expected name, size or mtime", s)
+}
+
+// SortRows sorts rows by key, ties by name.
+func SortRows(rows []Row, key SortKey) {
+	slices.SortStableFunc(rows, func(a, b Row) int {
+		switch key {
+		case SortSize:
+			if a.Size != b.Size {
+				if a.Size > b.Size {
+					return -1
+				}
+				return 1
+			}
+		case SortMtime:
+			switch {
+			case a.ModTime == nil && b.ModTime != nil:
+				return 1
+			case a.ModTime != nil && b.ModTime == nil:
+				return -1
+			case a.ModTime != nil && !a.ModTime.Equal(*b.ModTime):
+				return b.ModTime.Compare(*a.ModTime)
+			}
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// tableHeader are the columns of a long listing.
+var tableHeader = table.Row{"NAME", "KIND", "SIZE", "MODIFIED", "TAGS", "ID", "LINK"}
+
+// Columns of tableHeader cut short first when a listing is wider than the terminal.
+var shrinkableColumns = []int{0, 4, 6}
+
+// minColumnWidth is how narrow shrinkable columns get, wider headers excepted.
+const minColumnWidth = 8
+
+// columnPadding is the padding of every column of the table style.
+const columnPadding = 2
+
+// WriteTable writes rows as a table with a column per field of Row. Sizes are human
+// readable and directories have none. When width is positive the name, tags and link
+// columns are cut short, ending in "~", until lines fit in width characters.
+func WriteTable(w io.Writer, rows []Row, width int) error {
+	cells := make([][]string, 0, len(rows))
+	for _, r := range rows {
+		size := ""
+		if r.Kind != KindDir {
+			size = HumanSize(r.Size)
+		}
+		modTime := ""
+		if r.ModTime != nil {
+			modTime = r.ModTime.Local().Format("2006-01-02 15:04")
+		}
+		cells = append(cells, []string{r.Name, r.Kind, size, modTime, strings.Join(r.Tags, ","), r.Id, r.Link})
+	}
+
+	tw := table.NewWriter()
+	tw.SetOutputMirror(w)
+	tw.SetStyle(table.StyleLight)
+	tw.Style().Options = table.OptionsNoBordersAndSeparators
+	tw.Style().Options.SeparateHeader = true
+	tw.Style().Format.Header = text.FormatDefault
+	tw.AppendHeader(tableHeader)
+	for _, row := range cells {
+		tr := make(table.Row, len(row))
+		for i, c := range row {
+			tr[i] = c
+		}
+		tw.AppendRow(tr)
+	}
+
+	configs := []table.ColumnConfig{{Number: 3, Align: text.AlignRight}}
+	for col, widthMax := range fitColumns(cells, width) {
+		configs = append(configs, table.ColumnConfig{Number: col + 1, WidthMax: widthMax, WidthMaxEnforcer: truncate})
+	}
+	tw.SetColumnConfigs(configs)
+	tw.Render()
+	return nil
+}
+
+// fitColumns returns the widths to cut the shrinkable columns of cells to so that
+// lines fit in width, by column. The widest of them is narrowed first.
+func fitColumns(cells [][]string, width int) map[int]int {
+	widths := make([]int, len(tableHeader))
+	for i, h := range tableHeader {
+		widths[i] = text.RuneWidthWithoutEscSequences(h.(string))
+	}
+	for _, row := range cells {
+		for i, c := range row {
+			widths[i] = max(widths[i], text.RuneWidthWithoutEscSequences(c))
+		}
+	}
+
+	fitted := map[int]int{}
+	if width <= 0 {
+		return fitted
+	}
+	total := 0
+	for _, w := range widths {
+		total += w + columnPadding
+	}
+	for total > width {
+		widest := -1
+		for _, col := range shrinkableColumns {
+			floor := max(minColumnWidth, len(tableHeader[col].(string)))
+			if widths[col] > floor && (widest < 0 || widths[col] > widths[widest]) {
+				widest = col
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		widths[widest]--
+		total--
+		fitted[widest] = widths[widest]
+	}
+	return fitted
+}
+
+// truncate cuts text to maxLen characters, the last being "~".
+func truncate(s string, maxLen int) string {
+	if text.RuneWidthWithoutEscSequences(s) <= maxLen {
+		return s
+	}
+	return text.Trim(s, maxLen-1) + "~"
+}
+
+// TerminalWidth returns the number of columns of the terminal f writes to, else that
+// of the COLUMNS environment variable, 0 when neither is known.
+func TerminalWidth(f *os.File) int {
+	if width, ok := terminalColumns(f); ok {
+		return width
+	}
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width < 0 {
+		return 0
+	}
+	return width
+}
@@ -0,0 +1,108 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template formats records with a text/template, e.g. '{{.Path}}\t{{join .Tags ","}}',
+// the fields being those of the schema of the records. The escapes \t and \n of the
+// format stand for a tab and a newline, and keys missing from maps, such as
+// .Attributes.target, are empty. Besides the built-in functions of text/template
+// it can call:
+//
+//	join LIST SEP       the elements of LIST separated by SEP
+//	hasTag NODE TAG     whether the node has the tag
+//	humanSize SIZE      a size in bytes as 1.5 MB
+//	time LAYOUT TIME    a time in a layout of package time, e.g. "2006-01-02", "" for null
+//	pad WIDTH TEXT      TEXT padded with spaces to WIDTH characters
+//	upper, lower TEXT   TEXT in upper or lower case
+//	json VALUE          VALUE as JSON
+type Template struct {
+	tmpl *template.Template
+}
+
+// Funcs are the functions templates can call besides the built-in ones.
+var Funcs = template.FuncMap{
+	"join": strings.Join,
+	"hasTag": func(n Node, tag string) bool {
+		return slices.Contains(n.Tags, tag)
+	},
+	"humanSize": HumanSize,
+	"time": func(layout string, t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(layout)
+	},
+	"pad": func(width int, s string) string {
+		return fmt.Sprintf("%-*s", width, s)
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseTemplate parses format, see Template.
+func ParseTemplate(format string) (*Template, error) {
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	tmpl, err := template.New("format").Funcs(Funcs).Option("missingkey=zero").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format: %w", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Render returns the text of record.
+func (t *Template) Render(record any) (string, error) {
+	var b strings.Builder
+	err := t.tmpl.Execute(&b, record)
+	if err != nil {
+		return "", fmt.Errorf("format: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Write writes the text of every record of records, a slice, on a line of its own.
+func (t *Template) Write(w io.Writer, records any) error {
+	v := reflect.ValueOf(records)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("records must be a slice, got %T", records)
+	}
+	for i := 0; i < v.Len(); i++ {
+		text, err := t.Render(v.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HumanSize returns size in bytes in the largest unit it reaches, e.g. 1.5 MB. Units
+// are powers of 1024, as in size conditions of queries.
+func HumanSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	f := float64(size)
+	i := 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", f, units[i])
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package output
+
+import "os"
+
+// terminalColumns does not know terminal widths outside of Unix systems.
+func terminalColumns(f *os.File) (int, bool) {
+	return 0, false
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/heroku/self/MetaManager/internal/ds"
 	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/output"
 
 	"github.com/jedib0t/go-pretty/v6/list"
 )
@@ -93,6 +94,46 @@ type PrintingContext interface {
 	GetPrinter(info any) (file.PrinterFunc, error)
 }
 
+// TreePrintingContext is a PrintingContext that prints a node from the tree node,
+// e.g. to tell directories by their children. TrPrintV2 prefers GetTreePrinter.
+type TreePrintingContext interface {
+	PrintingContext
+	GetTreePrinter(node *ds.TreeNode) (file.PrinterFunc, error)
+}
+
+// TemplatePrinter prints every node as the text of a template executed on its
+// output.Node.
+type TemplatePrinter struct {
+	tmpl *output.Template
+}
+
+func NewTemplatePrinter(tmpl *output.Template) *TemplatePrinter {
+	return &TemplatePrinter{
+		tmpl: tmpl,
+	}
+}
+
+func (tp *TemplatePrinter) GetPrinter(info any) (file.PrinterFunc, error) {
+	nodeInfo, ok := info.(ds.TreeNodeInformable)
+	if !ok {
+		return nil, errors.New("info not convertible to TreeNodeInformable")
+	}
+	return tp.GetTreePrinter(ds.NewTreeNode(nodeInfo))
+}
+
+func (tp *TemplatePrinter) GetTreePrinter(node *ds.TreeNode) (file.PrinterFunc, error) {
+	return func(wr list.Writer) error {
+		str, err := tp.tmpl.Render(output.NewNode(node))
+		if err != nil {
+			return err
+		}
+
+		wr.AppendItem(str)
+
+		return nil
+	}, nil
+}
+
 func (mg *TreePrinterManager) TrPrintV2(prContexts []PrintingContext) error {
 	err := mg.trPrint2(prContexts, mg.trMg.Root)
 	if err != nil {
@@ -105,11 +146,28 @@ func (mg *TreePrinterManager) TrPrintV2(prContexts []PrintingContext) error {
 	return nil
 }
 
-func getPrinter2(prContexts []PrintingContext, info any) (func(list.Writer) error, error) {
+// TrRenderMarkdown returns the tree as a nested Markdown list instead of printing it,
+// every node appended by prContexts as in TrPrintV2.
+func (mg *TreePrinterManager) TrRenderMarkdown(prContexts []PrintingContext) (string, error) {
+	err := mg.trPrint2(prContexts, mg.trMg.Root)
+	if err != nil {
+		return "", err
+	}
+
+	return mg.wr.RenderMarkdown(), nil
+}
+
+func getPrinter2(prContexts []PrintingContext, node *ds.TreeNode) (func(list.Writer) error, error) {
 	var builder file.NodePrinterBuilder
 
 	for _, pr := range prContexts {
-		curPrinter, err := pr.GetPrinter(info)
+		var curPrinter file.PrinterFunc
+		var err error
+		if trPr, ok := pr.(TreePrintingContext); ok {
+			curPrinter, err = trPr.GetTreePrinter(node)
+		} else {
+			curPrinter, err = pr.GetPrinter(node.Info)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -120,7 +178,7 @@ func getPrinter2(prContexts []PrintingContext, info any) (func(list.Writer) erro
 }
 
 func (pr *TreePrinterManager) trPrint2(prContexts []PrintingContext, curNode *ds.TreeNode) error {
-	printFunc, err := getPrinter2(prContexts, curNode.Info)
+	printFunc, err := getPrinter2(prContexts, curNode)
 	if err != nil {
 		return err
 	}
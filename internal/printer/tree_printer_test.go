@@ -0,0 +1,31 @@
+package printer
+
+import (
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/jedib0t/go-pretty/v6/list"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplatePrinter(t *testing.T) {
+	child := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h/a.txt", Tags: []string{"x"}, Size: 2048}})
+	root := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h"}})
+	root.Children = []*ds.TreeNode{child}
+
+	tmpl, err := output.ParseTemplate(`{{.Name}} {{.Kind}} {{humanSize .Size}} {{join .Tags ","}}`)
+	require.NoError(t, err)
+	pr := NewTreePrinterManager(ds.NewTreeManager(root))
+	require.NoError(t, pr.trPrint2([]PrintingContext{NewTemplatePrinter(tmpl)}, root))
+	pr.wr.SetStyle(list.StyleConnectedLight)
+	require.Equal(t, "── h dir 0 B \n   └─ a.txt file 2.0 KB x", pr.wr.Render())
+
+	// Without the tree node, the kind comes from the info alone.
+	printFunc, err := NewTemplatePrinter(tmpl).GetPrinter(root.Info)
+	require.NoError(t, err)
+	wr := list.NewWriter()
+	require.NoError(t, printFunc(wr))
+	require.Equal(t, "* h file 0 B ", wr.Render())
+}
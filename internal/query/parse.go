@@ -0,0 +1,268 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError reports where a query stops making sense.
+type SyntaxError struct {
+	Query string
+	// Pos is the byte offset of the offending token
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid query: %s at column %d\n  %s\n  %s^", e.Msg, e.Pos+1, e.Query, strings.Repeat(" ", e.Pos))
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return fmt.Sprintf("%q", t.text)
+	}
+	return "\"" + t.text + "\""
+}
+
+// operators, longest first so that ">=" is not read as ">".
+var operators = []string{">=", "<=", "!=", ":", "=", "~", ">", "<"}
+
+const opChars = ":=~<>!"
+
+// lex splits src into tokens. The token after an operator is a value and runs up to
+// the next space or ")", so values like "2025-01-01" or "*.tar.gz" need no quotes.
+func lex(src string) ([]token, error) {
+	tokens := []token{}
+	afterOp := false
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+			continue
+		case c == '"':
+			text, next, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: text, pos: i})
+			i = next
+		case afterOp:
+			start := i
+			for i < len(src) && !unicode.IsSpace(rune(src[i])) && src[i] != ')' {
+				i++
+			}
+			if start == i {
+				return nil, &SyntaxError{Query: src, Pos: start, Msg: "missing value"}
+			}
+			tokens = append(tokens, token{kind: tokWord, text: src[start:i], pos: start})
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case strings.IndexByte(opChars, c) != -1:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{Query: src, Pos: i, Msg: fmt.Sprintf("unknown operator %q", string(c))}
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+			afterOp = true
+			continue
+		default:
+			start := i
+			for i < len(src) && !unicode.IsSpace(rune(src[i])) && strings.IndexByte(opChars+"()\"", src[i]) == -1 {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: src[start:i], pos: start})
+		}
+		afterOp = false
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(src)})
+	return tokens, nil
+}
+
+// lexString reads the quoted string starting at src[start]. A backslash escapes the
+// next character.
+func lexString(src string, start int) (string, int, error) {
+	var sb strings.Builder
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if i+1 < len(src) {
+				i++
+				sb.WriteByte(src[i])
+			}
+		case '"':
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(src[i])
+		}
+	}
+	return "", 0, &SyntaxError{Query: src, Pos: start, Msg: "unterminated string"}
+}
+
+// parser is a recursive descent parser over:
+//
+//	or      = and { "OR" and }
+//	and     = unary { ["AND"] unary }
+//	unary   = "NOT" unary | primary
+//	primary = "(" or ")" | field op value | word
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorAt(t token, format string, args ...any) error {
+	return &SyntaxError{Query: p.src, Pos: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isKeyword(t token, keyword string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind == tokEOF || t.kind == tokRParen || isKeyword(t, "OR") {
+			return left, nil
+		}
+		if isKeyword(t, "AND") {
+			p.next()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if isKeyword(p.peek(), "NOT") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorAt(closing, "expected \")\" to close the \"(\" at column %d, found %s", t.pos+1, closing.describe())
+		}
+		return inner, nil
+	case t.kind == tokEOF:
+		return nil, p.errorAt(t, "expected a condition, found end of query")
+	case t.kind == tokRParen || t.kind == tokOp:
+		return nil, p.errorAt(t, "expected a condition, found %s", t.describe())
+	case isKeyword(t, "AND") || isKeyword(t, "OR"):
+		return nil, p.errorAt(t, "expected a condition before %s", t.describe())
+	}
+
+	if p.peek().kind != tokOp {
+		// A lone word or string matches names containing it.
+		return wordExpr{strings.ToLower(t.text)}, nil
+	}
+	if t.kind != tokWord {
+		return nil, p.errorAt(t, "expected a field name, found %s", t.describe())
+	}
+	op := p.next()
+	value := p.next()
+	if value.kind != tokWord && value.kind != tokString {
+		return nil, p.errorAt(value, "expected a value after %s, found %s", op.describe(), value.describe())
+	}
+	return newCondition(p, t, op, value)
+}
+
+// parse parses src into its expression tree.
+func parse(src string) (expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, p.errorAt(p.peek(), "empty query")
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorAt(t, "unexpected %s", t.describe())
+	}
+	return e, nil
+}
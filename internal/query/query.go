@@ -0,0 +1,361 @@
+// Package query implements the search query language, e.g.
+//
+//	tag:acme AND (ext:pdf OR ext:docx) AND NOT tag:archived AND size>1MB AND modified<30d
+//
+// Conditions are field, operator and value. AND binds tighter than OR, NOT tighter
+// than both, parentheses group, and conditions next to each other are ANDed. A lone
+// word matches nodes whose name contains it.
+package query
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/index"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// Fields lists the fields a condition can test, for help messages.
+var Fields = []string{"tag", "id", "name", "ext", "path", "kind", "target", "mimetype", "vanished", "size", "modified"}
+
+// attributeFields are the fields read from the node record of --output, by the
+// attribute they test; kind is the record kind, the others its attributes.
+var attributeFields = map[string]string{
+	"kind":     "kind",
+	"target":   "target",
+	"mimetype": "mimeType",
+	"vanished": "vanished",
+}
+
+// now is the time ages are measured from, replaceable in tests.
+var now = time.Now
+
+// Query is a parsed query, ready to be matched against nodes.
+type Query struct {
+	src  string
+	root expr
+}
+
+// Parse parses src. Errors are *SyntaxError pointing at the offending part.
+func Parse(src string) (*Query, error) {
+	root, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+func (q *Query) String() string {
+	return q.src
+}
+
+// Match reports whether info satisfies the query.
+func (q *Query) Match(info file.NodeInformable) bool {
+	return q.root.match(info)
+}
+
+var _ data.NodeMatcher = (*Query)(nil)
+
+type expr interface {
+	match(file.NodeInformable) bool
+}
+
+type andExpr struct{ left, right expr }
+type orExpr struct{ left, right expr }
+type notExpr struct{ operand expr }
+
+// wordExpr matches names containing a lowercase word.
+type wordExpr struct{ word string }
+
+func (e andExpr) match(info file.NodeInformable) bool {
+	return e.left.match(info) && e.right.match(info)
+}
+
+func (e orExpr) match(info file.NodeInformable) bool {
+	return e.left.match(info) || e.right.match(info)
+}
+
+func (e notExpr) match(info file.NodeInformable) bool {
+	return !e.operand.match(info)
+}
+
+func (e wordExpr) match(info file.NodeInformable) bool {
+	return strings.Contains(strings.ToLower(nodeName(info)), e.word)
+}
+
+// condition tests one field of a node.
+type condition func(file.NodeInformable) bool
+
+func (c condition) match(info file.NodeInformable) bool {
+	return c(info)
+}
+
+// termCondition is a condition that only nodes indexed under all of its terms can
+// satisfy.
+type termCondition struct {
+	condition
+	terms []string
+}
+
+// indexTerms returns the index terms every node satisfying "field op value" is
+// indexed under, nil when the condition cannot be looked up in the index.
+func indexTerms(field, op, value string) []string {
+	if op != ":" && op != "=" {
+		return nil
+	}
+	var terms []string
+	switch {
+	case field == "path":
+		// Literal elements of a path glob are elements of the paths it matches.
+		for _, seg := range strings.Split(value, "/") {
+			if !strings.ContainsAny(seg, `*?[\`) {
+				for _, token := range index.Tokens(seg) {
+					terms = append(terms, index.Term(index.KindPath, token))
+				}
+			}
+		}
+	case strings.ContainsAny(value, "*?["):
+	case field == "tag":
+		terms = []string{index.Term(index.KindTag, value)}
+	case field == "id":
+		terms = []string{index.Term(index.KindId, value)}
+	case field == "name", field == "ext":
+		for _, token := range index.Tokens(value) {
+			terms = append(terms, index.Term(index.KindPath, token))
+		}
+	}
+	return terms
+}
+
+// Candidates returns the sorted paths of the nodes of ix that may match, looked up by
+// the tags, ids and path elements the query requires. ok is false when it requires
+// none, so that every node has to be tested.
+func (q *Query) Candidates(ix *index.Index) (paths []string, ok bool) {
+	return candidates(q.root, ix)
+}
+
+func candidates(e expr, ix *index.Index) ([]string, bool) {
+	switch x := e.(type) {
+	case andExpr:
+		left, okLeft := candidates(x.left, ix)
+		right, okRight := candidates(x.right, ix)
+		switch {
+		case okLeft && okRight:
+			return index.Intersect(left, right), true
+		case okLeft:
+			return left, true
+		}
+		return right, okRight
+	case orExpr:
+		left, okLeft := candidates(x.left, ix)
+		right, okRight := candidates(x.right, ix)
+		if okLeft && okRight {
+			return index.Union(left, right), true
+		}
+	case termCondition:
+		if len(x.terms) == 0 {
+			return nil, false
+		}
+		paths := ix.Lookup(x.terms[0])
+		for _, term := range x.terms[1:] {
+			paths = index.Intersect(paths, ix.Lookup(term))
+		}
+		return paths, true
+	}
+	return nil, false
+}
+
+// newCondition builds the condition "field op value".
+func newCondition(p *parser, field, op, value token) (expr, error) {
+	name := strings.ToLower(field.text)
+	switch name {
+	case "tag", "id", "name", "ext", "path", "kind", "target", "mimetype", "vanished":
+		test, err := stringTest(p, name, op, value)
+		if err != nil {
+			return nil, err
+		}
+		return termCondition{condition: condition(func(info file.NodeInformable) bool {
+			for _, v := range stringValues(name, info) {
+				if test(v) {
+					return op.text != "!="
+				}
+			}
+			return op.text == "!="
+		}), terms: indexTerms(name, op.text, value.text)}, nil
+	case "size":
+		size, err := utils.ParseSize(value.text)
+		if err != nil {
+			return nil, p.errorAt(value, "%v", err)
+		}
+		cmp, err := compareOp(p, name, op)
+		if err != nil {
+			return nil, err
+		}
+		return condition(func(info file.NodeInformable) bool {
+			got, _, ok := stat(info)
+			return ok && cmp(got, size)
+		}), nil
+	case "modified":
+		return modifiedCondition(p, name, op, value)
+	}
+	return nil, p.errorAt(field, "unknown field %q, expected one of %s", field.text, strings.Join(Fields, ", "))
+}
+
+// stringTest returns the test of one value of a text field. ":" and "=" compare,
+// or match a glob when the value has glob characters, "~" matches a regular
+// expression; "!=" negates the comparison for all values of the field.
+func stringTest(p *parser, field string, op, value token) (func(string) bool, error) {
+	want := value.text
+	fold := field == "ext"
+	if fold {
+		want = strings.ToLower(strings.TrimPrefix(want, "."))
+	}
+	switch op.text {
+	case ":", "=", "!=":
+		if !strings.ContainsAny(want, "*?[") {
+			return func(v string) bool { return v == want }, nil
+		}
+		if field == "path" {
+			g, err := data.CompileGlob(want)
+			if err != nil {
+				return nil, p.errorAt(value, "%v", err)
+			}
+			return g.Match, nil
+		}
+		if _, err := path.Match(want, ""); err != nil {
+			return nil, p.errorAt(value, "invalid pattern %q: %v", value.text, err)
+		}
+		return func(v string) bool {
+			ok, _ := path.Match(want, v)
+			return ok
+		}, nil
+	case "~":
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, p.errorAt(value, "invalid regular expression: %v", err)
+		}
+		return re.MatchString, nil
+	}
+	return nil, p.errorAt(op, "operator %q does not apply to %s, use \":\", \"!=\" or \"~\"", op.text, field)
+}
+
+// stringValues returns the values of a text field of info; tags may have several.
+func stringValues(field string, info file.NodeInformable) []string {
+	if attr, ok := attributeFields[field]; ok {
+		return attributeValues(attr, info)
+	}
+	switch field {
+	case "tag":
+		return info.GetTags()
+	case "id":
+		if info.GetId() == "" {
+			return nil
+		}
+		return []string{info.GetId()}
+	case "name":
+		return []string{nodeName(info)}
+	case "ext":
+		ext := path.Ext(nodeName(info))
+		if ext == "" {
+			return nil
+		}
+		return []string{strings.ToLower(ext[1:])}
+	}
+	return []string{info.GetAbsPath()}
+}
+
+// attributeValues returns the kind of the node record of info, or the value of one
+// of its attributes, see output.Node.Attributes.
+func attributeValues(attr string, info file.NodeInformable) []string {
+	treeInfo, ok := info.(ds.TreeNodeInformable)
+	if !ok {
+		return nil
+	}
+	n := output.NewNode(ds.NewTreeNode(treeInfo))
+	if attr == "kind" {
+		return []string{n.Kind}
+	}
+	if v, ok := n.Attributes()[attr]; ok {
+		return []string{v}
+	}
+	return nil
+}
+
+// nodeName is the last element of the node path.
+func nodeName(info file.NodeInformable) string {
+	p := strings.TrimSuffix(info.GetAbsPath(), "/")
+	return p[strings.LastIndex(p, "/")+1:]
+}
+
+// stat returns what was recorded of the node file. ok is false for nodes without it.
+func stat(info file.NodeInformable) (int64, time.Time, bool) {
+	st, ok := info.(file.Stated)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	return st.GetStat()
+}
+
+// compareOp returns the comparison an operator stands for.
+func compareOp(p *parser, field string, op token) (func(a, b int64) bool, error) {
+	switch op.text {
+	case ":", "=":
+		return func(a, b int64) bool { return a == b }, nil
+	case "!=":
+		return func(a, b int64) bool { return a != b }, nil
+	case ">":
+		return func(a, b int64) bool { return a > b }, nil
+	case ">=":
+		return func(a, b int64) bool { return a >= b }, nil
+	case "<":
+		return func(a, b int64) bool { return a < b }, nil
+	case "<=":
+		return func(a, b int64) bool { return a <= b }, nil
+	}
+	return nil, p.errorAt(op, "operator %q does not apply to %s", op.text, field)
+}
+
+// modifiedCondition compares modification times with an age, where "modified<30d"
+// means less than 30 days ago, or with a date, where "modified<2025-01-01" means
+// before that day and "modified:2025-01-01" during it.
+func modifiedCondition(p *parser, field string, op, value token) (expr, error) {
+	from, to, age, err := utils.ParseTimeRange(value.text, now())
+	if err != nil {
+		return nil, p.errorAt(value, "%v", err)
+	}
+	if age {
+		// A younger file was modified after the point in time.
+		reversed, ok := map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}[op.text]
+		if !ok {
+			return nil, p.errorAt(op, "compare ages with \"<\" or \">\", e.g. %s<%s", field, value.text)
+		}
+		op.text = reversed
+	}
+	var test func(time.Time) bool
+	switch op.text {
+	case ":", "=":
+		test = func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	case "!=":
+		test = func(t time.Time) bool { return t.Before(from) || !t.Before(to) }
+	case ">":
+		test = func(t time.Time) bool { return !t.Before(to) }
+	case ">=":
+		test = func(t time.Time) bool { return !t.Before(from) }
+	case "<":
+		test = func(t time.Time) bool { return t.Before(from) }
+	case "<=":
+		test = func(t time.Time) bool { return t.Before(to) }
+	default:
+		return nil, p.errorAt(op, "operator %q does not apply to %s", op.text, field)
+	}
+	return condition(func(info file.NodeInformable) bool {
+		_, modTime, ok := stat(info)
+		return ok && !modTime.IsZero() && test(modTime)
+	}), nil
+}
@@ -0,0 +1,148 @@
+package query
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/index"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryMatch(t *testing.T) {
+	fixed := time.Date(2025, 6, 30, 12, 0, 0, 0, time.Local)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	node := func(p string, size int64, age time.Duration, tags ...string) file.NodeInformable {
+		return &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: p, Tags: tags, Size: size, ModTime: fixed.Add(-age).Unix()}}
+	}
+	report := node("/r/reports/q1.PDF", 3<<20, 10*24*time.Hour, "acme")
+	archived := node("/r/reports/q0.pdf", 3<<20, 10*24*time.Hour, "acme", "archived")
+	small := node("/r/reports/notes.docx", 100, 24*time.Hour, "acme")
+	old := node("/r/other/q1.pdf", 3<<20, 90*24*time.Hour, "acme")
+	dir := &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/r/reports", Id: "rep"}}
+
+	tests := []struct {
+		query   string
+		matches []file.NodeInformable
+	}{
+		{`tag:acme AND (ext:pdf OR ext:docx) AND NOT tag:archived AND size>1MB AND modified<30d AND path~"reports/"`, []file.NodeInformable{report}},
+		{`tag:acme ext:.docx`, []file.NodeInformable{small}},
+		{`tag:acme AND ext:pdf OR id:rep`, []file.NodeInformable{report, archived, old, dir}},
+		{`NOT tag:acme`, []file.NodeInformable{dir}},
+		{`tag!=archived AND size<=100`, []file.NodeInformable{small}},
+		{`modified>60d`, []file.NodeInformable{old}},
+		{`modified>=2025-06-29`, []file.NodeInformable{small}},
+		{`modified:2025-06-20`, []file.NodeInformable{report, archived}},
+		{`name:q?.* path:/r/reports/*`, []file.NodeInformable{report, archived}},
+		{`path:/r/**/*.pdf`, []file.NodeInformable{archived, old}},
+		{`tag~^arch`, []file.NodeInformable{archived}},
+		{`NOTES`, []file.NodeInformable{small}},
+		{`"q1" size>=3MB`, []file.NodeInformable{report, old}},
+	}
+	all := []file.NodeInformable{report, archived, small, old, dir}
+	tree := ds.NewTreeNode(dir)
+	for _, n := range all[:4] {
+		tree.AddChild(ds.NewTreeNode(n.(ds.TreeNodeInformable)))
+	}
+	ix, err := index.Build(tree)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		require.NoError(t, err, tt.query)
+		matches := []file.NodeInformable{}
+		for _, n := range all {
+			if q.Match(n) {
+				matches = append(matches, n)
+			}
+		}
+		require.Equal(t, tt.matches, matches, tt.query)
+		// The index never leaves out a match.
+		if candidates, ok := q.Candidates(ix); ok {
+			for _, n := range matches {
+				require.Contains(t, candidates, n.GetAbsPath(), tt.query)
+			}
+		}
+	}
+
+	candidates := func(query string) ([]string, bool) {
+		q, err := Parse(query)
+		require.NoError(t, err)
+		return q.Candidates(ix)
+	}
+	paths, ok := candidates(`tag:archived OR id:rep`)
+	require.True(t, ok)
+	require.Equal(t, []string{"/r/reports", "/r/reports/q0.pdf"}, paths)
+	paths, ok = candidates(`path:/r/other/* AND size>1MB`)
+	require.True(t, ok)
+	require.Equal(t, []string{"/r/other/q1.pdf"}, paths)
+	_, ok = candidates(`NOT tag:acme`)
+	require.False(t, ok)
+	_, ok = candidates(`tag:acme OR size>1MB`)
+	require.False(t, ok)
+}
+
+func TestQueryAttributes(t *testing.T) {
+	mount := file.NewSymlinkNode("/h/usb", "/mnt/usb", nil)
+	docs := &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h/docs", Dir: true}}
+	gone := &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/h/gone.txt", Vanished: true}}
+	photo := &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "gdrive:/trip.jpg"}, DriveId: "d1", MimeType: "image/jpeg"}
+	all := []file.NodeInformable{mount, docs, gone, photo}
+
+	tests := []struct {
+		query   string
+		matches []file.NodeInformable
+	}{
+		{`target~"^/mnt/"`, []file.NodeInformable{mount}},
+		{`kind:dir`, []file.NodeInformable{docs}},
+		{`kind!=file`, []file.NodeInformable{mount, docs}},
+		{`vanished:true`, []file.NodeInformable{gone}},
+		{`mimetype:image/*`, []file.NodeInformable{photo}},
+		{`NOT target~"."`, []file.NodeInformable{docs, gone, photo}},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		require.NoError(t, err, tt.query)
+		matches := []file.NodeInformable{}
+		for _, n := range all {
+			if q.Match(n) {
+				matches = append(matches, n)
+			}
+		}
+		require.Equal(t, tt.matches, matches, tt.query)
+	}
+}
+
+func TestQuerySyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{``, 0, "empty query"},
+		{`tag:acme AND`, 12, "expected a condition"},
+		{`(tag:a OR tag:b`, 15, `expected ")"`},
+		{`tga:acme`, 0, `unknown field "tga"`},
+		{`size>big`, 5, "invalid size"},
+		{`tag>a`, 3, `operator ">" does not apply to tag`},
+		{`modified:30d`, 8, "compare ages"},
+		{`modified<yesterday`, 9, "invalid time"},
+		{`path~"("`, 5, "invalid regular expression"},
+		{`name:"unterminated`, 5, "unterminated string"},
+		{`tag:a )`, 6, `unexpected ")"`},
+		{`OR tag:a`, 0, "expected a condition before"},
+		{`tag:`, 4, "expected a value"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		var syntaxErr *SyntaxError
+		require.True(t, errors.As(err, &syntaxErr), "%s: %v", tt.query, err)
+		require.Equal(t, tt.pos, syntaxErr.Pos, tt.query)
+		require.Contains(t, syntaxErr.Msg, tt.msg, tt.query)
+	}
+	_, err := Parse(`size>big`)
+	require.Contains(t, err.Error(), "\n  size>big\n       ^")
+}
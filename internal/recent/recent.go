@@ -0,0 +1,68 @@
+// Package recent remembers which nodes were used lately, e.g. jumped to, so that
+// searches can rank them higher.
+package recent
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// MaxEntries is the number of nodes remembered; the least recently used are forgotten.
+const MaxEntries = 200
+
+// Path returns the path of recent.json for the given context.
+func Path(contextName string) (string, error) {
+	appDir, err := utils.GetAppDataDirForContext(contextName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, utils.RecentFileName), nil
+}
+
+// Load returns when the nodes of the given context were last used, by path. A missing
+// file yields no entries.
+func Load(contextName string) (map[string]time.Time, error) {
+	path, err := Path(contextName)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]time.Time{}
+	err = utils.ReadJSON(path, &used)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]time.Time{}, nil
+		}
+		return nil, err
+	}
+	return used, nil
+}
+
+// Touch records that the nodes at paths were used at the given time.
+func Touch(contextName string, at time.Time, paths ...string) error {
+	used, err := Load(contextName)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		used[p] = at
+	}
+	if len(used) > MaxEntries {
+		byAge := make([]string, 0, len(used))
+		for p := range used {
+			byAge = append(byAge, p)
+		}
+		sort.Slice(byAge, func(i, j int) bool { return used[byAge[i]].After(used[byAge[j]]) })
+		for _, p := range byAge[MaxEntries:] {
+			delete(used, p)
+		}
+	}
+	path, err := Path(contextName)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, used, true)
+}
@@ -59,12 +59,30 @@ func (f *FileStorageRW) Write(root *ds.TreeNode) error {
 		return err
 	}
 
-	err = os.WriteFile(f.dataFilePath, serializedNode, 0666)
+	// Write next to the data file and rename, so that a concurrent Read, e.g. while
+	// watch saves changes, never sees a partly written tree.
+	tmpFile, err := os.CreateTemp(filepath.Dir(f.dataFilePath), filepath.Base(f.dataFilePath)+".*.tmp")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmpFile.Name())
 
-	return nil
+	_, err = tmpFile.Write(serializedNode)
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+	err = tmpFile.Chmod(0644)
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+	err = tmpFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), f.dataFilePath)
 }
 
 func NewFileStorageRW(dataFilePath string) (*FileStorageRW, error) {
@@ -83,6 +101,11 @@ func (factory *FileStorageRWFactory) GetTreeRW() (TreeRW, error) {
 
 // GetRW returns a TreeRW for the given context's .mm directory. contextName must be non-empty and the .mm/<contextName> dir must exist.
 func GetRW(contextName string) (TreeRW, error) {
+	return GetIndexedRW(contextName)
+}
+
+// GetIndexedRW is GetRW giving access to the search index of the context.
+func GetIndexedRW(contextName string) (*IndexedRW, error) {
 	if contextName == "" {
 		return nil, &cmderror.UninitializedRoot{}
 	}
@@ -94,5 +117,5 @@ func GetRW(contextName string) (TreeRW, error) {
 		return nil, &cmderror.UninitializedRoot{}
 	}
 	dataFilePath := filepath.Join(root, utils.DataFileName)
-	return NewFileStorageRW(dataFilePath)
+	return NewIndexedRW(dataFilePath, filepath.Join(root, utils.IndexFileName))
 }
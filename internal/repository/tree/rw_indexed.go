@@ -0,0 +1,77 @@
+package tree
+
+import (
+	"errors"
+	"os"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/index"
+	"github.com/sirupsen/logrus"
+)
+
+// IndexedRW is a FileStorageRW that keeps the search index stored next to the tree up
+// to date on every write.
+type IndexedRW struct {
+	*FileStorageRW
+	indexFilePath string
+}
+
+func NewIndexedRW(dataFilePath, indexFilePath string) (*IndexedRW, error) {
+	rw, err := NewFileStorageRW(dataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return &IndexedRW{FileStorageRW: rw, indexFilePath: indexFilePath}, nil
+}
+
+// Write writes the tree and updates the index with what changed. The tree is what
+// counts: an index that cannot be updated is dropped, to be rebuilt by the next search.
+func (rw *IndexedRW) Write(root *ds.TreeNode) error {
+	ix, loadErr := index.Load(rw.indexFilePath, rw.dataFilePath)
+	err := rw.FileStorageRW.Write(root)
+	if err != nil {
+		return err
+	}
+	if loadErr != nil {
+		if !errors.Is(loadErr, index.ErrStale) {
+			logrus.Debugf("[index] load failed: %v", loadErr)
+		}
+		ix = index.New()
+	}
+	changed, err := ix.Update(root)
+	if err == nil {
+		err = ix.Save(rw.indexFilePath, rw.dataFilePath)
+	}
+	if err != nil {
+		logrus.Debugf("[index] update failed, dropping the index: %v", err)
+		if err := os.Remove(rw.indexFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logrus.Debugf("[index] cannot remove %q: %v", rw.indexFilePath, err)
+		}
+		return nil
+	}
+	logrus.Debugf("[index] updated %d nodes", changed)
+	return nil
+}
+
+// Index returns the index of the tree, rebuilding it when it is stale.
+func (rw *IndexedRW) Index() (*index.Index, error) {
+	ix, err := index.Load(rw.indexFilePath, rw.dataFilePath)
+	if errors.Is(err, index.ErrStale) {
+		logrus.Debugf("[index] stale, rebuilding")
+		return rw.RebuildIndex()
+	}
+	return ix, err
+}
+
+// RebuildIndex builds the index from the tree and saves it.
+func (rw *IndexedRW) RebuildIndex() (*index.Index, error) {
+	root, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	ix, err := index.Build(root)
+	if err != nil {
+		return nil, err
+	}
+	return ix, ix.Save(rw.indexFilePath, rw.dataFilePath)
+}
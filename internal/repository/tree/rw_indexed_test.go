@@ -0,0 +1,44 @@
+package tree
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/index"
+)
+
+func TestIndexedRW(t *testing.T) {
+	dir := t.TempDir()
+	dataFilePath := filepath.Join(dir, "data.json")
+	rw, err := NewIndexedRW(dataFilePath, filepath.Join(dir, "index.gob"))
+	require.NoError(t, err)
+
+	child := &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/root/a", Tags: []string{"acme"}}}
+	root := &ds.TreeNode{
+		Info:     &file.FileNode{GeneralNode: file.GeneralNode{AbsPath: "/root"}},
+		Children: []*ds.TreeNode{{Info: child}},
+	}
+	require.NoError(t, rw.Write(root))
+	ix, err := rw.Index()
+	require.NoError(t, err)
+	require.Equal(t, []string{"/root/a"}, ix.Lookup(index.Term(index.KindTag, "acme")))
+
+	child.AddTag("review")
+	require.NoError(t, rw.Write(root))
+	ix, err = rw.Index()
+	require.NoError(t, err)
+	require.Equal(t, []string{"/root/a"}, ix.Lookup(index.Term(index.KindTag, "review")))
+
+	// A tree written without the index is noticed and the index rebuilt.
+	plain, err := NewFileStorageRW(dataFilePath)
+	require.NoError(t, err)
+	root.Children = nil
+	require.NoError(t, plain.Write(root))
+	ix, err = rw.Index()
+	require.NoError(t, err)
+	require.Empty(t, ix.Lookup(index.Term(index.KindTag, "acme")))
+}
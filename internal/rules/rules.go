@@ -0,0 +1,260 @@
+// Package rules tags nodes automatically. A context keeps its rules in rules.json:
+//
+//	{"Rules": [
+//	  {"Name": "invoices", "Path": "invoices/**/*.pdf", "Tags": ["finance"]},
+//	  {"Name": "large", "Query": "size>1GB", "Tags": ["large"]},
+//	  {"Name": "go", "Contains": ["go.mod"], "Tags": ["go-project"]},
+//	  {"Name": "mounts", "Attributes": {"target": "/mnt/*"}, "Tags": ["mount"]}
+//	]}
+//
+// Every condition a rule sets must hold for it to apply. Rules only add: tags a node
+// already has are left alone.
+package rules
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/data"
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/heroku/self/MetaManager/internal/output"
+	"github.com/heroku/self/MetaManager/internal/query"
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// Rule adds tags to the nodes matching all of its conditions.
+type Rule struct {
+	// Name identifies the rule when explaining what it did; "rule N" when empty.
+	Name string `json:",omitempty"`
+
+	// Path is a glob the node path must match. A relative glob, like
+	// "invoices/**/*.pdf", matches at any depth.
+	Path string `json:",omitempty"`
+	// Ext lists extensions, without the dot, one of which the name must have.
+	Ext []string `json:",omitempty"`
+	// Query is a query of "search query" the node must match, e.g.
+	// "size>1GB AND modified<30d".
+	Query string `json:",omitempty"`
+	// Mime is a pattern like "image/*" the MIME type must match. It is the type
+	// Drive reports for Drive nodes, else the one of the extension.
+	Mime string `json:",omitempty"`
+	// DriveMime is a pattern the MIME type reported by Drive must match, e.g.
+	// "application/vnd.google-apps.*". Other nodes have none.
+	DriveMime string `json:",omitempty"`
+	// Contains lists names a directory must hold among its tracked entries.
+	Contains []string `json:",omitempty"`
+	// Attributes maps attributes of the node records of --output, vanished, target
+	// and mimeType, to patterns their values must match, e.g. {"target": "/mnt/*"}.
+	// A node without the attribute does not match.
+	Attributes map[string]string `json:",omitempty"`
+
+	// Tags are added to matching nodes.
+	Tags []string `json:",omitempty"`
+}
+
+// File is the content of rules.json.
+type File struct {
+	Rules []Rule
+}
+
+// Applied is a tag a rule added to a node.
+type Applied struct {
+	Path string
+	Rule string
+	Tag  string
+}
+
+func (a Applied) String() string {
+	return fmt.Sprintf("%s: +%s (rule %q)", a.Path, a.Tag, a.Rule)
+}
+
+// Set is a list of checked rules, ready to be applied.
+type Set struct {
+	rules []compiled
+}
+
+type compiled struct {
+	Rule
+	path  *data.Glob
+	query *query.Query
+}
+
+// Compile checks rules and returns their Set.
+func Compile(rules []Rule) (*Set, error) {
+	set := &Set{}
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if len(r.Tags) == 0 {
+			return nil, fmt.Errorf("%s: no tags to add", r.Name)
+		}
+		c := compiled{Rule: r}
+		var err error
+		if r.Path != "" {
+			pattern := r.Path
+			if !strings.HasPrefix(pattern, "/") && !strings.Contains(pattern, ":") {
+				pattern = "**/" + pattern
+			}
+			c.path, err = data.CompileGlob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", r.Name, err)
+			}
+		}
+		if r.Query != "" {
+			c.query, err = query.Parse(r.Query)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", r.Name, err)
+			}
+		}
+		patterns := []string{r.Mime, r.DriveMime}
+		for _, pattern := range r.Attributes {
+			patterns = append(patterns, pattern)
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern %q: %w", r.Name, pattern, err)
+			}
+		}
+		set.rules = append(set.rules, c)
+	}
+	return set, nil
+}
+
+// Len returns the number of rules.
+func (s *Set) Len() int {
+	return len(s.rules)
+}
+
+// Apply applies the rules to root and the nodes below it, and returns what they
+// added, node by node in tree order.
+func (s *Set) Apply(root *ds.TreeNode) ([]Applied, error) {
+	applied := []Applied{}
+	if len(s.rules) == 0 {
+		return applied, nil
+	}
+	it := ds.NewTreeIterator(ds.NewTreeManager(root))
+	for it.HasNext() {
+		node, err := it.Next()
+		if err != nil {
+			return applied, err
+		}
+		info, ok := node.Info.(file.NodeInformable)
+		if !ok || info.GetAbsPath() == file.RootsPath {
+			continue
+		}
+		for _, r := range s.rules {
+			if !r.match(node, info) {
+				continue
+			}
+			for _, tag := range r.Tags {
+				if !slices.Contains(info.GetTags(), tag) {
+					info.AddTag(tag)
+					applied = append(applied, Applied{Path: info.GetAbsPath(), Rule: r.Name, Tag: tag})
+				}
+			}
+		}
+	}
+	return applied, nil
+}
+
+func (r *compiled) match(node *ds.TreeNode, info file.NodeInformable) bool {
+	p := info.GetAbsPath()
+	name := path.Base(p)
+	if r.path != nil && !r.path.Match(p) {
+		return false
+	}
+	if len(r.Ext) > 0 && !slices.ContainsFunc(r.Ext, func(ext string) bool {
+		return strings.EqualFold(path.Ext(name), "."+strings.TrimPrefix(ext, "."))
+	}) {
+		return false
+	}
+	if r.query != nil && !r.query.Match(info) {
+		return false
+	}
+	if r.Mime != "" && !matchPattern(r.Mime, mimeType(info)) {
+		return false
+	}
+	if r.DriveMime != "" && !matchPattern(r.DriveMime, driveMimeType(info)) {
+		return false
+	}
+	if len(r.Attributes) > 0 {
+		attrs := output.NewNode(node).Attributes()
+		for name, pattern := range r.Attributes {
+			if !matchPattern(pattern, attrs[name]) {
+				return false
+			}
+		}
+	}
+	for _, want := range r.Contains {
+		if !slices.ContainsFunc(node.Children, func(child *ds.TreeNode) bool {
+			childInfo, ok := child.Info.(file.NodeInformable)
+			return ok && path.Base(childInfo.GetAbsPath()) == want
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPattern matches v against a path.Match pattern; nothing matches an empty v.
+func matchPattern(pattern, v string) bool {
+	ok, _ := path.Match(pattern, v)
+	return v != "" && ok
+}
+
+// driveMimeType returns the MIME type Drive reported for info, if any.
+func driveMimeType(info file.NodeInformable) string {
+	fn, ok := info.(*file.FileNode)
+	if !ok {
+		return ""
+	}
+	return fn.MimeType
+}
+
+// mimeType returns the MIME type Drive reported for info, else the one of its
+// extension without parameters, "" when unknown.
+func mimeType(info file.NodeInformable) string {
+	if t := driveMimeType(info); t != "" {
+		return t
+	}
+	t, _, err := mime.ParseMediaType(mime.TypeByExtension(path.Ext(info.GetAbsPath())))
+	if err != nil {
+		return ""
+	}
+	return t
+}
+
+// Path returns the path of rules.json for the given context.
+func Path(contextName string) (string, error) {
+	appDir, err := utils.GetAppDataDirForContext(contextName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, utils.RulesFileName), nil
+}
+
+// Load reads and compiles the rules of the given context. A missing file yields no
+// rules.
+func Load(contextName string) (*Set, error) {
+	p, err := Path(contextName)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	err = utils.ReadJSON(p, &f)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	set, err := Compile(f.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return set, nil
+}
@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/heroku/self/MetaManager/internal/ds"
+	"github.com/heroku/self/MetaManager/internal/file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	node := func(p string, size int64, children ...*ds.TreeNode) *ds.TreeNode {
+		n := ds.NewTreeNode(&file.FileNode{GeneralNode: file.GeneralNode{AbsPath: p, Size: size}})
+		n.Children = children
+		return n
+	}
+	invoice := node("/h/invoices/2025/march.pdf", 100)
+	otherPdf := node("/h/letters/march.pdf", 100)
+	movie := node("/h/movie.mkv", 2<<30)
+	photo := node("/h/trip.JPG", 100)
+	goMod := node("/h/proj/go.mod", 10)
+	proj := node("/h/proj", 0, goMod)
+	doc := ds.NewTreeNode(&file.FileNode{
+		GeneralNode: file.GeneralNode{AbsPath: "gdrive:/Plan"},
+		DriveId:     "d1",
+		MimeType:    "application/vnd.google-apps.document",
+	})
+	mount := ds.NewTreeNode(&file.SymlinkNode{GeneralNode: file.GeneralNode{AbsPath: "/h/usb"}, Kind: file.KindSymlink, Target: "/mnt/usb"})
+	root := node("/h", 0, mount, node("/h/invoices", 0, node("/h/invoices/2025", 0, invoice)), node("/h/letters", 0, otherPdf), movie, photo, proj, doc)
+
+	set, err := Compile([]Rule{
+		{Name: "invoices", Path: "invoices/**/*.pdf", Tags: []string{"finance"}},
+		{Name: "large", Query: "size>1GB", Tags: []string{"large"}},
+		{Name: "go", Contains: []string{"go.mod"}, Tags: []string{"go-project"}},
+		{Mime: "image/*", Ext: []string{"jpg", "png"}, Tags: []string{"photo"}},
+		{Name: "docs", DriveMime: "application/vnd.google-apps.*", Tags: []string{"gdoc"}},
+		{Name: "mounts", Attributes: map[string]string{"target": "/mnt/*"}, Tags: []string{"mount"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 6, set.Len())
+
+	applied, err := set.Apply(root)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Applied{
+		{Path: "/h/invoices/2025/march.pdf", Rule: "invoices", Tag: "finance"},
+		{Path: "/h/movie.mkv", Rule: "large", Tag: "large"},
+		{Path: "/h/proj", Rule: "go", Tag: "go-project"},
+		{Path: "/h/trip.JPG", Rule: "rule 4", Tag: "photo"},
+		{Path: "gdrive:/Plan", Rule: "docs", Tag: "gdoc"},
+		{Path: "/h/usb", Rule: "mounts", Tag: "mount"},
+	}, applied)
+	require.Empty(t, otherPdf.Info.(file.NodeInformable).GetTags())
+	require.Equal(t, `/h/proj: +go-project (rule "go")`, Applied{Path: "/h/proj", Rule: "go", Tag: "go-project"}.String())
+
+	// Rules only add what is missing.
+	applied, err = set.Apply(root)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+}
+
+func TestCompileErrors(t *testing.T) {
+	_, err := Compile([]Rule{{Name: "empty", Path: "*.pdf"}})
+	require.ErrorContains(t, err, "no tags to add")
+	_, err = Compile([]Rule{{Path: "a**/x", Tags: []string{"t"}}})
+	require.ErrorContains(t, err, "rule 1")
+	_, err = Compile([]Rule{{Query: "size>", Tags: []string{"t"}}})
+	require.Error(t, err)
+	_, err = Compile([]Rule{{Mime: "image/[", Tags: []string{"t"}}})
+	require.ErrorContains(t, err, "invalid pattern")
+	_, err = Compile([]Rule{{Attributes: map[string]string{"target": "["}, Tags: []string{"t"}}})
+	require.ErrorContains(t, err, "invalid pattern")
+}
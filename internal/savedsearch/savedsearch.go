@@ -0,0 +1,109 @@
+// Package savedsearch keeps the queries saved by name with "search save", and the
+// virtual directory of a saved search the context-aware "cd" went into.
+package savedsearch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// DirPrefix starts the virtual directory of a saved search, like "@search:unpaid".
+// DirPrefix alone is the directory holding every saved search.
+const DirPrefix = "@search:"
+
+// Searches are the saved searches of a context.
+type Searches struct {
+	// Queries are the saved queries by name.
+	Queries map[string]string
+	// Cwd is the virtual directory "cd" went into, empty outside of them.
+	Cwd string `json:",omitempty"`
+}
+
+// Names returns the names of the saved searches in order.
+func (s *Searches) Names() []string {
+	names := make([]string, 0, len(s.Queries))
+	for name := range s.Queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query returns the query saved as name.
+func (s *Searches) Query(name string) (string, error) {
+	q, ok := s.Queries[name]
+	if !ok {
+		return "", fmt.Errorf("no saved search %q; see \"search list\"", name)
+	}
+	return q, nil
+}
+
+// Dir returns the virtual directory of the saved search name.
+func Dir(name string) string {
+	return DirPrefix + name
+}
+
+// SplitDir returns the name of the saved search whose virtual directory is p, ""
+// for DirPrefix itself. ok is false when p is not a virtual directory.
+func SplitDir(p string) (name string, ok bool) {
+	if !strings.HasPrefix(p, DirPrefix) {
+		return "", false
+	}
+	return strings.TrimSuffix(p[len(DirPrefix):], "/"), true
+}
+
+// ValidName reports whether name can name a saved search: letters, digits, ".", "-"
+// and "_", not starting with ".".
+func ValidName(name string) bool {
+	if name == "" || name[0] == '.' {
+		return false
+	}
+	for _, c := range name {
+		ok := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			c == '.' || c == '-' || c == '_'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Path returns the path of searches.json for the given context.
+func Path(contextName string) (string, error) {
+	appDir, err := utils.GetAppDataDirForContext(contextName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, utils.SearchesFileName), nil
+}
+
+// Load reads the saved searches of the given context. A missing file yields none.
+func Load(contextName string) (*Searches, error) {
+	path, err := Path(contextName)
+	if err != nil {
+		return nil, err
+	}
+	s := &Searches{}
+	err = utils.ReadJSON(path, s)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if s.Queries == nil {
+		s.Queries = map[string]string{}
+	}
+	return s, nil
+}
+
+// Save writes s to searches.json of the given context.
+func Save(contextName string, s *Searches) error {
+	path, err := Path(contextName)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, s, true)
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/heroku/self/MetaManager/internal/googleauth"
 	"github.com/heroku/self/MetaManager/internal/utils"
@@ -61,6 +62,9 @@ type RootEntry struct {
 	Name     string
 	IsFolder bool
 	MimeType string
+	// Size is zero for folders and Google Docs, which have no stored content
+	Size         int64
+	ModifiedTime time.Time
 }
 
 // NewGDriveService creates a Drive API client using the given OAuth config and token.
@@ -126,7 +130,7 @@ func (g *GDriveService) ListFolder(ctx context.Context, folderID string) ([]Root
 	q := fmt.Sprintf("%q in parents and trashed = false", parentID)
 	call := g.svc.Files.List().
 		Q(q).
-		Fields("nextPageToken, files(id, name, mimeType)").
+		Fields("nextPageToken, files(id, name, mimeType, size, modifiedTime)").
 		PageSize(1000)
 	var all []RootEntry
 	for {
@@ -135,11 +139,15 @@ func (g *GDriveService) ListFolder(ctx context.Context, folderID string) ([]Root
 			return nil, fmt.Errorf("drive files.list: %w", err)
 		}
 		for _, f := range r.Files {
+			// modifiedTime is RFC 3339; an unparsable value is left as the zero time
+			modified, _ := time.Parse(time.RFC3339, f.ModifiedTime)
 			all = append(all, RootEntry{
-				Id:       f.Id,
-				Name:     f.Name,
-				IsFolder: f.MimeType == DriveFolderMimeType,
-				MimeType: f.MimeType,
+				Id:           f.Id,
+				Name:         f.Name,
+				IsFolder:     f.MimeType == DriveFolderMimeType,
+				MimeType:     f.MimeType,
+				Size:         f.Size,
+				ModifiedTime: modified,
 			})
 		}
 		if r.NextPageToken == "" {
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+	"github.com/pkg/sftp"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultSSHPort is used for hosts given without a port.
+const DefaultSSHPort = "22"
+
+// defaultIdentityFiles are tried, relative to ~/.ssh, when no identity file is set.
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// SFTPSettings is how an sftp context authenticates to its hosts. Empty settings use
+// the SSH agent, the default keys of ~/.ssh and ~/.ssh/known_hosts, like ssh does.
+type SFTPSettings struct {
+	// User logs in to hosts given without one, the local user by default
+	User string `json:",omitempty"`
+	// IdentityFiles are private keys to authenticate with, instead of the default ones
+	IdentityFiles []string `json:",omitempty"`
+	// KnownHostsFile lists the accepted host keys, ~/.ssh/known_hosts by default
+	KnownHostsFile string `json:",omitempty"`
+	// NoAgent keeps the SSH agent of SSH_AUTH_SOCK out
+	NoAgent bool `json:",omitempty"`
+}
+
+// SFTPSettingsPath returns the path of sftp.json for the given context.
+func SFTPSettingsPath(contextName string) (string, error) {
+	appDir, err := utils.GetAppDataDirForContext(contextName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, utils.SFTPFileName), nil
+}
+
+// LoadSFTPSettings reads the settings of the given context. A missing file yields
+// empty settings.
+func LoadSFTPSettings(contextName string) (*SFTPSettings, error) {
+	path, err := SFTPSettingsPath(contextName)
+	if err != nil {
+		return nil, err
+	}
+	var s SFTPSettings
+	err = utils.ReadJSON(path, &s)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &s, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveSFTPSettings writes s to sftp.json of the given context.
+func SaveSFTPSettings(contextName string, s *SFTPSettings) error {
+	path, err := SFTPSettingsPath(contextName)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, s, true)
+}
+
+// SFTPServiceInterface defines the SFTP operations used to track remote directories.
+// Hosts are given like in virtual paths: "host", "user@host" or "user@host:port".
+// This allows for mocking in tests.
+type SFTPServiceInterface interface {
+	ListAtPath(ctx context.Context, host, path string) ([]RootEntry, error)
+	Stat(ctx context.Context, host, path string) (*RootEntry, error)
+}
+
+// SFTPService lists remote directories over SSH. Connections are opened on first use
+// of a host and kept until Close.
+type SFTPService struct {
+	settings SFTPSettings
+	mu       sync.Mutex
+	clients  map[string]*sftpConn
+	agent    net.Conn
+}
+
+type sftpConn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+// Ensure SFTPService implements SFTPServiceInterface
+var _ SFTPServiceInterface = (*SFTPService)(nil)
+
+// NewSFTPService creates a service authenticating as described by s.
+func NewSFTPService(s *SFTPSettings) *SFTPService {
+	return &SFTPService{settings: *s, clients: map[string]*sftpConn{}}
+}
+
+// GetSFTPService returns the SFTP service of the given context.
+func GetSFTPService(contextName string) (*SFTPService, error) {
+	s, err := LoadSFTPSettings(contextName)
+	if err != nil {
+		return nil, err
+	}
+	return NewSFTPService(s), nil
+}
+
+// splitHost returns the user and the address to dial for host.
+func (s *SFTPService) splitHost(host string) (userName, addr string, err error) {
+	userName, hostPort, found := strings.Cut(host, "@")
+	if !found {
+		hostPort = host
+		userName = s.settings.User
+	}
+	if userName == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", "", fmt.Errorf("no user to log in to %s: %w", host, err)
+		}
+		userName = u.Username
+	}
+	if _, _, err := net.SplitHostPort(hostPort); err != nil {
+		hostPort = net.JoinHostPort(hostPort, DefaultSSHPort)
+	}
+	return userName, hostPort, nil
+}
+
+// homePath returns p below the home directory of the local user.
+func homePath(p string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, p), nil
+}
+
+// authMethods returns the agent, when there is one, and the identity files. Default
+// identity files that are missing or protected by a passphrase are skipped.
+func (s *SFTPService) authMethods() ([]ssh.AuthMethod, error) {
+	methods := []ssh.AuthMethod{}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" && !s.settings.NoAgent {
+		if s.agent == nil {
+			conn, err := net.Dial("unix", sock)
+			if err != nil {
+				logrus.Debugf("[sftp] no SSH agent at %q: %v", sock, err)
+			} else {
+				s.agent = conn
+			}
+		}
+		if s.agent != nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(s.agent).Signers))
+		}
+	}
+
+	files := s.settings.IdentityFiles
+	explicit := len(files) > 0
+	if !explicit {
+		for _, name := range defaultIdentityFiles {
+			p, err := homePath(filepath.Join(".ssh", name))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, p)
+		}
+	}
+	signers := []ssh.Signer{}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			if !explicit && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("read identity file: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			logrus.Debugf("[sftp] skipping %q, it needs a passphrase; add it to the SSH agent instead", f)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse identity file %s: %w", f, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no SSH agent or private key to authenticate with")
+	}
+	return methods, nil
+}
+
+// client returns the SFTP client of host, connecting on first use.
+func (s *SFTPService) client(ctx context.Context, host string) (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clients[host]; ok {
+		return c.sftp, nil
+	}
+
+	userName, addr, err := s.splitHost(host)
+	if err != nil {
+		return nil, err
+	}
+	knownHostsFile := s.settings.KnownHostsFile
+	if knownHostsFile == "" {
+		knownHostsFile, err = homePath(filepath.Join(".ssh", "known_hosts"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read known hosts: %w", err)
+	}
+	auth, err := s.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User:            userName,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	}
+
+	logrus.Debugf("[sftp] connecting to %s as %s", addr, userName)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", host, err)
+	}
+	// The handshake is bounded by Timeout, which ssh only applies when it dials itself,
+	// and by ctx.
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = conn.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("ssh %s: %w", host, ctxErr)
+		}
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("%s is not in %s; connect once with ssh to add it", host, knownHostsFile)
+		}
+		return nil, fmt.Errorf("ssh %s: %w", host, err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("sftp %s: %w", host, err)
+	}
+	s.clients[host] = &sftpConn{ssh: sshClient, sftp: sftpClient}
+	return sftpClient, nil
+}
+
+// Close closes the connections to every host.
+func (s *SFTPService) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for host, c := range s.clients {
+		errs = append(errs, c.sftp.Close(), c.ssh.Close())
+		delete(s.clients, host)
+	}
+	if s.agent != nil {
+		errs = append(errs, s.agent.Close())
+		s.agent = nil
+	}
+	return errors.Join(errs...)
+}
+
+// sftpEntry describes the remote file info found at p.
+func sftpEntry(p string, info fs.FileInfo) RootEntry {
+	return RootEntry{
+		Id:           p,
+		Name:         path.Base(p),
+		IsFolder:     info.IsDir(),
+		Size:         info.Size(),
+		ModifiedTime: info.ModTime(),
+	}
+}
+
+// Stat returns the entry at the absolute remote path p of host. A missing entry is
+// reported as fs.ErrNotExist.
+func (s *SFTPService) Stat(ctx context.Context, host, p string) (*RootEntry, error) {
+	c, err := s.client(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	p = path.Clean("/" + p)
+	info, err := c.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("sftp %s%s: %w", host, p, err)
+	}
+	e := sftpEntry(p, info)
+	return &e, nil
+}
+
+// ListAtPath lists the remote directory p of host. Entries are sorted like
+// GDriveService.ListFolder sorts them, folders first. Symbolic links are listed as
+// files and not followed.
+func (s *SFTPService) ListAtPath(ctx context.Context, host, p string) ([]RootEntry, error) {
+	c, err := s.client(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p = path.Clean("/" + p)
+	infos, err := c.ReadDir(p)
+	if err != nil {
+		return nil, fmt.Errorf("sftp %s%s: %w", host, p, err)
+	}
+	all := make([]RootEntry, 0, len(infos))
+	for _, info := range infos {
+		all = append(all, sftpEntry(path.Join(p, info.Name()), info))
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].IsFolder != all[j].IsFolder {
+			return all[i].IsFolder
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/services/sftptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSFTPService(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	srv := sftptest.NewServer(t)
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sets", "raw"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sets", "a.csv"), []byte("1,2"), 0644))
+	ctx := context.Background()
+
+	svc := NewSFTPService(&SFTPSettings{IdentityFiles: []string{srv.IdentityFile}, KnownHostsFile: srv.KnownHostsFile})
+	defer svc.Close()
+
+	entries, err := svc.ListAtPath(ctx, srv.Addr, filepath.Join(dir, "sets"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "raw", entries[0].Name)
+	require.True(t, entries[0].IsFolder)
+	require.Equal(t, filepath.Join(dir, "sets", "a.csv"), entries[1].Id)
+	require.Equal(t, int64(3), entries[1].Size)
+
+	// The connection is reused, a user in the host is fine.
+	entry, err := svc.Stat(ctx, "someone@"+srv.Addr, filepath.Join(dir, "sets", "a.csv"))
+	require.NoError(t, err)
+	require.False(t, entry.IsFolder)
+	_, err = svc.Stat(ctx, srv.Addr, filepath.Join(dir, "missing"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, svc.Close())
+
+	// Hosts missing from known_hosts are refused.
+	emptyKnownHosts := filepath.Join(dir, "known_hosts")
+	require.NoError(t, os.WriteFile(emptyKnownHosts, nil, 0644))
+	svc = NewSFTPService(&SFTPSettings{IdentityFiles: []string{srv.IdentityFile}, KnownHostsFile: emptyKnownHosts})
+	_, err = svc.Stat(ctx, srv.Addr, "/")
+	require.ErrorContains(t, err, "is not in")
+
+	// So are unknown keys.
+	svc = NewSFTPService(&SFTPSettings{IdentityFiles: []string{filepath.Join(dir, "sets", "a.csv")}, KnownHostsFile: srv.KnownHostsFile})
+	_, err = svc.Stat(ctx, srv.Addr, "/")
+	require.ErrorContains(t, err, "identity file")
+}
+
+func TestSFTPStalledHandshake(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	srv := sftptest.NewServer(t)
+	// A host accepting connections without ever answering the handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	svc := NewSFTPService(&SFTPSettings{IdentityFiles: []string{srv.IdentityFile}, KnownHostsFile: srv.KnownHostsFile})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = svc.Stat(ctx, ln.Addr().String(), "/")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestSFTPSettings(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("MM_TEST_CONTEXT_DIR", dir)
+	defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+
+	settings, err := LoadSFTPSettings("lab")
+	require.NoError(t, err)
+	require.Equal(t, &SFTPSettings{}, settings)
+
+	path, err := SFTPSettingsPath("lab")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	settings = &SFTPSettings{User: "me", IdentityFiles: []string{"/keys/lab"}, NoAgent: true}
+	require.NoError(t, SaveSFTPSettings("lab", settings))
+	got, err := LoadSFTPSettings("lab")
+	require.NoError(t, err)
+	require.Equal(t, settings, got)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// WebDAVSettings is how a webdav context reaches its server. Every webdav context has
+// its own settings, kept next to its tree.
+type WebDAVSettings struct {
+	// URL is the collection the context's "webdav:/" stands for
+	URL      string
+	Username string `json:",omitempty"`
+	Password string `json:",omitempty"`
+	// Cwd is the working directory of "webdav cd", a server path like "/Folder"
+	Cwd string `json:",omitempty"`
+}
+
+// WebDAVSettingsPath returns the path of webdav.json for the given context.
+func WebDAVSettingsPath(contextName string) (string, error) {
+	appDir, err := utils.GetAppDataDirForContext(contextName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, utils.WebDAVFileName), nil
+}
+
+// LoadWebDAVSettings reads the settings of the given context. It fails when no server
+// was set up yet.
+func LoadWebDAVSettings(contextName string) (*WebDAVSettings, error) {
+	path, err := WebDAVSettingsPath(contextName)
+	if err != nil {
+		return nil, err
+	}
+	var s WebDAVSettings
+	err = utils.ReadJSON(path, &s)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no WebDAV server set up for context %q; run webdav login first", contextName)
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveWebDAVSettings writes s to webdav.json of the given context. The file is only
+// readable by its owner, as it holds the password.
+func SaveWebDAVSettings(contextName string, s *WebDAVSettings) error {
+	path, err := WebDAVSettingsPath(contextName)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(path, s, true)
+}
+
+// WebDAVServiceInterface defines the WebDAV operations used to track a server.
+// This allows for mocking in tests.
+type WebDAVServiceInterface interface {
+	ListAtPath(ctx context.Context, path string) ([]RootEntry, error)
+	Stat(ctx context.Context, path string) (*RootEntry, error)
+}
+
+// WebDAVService lists collections of a WebDAV server with PROPFIND requests.
+type WebDAVService struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+// Ensure WebDAVService implements WebDAVServiceInterface
+var _ WebDAVServiceInterface = (*WebDAVService)(nil)
+
+// NewWebDAVService creates a client for the server described by s.
+func NewWebDAVService(s *WebDAVSettings) (*WebDAVService, error) {
+	base, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse WebDAV URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("WebDAV URL %q must start with http:// or https://", s.URL)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	return &WebDAVService{
+		base:     base,
+		username: s.Username,
+		password: s.Password,
+		client:   &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// GetWebDAVService returns the WebDAV service of the given context.
+func GetWebDAVService(contextName string) (*WebDAVService, error) {
+	s, err := LoadWebDAVSettings(contextName)
+	if err != nil {
+		return nil, err
+	}
+	return NewWebDAVService(s)
+}
+
+// propfindBody asks for the properties RootEntry is made of.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/></D:prop></D:propfind>`
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"DAV: prop"`
+	Status string  `xml:"DAV: status"`
+}
+
+type davProp struct {
+	ResourceType struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+	ContentLength string `xml:"DAV: getcontentlength"`
+	LastModified  string `xml:"DAV: getlastmodified"`
+}
+
+// propfind returns the entries of the PROPFIND response for the server path p, keyed by
+// their server path. A missing resource is reported as fs.ErrNotExist.
+func (w *WebDAVService) propfind(ctx context.Context, p string, depth string) (map[string]RootEntry, error) {
+	p = path.Clean("/" + p)
+	u := *w.base
+	u.Path = w.base.Path + p
+	if depth != "0" && !strings.HasSuffix(u.Path, "/") {
+		// Collections are listed at their canonical URL, saving a redirect.
+		u.Path += "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", u.String(), bytes.NewBufferString(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	if w.username != "" || w.password != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("webdav propfind %q: %w", p, err)
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("webdav %q: %w", p, fs.ErrNotExist)
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("webdav propfind %q: %s; check the credentials with webdav login", p, res.Status)
+	case res.StatusCode != http.StatusMultiStatus:
+		return nil, fmt.Errorf("webdav propfind %q: %s", p, res.Status)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(io.LimitReader(res.Body, 64<<20)).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("webdav propfind %q: decode response: %w", p, err)
+	}
+	entries := make(map[string]RootEntry, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			return nil, fmt.Errorf("webdav propfind %q: bad href %q: %w", p, r.Href, err)
+		}
+		// Hrefs are server paths, below the collection of the base URL.
+		rel, ok := strings.CutPrefix(strings.TrimSuffix(href.Path, "/"), w.base.Path)
+		if !ok {
+			continue
+		}
+		rel = path.Clean("/" + rel)
+		e := RootEntry{Id: rel, Name: path.Base(rel)}
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil {
+				e.IsFolder = true
+			}
+			if size, err := strconv.ParseInt(ps.Prop.ContentLength, 10, 64); err == nil {
+				e.Size = size
+			}
+			if ps.Prop.LastModified != "" {
+				// an unparsable date is left as the zero time
+				e.ModifiedTime, _ = http.ParseTime(ps.Prop.LastModified)
+			}
+		}
+		entries[rel] = e
+	}
+	return entries, nil
+}
+
+// Stat returns the entry at the server path p, like "/" or "/Folder/file.txt".
+func (w *WebDAVService) Stat(ctx context.Context, p string) (*RootEntry, error) {
+	entries, err := w.propfind(ctx, p, "0")
+	if err != nil {
+		return nil, err
+	}
+	e, ok := entries[path.Clean("/"+p)]
+	if !ok {
+		return nil, fmt.Errorf("webdav %q: %w", p, fs.ErrNotExist)
+	}
+	return &e, nil
+}
+
+// ListAtPath lists the collection at the server path p. Entries are sorted like
+// GDriveService.ListFolder sorts them, folders first.
+func (w *WebDAVService) ListAtPath(ctx context.Context, p string) ([]RootEntry, error) {
+	entries, err := w.propfind(ctx, p, "1")
+	if err != nil {
+		return nil, err
+	}
+	self := path.Clean("/" + p)
+	all := make([]RootEntry, 0, len(entries))
+	for rel, e := range entries {
+		if rel != self {
+			all = append(all, e)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].IsFolder != all[j].IsFolder {
+			return all[i].IsFolder
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// newWebDAVTestServer serves dir below /dav/ and requires user/secret.
+func newWebDAVTestServer(t *testing.T, dir string) *httptest.Server {
+	h := &webdav.Handler{Prefix: "/dav", FileSystem: webdav.Dir(dir), LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWebDAVService(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Folder", "Sub dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Folder", "a.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "top.txt"), nil, 0644))
+	srv := newWebDAVTestServer(t, dir)
+	ctx := context.Background()
+
+	svc, err := NewWebDAVService(&WebDAVSettings{URL: srv.URL + "/dav/", Username: "user", Password: "secret"})
+	require.NoError(t, err)
+
+	entries, err := svc.ListAtPath(ctx, "/")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, RootEntry{Id: "/Folder", Name: "Folder", IsFolder: true, ModifiedTime: entries[0].ModifiedTime}, entries[0])
+	require.Equal(t, "top.txt", entries[1].Name)
+
+	entries, err = svc.ListAtPath(ctx, "/Folder")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "/Folder/Sub dir", entries[0].Id)
+	require.Equal(t, "a.txt", entries[1].Name)
+	require.Equal(t, int64(5), entries[1].Size)
+	require.False(t, entries[1].ModifiedTime.IsZero())
+
+	entry, err := svc.Stat(ctx, "/Folder/a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "/Folder/a.txt", entry.Id)
+	require.False(t, entry.IsFolder)
+
+	_, err = svc.Stat(ctx, "/missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	svc, err = NewWebDAVService(&WebDAVSettings{URL: srv.URL + "/dav", Username: "user", Password: "wrong"})
+	require.NoError(t, err)
+	_, err = svc.ListAtPath(ctx, "/")
+	require.ErrorContains(t, err, "401")
+
+	_, err = NewWebDAVService(&WebDAVSettings{URL: "ftp://example.com"})
+	require.Error(t, err)
+}
+
+func TestWebDAVSettings(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("MM_TEST_CONTEXT_DIR", dir)
+	defer os.Unsetenv("MM_TEST_CONTEXT_DIR")
+
+	_, err := LoadWebDAVSettings("dav")
+	require.ErrorContains(t, err, "webdav login")
+
+	path, err := WebDAVSettingsPath("dav")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	settings := &WebDAVSettings{URL: "https://dav.example.com", Username: "user", Password: "secret", Cwd: "/Folder"}
+	require.NoError(t, SaveWebDAVSettings("dav", settings))
+	got, err := LoadWebDAVSettings("dav")
+	require.NoError(t, err)
+	require.Equal(t, settings, got)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
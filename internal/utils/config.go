@@ -2,7 +2,14 @@ package utils
 
 // File and directory names used by MetaManager.
 const (
-	DataFileName   = "data.json"
-	ConfigFileName = "config.json"
-	MMDirName      = ".mm"
+	DataFileName     = "data.json"
+	IndexFileName    = "index.gob"
+	ConfigFileName   = "config.json"
+	OrphansFileName  = "orphans.json"
+	RecentFileName   = "recent.json"
+	SearchesFileName = "searches.json"
+	RulesFileName    = "rules.json"
+	WebDAVFileName   = "webdav.json"
+	SFTPFileName     = "sftp.json"
+	MMDirName        = ".mm"
 )
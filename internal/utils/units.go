@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSize parses a byte count such as "512", "10K", "1.5MB", "2g" or "1KiB". Units
+// are powers of 1024.
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+	mult := int64(1)
+	if n := len(str); n > 0 {
+		if i := strings.IndexByte("KMGT", str[n-1]); i >= 0 {
+			mult = int64(1) << (10 * (i + 1))
+			str = str[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 512, 10K, 1.5MB or 2G", s)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// ageUnits are the units of ages beyond those of time.ParseDuration.
+var ageUnits = map[byte]time.Duration{
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+	'y': 365 * 24 * time.Hour,
+}
+
+// ParseTimeRange parses when something happened, from included and to excluded:
+//   - an age such as "36h", "30d", "2w" or "1y", or "90m" and other durations of
+//     time.ParseDuration, is the point that long before now; from and to are that
+//     point and age is set;
+//   - a date like "2006-01-02" is that whole day in local time;
+//   - an RFC 3339 timestamp is the second it names.
+func ParseTimeRange(s string, now time.Time) (from, to time.Time, age bool, err error) {
+	s = strings.TrimSpace(s)
+	if n := len(s); n > 1 {
+		if unit, ok := ageUnits[s[n-1]]; ok {
+			if v, err := strconv.Atoi(s[:n-1]); err == nil && v >= 0 {
+				from = now.Add(-time.Duration(v) * unit)
+				return from, from, true, nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		from = now.Add(-d)
+		return from, from, true, nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return day, day.AddDate(0, 0, 1), false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, t.Add(time.Second), false, nil
+	}
+	return time.Time{}, time.Time{}, false, fmt.Errorf("invalid time %q, expected an age like 36h, 30d, 2w or 1y, a date like 2006-01-02, or an RFC 3339 timestamp", s)
+}
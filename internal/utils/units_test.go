@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{"512": 512, "10K": 10 << 10, "1.5MB": 3 << 19, "2g": 2 << 30, "1KiB": 1024, "500kb": 500 << 10} {
+		got, err := ParseSize(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	_, err := ParseSize("lots")
+	require.ErrorContains(t, err, "invalid size")
+}
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	for in, want := range map[string]time.Time{
+		"36h": now.Add(-36 * time.Hour),
+		"90m": now.Add(-90 * time.Minute),
+		"7d":  now.AddDate(0, 0, -7),
+		"2w":  now.AddDate(0, 0, -14),
+		"1y":  now.AddDate(0, 0, -365),
+	} {
+		from, to, age, err := ParseTimeRange(in, now)
+		require.NoError(t, err, in)
+		require.True(t, age, in)
+		require.Equal(t, want, from, in)
+		require.Equal(t, from, to, in)
+	}
+
+	from, to, age, err := ParseTimeRange("2024-01-02", now)
+	require.NoError(t, err)
+	require.False(t, age)
+	require.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local), from)
+	require.Equal(t, from.AddDate(0, 0, 1), to)
+
+	from, to, _, err = ParseTimeRange("2024-01-02T03:04:05Z", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), from)
+	require.Equal(t, from.Add(time.Second), to)
+
+	_, _, _, err = ParseTimeRange("last week", now)
+	require.ErrorContains(t, err, "invalid time")
+}
@@ -0,0 +1,240 @@
+// Package view materializes tracked nodes as a directory other tools can browse: one
+// subdirectory per group, e.g. per tag, holding a symlink to every node of the group,
+// or a .url shortcut file for nodes only reachable through a web link.
+//
+// A view directory records what it holds in ManifestFileName, so that materializing
+// it again adds the links that are missing and prunes the stale ones, leaving
+// anything else in the directory alone.
+package view
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/heroku/self/MetaManager/internal/utils"
+)
+
+// ManifestFileName is the file of a view directory listing the links it holds.
+const ManifestFileName = ".mmview.json"
+
+// ShortcutExt is the extension of shortcut files.
+const ShortcutExt = ".url"
+
+// Link is an entry of a view.
+type Link struct {
+	// Group is the subdirectory holding the link.
+	Group string
+	// Target is the path of the node the link points to.
+	Target string
+	// Shortcut makes the link a .url shortcut file instead of a symlink.
+	Shortcut bool
+}
+
+// Result is what Materialize changed.
+type Result struct {
+	Added   int
+	Removed int
+	Kept    int
+}
+
+// manifest maps the path of every link, relative to the view directory, to its
+// target. Shortcuts keep the target, not the URL, so they are not looked up again.
+type manifest map[string]string
+
+// Materialize makes dir hold exactly links. url returns the address a shortcut to a
+// target opens; it is only called for shortcuts not already in the view. A directory
+// that is not empty must have been made by Materialize.
+func Materialize(dir string, links []Link, url func(target string) (string, error)) (*Result, error) {
+	manifestPath := filepath.Join(dir, ManifestFileName)
+	old := manifest{}
+	err := utils.ReadJSON(manifestPath, &old)
+	if errors.Is(err, os.ErrNotExist) {
+		err = checkEmpty(dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := layout(links)
+	stale := groupsOf(old)
+	for g := range groupsOf(wanted) {
+		delete(stale, g)
+	}
+	res := &Result{}
+	for rel, target := range old {
+		if l, ok := wanted[rel]; ok && l.Target == target {
+			continue
+		}
+		err := removeLink(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		delete(old, rel)
+		res.Removed++
+	}
+
+	// Links are created in order so that errors are reproducible.
+	rels := make([]string, 0, len(wanted))
+	for rel := range wanted {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		l := wanted[rel]
+		linkPath := filepath.Join(dir, filepath.FromSlash(rel))
+		if _, ok := old[rel]; ok {
+			if _, err := os.Lstat(linkPath); err == nil {
+				res.Kept++
+				continue
+			}
+		}
+		err := createLink(linkPath, l, url)
+		if err != nil {
+			// Keep track of the links made so far.
+			saveErr := utils.WriteJSON(manifestPath, old, true)
+			return nil, errors.Join(err, saveErr)
+		}
+		old[rel] = l.Target
+		res.Added++
+	}
+
+	for g := range stale {
+		err = removeEmptyDir(filepath.Join(dir, g))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = utils.WriteJSON(manifestPath, old, true)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// layout returns the links by path relative to the view directory. Links of a group
+// with the same name get " (2)", " (3)"... in the order of their targets.
+func layout(links []Link) map[string]Link {
+	sorted := append([]Link{}, links...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Group != sorted[j].Group {
+			return sorted[i].Group < sorted[j].Group
+		}
+		return sorted[i].Target < sorted[j].Target
+	})
+
+	wanted := map[string]Link{}
+	seen := map[Link]bool{}
+	for _, l := range sorted {
+		if seen[l] {
+			continue
+		}
+		seen[l] = true
+		name := path.Base(filepath.ToSlash(l.Target))
+		if l.Shortcut {
+			name += ShortcutExt
+		}
+		group := GroupDirName(l.Group)
+		rel := group + "/" + name
+		ext := path.Ext(name)
+		stem := strings.TrimSuffix(name, ext)
+		for n := 2; ; n++ {
+			if _, taken := wanted[rel]; !taken {
+				break
+			}
+			rel = fmt.Sprintf("%s/%s (%d)%s", group, stem, n, ext)
+		}
+		wanted[rel] = l
+	}
+	return wanted
+}
+
+// GroupDirName returns the name of the subdirectory of a group. Path separators and
+// names special to file systems are replaced.
+func GroupDirName(group string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(group)
+	if name == "" || name == "." || name == ".." {
+		name = "_" + name
+	}
+	return name
+}
+
+// checkEmpty fails unless dir is missing or empty.
+func checkEmpty(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s is not empty and holds no %s; use a new or empty directory", dir, ManifestFileName)
+	}
+	return nil
+}
+
+// createLink creates l at linkPath: a symlink to its target, or a shortcut file opening
+// the URL of its target.
+func createLink(linkPath string, l Link, url func(string) (string, error)) error {
+	err := os.MkdirAll(filepath.Dir(linkPath), 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(linkPath); err == nil {
+		return fmt.Errorf("%s exists and was not made by the view", linkPath)
+	}
+	if !l.Shortcut {
+		return os.Symlink(l.Target, linkPath)
+	}
+	u, err := url(l.Target)
+	if err != nil {
+		return fmt.Errorf("link of %s: %w", l.Target, err)
+	}
+	return os.WriteFile(linkPath, []byte("[InternetShortcut]\r\nURL="+u+"\r\n"), 0644)
+}
+
+// removeLink removes a link the view made, unless something else took its place.
+func removeLink(linkPath string) error {
+	fi, err := os.Lstat(linkPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink == 0 && !(fi.Mode().IsRegular() && strings.HasSuffix(linkPath, ShortcutExt)) {
+		return nil
+	}
+	return os.Remove(linkPath)
+}
+
+// groupsOf returns the subdirectories holding the links of m, keyed by paths
+// relative to the view directory.
+func groupsOf[V any](m map[string]V) map[string]bool {
+	groups := map[string]bool{}
+	for rel := range m {
+		groups[strings.SplitN(rel, "/", 2)[0]] = true
+	}
+	return groups
+}
+
+// removeEmptyDir removes dir if it exists and is empty.
+func removeEmptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) || len(entries) > 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(dir)
+}
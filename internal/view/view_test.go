@@ -0,0 +1,65 @@
+package view
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaterialize(t *testing.T) {
+	src := t.TempDir()
+	for _, p := range []string{"a/report.pdf", "b/report.pdf", "notes.txt"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(src, p)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(src, p), nil, 0644))
+	}
+	dir := filepath.Join(t.TempDir(), "view")
+	noURL := func(string) (string, error) { return "", errors.New("unexpected") }
+
+	links := []Link{
+		{Group: "finance", Target: filepath.Join(src, "a/report.pdf")},
+		{Group: "finance", Target: filepath.Join(src, "b/report.pdf")},
+		{Group: "finance", Target: filepath.Join(src, "b/report.pdf")},
+		{Group: "a/b", Target: filepath.Join(src, "notes.txt")},
+		{Group: "web", Target: "gdrive:/Plan", Shortcut: true},
+	}
+	url := func(target string) (string, error) { return "https://drive/" + target, nil }
+	res, err := Materialize(dir, links, url)
+	require.NoError(t, err)
+	require.Equal(t, &Result{Added: 4}, res)
+
+	target, err := os.Readlink(filepath.Join(dir, "finance", "report (2).pdf"))
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(src, "b/report.pdf"), target)
+	_, err = os.Readlink(filepath.Join(dir, "a_b", "notes.txt"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dir, "web", "Plan.url"))
+	require.NoError(t, err)
+	require.Equal(t, "[InternetShortcut]\r\nURL=https://drive/gdrive:/Plan\r\n", string(content))
+
+	// Files of the user are kept, stale links and emptied groups pruned.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "finance", "mine.txt"), nil, 0644))
+	res, err = Materialize(dir, links[:1], noURL)
+	require.NoError(t, err)
+	require.Equal(t, &Result{Removed: 3, Kept: 1}, res)
+	require.FileExists(t, filepath.Join(dir, "finance", "mine.txt"))
+	require.NoDirExists(t, filepath.Join(dir, "web"))
+	require.NoDirExists(t, filepath.Join(dir, "a_b"))
+
+	// Deleted links are made again.
+	require.NoError(t, os.Remove(filepath.Join(dir, "finance", "report.pdf")))
+	res, err = Materialize(dir, links[:1], noURL)
+	require.NoError(t, err)
+	require.Equal(t, &Result{Added: 1}, res)
+
+	_, err = Materialize(src, links, noURL)
+	require.ErrorContains(t, err, "not empty")
+}
+
+func TestGroupDirName(t *testing.T) {
+	require.Equal(t, "work_2025", GroupDirName("work/2025"))
+	require.Equal(t, "_..", GroupDirName(".."))
+	require.Equal(t, "_", GroupDirName(""))
+}
@@ -0,0 +1,97 @@
+// Package xattr keeps tags in the user.xdg.tags extended attribute that Linux desktop
+// file managers read, and resolves conflicts between those and the tags of the tree.
+package xattr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TagsName is the extended attribute holding the tags of a file, separated by commas.
+const TagsName = "user.xdg.tags"
+
+// ErrUnsupported is returned for files whose file system, or platform, has no user
+// extended attributes.
+var ErrUnsupported = errors.New("extended attributes are not supported")
+
+// Policy is how syncing tags into a file or node that already has different tags
+// resolves the conflict.
+type Policy string
+
+const (
+	// PolicyMerge keeps the tags of both sides.
+	PolicyMerge Policy = "merge"
+	// PolicyOverwrite replaces the tags with those synced in.
+	PolicyOverwrite Policy = "overwrite"
+	// PolicySkip leaves the tags alone.
+	PolicySkip Policy = "skip"
+)
+
+// ParsePolicy returns the policy named s, PolicyMerge when s is empty.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case "":
+		return PolicyMerge, nil
+	case PolicyMerge, PolicyOverwrite, PolicySkip:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid policy %q, expected merge, overwrite or skip", s)
+}
+
+// ParseTags splits the value of TagsName into tags.
+func ParseTags(value string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// FormatTags returns the value of TagsName holding tags.
+func FormatTags(tags []string) string {
+	return strings.Join(tags, ",")
+}
+
+// Resolve returns the tags dst gets when src is synced into it. conflict reports
+// that dst had tags of its own that differ from src, which policy p settled.
+func Resolve(src, dst []string, p Policy) (result []string, conflict bool) {
+	if SameTags(src, dst) {
+		return dst, false
+	}
+	if len(dst) == 0 {
+		return src, false
+	}
+	switch p {
+	case PolicyOverwrite:
+		return src, true
+	case PolicySkip:
+		return dst, true
+	}
+	result = append([]string{}, dst...)
+	for _, tag := range src {
+		if !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result, true
+}
+
+// SameTags reports whether a and b hold the same tags, in any order.
+func SameTags(a, b []string) bool {
+	for _, tag := range a {
+		if !slices.Contains(b, tag) {
+			return false
+		}
+	}
+	for _, tag := range b {
+		if !slices.Contains(a, tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package xattr
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetTags returns the tags in the TagsName attribute of the file at path. ok is false
+// when the file has no such attribute.
+func GetTags(path string) (tags []string, ok bool, err error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := unix.Getxattr(path, TagsName, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// The value grew since it was sized, or is larger than buf.
+			size, err := unix.Getxattr(path, TagsName, nil)
+			if err != nil {
+				return nil, false, convertErr(err)
+			}
+			buf = make([]byte, size+1)
+			continue
+		}
+		if errors.Is(err, unix.ENODATA) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, convertErr(err)
+		}
+		return ParseTags(string(buf[:n])), true, nil
+	}
+}
+
+// SetTags writes tags to the TagsName attribute of the file at path, removing the
+// attribute when tags is empty.
+func SetTags(path string, tags []string) error {
+	if len(tags) == 0 {
+		err := unix.Removexattr(path, TagsName)
+		if errors.Is(err, unix.ENODATA) {
+			return nil
+		}
+		return convertErr(err)
+	}
+	return convertErr(unix.Setxattr(path, TagsName, []byte(FormatTags(tags)), 0))
+}
+
+// convertErr turns the errors of file systems without user attributes into
+// ErrUnsupported.
+func convertErr(err error) error {
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		return ErrUnsupported
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package xattr
+
+// GetTags always fails with ErrUnsupported outside of Linux.
+func GetTags(path string) (tags []string, ok bool, err error) {
+	return nil, false, ErrUnsupported
+}
+
+// SetTags always fails with ErrUnsupported outside of Linux.
+func SetTags(path string, tags []string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,65 @@
+package xattr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	tags, conflict := Resolve([]string{"a"}, nil, PolicySkip)
+	require.Equal(t, []string{"a"}, tags)
+	require.False(t, conflict)
+
+	tags, conflict = Resolve([]string{"b", "a"}, []string{"a", "b"}, PolicyOverwrite)
+	require.Equal(t, []string{"a", "b"}, tags)
+	require.False(t, conflict)
+
+	tags, conflict = Resolve([]string{"a", "c"}, []string{"b", "a"}, PolicyMerge)
+	require.Equal(t, []string{"b", "a", "c"}, tags)
+	require.True(t, conflict)
+
+	tags, conflict = Resolve([]string{"a"}, []string{"b"}, PolicyOverwrite)
+	require.Equal(t, []string{"a"}, tags)
+	require.True(t, conflict)
+
+	tags, conflict = Resolve([]string{"a"}, []string{"b"}, PolicySkip)
+	require.Equal(t, []string{"b"}, tags)
+	require.True(t, conflict)
+}
+
+func TestParse(t *testing.T) {
+	require.Equal(t, []string{"work", "big files"}, ParseTags(" work,,big files,work"))
+	require.Empty(t, ParseTags(""))
+	require.Equal(t, "a,b", FormatTags([]string{"a", "b"}))
+
+	p, err := ParsePolicy("")
+	require.NoError(t, err)
+	require.Equal(t, PolicyMerge, p)
+	_, err = ParsePolicy("newest")
+	require.ErrorContains(t, err, "invalid policy")
+}
+
+func TestGetSetTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.txt")
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+
+	err := SetTags(path, []string{"a", "b"})
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip("no extended attributes here")
+	}
+	require.NoError(t, err)
+	tags, ok, err := GetTags(path)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"a", "b"}, tags)
+
+	require.NoError(t, SetTags(path, nil))
+	_, ok, err = GetTags(path)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.NoError(t, SetTags(path, nil))
+}